@@ -1,56 +1,353 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+	// Store hours are kept in IANA zones; the hosts may lack a zone database.
+	_ "time/tzdata"
 
+	"github.com/XSAM/otelsql"
 	"github.com/go-playground/validator/v10"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
-	_ "github.com/joho/godotenv/autoload"
+	"github.com/redis/go-redis/v9"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 
 	"github.com/Risuii/config"
 	"github.com/Risuii/config/bcrypt"
+	"github.com/Risuii/config/jwt"
+	"github.com/Risuii/helpers/blob"
+	"github.com/Risuii/helpers/cache"
 	"github.com/Risuii/helpers/constant"
+	"github.com/Risuii/helpers/etag"
+	"github.com/Risuii/helpers/health"
+	"github.com/Risuii/helpers/i18n"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/mailer"
+	"github.com/Risuii/helpers/media"
+	"github.com/Risuii/helpers/metrics"
+	"github.com/Risuii/helpers/middleware"
+	"github.com/Risuii/helpers/oidc"
+	"github.com/Risuii/helpers/patch"
+	"github.com/Risuii/helpers/ratelimit"
+	"github.com/Risuii/helpers/requestid"
+	"github.com/Risuii/helpers/retry"
+	"github.com/Risuii/helpers/stmtcache"
+	"github.com/Risuii/helpers/tracing"
 	"github.com/Risuii/internal/account"
+	"github.com/Risuii/internal/address"
+	"github.com/Risuii/internal/admin"
 	"github.com/Risuii/internal/item"
 	"github.com/Risuii/internal/store"
 )
 
 func main() {
-	cfg := config.New()
+	cfg, opts, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if opts.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var level slog.Level
+	level.UnmarshalText([]byte(cfg.Log.Level))
+
+	log := logger.New(os.Stdout, level)
+	slog.SetDefault(log)
+
+	jwt.JWT_KEY = []byte(cfg.JWT.Secret)
+	jwt.TokenTTL = cfg.JWT.TokenTTL
+
+	if err := run(cfg, log); err != nil {
+		log.Error("server stopped", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run(cfg *config.Config, log *slog.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.New(ctx, cfg.Tracing.Exporter, cfg.Tracing.ServiceName)
+	if err != nil {
+		return fmt.Errorf("set up tracing: %w", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	db, err := otelsql.Open("mysql", cfg.DSN(), otelsql.WithAttributes(semconv.DBSystemMySQL))
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+
+	db.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
+
+	retry.Default.Attempts = cfg.Database.RetryAttempts
+
+	// The database often comes up after the app in a fresh deployment, so
+	// keep pinging it until connect_timeout instead of failing right away.
+	connectCtx, cancelConnect := context.WithTimeout(ctx, cfg.Database.ConnectTimeout)
+	defer cancelConnect()
 
-	db, err := sql.Open("mysql", cfg.Database.DSN)
+	attempt := 0
+	err = retry.Policy{Initial: 500 * time.Millisecond, Max: 10 * time.Second}.Do(connectCtx, func(ctx context.Context) error {
+		attempt++
+		err := db.PingContext(ctx)
+		if err != nil {
+			log.Warn("database not reachable yet", "attempt", attempt, "error", err)
+		}
+		return err
+	})
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("connect database: %w", err)
 	}
 
 	validator := validator.New()
+	validator.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	patch.RegisterValidator(validator)
+	if err := i18n.RegisterValidator(validator); err != nil {
+		return fmt.Errorf("register validator translations: %w", err)
+	}
+
 	router := mux.NewRouter()
 	bcrypt := bcrypt.NewBcrypt(cfg.Bcrypt.HashCost)
 
-	userRepo := account.NewAccountRepositoryImpl(db, constant.TableAccount)
+	checker := health.New(5 * time.Second)
+	checker.Add("database", db.PingContext)
+	router.HandleFunc("/healthz", checker.Liveness).Methods(http.MethodGet)
+	router.HandleFunc("/readyz", checker.Readiness).Methods(http.MethodGet)
+
+	metrics.RegisterDB(db, "mysql")
+	router.Use(tracing.RouteMiddleware, metrics.Middleware)
+	router.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
+
+	var redisClient *redis.Client
+	if cfg.Cache.Driver == "redis" || cfg.RateLimit.Store == "redis" {
+		redisClient = redis.NewClient(&redis.Options{
+			Addr:     cfg.Cache.RedisAddr,
+			Password: cfg.Cache.RedisPassword,
+			DB:       cfg.Cache.RedisDB,
+		})
+		defer redisClient.Close()
+
+		checker.Add("redis", func(ctx context.Context) error {
+			return redisClient.Ping(ctx).Err()
+		})
+	}
+
+	var catalogCache cache.Cache
+	switch cfg.Cache.Driver {
+	case "memory":
+		catalogCache = cache.NewLRU(cfg.Cache.Size)
+	case "redis":
+		catalogCache = cache.NewRedis(redisClient, cfg.Tracing.ServiceName+":")
+	}
+
+	limitStore := ratelimit.NewMemoryStore()
+	if cfg.RateLimit.Store == "redis" {
+		limitStore = ratelimit.NewRedisStore(redisClient, cfg.Tracing.ServiceName+":")
+	}
+	// The limits were checked by config.Validate.
+	limit := func(s string) ratelimit.Limit {
+		l, _ := ratelimit.ParseLimit(s)
+		return l
+	}
+	router.Use(ratelimit.Middleware(map[string][]ratelimit.Rule{
+		"/login": {
+			{Limiter: ratelimit.NewLimiter(limitStore, "login_ip", limit(cfg.RateLimit.LoginIP)), Key: ratelimit.ByIP},
+			{Limiter: ratelimit.NewLimiter(limitStore, "login_account", limit(cfg.RateLimit.LoginAccount)), Key: ratelimit.ByJSONField("email")},
+		},
+		"/register": {
+			{Limiter: ratelimit.NewLimiter(limitStore, "register_ip", limit(cfg.RateLimit.RegisterIP)), Key: ratelimit.ByIP},
+		},
+		"/forgot-password": {
+			{Limiter: ratelimit.NewLimiter(limitStore, "forgot_password_ip", limit(cfg.RateLimit.ForgotPasswordIP)), Key: ratelimit.ByIP},
+			{Limiter: ratelimit.NewLimiter(limitStore, "forgot_password", limit(cfg.RateLimit.ForgotPassword)), Key: ratelimit.ByJSONField("email")},
+		},
+		"/reset-password": {
+			{Limiter: ratelimit.NewLimiter(limitStore, "reset_password_ip", limit(cfg.RateLimit.ResetPasswordIP)), Key: ratelimit.ByIP},
+		},
+		"/login/mfa": {
+			{Limiter: ratelimit.NewLimiter(limitStore, "mfa_ip", limit(cfg.RateLimit.MFAIP)), Key: ratelimit.ByIP},
+		},
+		// Probes and scrapes come from the platform, often through one
+		// address, and must not be turned away.
+		"/healthz": nil,
+		"/readyz":  nil,
+		"/metrics": nil,
+		"/auth/{provider}/callback": {
+			{Limiter: ratelimit.NewLimiter(limitStore, "sso_callback_ip", limit(cfg.RateLimit.SSOCallbackIP)), Key: ratelimit.ByIP},
+		},
+		ratelimit.DefaultRoute: {
+			{Limiter: ratelimit.NewLimiter(limitStore, "default_ip", limit(cfg.RateLimit.DefaultIP)), Key: ratelimit.ByIP},
+			{Limiter: ratelimit.NewLimiter(limitStore, "default_user", limit(cfg.RateLimit.DefaultUser)), Key: ratelimit.ByUser},
+		},
+	}))
+	lockout := ratelimit.NewLockout(limitStore, cfg.RateLimit.LockoutFailures, cfg.RateLimit.LockoutDuration)
+
+	var mail mailer.Mailer
+	switch cfg.Mail.Driver {
+	case "smtp":
+		mail = mailer.NewSMTP(cfg.Mail.SMTPHost, cfg.Mail.SMTPPort, cfg.Mail.SMTPUsername, cfg.Mail.SMTPPassword, cfg.Mail.From, cfg.Mail.SMTPTimeout)
+	case "file":
+		mail = mailer.NewFile(cfg.Mail.Dir, cfg.Mail.From)
+	default:
+		mail = mailer.NewLog()
+	}
+	verifier := account.NewEmailVerifier([]byte(cfg.JWT.Secret), mail, cfg.App.PublicURL, cfg.Account.VerificationTTL)
+	emailChanges := account.NewEmailChanges([]byte(cfg.JWT.Secret), mail, cfg.App.PublicURL, cfg.Account.VerificationTTL)
+
+	userRepo := account.NewAccountRepositoryMetrics(account.NewAccountRepositoryImpl(db, constant.TableAccount))
+	resets := account.NewPasswordResets(account.NewPasswordResetRepositoryImpl(db, constant.TablePasswordResets), mail, cfg.App.PublicURL, cfg.Account.PasswordResetTTL)
+	mfa := account.NewMFA([]byte(cfg.JWT.Secret), account.NewRecoveryCodeRepositoryImpl(db, constant.TableRecoveryCodes), cfg.Account.MFAIssuer, cfg.Account.MFAPendingTTL)
+	router.Use(account.NewSessionGuard(userRepo))
+
+	var ssoProviders []*oidc.Provider
+	if cfg.OIDC.Issuer != "" {
+		ssoProviders = append(ssoProviders, oidc.New(oidc.Config{
+			Name:         cfg.OIDC.Name,
+			Issuer:       cfg.OIDC.Issuer,
+			ClientID:     cfg.OIDC.ClientID,
+			ClientSecret: cfg.OIDC.ClientSecret,
+			RedirectURL:  strings.TrimSuffix(cfg.App.PublicURL, "/") + "/auth/" + cfg.OIDC.Name + "/callback",
+			Scopes:       strings.Fields(cfg.OIDC.Scopes),
+		}, nil))
+	}
+
+	var blobs blob.BlobStore
+	switch cfg.Media.Driver {
+	case "s3":
+		blobs, err = blob.NewS3(blob.S3Config{
+			Endpoint:  cfg.Media.S3Endpoint,
+			Region:    cfg.Media.S3Region,
+			Bucket:    cfg.Media.S3Bucket,
+			AccessKey: cfg.Media.S3AccessKey,
+			SecretKey: cfg.Media.S3SecretKey,
+			PathStyle: cfg.Media.S3PathStyle,
+			PublicURL: cfg.Media.PublicURL,
+		}, &http.Client{Timeout: time.Minute})
+		if err != nil {
+			return err
+		}
+	default:
+		publicURL := cfg.Media.PublicURL
+		if publicURL == "" {
+			publicURL = strings.TrimSuffix(cfg.App.PublicURL, "/") + "/media"
+		}
+		blobs = blob.NewLocal(cfg.Media.Dir, publicURL)
+		router.PathPrefix("/media/").Handler(http.StripPrefix("/media/", blob.Handler(blobs))).Methods(http.MethodGet)
+	}
+	uploads := media.Limits{
+		MaxSize:       int64(cfg.Media.MaxUploadSize),
+		MaxPixels:     cfg.Media.MaxPixels,
+		ThumbnailSize: cfg.Media.ThumbnailSize,
+	}
+	storage := media.NewStorage(blobs, uploads)
+
+	apiKeys := store.NewAPIKeyRepositoryImpl(db, constant.TableAPIKeys)
+	router.Use(store.NewAPIKeyAuth(apiKeys))
+
 	storeRepo := store.NewStoreRepository(db, constant.TableStores)
-	itemRepo := item.NewItemRepositoryImpl(db, constant.TableItems)
-	userUseCase := account.NewAccountUseCaseImpl(userRepo, bcrypt)
-	storeUseCase := store.NewStoreUseCaseImpl(storeRepo)
-	itemUseCase := item.NewItemUseCaseImpl(itemRepo)
+	itemRepo := item.NewItemRepositoryMetrics(item.NewItemRepositoryImpl(db, constant.TableItems))
+	if catalogCache != nil {
+		storeRepo = store.NewStoreRepositoryCache(storeRepo, catalogCache, cfg.Cache.StoreTTL)
+		itemRepo = item.NewItemRepositoryCache(itemRepo, catalogCache, cfg.Cache.ItemTTL)
+	}
+	userUseCase := account.NewAccountUseCaseTracing(account.NewAccountUseCaseMetrics(account.NewAccountUseCaseImpl(userRepo, account.NewIdentityRepositoryMetrics(account.NewIdentityRepositoryImpl(db, constant.TableIdentities, constant.TableAccount)), bcrypt, lockout, verifier, resets, emailChanges, mfa)))
+	members := store.NewMemberRepositoryImpl(db, constant.TableStoreMembers, constant.TableAccount)
+	invitations := store.NewInvitations(store.NewInvitationRepositoryImpl(db, constant.TableInvitations, constant.TableStoreMembers), mail, cfg.App.PublicURL, cfg.Store.InvitationTTL)
+	transfers := store.NewTransfers(store.NewTransferRepositoryImpl(db, constant.TableTransfers, constant.TableStores, constant.TableStoreMembers, constant.TableStoreAudit), mail, cfg.App.PublicURL, cfg.Store.TransferTTL)
+	lifecycle := store.NewLifecycle(store.NewStatusRepositoryImpl(db, constant.TableStores, constant.TableStoreAudit), mail)
+	storeUseCase := store.NewStoreUseCaseTracing(store.NewStoreUseCaseImpl(storeRepo, userRepo, apiKeys, members, invitations, transfers, store.NewAuditRepositoryImpl(db, constant.TableStoreAudit), lifecycle, storage))
+	itemUseCase := item.NewItemUseCaseTracing(item.NewItemUseCaseImpl(itemRepo, item.NewImageRepositoryImpl(db, constant.TableItemImages), storage))
+	addressUseCase := address.NewAddressUseCaseTracing(address.NewAddressUseCaseImpl(address.NewAddressRepositoryImpl(db, constant.TableAddresses)))
 
 	account.NewAbsensiHandler(router, validator, userUseCase)
-	store.NewStoreHandler(router, validator, storeUseCase)
-	item.NewItemHandler(router, validator, itemUseCase)
+	account.NewSSOHandler(router, userUseCase, ssoProviders, []byte(cfg.JWT.Secret), cfg.OIDC.StateTTL)
+	store.NewStoreHandler(router, validator, storeUseCase, uploads)
+	item.NewItemHandler(router, validator, itemUseCase, store.NewAccess(storeRepo, members), uploads)
+	address.NewAddressHandler(router, validator, addressUseCase)
+	admin.NewAdminHandler(router, cfg.Admin.Token, validator, admin.NewAdminUseCaseImpl(db), storeUseCase)
+
+	handler := middleware.Chain(router,
+		requestid.Middleware,
+		tracing.Middleware,
+		i18n.Middleware,
+		middleware.AccessLog(log),
+		middleware.Recover,
+		etag.Middleware,
+	)
 
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%s", cfg.App.Port),
-		Handler: router,
+		Addr:         fmt.Sprintf(":%s", cfg.App.Port),
+		Handler:      handler,
+		ReadTimeout:  cfg.App.ReadTimeout,
+		WriteTimeout: cfg.App.WriteTimeout,
+		IdleTimeout:  cfg.App.IdleTimeout,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Info("server on", "addr", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Info("shutting down, draining in-flight requests")
+	checker.Drain()
+
+	// Load balancers only stop routing here once their next readiness
+	// probe fails, so requests keep coming for a while.
+	time.Sleep(cfg.App.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.App.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+
+	// Reset mails still being sent need the database until they are done.
+	resets.Wait()
+
+	if err := stmtcache.Release(db); err != nil {
+		log.Warn("closing prepared statements", "error", err)
 	}
 
-	port := os.Getenv("PORT")
+	log.Info("server stopped")
 
-	fmt.Println("SERVER ON")
-	fmt.Println("PORT :", port)
-	log.Fatal(server.ListenAndServe())
+	return nil
 }
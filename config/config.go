@@ -1,78 +1,235 @@
 package config
 
 import (
-	"fmt"
-	"log"
-	"net/url"
-	"os"
-	"strconv"
+	"time"
 
-	"github.com/joho/godotenv"
+	"github.com/go-sql-driver/mysql"
 )
 
+// Config is filled in layers: defaults, then the optional YAML/TOML file,
+// then environment variables, then command line flags. Every field is named
+// after its path in the file, e.g. database.host, which is also its flag
+// name. Fields tagged secret can be read from the file named by <ENV>_FILE
+// and are redacted by --print-config.
 type Config struct {
 	App struct {
-		Port string
-	}
+		Port            string        `yaml:"port" toml:"port" env:"PORT"`
+		ReadTimeout     time.Duration `yaml:"read_timeout" toml:"read_timeout" env:"HTTP_READ_TIMEOUT"`
+		WriteTimeout    time.Duration `yaml:"write_timeout" toml:"write_timeout" env:"HTTP_WRITE_TIMEOUT"`
+		IdleTimeout     time.Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT"`
+		ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT"`
+		// DrainDelay is how long /readyz fails before the server stops
+		// accepting connections on shutdown.
+		DrainDelay time.Duration `yaml:"drain_delay" toml:"drain_delay" env:"HTTP_DRAIN_DELAY"`
+		// PublicURL is where users reach the API, used for links in emails.
+		PublicURL string `yaml:"public_url" toml:"public_url" env:"PUBLIC_URL"`
+	} `yaml:"app" toml:"app"`
+	Log struct {
+		Level string `yaml:"level" toml:"level" env:"LOG_LEVEL"`
+	} `yaml:"log" toml:"log"`
 	Database struct {
-		DSN string
-	}
+		Host            string        `yaml:"host" toml:"host" env:"DB_HOST"`
+		Port            string        `yaml:"port" toml:"port" env:"DB_PORT"`
+		Username        string        `yaml:"username" toml:"username" env:"DB_USERNAME"`
+		Password        string        `yaml:"password" toml:"password" env:"DB_PASSWORD" secret:"true"`
+		Name            string        `yaml:"name" toml:"name" env:"DB_DATABASE_NAME"`
+		Location        string        `yaml:"location" toml:"location" env:"DB_LOCATION"`
+		MaxOpenConns    int           `yaml:"max_open_conns" toml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+		MaxIdleConns    int           `yaml:"max_idle_conns" toml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+		ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+		ConnectTimeout  time.Duration `yaml:"connect_timeout" toml:"connect_timeout" env:"DB_CONNECT_TIMEOUT"`
+		RetryAttempts   int           `yaml:"retry_attempts" toml:"retry_attempts" env:"DB_RETRY_ATTEMPTS"`
+	} `yaml:"database" toml:"database"`
 	Bcrypt struct {
-		HashCost int
-	}
+		HashCost int `yaml:"hash_cost" toml:"hash_cost" env:"BCRYPT_HASH_COST"`
+	} `yaml:"bcrypt" toml:"bcrypt"`
+	JWT struct {
+		Secret   string        `yaml:"secret" toml:"secret" env:"JWT_SECRET" secret:"true"`
+		TokenTTL time.Duration `yaml:"token_ttl" toml:"token_ttl" env:"JWT_TOKEN_TTL"`
+	} `yaml:"jwt" toml:"jwt"`
+	Cache struct {
+		// memory, redis or none
+		Driver        string        `yaml:"driver" toml:"driver" env:"CACHE_DRIVER"`
+		Size          int           `yaml:"size" toml:"size" env:"CACHE_SIZE"`
+		ItemTTL       time.Duration `yaml:"item_ttl" toml:"item_ttl" env:"CACHE_ITEM_TTL"`
+		StoreTTL      time.Duration `yaml:"store_ttl" toml:"store_ttl" env:"CACHE_STORE_TTL"`
+		RedisAddr     string        `yaml:"redis_addr" toml:"redis_addr" env:"REDIS_ADDR"`
+		RedisPassword string        `yaml:"redis_password" toml:"redis_password" env:"REDIS_PASSWORD" secret:"true"`
+		RedisDB       int           `yaml:"redis_db" toml:"redis_db" env:"REDIS_DB"`
+	} `yaml:"cache" toml:"cache"`
+	Account struct {
+		VerificationTTL  time.Duration `yaml:"verification_ttl" toml:"verification_ttl" env:"ACCOUNT_VERIFICATION_TTL"`
+		PasswordResetTTL time.Duration `yaml:"password_reset_ttl" toml:"password_reset_ttl" env:"ACCOUNT_PASSWORD_RESET_TTL"`
+		// MFAIssuer names the service in authenticator apps.
+		MFAIssuer     string        `yaml:"mfa_issuer" toml:"mfa_issuer" env:"ACCOUNT_MFA_ISSUER"`
+		MFAPendingTTL time.Duration `yaml:"mfa_pending_ttl" toml:"mfa_pending_ttl" env:"ACCOUNT_MFA_PENDING_TTL"`
+	} `yaml:"account" toml:"account"`
+	Store struct {
+		// InvitationTTL is how long an invitation to join a store stays valid.
+		InvitationTTL time.Duration `yaml:"invitation_ttl" toml:"invitation_ttl" env:"STORE_INVITATION_TTL"`
+		// TransferTTL is how long the recipient of a store has to accept it.
+		TransferTTL time.Duration `yaml:"transfer_ttl" toml:"transfer_ttl" env:"STORE_TRANSFER_TTL"`
+	} `yaml:"store" toml:"store"`
+	Mail struct {
+		// log, file or smtp
+		Driver       string `yaml:"driver" toml:"driver" env:"MAIL_DRIVER"`
+		From         string `yaml:"from" toml:"from" env:"MAIL_FROM"`
+		Dir          string `yaml:"dir" toml:"dir" env:"MAIL_DIR"`
+		SMTPHost     string `yaml:"smtp_host" toml:"smtp_host" env:"SMTP_HOST"`
+		SMTPPort     string `yaml:"smtp_port" toml:"smtp_port" env:"SMTP_PORT"`
+		SMTPUsername string `yaml:"smtp_username" toml:"smtp_username" env:"SMTP_USERNAME"`
+		SMTPPassword string `yaml:"smtp_password" toml:"smtp_password" env:"SMTP_PASSWORD" secret:"true"`
+		// SMTPTimeout bounds the sending of one message, connection included.
+		SMTPTimeout time.Duration `yaml:"smtp_timeout" toml:"smtp_timeout" env:"SMTP_TIMEOUT"`
+	} `yaml:"mail" toml:"mail"`
+	Media struct {
+		// local or s3; local keeps the files in dir and serves them under
+		// /media/, s3 works with any S3 compatible service
+		Driver string `yaml:"driver" toml:"driver" env:"MEDIA_DRIVER"`
+		Dir    string `yaml:"dir" toml:"dir" env:"MEDIA_DIR"`
+		// PublicURL is where clients download the files; it defaults to
+		// <app.public_url>/media for local and to the bucket for s3
+		PublicURL     string `yaml:"public_url" toml:"public_url" env:"MEDIA_PUBLIC_URL"`
+		MaxUploadSize int    `yaml:"max_upload_size" toml:"max_upload_size" env:"MEDIA_MAX_UPLOAD_SIZE"`
+		MaxPixels     int    `yaml:"max_pixels" toml:"max_pixels" env:"MEDIA_MAX_PIXELS"`
+		ThumbnailSize int    `yaml:"thumbnail_size" toml:"thumbnail_size" env:"MEDIA_THUMBNAIL_SIZE"`
+		S3Endpoint    string `yaml:"s3_endpoint" toml:"s3_endpoint" env:"S3_ENDPOINT"`
+		S3Region      string `yaml:"s3_region" toml:"s3_region" env:"S3_REGION"`
+		S3Bucket      string `yaml:"s3_bucket" toml:"s3_bucket" env:"S3_BUCKET"`
+		S3AccessKey   string `yaml:"s3_access_key" toml:"s3_access_key" env:"S3_ACCESS_KEY"`
+		S3SecretKey   string `yaml:"s3_secret_key" toml:"s3_secret_key" env:"S3_SECRET_KEY" secret:"true"`
+		S3PathStyle   bool   `yaml:"s3_path_style" toml:"s3_path_style" env:"S3_PATH_STYLE"`
+	} `yaml:"media" toml:"media"`
+	RateLimit struct {
+		// memory or redis; redis shares the limits between instances and
+		// uses the connection settings of the cache section
+		Store string `yaml:"store" toml:"store" env:"RATE_LIMIT_STORE"`
+		// limits are written as <requests>/<duration>, e.g. 5/1m
+		LoginIP          string        `yaml:"login_ip" toml:"login_ip" env:"RATE_LIMIT_LOGIN_IP"`
+		LoginAccount     string        `yaml:"login_account" toml:"login_account" env:"RATE_LIMIT_LOGIN_ACCOUNT"`
+		RegisterIP       string        `yaml:"register_ip" toml:"register_ip" env:"RATE_LIMIT_REGISTER_IP"`
+		ForgotPassword   string        `yaml:"forgot_password" toml:"forgot_password" env:"RATE_LIMIT_FORGOT_PASSWORD"`
+		ForgotPasswordIP string        `yaml:"forgot_password_ip" toml:"forgot_password_ip" env:"RATE_LIMIT_FORGOT_PASSWORD_IP"`
+		ResetPasswordIP  string        `yaml:"reset_password_ip" toml:"reset_password_ip" env:"RATE_LIMIT_RESET_PASSWORD_IP"`
+		MFAIP            string        `yaml:"mfa_ip" toml:"mfa_ip" env:"RATE_LIMIT_MFA_IP"`
+		SSOCallbackIP    string        `yaml:"sso_callback_ip" toml:"sso_callback_ip" env:"RATE_LIMIT_SSO_CALLBACK_IP"`
+		DefaultIP        string        `yaml:"default_ip" toml:"default_ip" env:"RATE_LIMIT_DEFAULT_IP"`
+		DefaultUser      string        `yaml:"default_user" toml:"default_user" env:"RATE_LIMIT_DEFAULT_USER"`
+		LockoutFailures  int           `yaml:"lockout_failures" toml:"lockout_failures" env:"LOCKOUT_FAILURES"`
+		LockoutDuration  time.Duration `yaml:"lockout_duration" toml:"lockout_duration" env:"LOCKOUT_DURATION"`
+	} `yaml:"rate_limit" toml:"rate_limit"`
+	OIDC struct {
+		// login through an OpenID Connect provider is off while no issuer is
+		// set; name is the provider segment of /auth/{name}/login
+		Name         string        `yaml:"name" toml:"name" env:"OIDC_PROVIDER_NAME"`
+		Issuer       string        `yaml:"issuer" toml:"issuer" env:"OIDC_ISSUER"`
+		ClientID     string        `yaml:"client_id" toml:"client_id" env:"OIDC_CLIENT_ID"`
+		ClientSecret string        `yaml:"client_secret" toml:"client_secret" env:"OIDC_CLIENT_SECRET" secret:"true"`
+		Scopes       string        `yaml:"scopes" toml:"scopes" env:"OIDC_SCOPES"`
+		StateTTL     time.Duration `yaml:"state_ttl" toml:"state_ttl" env:"OIDC_STATE_TTL"`
+	} `yaml:"oidc" toml:"oidc"`
+	Admin struct {
+		// the admin API is disabled while no token is set
+		Token string `yaml:"token" toml:"token" env:"ADMIN_TOKEN" secret:"true"`
+	} `yaml:"admin" toml:"admin"`
+	Tracing struct {
+		// otlp, stdout or none
+		Exporter    string `yaml:"exporter" toml:"exporter" env:"TRACING_EXPORTER"`
+		ServiceName string `yaml:"service_name" toml:"service_name" env:"TRACING_SERVICE_NAME"`
+	} `yaml:"tracing" toml:"tracing"`
 }
 
-func New() *Config {
+func defaults() *Config {
 	c := new(Config)
-	c.loadApp()
-	c.loadDatabase()
-	c.loadBcrypt()
-
-	return c
-}
-
-func (c *Config) loadApp() *Config {
-	port := os.Getenv("PORT")
 
-	c.App.Port = port
+	c.App.Port = "8080"
+	c.App.ReadTimeout = 10 * time.Second
+	c.App.WriteTimeout = 15 * time.Second
+	c.App.IdleTimeout = 60 * time.Second
+	c.App.ShutdownTimeout = 20 * time.Second
+	c.App.DrainDelay = 5 * time.Second
+	c.App.PublicURL = "http://localhost:8080"
+
+	c.Log.Level = "info"
+
+	c.Database.Host = "127.0.0.1"
+	c.Database.Port = "3306"
+	c.Database.Location = "Asia/Jakarta"
+	c.Database.MaxOpenConns = 25
+	c.Database.MaxIdleConns = 25
+	c.Database.ConnMaxLifetime = 5 * time.Minute
+	c.Database.ConnectTimeout = 30 * time.Second
+	c.Database.RetryAttempts = 3
+
+	c.Bcrypt.HashCost = 10
+
+	c.JWT.TokenTTL = 24 * time.Hour
+
+	c.Cache.Driver = "memory"
+	c.Cache.Size = 10000
+	c.Cache.ItemTTL = time.Minute
+	c.Cache.StoreTTL = 5 * time.Minute
+	c.Cache.RedisAddr = "127.0.0.1:6379"
+
+	c.Account.VerificationTTL = 48 * time.Hour
+	c.Account.PasswordResetTTL = time.Hour
+	c.Account.MFAIssuer = "Mini Ecommerce"
+	c.Account.MFAPendingTTL = 5 * time.Minute
+
+	c.Store.InvitationTTL = 7 * 24 * time.Hour
+	c.Store.TransferTTL = 72 * time.Hour
+
+	c.Mail.Driver = "log"
+	c.Mail.From = "no-reply@localhost"
+	c.Mail.Dir = "mail"
+	c.Mail.SMTPPort = "587"
+	c.Mail.SMTPTimeout = 10 * time.Second
+
+	c.Media.Driver = "local"
+	c.Media.Dir = "media"
+	c.Media.MaxUploadSize = 5 << 20
+	c.Media.MaxPixels = 40_000_000
+	c.Media.ThumbnailSize = 320
+	c.Media.S3Region = "us-east-1"
+
+	c.RateLimit.Store = "memory"
+	c.RateLimit.LoginIP = "20/1m"
+	c.RateLimit.LoginAccount = "5/1m"
+	c.RateLimit.RegisterIP = "5/1h"
+	c.RateLimit.ForgotPassword = "3/1h"
+	c.RateLimit.ForgotPasswordIP = "10/1h"
+	c.RateLimit.ResetPasswordIP = "10/1h"
+	c.RateLimit.MFAIP = "20/1m"
+	c.RateLimit.SSOCallbackIP = "20/1m"
+	c.RateLimit.DefaultIP = "300/1m"
+	c.RateLimit.DefaultUser = "120/1m"
+	c.RateLimit.LockoutFailures = 5
+	c.RateLimit.LockoutDuration = 15 * time.Minute
+
+	c.OIDC.Name = "oidc"
+	c.OIDC.Scopes = "openid email profile"
+	c.OIDC.StateTTL = 10 * time.Minute
+
+	c.Tracing.Exporter = "none"
+	c.Tracing.ServiceName = "mini-ecommerce"
 
 	return c
 }
 
-func (c *Config) loadDatabase() *Config {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
+// DSN returns the MySQL data source name, with parseTime and the configured
+// time zone.
+func (c *Config) DSN() string {
+	dsn := mysql.NewConfig()
+	dsn.Net = "tcp"
+	dsn.Addr = c.Database.Host + ":" + c.Database.Port
+	dsn.User = c.Database.Username
+	dsn.Passwd = c.Database.Password
+	dsn.DBName = c.Database.Name
+	dsn.ParseTime = true
+
+	if loc, err := time.LoadLocation(c.Database.Location); err == nil {
+		dsn.Loc = loc
 	}
 
-	// get Env Value
-
-	host := os.Getenv("DB_HOST")
-	port := os.Getenv("DB_PORT")
-	username := os.Getenv("DB_USERNAME")
-	password := os.Getenv("DB_PASSWORD")
-	database := os.Getenv("DB_DATABASE_NAME")
-
-	connVal := url.Values{}
-	// connVal.Add("parseTime", "1")
-	connVal.Add("loc", "Asia/Jakarta")
-
-	dbConnection := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true", username, password, host, port, database)
-
-	dsn := fmt.Sprintf("%s?%s", dbConnection, connVal.Encode())
-
-	c.Database.DSN = dsn
-
-	c.Database.DSN = dbConnection
-
-	return c
-}
-
-func (c *Config) loadBcrypt() *Config {
-	// env value
-	hashCost := os.Getenv("BCRYPT_HASH_COST")
-
-	c.Bcrypt.HashCost, _ = strconv.Atoi(hashCost)
-
-	return c
+	return dsn.FormatDSN()
 }
@@ -1,10 +1,16 @@
 package jwt
 
 import (
+	"time"
+
 	"github.com/dgrijalva/jwt-go"
 )
 
-var JWT_KEY = []byte("rahasia")
+// JWT_KEY and TokenTTL are set from the configuration at startup.
+var (
+	JWT_KEY  []byte
+	TokenTTL = 24 * time.Hour
+)
 
 type JWTclaim struct {
 	ID      int64
@@ -12,5 +18,24 @@ type JWTclaim struct {
 	StoreID int64
 	Email   string
 	Name    string
+	// SessionEpoch must match the account's, see account.NewSessionGuard.
+	SessionEpoch int64
 	jwt.StandardClaims
 }
+
+// ParseClaims verifies tokenString and returns the claims it carries.
+func ParseClaims(tokenString string) (*JWTclaim, error) {
+	claims := &JWTclaim{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return JWT_KEY, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, jwt.ErrSignatureInvalid
+	}
+
+	return claims, nil
+}
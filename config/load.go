@@ -0,0 +1,214 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[REDACTED]"
+
+// Options are the flags that drive loading itself rather than the config.
+type Options struct {
+	File        string
+	PrintConfig bool
+}
+
+// Load builds the configuration from defaults, the config file, the
+// environment (including an optional .env file) and args, then validates it.
+func Load(args []string) (*Config, Options, error) {
+	var opts Options
+	c := defaults()
+
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, opts, fmt.Errorf(".env: %w", err)
+	}
+
+	fields := c.fields()
+
+	set := flag.NewFlagSet("app", flag.ContinueOnError)
+	set.StringVar(&opts.File, "config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file")
+	set.BoolVar(&opts.PrintConfig, "print-config", false, "print the resolved config with secrets redacted and exit")
+
+	// Flags are applied last, so they are recorded here and replayed once
+	// the file and the environment have been read.
+	type flagValue struct {
+		field field
+		value string
+	}
+	var flagValues []flagValue
+	for _, f := range fields {
+		f := f
+		set.Func(f.path, fmt.Sprintf("overrides %s (env %s)", f.path, f.env), func(s string) error {
+			flagValues = append(flagValues, flagValue{field: f, value: s})
+			return nil
+		})
+	}
+
+	if err := set.Parse(args); err != nil {
+		return nil, opts, err
+	}
+
+	if opts.File != "" {
+		if err := c.loadFile(opts.File); err != nil {
+			return nil, opts, err
+		}
+	}
+
+	var errs []error
+	for _, f := range fields {
+		if err := f.loadEnv(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, fv := range flagValues {
+		if err := fv.field.set(fv.value); err != nil {
+			errs = append(errs, fmt.Errorf("--%s: %w", fv.field.path, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, opts, joinErrors("invalid configuration", errs)
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, opts, err
+	}
+
+	return c, opts, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(raw, c)
+	case ".toml":
+		_, err = toml.Decode(string(raw), c)
+	default:
+		return fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Print writes c as YAML with every secret redacted.
+func (c *Config) Print(w io.Writer) error {
+	clone := *c
+	for _, f := range clone.fields() {
+		if f.secret && f.value.String() != "" {
+			f.value.SetString(redacted)
+		}
+	}
+
+	return yaml.NewEncoder(w).Encode(&clone)
+}
+
+// field is one leaf of the configuration, addressed by its dotted path.
+type field struct {
+	path   string
+	env    string
+	secret bool
+	value  reflect.Value
+}
+
+func (c *Config) fields() []field {
+	var fields []field
+
+	root := reflect.ValueOf(c).Elem()
+	for i := 0; i < root.NumField(); i++ {
+		section := root.Type().Field(i)
+		for j := 0; j < section.Type.NumField(); j++ {
+			leaf := section.Type.Field(j)
+			fields = append(fields, field{
+				path:   section.Tag.Get("yaml") + "." + leaf.Tag.Get("yaml"),
+				env:    leaf.Tag.Get("env"),
+				secret: leaf.Tag.Get("secret") == "true",
+				value:  root.Field(i).Field(j),
+			})
+		}
+	}
+
+	return fields
+}
+
+func (f field) loadEnv() error {
+	if f.secret {
+		if path, ok := os.LookupEnv(f.env + "_FILE"); ok {
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("%s_FILE: %w", f.env, err)
+			}
+			return f.set(strings.TrimSpace(string(raw)))
+		}
+	}
+
+	value, ok := os.LookupEnv(f.env)
+	if !ok || value == "" {
+		return nil
+	}
+
+	if err := f.set(value); err != nil {
+		return fmt.Errorf("%s: %w", f.env, err)
+	}
+
+	return nil
+}
+
+func (f field) set(s string) error {
+	switch f.value.Interface().(type) {
+	case string:
+		f.value.SetString(s)
+	case int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", s)
+		}
+		f.value.SetInt(int64(n))
+	case bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", s)
+		}
+		f.value.SetBool(b)
+	case time.Duration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 15s or 5m", s)
+		}
+		f.value.SetInt(int64(d))
+	default:
+		return fmt.Errorf("unsupported type %s", f.value.Type())
+	}
+
+	return nil
+}
+
+func joinErrors(title string, errs []error) error {
+	lines := make([]string, 0, len(errs))
+	for _, err := range errs {
+		lines = append(lines, "  - "+err.Error())
+	}
+
+	return fmt.Errorf("%s:\n%s", title, strings.Join(lines, "\n"))
+}
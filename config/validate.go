@@ -0,0 +1,195 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/Risuii/helpers/ratelimit"
+)
+
+// Validate reports every problem at once so that a misconfigured deployment
+// can be fixed in one go.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(validPort(c.App.Port), "app.port (PORT): %q must be a number between 1 and 65535", c.App.Port)
+	check(c.App.ReadTimeout > 0, "app.read_timeout (HTTP_READ_TIMEOUT): must be positive")
+	check(c.App.WriteTimeout > 0, "app.write_timeout (HTTP_WRITE_TIMEOUT): must be positive")
+	check(c.App.IdleTimeout > 0, "app.idle_timeout (HTTP_IDLE_TIMEOUT): must be positive")
+	check(c.App.ShutdownTimeout > 0, "app.shutdown_timeout (HTTP_SHUTDOWN_TIMEOUT): must be positive")
+	check(c.App.DrainDelay >= 0, "app.drain_delay (HTTP_DRAIN_DELAY): must not be negative")
+
+	if u, err := url.Parse(c.App.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		check(false, "app.public_url (PUBLIC_URL): %q must be an absolute http or https URL", c.App.PublicURL)
+	}
+
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		check(false, "log.level (LOG_LEVEL): %q must be one of debug, info, warn, error", c.Log.Level)
+	}
+
+	check(c.Database.Host != "", "database.host (DB_HOST): is required")
+	check(validPort(c.Database.Port), "database.port (DB_PORT): %q must be a number between 1 and 65535", c.Database.Port)
+	check(c.Database.Username != "", "database.username (DB_USERNAME): is required")
+	check(c.Database.Name != "", "database.name (DB_DATABASE_NAME): is required")
+	if _, err := time.LoadLocation(c.Database.Location); err != nil {
+		check(false, "database.location (DB_LOCATION): %q is not a known time zone", c.Database.Location)
+	}
+	check(c.Database.MaxOpenConns > 0, "database.max_open_conns (DB_MAX_OPEN_CONNS): must be positive")
+	check(c.Database.MaxIdleConns >= 0 && c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"database.max_idle_conns (DB_MAX_IDLE_CONNS): must be between 0 and database.max_open_conns")
+	check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime (DB_CONN_MAX_LIFETIME): must not be negative")
+	check(c.Database.ConnectTimeout > 0, "database.connect_timeout (DB_CONNECT_TIMEOUT): must be positive")
+	check(c.Database.RetryAttempts >= 1, "database.retry_attempts (DB_RETRY_ATTEMPTS): must be at least 1")
+
+	check(c.Bcrypt.HashCost >= bcrypt.MinCost && c.Bcrypt.HashCost <= bcrypt.MaxCost,
+		"bcrypt.hash_cost (BCRYPT_HASH_COST): %d must be between %d and %d", c.Bcrypt.HashCost, bcrypt.MinCost, bcrypt.MaxCost)
+
+	check(len(c.JWT.Secret) >= 32, "jwt.secret (JWT_SECRET or JWT_SECRET_FILE): must be at least 32 characters")
+	check(c.JWT.TokenTTL > 0, "jwt.token_ttl (JWT_TOKEN_TTL): must be positive")
+
+	switch c.Cache.Driver {
+	case "memory":
+		check(c.Cache.Size > 0, "cache.size (CACHE_SIZE): must be positive")
+	case "redis":
+		check(c.Cache.RedisAddr != "", "cache.redis_addr (REDIS_ADDR): is required with the redis driver")
+		check(c.Cache.RedisDB >= 0, "cache.redis_db (REDIS_DB): must not be negative")
+	case "none":
+	default:
+		check(false, "cache.driver (CACHE_DRIVER): %q must be one of memory, redis, none", c.Cache.Driver)
+	}
+	if c.Cache.Driver != "none" {
+		check(c.Cache.ItemTTL > 0, "cache.item_ttl (CACHE_ITEM_TTL): must be positive")
+		check(c.Cache.StoreTTL > 0, "cache.store_ttl (CACHE_STORE_TTL): must be positive")
+	}
+
+	check(c.Account.VerificationTTL >= time.Hour, "account.verification_ttl (ACCOUNT_VERIFICATION_TTL): must be at least 1h")
+	check(c.Account.PasswordResetTTL >= 5*time.Minute && c.Account.PasswordResetTTL <= 24*time.Hour,
+		"account.password_reset_ttl (ACCOUNT_PASSWORD_RESET_TTL): must be between 5m and 24h")
+	check(c.Account.MFAIssuer != "" && !strings.Contains(c.Account.MFAIssuer, ":"),
+		"account.mfa_issuer (ACCOUNT_MFA_ISSUER): is required and must not contain a colon")
+	check(c.Account.MFAPendingTTL >= time.Minute && c.Account.MFAPendingTTL <= 30*time.Minute,
+		"account.mfa_pending_ttl (ACCOUNT_MFA_PENDING_TTL): must be between 1m and 30m")
+
+	check(c.Store.InvitationTTL >= time.Hour && c.Store.InvitationTTL <= 30*24*time.Hour,
+		"store.invitation_ttl (STORE_INVITATION_TTL): must be between 1h and 720h")
+	check(c.Store.TransferTTL >= time.Hour && c.Store.TransferTTL <= 30*24*time.Hour,
+		"store.transfer_ttl (STORE_TRANSFER_TTL): must be between 1h and 720h")
+
+	check(c.Mail.From != "", "mail.from (MAIL_FROM): is required")
+	switch c.Mail.Driver {
+	case "log":
+	case "file":
+		check(c.Mail.Dir != "", "mail.dir (MAIL_DIR): is required with the file driver")
+	case "smtp":
+		check(c.Mail.SMTPHost != "", "mail.smtp_host (SMTP_HOST): is required with the smtp driver")
+		check(validPort(c.Mail.SMTPPort), "mail.smtp_port (SMTP_PORT): %q must be a number between 1 and 65535", c.Mail.SMTPPort)
+		check(c.Mail.SMTPTimeout > 0, "mail.smtp_timeout (SMTP_TIMEOUT): must be positive")
+	default:
+		check(false, "mail.driver (MAIL_DRIVER): %q must be one of log, file, smtp", c.Mail.Driver)
+	}
+
+	switch c.Media.Driver {
+	case "local":
+		check(c.Media.Dir != "", "media.dir (MEDIA_DIR): is required with the local driver")
+	case "s3":
+		if u, err := url.Parse(c.Media.S3Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			check(false, "media.s3_endpoint (S3_ENDPOINT): %q must be an absolute http or https URL", c.Media.S3Endpoint)
+		}
+		check(c.Media.S3Region != "", "media.s3_region (S3_REGION): is required with the s3 driver")
+		check(c.Media.S3Bucket != "", "media.s3_bucket (S3_BUCKET): is required with the s3 driver")
+		check(c.Media.S3AccessKey != "", "media.s3_access_key (S3_ACCESS_KEY): is required with the s3 driver")
+		check(c.Media.S3SecretKey != "", "media.s3_secret_key (S3_SECRET_KEY or S3_SECRET_KEY_FILE): is required with the s3 driver")
+	default:
+		check(false, "media.driver (MEDIA_DRIVER): %q must be one of local, s3", c.Media.Driver)
+	}
+	if c.Media.PublicURL != "" {
+		if u, err := url.Parse(c.Media.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			check(false, "media.public_url (MEDIA_PUBLIC_URL): %q must be an absolute http or https URL", c.Media.PublicURL)
+		}
+	}
+	check(c.Media.MaxUploadSize > 0, "media.max_upload_size (MEDIA_MAX_UPLOAD_SIZE): must be positive")
+	check(c.Media.MaxPixels > 0, "media.max_pixels (MEDIA_MAX_PIXELS): must be positive")
+	check(c.Media.ThumbnailSize >= 16 && c.Media.ThumbnailSize <= 2048,
+		"media.thumbnail_size (MEDIA_THUMBNAIL_SIZE): must be between 16 and 2048")
+
+	switch c.RateLimit.Store {
+	case "memory":
+	case "redis":
+		check(c.Cache.RedisAddr != "", "cache.redis_addr (REDIS_ADDR): is required with the redis rate limit store")
+	default:
+		check(false, "rate_limit.store (RATE_LIMIT_STORE): %q must be one of memory, redis", c.RateLimit.Store)
+	}
+	for _, limit := range []struct{ path, env, value string }{
+		{"rate_limit.login_ip", "RATE_LIMIT_LOGIN_IP", c.RateLimit.LoginIP},
+		{"rate_limit.login_account", "RATE_LIMIT_LOGIN_ACCOUNT", c.RateLimit.LoginAccount},
+		{"rate_limit.register_ip", "RATE_LIMIT_REGISTER_IP", c.RateLimit.RegisterIP},
+		{"rate_limit.forgot_password", "RATE_LIMIT_FORGOT_PASSWORD", c.RateLimit.ForgotPassword},
+		{"rate_limit.forgot_password_ip", "RATE_LIMIT_FORGOT_PASSWORD_IP", c.RateLimit.ForgotPasswordIP},
+		{"rate_limit.reset_password_ip", "RATE_LIMIT_RESET_PASSWORD_IP", c.RateLimit.ResetPasswordIP},
+		{"rate_limit.mfa_ip", "RATE_LIMIT_MFA_IP", c.RateLimit.MFAIP},
+		{"rate_limit.sso_callback_ip", "RATE_LIMIT_SSO_CALLBACK_IP", c.RateLimit.SSOCallbackIP},
+		{"rate_limit.default_ip", "RATE_LIMIT_DEFAULT_IP", c.RateLimit.DefaultIP},
+		{"rate_limit.default_user", "RATE_LIMIT_DEFAULT_USER", c.RateLimit.DefaultUser},
+	} {
+		if _, err := ratelimit.ParseLimit(limit.value); err != nil {
+			check(false, "%s (%s): %v", limit.path, limit.env, err)
+		}
+	}
+	check(c.RateLimit.LockoutFailures > 0, "rate_limit.lockout_failures (LOCKOUT_FAILURES): must be positive")
+	check(c.RateLimit.LockoutDuration > 0, "rate_limit.lockout_duration (LOCKOUT_DURATION): must be positive")
+
+	if c.OIDC.Issuer != "" {
+		if u, err := url.Parse(c.OIDC.Issuer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			check(false, "oidc.issuer (OIDC_ISSUER): %q must be an absolute http or https URL", c.OIDC.Issuer)
+		}
+		check(validName(c.OIDC.Name), "oidc.name (OIDC_PROVIDER_NAME): %q must be lowercase letters, digits and dashes", c.OIDC.Name)
+		check(c.OIDC.ClientID != "", "oidc.client_id (OIDC_CLIENT_ID): is required with an issuer")
+		check(strings.Contains(" "+c.OIDC.Scopes+" ", " openid "), "oidc.scopes (OIDC_SCOPES): must include openid")
+		check(c.OIDC.StateTTL >= time.Minute && c.OIDC.StateTTL <= time.Hour, "oidc.state_ttl (OIDC_STATE_TTL): must be between 1m and 1h")
+	}
+
+	check(c.Admin.Token == "" || len(c.Admin.Token) >= 32, "admin.token (ADMIN_TOKEN or ADMIN_TOKEN_FILE): must be at least 32 characters when set")
+
+	switch c.Tracing.Exporter {
+	case "otlp", "stdout", "none":
+	default:
+		check(false, "tracing.exporter (TRACING_EXPORTER): %q must be one of otlp, stdout, none", c.Tracing.Exporter)
+	}
+	check(c.Tracing.ServiceName != "", "tracing.service_name (TRACING_SERVICE_NAME): is required")
+
+	if len(errs) > 0 {
+		return joinErrors("invalid configuration", errs)
+	}
+
+	return nil
+}
+
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
@@ -0,0 +1,5 @@
+ALTER TABLE `ecommerce`.`items` DROP COLUMN `version`;
+
+ALTER TABLE `ecommerce`.`stores` DROP COLUMN `version`;
+
+ALTER TABLE `ecommerce`.`users` DROP COLUMN `version`;
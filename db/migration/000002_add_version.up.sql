@@ -0,0 +1,5 @@
+ALTER TABLE `ecommerce`.`users` ADD COLUMN `version` INT NOT NULL DEFAULT 1;
+
+ALTER TABLE `ecommerce`.`stores` ADD COLUMN `version` INT NOT NULL DEFAULT 1;
+
+ALTER TABLE `ecommerce`.`items` ADD COLUMN `version` INT NOT NULL DEFAULT 1;
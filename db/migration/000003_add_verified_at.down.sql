@@ -0,0 +1 @@
+ALTER TABLE `ecommerce`.`users` DROP COLUMN `verified_at`;
@@ -0,0 +1 @@
+ALTER TABLE `ecommerce`.`users` ADD COLUMN `verified_at` DATETIME NULL;
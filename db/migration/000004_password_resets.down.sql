@@ -0,0 +1,3 @@
+DROP TABLE `ecommerce`.`password_resets`;
+
+ALTER TABLE `ecommerce`.`users` DROP COLUMN `session_epoch`;
@@ -0,0 +1,13 @@
+ALTER TABLE `ecommerce`.`users` ADD COLUMN `session_epoch` INT NOT NULL DEFAULT 0;
+
+CREATE TABLE `ecommerce`.`password_resets` (
+    `ID` INT NOT NULL AUTO_INCREMENT,
+    `userID` INT NOT NULL,
+    `token_hash` CHAR(64) NOT NULL,
+    `expires_at` DATETIME NOT NULL,
+    `used_at` DATETIME NULL,
+    `created_at` DATETIME NOT NULL,
+    PRIMARY KEY (`ID`),
+    UNIQUE KEY (`token_hash`),
+    FOREIGN KEY (`userID`) REFERENCES users(`ID`) ON DELETE CASCADE
+);
@@ -0,0 +1 @@
+ALTER TABLE `ecommerce`.`users` DROP INDEX `users_email_unique`;
@@ -0,0 +1,15 @@
+ALTER TABLE `ecommerce`.`users`
+    ADD COLUMN `totp_secret` VARCHAR(255) NULL,
+    ADD COLUMN `totp_enabled_at` DATETIME NULL,
+    ADD COLUMN `totp_last_counter` BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE `ecommerce`.`recovery_codes` (
+    `ID` INT NOT NULL AUTO_INCREMENT,
+    `userID` INT NOT NULL,
+    `code_hash` CHAR(64) NOT NULL,
+    `used_at` DATETIME NULL,
+    `created_at` DATETIME NOT NULL,
+    PRIMARY KEY (`ID`),
+    UNIQUE KEY (`userID`, `code_hash`),
+    FOREIGN KEY (`userID`) REFERENCES users(`ID`) ON DELETE CASCADE
+);
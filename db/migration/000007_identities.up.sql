@@ -0,0 +1,12 @@
+CREATE TABLE `ecommerce`.`identities` (
+    `ID` INT NOT NULL AUTO_INCREMENT,
+    `userID` INT NOT NULL,
+    `provider` VARCHAR(64) NOT NULL,
+    `subject` VARCHAR(255) NOT NULL,
+    `email` VARCHAR(255) NOT NULL DEFAULT '',
+    `created_at` DATETIME NOT NULL,
+    PRIMARY KEY (`ID`),
+    UNIQUE KEY (`provider`, `subject`),
+    UNIQUE KEY (`userID`, `provider`),
+    FOREIGN KEY (`userID`) REFERENCES users(`ID`) ON DELETE CASCADE
+);
@@ -0,0 +1,15 @@
+CREATE TABLE `ecommerce`.`api_keys` (
+    `ID` INT NOT NULL AUTO_INCREMENT,
+    `storeID` INT NOT NULL,
+    `name` VARCHAR(64) NOT NULL,
+    `hint` VARCHAR(16) NOT NULL,
+    `key_hash` CHAR(64) NOT NULL,
+    `scopes` VARCHAR(255) NOT NULL,
+    `last_used_at` DATETIME NULL,
+    `expires_at` DATETIME NULL,
+    `created_at` DATETIME NOT NULL,
+    PRIMARY KEY (`ID`),
+    UNIQUE KEY (`key_hash`),
+    KEY (`storeID`),
+    FOREIGN KEY (`storeID`) REFERENCES stores(`ID`) ON DELETE CASCADE
+);
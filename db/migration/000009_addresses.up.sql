@@ -0,0 +1,19 @@
+CREATE TABLE `ecommerce`.`addresses` (
+    `ID` INT NOT NULL AUTO_INCREMENT,
+    `userID` INT NOT NULL,
+    `label` VARCHAR(64) NOT NULL,
+    `recipient` VARCHAR(128) NOT NULL,
+    `phone` VARCHAR(32) NOT NULL,
+    `street` VARCHAR(255) NOT NULL,
+    `city` VARCHAR(128) NOT NULL,
+    `province` VARCHAR(128) NOT NULL DEFAULT '',
+    `postal_code` VARCHAR(16) NOT NULL,
+    `country` CHAR(2) NOT NULL,
+    `is_default` BOOLEAN NOT NULL DEFAULT FALSE,
+    `created_at` DATETIME NOT NULL,
+    `update_at` DATETIME NOT NULL,
+    `version` INT NOT NULL DEFAULT 1,
+    PRIMARY KEY (`ID`),
+    KEY (`userID`),
+    FOREIGN KEY (`userID`) REFERENCES users(`ID`) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+DROP TABLE `ecommerce`.`store_invitations`;
+
+DROP TABLE `ecommerce`.`store_members`;
@@ -0,0 +1,27 @@
+CREATE TABLE `ecommerce`.`store_members` (
+    `ID` INT NOT NULL AUTO_INCREMENT,
+    `storeID` INT NOT NULL,
+    `userID` INT NOT NULL,
+    `role` VARCHAR(16) NOT NULL,
+    `created_at` DATETIME NOT NULL,
+    PRIMARY KEY (`ID`),
+    UNIQUE KEY (`storeID`, `userID`),
+    KEY (`userID`),
+    FOREIGN KEY (`storeID`) REFERENCES stores(`ID`) ON DELETE CASCADE,
+    FOREIGN KEY (`userID`) REFERENCES users(`ID`) ON DELETE CASCADE
+);
+
+CREATE TABLE `ecommerce`.`store_invitations` (
+    `ID` INT NOT NULL AUTO_INCREMENT,
+    `storeID` INT NOT NULL,
+    `email` VARCHAR(255) NOT NULL,
+    `role` VARCHAR(16) NOT NULL,
+    `invited_by` INT NOT NULL,
+    `token_hash` CHAR(64) NOT NULL,
+    `expires_at` DATETIME NOT NULL,
+    `created_at` DATETIME NOT NULL,
+    PRIMARY KEY (`ID`),
+    UNIQUE KEY (`token_hash`),
+    UNIQUE KEY (`storeID`, `email`),
+    FOREIGN KEY (`storeID`) REFERENCES stores(`ID`) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+DROP TABLE `ecommerce`.`store_audit_log`;
+
+DROP TABLE `ecommerce`.`store_transfers`;
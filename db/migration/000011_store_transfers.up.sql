@@ -0,0 +1,22 @@
+CREATE TABLE `ecommerce`.`store_transfers` (
+    `storeID` INT NOT NULL,
+    `from_user` INT NOT NULL,
+    `to_user` INT NOT NULL,
+    `expires_at` DATETIME NOT NULL,
+    `created_at` DATETIME NOT NULL,
+    PRIMARY KEY (`storeID`),
+    KEY (`to_user`),
+    FOREIGN KEY (`storeID`) REFERENCES stores(`ID`) ON DELETE CASCADE,
+    FOREIGN KEY (`to_user`) REFERENCES users(`ID`) ON DELETE CASCADE
+);
+
+CREATE TABLE `ecommerce`.`store_audit_log` (
+    `ID` INT NOT NULL AUTO_INCREMENT,
+    `storeID` INT NOT NULL,
+    `actorID` INT NOT NULL,
+    `action` VARCHAR(64) NOT NULL,
+    `details` TEXT NULL,
+    `created_at` DATETIME NOT NULL,
+    PRIMARY KEY (`ID`),
+    KEY (`storeID`, `created_at`)
+);
@@ -0,0 +1,14 @@
+ALTER TABLE `ecommerce`.`stores`
+    DROP INDEX `stores_slug_unique`,
+    DROP COLUMN `slug`,
+    DROP COLUMN `logo_url`,
+    DROP COLUMN `banner_url`,
+    DROP COLUMN `contact_email`,
+    DROP COLUMN `contact_phone`,
+    DROP COLUMN `address`,
+    DROP COLUMN `city`,
+    DROP COLUMN `country`,
+    DROP COLUMN `latitude`,
+    DROP COLUMN `longitude`,
+    DROP COLUMN `timezone`,
+    DROP COLUMN `hours`;
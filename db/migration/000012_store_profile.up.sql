@@ -0,0 +1,19 @@
+ALTER TABLE `ecommerce`.`stores`
+    ADD COLUMN `slug` VARCHAR(64) NULL AFTER `nameStore`,
+    ADD COLUMN `logo_url` VARCHAR(2048) NOT NULL DEFAULT '',
+    ADD COLUMN `banner_url` VARCHAR(2048) NOT NULL DEFAULT '',
+    ADD COLUMN `contact_email` VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN `contact_phone` VARCHAR(32) NOT NULL DEFAULT '',
+    ADD COLUMN `address` VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN `city` VARCHAR(128) NOT NULL DEFAULT '',
+    ADD COLUMN `country` CHAR(2) NOT NULL DEFAULT '',
+    ADD COLUMN `latitude` DECIMAL(9,6) NULL,
+    ADD COLUMN `longitude` DECIMAL(9,6) NULL,
+    ADD COLUMN `timezone` VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    ADD COLUMN `hours` TEXT NULL;
+
+UPDATE `ecommerce`.`stores` SET `slug` = CONCAT('store-', `ID`);
+
+ALTER TABLE `ecommerce`.`stores`
+    MODIFY COLUMN `slug` VARCHAR(64) NOT NULL,
+    ADD UNIQUE INDEX `stores_slug_unique` (`slug`);
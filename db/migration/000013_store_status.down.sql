@@ -0,0 +1,4 @@
+ALTER TABLE `ecommerce`.`stores`
+    DROP INDEX `stores_status`,
+    DROP COLUMN `status`,
+    DROP COLUMN `status_reason`;
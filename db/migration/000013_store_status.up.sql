@@ -0,0 +1,4 @@
+ALTER TABLE `ecommerce`.`stores`
+    ADD COLUMN `status` VARCHAR(16) NOT NULL DEFAULT 'active',
+    ADD COLUMN `status_reason` VARCHAR(32) NOT NULL DEFAULT '',
+    ADD INDEX `stores_status` (`status`);
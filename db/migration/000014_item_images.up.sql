@@ -0,0 +1,15 @@
+CREATE TABLE `ecommerce`.`item_images` (
+    `ID` INT NOT NULL AUTO_INCREMENT,
+    `itemID` INT NOT NULL,
+    `position` INT NOT NULL,
+    `blob_key` VARCHAR(255) NOT NULL,
+    `thumbnail_key` VARCHAR(255) NOT NULL,
+    `content_type` VARCHAR(32) NOT NULL,
+    `width` INT NOT NULL,
+    `height` INT NOT NULL,
+    `size` INT NOT NULL,
+    `created_at` DATETIME NOT NULL,
+    PRIMARY KEY (`ID`),
+    KEY (`itemID`, `position`),
+    FOREIGN KEY (`itemID`) REFERENCES items(`ID`) ON DELETE CASCADE
+);
@@ -0,0 +1,83 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// ErrNotFound is returned by Get for keys that hold nothing.
+var ErrNotFound = errors.New("blob: not found")
+
+// BlobStore keeps files under keys such as "items/7/3f2a.jpg". Keys are
+// slash separated, never start with a slash and have no "." or ".."
+// segments. Writing a key that exists replaces the file.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// URL is where clients download the file of key.
+	URL(key string) string
+}
+
+// ValidKey reports whether key is a key as described on BlobStore.
+func ValidKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) {
+		return false
+	}
+
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+
+	return true
+}
+
+// KeyOf returns the key of a URL made by s.URL, and false for URLs that
+// point elsewhere.
+func KeyOf(s BlobStore, url string) (string, bool) {
+	key, ok := strings.CutPrefix(url, s.URL(""))
+	if !ok || !ValidKey(key) {
+		return "", false
+	}
+
+	return key, true
+}
+
+// Handler serves the files of s under their keys, for stores that are not
+// reachable by clients themselves, such as the local one. Keys are never
+// reused for other content, so files are cached for good.
+func Handler(s BlobStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/")
+		if !ValidKey(key) {
+			http.NotFound(w, r)
+			return
+		}
+
+		body, err := s.Get(r.Context(), key)
+		if errors.Is(err, ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		defer body.Close()
+
+		if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+
+		io.Copy(w, body)
+	})
+}
@@ -0,0 +1,170 @@
+package blob
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Config points at a bucket of S3 or of a service that speaks its API,
+// such as MinIO or Cloudflare R2.
+type S3Config struct {
+	// Endpoint is the base URL of the service, e.g.
+	// https://s3.eu-west-1.amazonaws.com.
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle puts the bucket in the path instead of the host name, as
+	// most S3 compatible services expect.
+	PathStyle bool
+	// PublicURL is where clients download the files, the URL of the bucket
+	// when empty. Set it when a CDN serves the bucket.
+	PublicURL string
+}
+
+type s3Store struct {
+	cfg       S3Config
+	endpoint  *url.URL
+	publicURL string
+	client    *http.Client
+}
+
+// NewS3 stores the files as objects of the bucket, signing requests with
+// AWS Signature Version 4. Objects are not made public by the store; grant
+// read access to the bucket, or to PublicURL, separately.
+func NewS3(cfg S3Config, client *http.Client) (BlobStore, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("blob: s3 endpoint %q must be an absolute http or https URL", cfg.Endpoint)
+	}
+
+	s := &s3Store{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   client,
+	}
+
+	s.publicURL = strings.TrimSuffix(cfg.PublicURL, "/")
+	if s.publicURL == "" {
+		s.publicURL = strings.TrimSuffix(s.objectURL("").String(), "/")
+	}
+	s.publicURL += "/"
+
+	return s, nil
+}
+
+// objectURL addresses key in the bucket, by path or by virtual host.
+func (s *s3Store) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	p := "/" + key
+	if s.cfg.PathStyle {
+		p = "/" + s.cfg.Bucket + p
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+	}
+
+	u.Path += p
+	u.RawPath = escapePath(u.Path)
+
+	return &u
+}
+
+func (s *s3Store) do(ctx context.Context, method, key string, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	if !ValidKey(key) {
+		return nil, fmt.Errorf("blob: invalid key %q", key)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key).String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	if body != nil {
+		req.ContentLength = size
+		// Uploads are streamed, so their hash is not known up front.
+		req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	} else {
+		req.Header.Set("X-Amz-Content-Sha256", emptyHash)
+	}
+
+	SignV4(req, s.cfg.AccessKey, s.cfg.SecretKey, s.cfg.Region, time.Now())
+
+	return s.client.Do(req)
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	res, err := s.do(ctx, http.MethodPut, key, r, size, http.Header{"Content-Type": {contentType}})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return s3Error(res, key)
+	}
+
+	return nil
+}
+
+func (s *s3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	res, err := s.do(ctx, http.MethodGet, key, nil, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+		return nil, ErrNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		return nil, s3Error(res, key)
+	}
+
+	return res.Body, nil
+}
+
+// Delete does nothing for keys that hold nothing, as S3 itself.
+func (s *s3Store) Delete(ctx context.Context, key string) error {
+	res, err := s.do(ctx, http.MethodDelete, key, nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNotFound {
+		return s3Error(res, key)
+	}
+
+	return nil
+}
+
+func (s *s3Store) URL(key string) string {
+	return s.publicURL + key
+}
+
+// s3Error reads the error document of a failed request.
+func s3Error(res *http.Response, key string) error {
+	var doc struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	xml.NewDecoder(io.LimitReader(res.Body, 64<<10)).Decode(&doc)
+
+	if doc.Code == "" {
+		doc.Code = res.Status
+	}
+
+	return fmt.Errorf("blob: s3 %s %s: %s %s", res.Request.Method, key, doc.Code, doc.Message)
+}
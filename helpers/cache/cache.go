@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/metrics"
+)
+
+// Cache stores opaque values under string keys until their TTL runs out.
+// A missing key is reported with ok false and a nil error.
+type Cache interface {
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Loader reads through a Cache. Concurrent misses on the same key share a
+// single load, and TTLs are jittered so that keys filled together do not
+// expire together, which keeps a hot key from stampeding the database.
+//
+// The cache is an optimisation only: when it fails the error is logged and
+// the value is loaded from the source.
+type Loader struct {
+	cache Cache
+	ttl   time.Duration
+	group singleflight.Group
+	// generations are bumped by Invalidate so that a load that started
+	// before a write does not cache what it read. Keys share them by hash,
+	// which at worst skips caching a load now and then.
+	generations [256]atomic.Uint64
+}
+
+func NewLoader(cache Cache, ttl time.Duration) *Loader {
+	return &Loader{
+		cache: cache,
+		ttl:   ttl,
+	}
+}
+
+type freshKey struct{}
+
+// Fresh returns a context in which Load always calls load and leaves the
+// cache alone. Use cases read through it before they write, so that their
+// version and ownership checks are never made against a stale entry.
+func Fresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, freshKey{}, true)
+}
+
+// Load returns the cached value of key, or calls load and caches its result.
+// Errors returned by load are not cached.
+func Load[T any](ctx context.Context, l *Loader, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	if fresh, _ := ctx.Value(freshKey{}).(bool); fresh {
+		return load(ctx)
+	}
+
+	var value T
+
+	raw, ok, err := l.cache.Get(ctx, key)
+	if err != nil {
+		logger.FromContext(ctx).WarnContext(ctx, "cache get", "key", key, "error", err)
+	}
+	if ok && json.Unmarshal(raw, &value) == nil {
+		metrics.CacheLookups.WithLabelValues("hit").Inc()
+		return value, nil
+	}
+	metrics.CacheLookups.WithLabelValues("miss").Inc()
+
+	// The shared load must not be cut short because the caller that happened
+	// to start it went away.
+	shared, err, _ := l.group.Do(key, func() (interface{}, error) {
+		ctx := context.WithoutCancel(ctx)
+		generation := l.generation(key)
+		start := generation.Load()
+
+		value, err := load(ctx)
+		if err != nil {
+			return value, err
+		}
+
+		raw, err := json.Marshal(value)
+		if err != nil || generation.Load() != start {
+			return value, nil
+		}
+
+		if err := l.cache.Set(ctx, key, raw, l.jitter()); err != nil {
+			logger.FromContext(ctx).WarnContext(ctx, "cache set", "key", key, "error", err)
+		}
+		// An invalidation that ran during Set may have deleted the key
+		// before it was written.
+		if generation.Load() != start {
+			l.delete(ctx, key)
+		}
+
+		return value, nil
+	})
+	if err != nil {
+		return value, err
+	}
+
+	return shared.(T), nil
+}
+
+// Invalidate drops keys after a write. A failure is logged, the entries
+// then live until their TTL.
+func (l *Loader) Invalidate(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		l.generation(key).Add(1)
+		l.group.Forget(key)
+	}
+	l.delete(ctx, keys...)
+}
+
+func (l *Loader) delete(ctx context.Context, keys ...string) {
+	if err := l.cache.Delete(ctx, keys...); err != nil {
+		logger.FromContext(ctx).WarnContext(ctx, "cache delete", "keys", keys, "error", err)
+	}
+}
+
+func (l *Loader) generation(key string) *atomic.Uint64 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &l.generations[h.Sum32()%uint32(len(l.generations))]
+}
+
+// jitter spreads the TTL over [90%, 110%].
+func (l *Loader) jitter() time.Duration {
+	spread := int64(l.ttl) / 5
+	if spread <= 0 {
+		return l.ttl
+	}
+
+	return l.ttl - time.Duration(spread/2) + time.Duration(rand.Int63n(spread))
+}
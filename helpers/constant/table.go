@@ -4,4 +4,15 @@ const (
 	TableAccount = "users"
 	TableStores  = "stores"
 	TableItems   = "items"
+
+	TablePasswordResets = "password_resets"
+	TableRecoveryCodes  = "recovery_codes"
+	TableIdentities     = "identities"
+	TableAPIKeys        = "api_keys"
+	TableAddresses      = "addresses"
+	TableStoreMembers   = "store_members"
+	TableInvitations    = "store_invitations"
+	TableTransfers      = "store_transfers"
+	TableStoreAudit     = "store_audit_log"
+	TableItemImages     = "item_images"
 )
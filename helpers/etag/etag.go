@@ -0,0 +1,109 @@
+package etag
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Risuii/helpers/exception"
+)
+
+const (
+	HeaderETag        = "ETag"
+	HeaderIfMatch     = "If-Match"
+	HeaderIfNoneMatch = "If-None-Match"
+)
+
+// Version returns the strong entity tag of a single versioned row.
+func Version(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// List returns a weak entity tag for a collection, derived from the ID and
+// version of every row in it.
+func List(idVersions ...[2]int64) string {
+	h := sha1.New()
+	for _, iv := range idVersions {
+		fmt.Fprintf(h, "%d:%d;", iv[0], iv[1])
+	}
+
+	return `W/"` + hex.EncodeToString(h.Sum(nil))[:16] + `"`
+}
+
+// IfMatch returns the version the client expects to modify. Writes without
+// If-Match are rejected so that blind overwrites cannot happen.
+func IfMatch(r *http.Request) (int64, error) {
+	header := strings.TrimSpace(r.Header.Get(HeaderIfMatch))
+	if header == "" {
+		return 0, exception.ErrPreconditionNeeded
+	}
+
+	unquoted, err := strconv.Unquote(header)
+	if err != nil {
+		return 0, exception.ErrPreconditionFailed.Wrap(err)
+	}
+
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil {
+		return 0, exception.ErrPreconditionFailed.Wrap(err)
+	}
+
+	return version, nil
+}
+
+func noneMatch(header, tag string) bool {
+	if tag == "" {
+		return true
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(tag, "W/") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Middleware answers conditional GETs with 304 Not Modified when the ETag
+// set by the handler matches one listed in If-None-Match.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get(HeaderIfNoneMatch)
+		if header == "" || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(&conditionalWriter{ResponseWriter: w, ifNoneMatch: header}, r)
+	})
+}
+
+type conditionalWriter struct {
+	http.ResponseWriter
+	ifNoneMatch string
+	notModified bool
+}
+
+func (cw *conditionalWriter) WriteHeader(statusCode int) {
+	if statusCode == http.StatusOK && !noneMatch(cw.ifNoneMatch, cw.Header().Get(HeaderETag)) {
+		cw.notModified = true
+		cw.Header().Del("Content-Type")
+		cw.ResponseWriter.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	cw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (cw *conditionalWriter) Write(b []byte) (int, error) {
+	if cw.notModified {
+		return len(b), nil
+	}
+
+	return cw.ResponseWriter.Write(b)
+}
@@ -1,13 +1,184 @@
 package exception
 
-import "fmt"
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Kind groups errors by the way they are reported to clients. Its value is
+// also the "status" string written in the response envelope.
+type Kind string
+
+const (
+	KindBadRequest           Kind = "BAD_REQUEST"
+	KindUnauthorized         Kind = "UNAUTHORIZED"
+	KindForbidden            Kind = "FORBIDDEN"
+	KindNotFound             Kind = "NOT_FOUND"
+	KindConflicted           Kind = "CONFLICTED"
+	KindUnprocessableEntity  Kind = "UNPROCESSABLE_ENTITY"
+	KindPreconditionFailed   Kind = "PRECONDITION_FAILED"
+	KindPreconditionNeeded   Kind = "PRECONDITION_REQUIRED"
+	KindTooManyRequests      Kind = "TOO_MANY_REQUESTS"
+	KindPayloadTooLarge      Kind = "PAYLOAD_TOO_LARGE"
+	KindUnsupportedMediaType Kind = "UNSUPPORTED_MEDIA_TYPE"
+	KindServiceUnavailable   Kind = "SERVICE_UNAVAILABLE"
+	KindInternalServer       Kind = "INTERNAL_SERVER_ERROR"
+)
+
+// HTTPStatus is the single place where error kinds are mapped to HTTP status codes.
+func (k Kind) HTTPStatus() int {
+	switch k {
+	case KindBadRequest:
+		return http.StatusBadRequest
+	case KindUnauthorized:
+		return http.StatusUnauthorized
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflicted:
+		return http.StatusConflict
+	case KindUnprocessableEntity:
+		return http.StatusUnprocessableEntity
+	case KindPreconditionFailed:
+		return http.StatusPreconditionFailed
+	case KindPreconditionNeeded:
+		return http.StatusPreconditionRequired
+	case KindTooManyRequests:
+		return http.StatusTooManyRequests
+	case KindPayloadTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case KindUnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	case KindServiceUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Error is the base type of every error that is reported to API clients.
+// Code is stable and meant for machines, Message is meant for humans.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Fields  []FieldError
+	// RetryAfter tells clients when to try again, it is sent as the
+	// Retry-After header.
+	RetryAfter time.Duration
+	cause      error
+}
+
+func New(kind Kind, code, message string) *Error {
+	return &Error{
+		Kind:    kind,
+		Code:    code,
+		Message: message,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return e.Message + ": " + e.cause.Error()
+	}
+
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is reports errors with the same code as equal, so wrapped copies of a
+// sentinel still match it with errors.Is.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Wrap returns a copy of e that keeps err as its cause. The cause is only
+// used for logging and is never sent to clients.
+func (e *Error) Wrap(err error) *Error {
+	clone := *e
+	clone.cause = err
+	return &clone
+}
+
+// WithFields returns a copy of e carrying per-field details.
+func (e *Error) WithFields(fields ...FieldError) *Error {
+	clone := *e
+	clone.Fields = append([]FieldError{}, fields...)
+	return &clone
+}
+
+// WithRetryAfter returns a copy of e asking the client to wait d.
+func (e *Error) WithRetryAfter(d time.Duration) *Error {
+	clone := *e
+	clone.RetryAfter = d
+	return &clone
+}
+
+// From returns err as an *Error. Errors that are not part of the hierarchy
+// are reported as internal server errors.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	return ErrInternalServer.Wrap(err)
+}
+
+// KindOf returns the kind of err, see From.
+func KindOf(err error) Kind {
+	return From(err).Kind
+}
 
 var (
-	ErrConflicted          = fmt.Errorf("conflicted")
-	ErrInternalServer      = fmt.Errorf("internal server error")
-	ErrNotFound            = fmt.Errorf("not found error")
-	ErrBadRequest          = fmt.Errorf("bad request")
-	ErrUnauthorized        = fmt.Errorf("unauthorized")
-	ErrNotPremium          = fmt.Errorf("not premium user")
-	ErrUnprocessableEntity = fmt.Errorf("UnprocessableEntity")
+	ErrConflicted          = New(KindConflicted, "CONFLICTED", "conflicted")
+	ErrInternalServer      = New(KindInternalServer, "INTERNAL_SERVER_ERROR", "internal server error")
+	ErrNotFound            = New(KindNotFound, "NOT_FOUND", "not found error")
+	ErrBadRequest          = New(KindBadRequest, "BAD_REQUEST", "bad request")
+	ErrValidation          = New(KindBadRequest, "VALIDATION_FAILED", "validation failed")
+	ErrUnauthorized        = New(KindUnauthorized, "UNAUTHORIZED", "unauthorized")
+	ErrNotPremium          = New(KindForbidden, "NOT_PREMIUM", "not premium user")
+	ErrUnprocessableEntity = New(KindUnprocessableEntity, "UNPROCESSABLE_ENTITY", "request body could not be decoded")
+	ErrPreconditionFailed  = New(KindPreconditionFailed, "VERSION_MISMATCH", "the resource was modified by someone else")
+	ErrPreconditionNeeded  = New(KindPreconditionNeeded, "IF_MATCH_REQUIRED", "the If-Match header is required")
+	ErrInvalidToken        = New(KindBadRequest, "INVALID_TOKEN", "the token is invalid or has expired")
+	ErrEmailNotVerified    = New(KindForbidden, "EMAIL_NOT_VERIFIED", "the email address has not been verified yet")
+	ErrAlreadyVerified     = New(KindConflicted, "ALREADY_VERIFIED", "the email address is already verified")
+	ErrEmailTaken          = New(KindConflicted, "EMAIL_TAKEN", "the email address belongs to another account")
+	ErrWrongPassword       = New(KindForbidden, "WRONG_PASSWORD", "the current password is not correct")
+	ErrInvalidCode         = New(KindBadRequest, "INVALID_CODE", "the code is not valid")
+	ErrMFAEnabled          = New(KindConflicted, "MFA_ENABLED", "two-factor authentication is already enabled")
+	ErrMFANotEnabled       = New(KindConflicted, "MFA_NOT_ENABLED", "two-factor authentication is not enabled")
+	ErrMFANotEnrolled      = New(KindConflicted, "MFA_NOT_ENROLLED", "start the two-factor enrolment first")
+	ErrSSOFailed           = New(KindBadRequest, "SSO_FAILED", "the login with the identity provider failed")
+	ErrSSOEmailUnverified  = New(KindForbidden, "SSO_EMAIL_NOT_VERIFIED", "the identity provider has not verified the email address")
+	ErrAccountExists       = New(KindConflicted, "ACCOUNT_EXISTS", "an account with this email exists, log in to it and link the provider from there")
+	ErrIdentityLinked      = New(KindConflicted, "IDENTITY_LINKED", "the identity is already linked to an account")
+	ErrLastLoginMethod     = New(KindConflicted, "LAST_LOGIN_METHOD", "set a password before removing the last linked identity")
+	ErrInvalidAPIKey       = New(KindUnauthorized, "INVALID_API_KEY", "the API key is invalid, has expired or was revoked")
+	ErrInsufficientScope   = New(KindForbidden, "INSUFFICIENT_SCOPE", "the API key lacks the scope this endpoint needs")
+	ErrAddressBookFull     = New(KindConflicted, "ADDRESS_BOOK_FULL", "the address book is full, delete an address first")
+	ErrNoShippingAddress   = New(KindBadRequest, "NO_SHIPPING_ADDRESS", "add an address to the address book first")
+	ErrForbidden           = New(KindForbidden, "FORBIDDEN", "your role in the store does not allow this")
+	ErrAlreadyMember       = New(KindConflicted, "ALREADY_MEMBER", "the account is already a member of the store")
+	ErrInvitationPending   = New(KindConflicted, "INVITATION_PENDING", "an invitation was already sent to this email address")
+	ErrInvitationEmail     = New(KindForbidden, "INVITATION_EMAIL_MISMATCH", "the invitation was sent to another email address")
+	ErrTransferPending     = New(KindConflicted, "TRANSFER_PENDING", "a transfer of the store is already pending, cancel it first")
+	ErrTransferRecipient   = New(KindBadRequest, "TRANSFER_RECIPIENT", "the store can only be transferred to another account with a verified email address")
+	ErrStoreUnavailable    = New(KindForbidden, "STORE_UNAVAILABLE", "the store is suspended or closed")
+	ErrStatusTransition    = New(KindConflicted, "STATUS_TRANSITION", "the store cannot move to this state from its current one")
+	ErrSlugTaken           = New(KindConflicted, "SLUG_TAKEN", "another store already uses this address")
+	ErrFileTooLarge        = New(KindPayloadTooLarge, "FILE_TOO_LARGE", "the file is larger than allowed")
+	ErrUnsupportedImage    = New(KindUnsupportedMediaType, "UNSUPPORTED_IMAGE", "the file is not a JPEG, PNG or GIF image, or its dimensions are too large")
+	ErrGalleryFull         = New(KindConflicted, "GALLERY_FULL", "the item has as many images as allowed, delete one first")
+	ErrImageOrder          = New(KindBadRequest, "IMAGE_ORDER", "the order must list every image of the item exactly once")
+	ErrTooManyRequests     = New(KindTooManyRequests, "RATE_LIMITED", "too many requests, try again later")
+	ErrAccountLocked       = New(KindTooManyRequests, "ACCOUNT_LOCKED", "too many failed logins, the account is locked for a while")
+	ErrUnavailable         = New(KindServiceUnavailable, "SERVICE_UNAVAILABLE", "service unavailable")
 )
@@ -0,0 +1,59 @@
+package exception
+
+import (
+	"errors"
+	"fmt"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError describes why a single request field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Tag     string `json:"tag"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+
+	err validator.FieldError
+}
+
+// Translate returns a copy of f whose message uses the validator
+// translations registered for trans. Field errors raised outside the
+// validator are looked up by their tag.
+func (f FieldError) Translate(trans ut.Translator) FieldError {
+	if f.err == nil {
+		if msg, err := trans.T(f.Tag, f.Field, f.Param); err == nil {
+			f.Message = msg
+		}
+		return f
+	}
+
+	if msg := f.err.Translate(trans); msg != "" {
+		f.Message = msg
+	}
+
+	return f
+}
+
+// Validation converts the error returned by validator into ErrValidation
+// with one FieldError per rejected field.
+func Validation(err error) *Error {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return ErrValidation.Wrap(err)
+	}
+
+	fields := make([]FieldError, 0, len(errs))
+	for _, fe := range errs {
+		fields = append(fields, FieldError{
+			Field:   fe.Field(),
+			Tag:     fe.Tag(),
+			Param:   fe.Param(),
+			Message: fmt.Sprintf("%s failed on the '%s' rule", fe.Field(), fe.Tag()),
+			err:     fe,
+		})
+	}
+
+	return ErrValidation.WithFields(fields...).Wrap(err)
+}
@@ -0,0 +1,90 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/response"
+)
+
+// Check reports whether a dependency, such as the database or a background
+// worker, is able to serve traffic.
+type Check func(ctx context.Context) error
+
+type Checker struct {
+	mu       sync.RWMutex
+	checks   map[string]Check
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+func New(timeout time.Duration) *Checker {
+	return &Checker{
+		checks:  map[string]Check{},
+		timeout: timeout,
+	}
+}
+
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks[name] = check
+}
+
+// Drain makes readiness fail so that load balancers stop sending traffic
+// while in-flight requests finish.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Liveness only tells that the process is up and serving HTTP.
+func (c *Checker) Liveness(w http.ResponseWriter, r *http.Request) {
+	response.Success(response.StatusOK, "alive").JSON(w)
+}
+
+// Readiness runs every registered check and fails if any of them does.
+func (c *Checker) Readiness(w http.ResponseWriter, r *http.Request) {
+	if c.draining.Load() {
+		response.Error(response.StatusServiceUnavailable, exception.ErrUnavailable).JSON(w)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), c.timeout)
+	defer cancel()
+
+	c.mu.RLock()
+	names := make([]string, 0, len(c.checks))
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		names = append(names, name)
+		checks[name] = check
+	}
+	c.mu.RUnlock()
+	sort.Strings(names)
+
+	var failed []exception.FieldError
+	for _, name := range names {
+		if err := checks[name](ctx); err != nil {
+			logger.FromContext(ctx).ErrorContext(ctx, "readiness check failed", "check", name, "error", err)
+			failed = append(failed, exception.FieldError{
+				Field:   name,
+				Tag:     "unhealthy",
+				Message: name + " is unhealthy",
+			})
+		}
+	}
+
+	if len(failed) > 0 {
+		response.Error(response.StatusServiceUnavailable, exception.ErrUnavailable.WithFields(failed...)).JSON(w)
+		return
+	}
+
+	response.Success(response.StatusOK, names).JSON(w)
+}
@@ -0,0 +1,158 @@
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/id"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	idTranslations "github.com/go-playground/validator/v10/translations/id"
+	"golang.org/x/text/language"
+)
+
+// Bundles are flat JSON objects, one file per locale, mapping an error code
+// or a validator tag to its message. They are meant to be edited directly.
+//
+//go:embed locales/*.json
+var bundles embed.FS
+
+const (
+	HeaderContentLanguage = "Content-Language"
+	DefaultLocale         = "en"
+)
+
+type ctxKey struct{}
+
+var universal = newUniversal()
+
+func newUniversal() *ut.UniversalTranslator {
+	fallback := en.New()
+	uni := ut.New(fallback, fallback, id.New())
+
+	if err := loadBundles(uni); err != nil {
+		panic(err)
+	}
+
+	return uni
+}
+
+func loadBundles(uni *ut.UniversalTranslator) error {
+	files, err := bundles.ReadDir("locales")
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		locale := strings.TrimSuffix(file.Name(), path.Ext(file.Name()))
+		trans, found := uni.GetTranslator(locale)
+		if !found {
+			continue
+		}
+
+		raw, err := bundles.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			return err
+		}
+
+		messages := map[string]string{}
+		if err := json.Unmarshal(raw, &messages); err != nil {
+			return err
+		}
+
+		for key, text := range messages {
+			if err := trans.Add(key, text, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// RegisterValidator installs the validator's built-in messages for every
+// supported locale. Bundle entries keyed by a validator tag take precedence.
+func RegisterValidator(v *validator.Validate) error {
+	registrations := map[string]func(*validator.Validate, ut.Translator) error{
+		"en": enTranslations.RegisterDefaultTranslations,
+		"id": idTranslations.RegisterDefaultTranslations,
+	}
+
+	for locale, register := range registrations {
+		trans, _ := universal.GetTranslator(locale)
+		if err := register(v, trans); err != nil {
+			return err
+		}
+	}
+
+	return loadBundles(universal)
+}
+
+// Translator returns the translator of locale, or the default one.
+func Translator(locale string) ut.Translator {
+	trans, _ := universal.FindTranslator(locale)
+	return trans
+}
+
+// Negotiate picks the best supported locale from an Accept-Language header.
+func Negotiate(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil {
+		return DefaultLocale
+	}
+
+	for _, tag := range tags {
+		base, _ := tag.Base()
+		if _, found := universal.GetTranslator(base.String()); found {
+			return base.String()
+		}
+	}
+
+	return DefaultLocale
+}
+
+// Message translates key, falling back to the given text when the bundle
+// of the translator has no entry for it. Params replace {0}, {1}, ... in
+// either text.
+func Message(trans ut.Translator, key, fallback string, params ...string) string {
+	text, err := trans.T(key, params...)
+	if err != nil || text == "" {
+		for i, param := range params {
+			fallback = strings.ReplaceAll(fallback, "{"+strconv.Itoa(i)+"}", param)
+		}
+		return fallback
+	}
+
+	return text
+}
+
+// Middleware negotiates the response language and announces it through the
+// Content-Language header, which the response package reads back.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale := Negotiate(r.Header.Get("Accept-Language"))
+
+		w.Header().Set(HeaderContentLanguage, locale)
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), locale)))
+	})
+}
+
+func NewContext(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, locale)
+}
+
+func FromContext(ctx context.Context) string {
+	locale, ok := ctx.Value(ctxKey{}).(string)
+	if !ok {
+		return DefaultLocale
+	}
+
+	return locale
+}
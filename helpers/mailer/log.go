@@ -0,0 +1,64 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/Risuii/helpers/logger"
+)
+
+type logMailer struct{}
+
+// NewLog writes every message to the request logger instead of sending it.
+// Links carry tokens in their query, which are redacted at INFO; the body
+// as it is is only logged at DEBUG.
+func NewLog() Mailer {
+	return logMailer{}
+}
+
+// linkQuery matches the query of a link in a body.
+var linkQuery = regexp.MustCompile(`(https?://[^\s?]+)\?\S+`)
+
+func (logMailer) Send(ctx context.Context, msg Message) error {
+	log := logger.FromContext(ctx)
+	log.InfoContext(ctx, "mail",
+		slog.String("to", msg.To),
+		slog.String("subject", msg.Subject),
+		slog.String("body", linkQuery.ReplaceAllString(msg.Body, "$1?REDACTED")),
+	)
+	log.DebugContext(ctx, "mail body",
+		slog.String("to", msg.To),
+		slog.String("body", msg.Body),
+	)
+
+	return nil
+}
+
+type fileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFile stores every message as an .eml file in dir, where it can be
+// opened with a mail client or read by tests.
+func NewFile(dir, from string) Mailer {
+	return &fileMailer{
+		dir:  dir,
+		from: from,
+	}
+}
+
+func (m *fileMailer) Send(ctx context.Context, msg Message) error {
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), filepath.Base(msg.To))
+
+	return os.WriteFile(filepath.Join(m.dir, name), format(m.from, msg), 0o644)
+}
@@ -0,0 +1,16 @@
+package mailer
+
+import "context"
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages. Use the log or file mailer in development and
+// tests, and SMTP in production.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
@@ -0,0 +1,233 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	// GIFs are decoded by image.Decode.
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/Risuii/helpers/exception"
+)
+
+// formOverhead is what a multipart body may carry on top of its file: the
+// boundaries, the part headers and small fields.
+const formOverhead = 64 << 10
+
+// Limits bound the images that are accepted.
+type Limits struct {
+	// MaxSize is the largest file, in bytes.
+	MaxSize int64
+	// MaxPixels is the largest width times height, which bounds the memory
+	// that decoding takes whatever the size of the file.
+	MaxPixels int
+	// ThumbnailSize is the largest side of thumbnails, in pixels.
+	ThumbnailSize int
+}
+
+// Image is an encoded image that was checked against Limits.
+type Image struct {
+	Data        []byte
+	ContentType string
+	// Ext is the file extension of ContentType, with its dot.
+	Ext    string
+	Width  int
+	Height int
+
+	decoded image.Image
+}
+
+// Thumbnail is img scaled by Fit. Thumbnails of JPEGs are JPEGs; those of
+// PNGs and GIFs are PNGs, of their first frame for animated GIFs.
+func (img Image) Thumbnail(size int) (Image, error) {
+	contentType := "image/png"
+	if img.ContentType == "image/jpeg" {
+		contentType = "image/jpeg"
+	}
+
+	scaled := Fit(img.decoded, size)
+	data, err := Encode(scaled, contentType)
+	if err != nil {
+		return Image{}, err
+	}
+
+	return Image{
+		Data:        data,
+		ContentType: contentType,
+		Ext:         extensions[contentType],
+		Width:       scaled.Bounds().Dx(),
+		Height:      scaled.Bounds().Dy(),
+		decoded:     scaled,
+	}, nil
+}
+
+// extensions are the accepted types, by their sniffed content type.
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// Read reads the file of the multipart field of r into memory. It fails
+// with ErrFileTooLarge past limits.MaxSize and with ErrValidation when the
+// field is missing. The content type claimed by the client is ignored; see
+// Decode.
+func Read(w http.ResponseWriter, r *http.Request, field string, limits Limits) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, limits.MaxSize+formOverhead)
+
+	form, err := r.MultipartReader()
+	if err != nil {
+		return nil, exception.ErrBadRequest.Wrap(err)
+	}
+
+	for {
+		part, err := form.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, exception.ErrValidation.WithFields(exception.FieldError{
+				Field:   field,
+				Tag:     "required",
+				Message: fmt.Sprintf("%s failed on the 'required' rule", field),
+			})
+		}
+		if err != nil {
+			return nil, readError(err)
+		}
+
+		if part.FormName() != field || part.FileName() == "" {
+			part.Close()
+			continue
+		}
+
+		return readPart(part, limits.MaxSize)
+	}
+}
+
+func readPart(part *multipart.Part, maxSize int64) ([]byte, error) {
+	defer part.Close()
+
+	data, err := io.ReadAll(io.LimitReader(part, maxSize+1))
+	if err != nil {
+		return nil, readError(err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, exception.ErrFileTooLarge
+	}
+
+	return data, nil
+}
+
+func readError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return exception.ErrFileTooLarge
+	}
+
+	return exception.ErrBadRequest.Wrap(err)
+}
+
+// Decode sniffs the type of data from its content and decodes it, failing
+// with ErrUnsupportedImage for anything but a JPEG, PNG or GIF image within
+// limits.MaxPixels.
+func Decode(data []byte, limits Limits) (Image, error) {
+	contentType := http.DetectContentType(data)
+	ext, ok := extensions[contentType]
+	if !ok {
+		return Image{}, exception.ErrUnsupportedImage
+	}
+
+	// The header is read first, so that a small file that claims huge
+	// dimensions is rejected before any pixel is allocated.
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, exception.ErrUnsupportedImage.Wrap(err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > limits.MaxPixels/cfg.Height {
+		return Image{}, exception.ErrUnsupportedImage
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, exception.ErrUnsupportedImage.Wrap(err)
+	}
+
+	return Image{
+		Data:        data,
+		ContentType: contentType,
+		Ext:         ext,
+		Width:       cfg.Width,
+		Height:      cfg.Height,
+		decoded:     decoded,
+	}, nil
+}
+
+// Encode writes img as JPEG or PNG, by contentType.
+func Encode(img image.Image, contentType string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+
+	switch contentType {
+	case "image/jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	case "image/png":
+		err = png.Encode(&buf, img)
+	default:
+		err = fmt.Errorf("media: cannot encode %s", contentType)
+	}
+
+	return buf.Bytes(), err
+}
+
+// Fit scales img down to fit in a square of size pixels, keeping its
+// aspect ratio. Every pixel of the result is the average of the pixels it
+// covers. Images that already fit are returned as they are.
+func Fit(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if size <= 0 || (w <= size && h <= size) {
+		return img
+	}
+
+	tw, th := size, max(1, h*size/w)
+	if h > w {
+		tw, th = max(1, w*size/h), size
+	}
+
+	// Pixels are averaged premultiplied, so that transparent ones do not
+	// darken the edges.
+	src := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := y*h/th, max((y+1)*h/th, y*h/th+1)
+		for x := 0; x < tw; x++ {
+			x0, x1 := x*w/tw, max((x+1)*w/tw, x*w/tw+1)
+
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride+x0*4 : sy*src.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += int(row[i])
+					sum[1] += int(row[i+1])
+					sum[2] += int(row[i+2])
+					sum[3] += int(row[i+3])
+				}
+			}
+
+			n := (y1 - y0) * (x1 - x0)
+			i := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[i+c] = uint8((sum[c] + n/2) / n)
+			}
+		}
+	}
+
+	return dst
+}
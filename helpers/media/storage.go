@@ -0,0 +1,132 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/Risuii/helpers/blob"
+	"github.com/Risuii/helpers/logger"
+)
+
+// Storage keeps uploaded images in a blob store, each under a key of its
+// own so that the files can be cached for good.
+type Storage struct {
+	blobs  blob.BlobStore
+	limits Limits
+}
+
+// Saved is an image kept by Storage. ThumbnailKey is empty when no
+// thumbnail was asked for.
+type Saved struct {
+	Key          string
+	ThumbnailKey string
+	ContentType  string
+	Width        int
+	Height       int
+	Size         int64
+}
+
+func NewStorage(blobs blob.BlobStore, limits Limits) *Storage {
+	return &Storage{
+		blobs:  blobs,
+		limits: limits,
+	}
+}
+
+// Limits are the limits the images are checked against.
+func (s *Storage) Limits() Limits {
+	return s.limits
+}
+
+// Save decodes data, see Decode, and stores it under a new key in dir,
+// along with its thumbnail when thumbnail is set.
+func (s *Storage) Save(ctx context.Context, dir string, data []byte, thumbnail bool) (Saved, error) {
+	img, err := Decode(data, s.limits)
+	if err != nil {
+		return Saved{}, err
+	}
+
+	name, err := randomName()
+	if err != nil {
+		return Saved{}, err
+	}
+
+	saved := Saved{
+		Key:         dir + "/" + name + img.Ext,
+		ContentType: img.ContentType,
+		Width:       img.Width,
+		Height:      img.Height,
+		Size:        int64(len(img.Data)),
+	}
+
+	if thumbnail {
+		thumb, err := img.Thumbnail(s.limits.ThumbnailSize)
+		if err != nil {
+			return Saved{}, err
+		}
+
+		saved.ThumbnailKey = dir + "/" + name + "_thumb" + thumb.Ext
+		if err := s.blobs.Put(ctx, saved.ThumbnailKey, bytes.NewReader(thumb.Data), int64(len(thumb.Data)), thumb.ContentType); err != nil {
+			return Saved{}, err
+		}
+	}
+
+	if err := s.blobs.Put(ctx, saved.Key, bytes.NewReader(img.Data), saved.Size, img.ContentType); err != nil {
+		s.Delete(ctx, saved.ThumbnailKey)
+		return Saved{}, err
+	}
+
+	return saved, nil
+}
+
+// URL is where clients download the file of key, or empty for an empty
+// key.
+func (s *Storage) URL(key string) string {
+	if key == "" {
+		return ""
+	}
+
+	return s.blobs.URL(key)
+}
+
+// Delete removes the files of keys, skipping empty ones. Failures are
+// logged and otherwise ignored: a file left behind is only wasted space.
+func (s *Storage) Delete(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+
+		if err := s.blobs.Delete(ctx, key); err != nil {
+			logger.FromContext(ctx).ErrorContext(ctx, "delete blob", "key", key, "error", err)
+		}
+	}
+}
+
+// Owns reports whether url points into the blob store, so that it must
+// only ever be set to the URL of a file that Save made.
+func (s *Storage) Owns(url string) bool {
+	return strings.HasPrefix(url, s.blobs.URL(""))
+}
+
+// DeleteURL removes the file served at url when it is one of s under dir.
+// URLs are stored as data and could point at files of others, so files
+// outside dir are left alone.
+func (s *Storage) DeleteURL(ctx context.Context, dir string, url string) {
+	key, ok := blob.KeyOf(s.blobs, url)
+	if ok && strings.HasPrefix(key, dir+"/") {
+		s.Delete(ctx, key)
+	}
+}
+
+func randomName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Risuii/config/jwt"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/requestid"
+)
+
+// AccessLog stores a request scoped logger in the context and writes one
+// log line per request once it has been served.
+func AccessLog(base *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			l := base.With(slog.String("request_id", requestid.FromContext(r.Context())))
+			sw := &statusWriter{ResponseWriter: w}
+
+			next.ServeHTTP(sw, r.WithContext(logger.NewContext(r.Context(), l)))
+
+			if sw.status == 0 {
+				sw.status = http.StatusOK
+			}
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", sw.status),
+				slog.Int("bytes", sw.bytes),
+				slog.Duration("latency", time.Since(start)),
+				slog.String("remote_addr", r.RemoteAddr),
+			}
+			if userID := userIDFromCookie(r); userID != 0 {
+				attrs = append(attrs, slog.Int64("user_id", userID))
+			}
+
+			level := slog.LevelInfo
+			if sw.status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+
+			l.LogAttrs(r.Context(), level, "http request", attrs...)
+		})
+	}
+}
+
+func userIDFromCookie(r *http.Request) int64 {
+	c, err := r.Cookie("token")
+	if err != nil {
+		return 0
+	}
+
+	claims, err := jwt.ParseClaims(c.Value)
+	if err != nil {
+		return 0
+	}
+
+	return claims.UserID
+}
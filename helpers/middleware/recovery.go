@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/response"
+)
+
+// Recover turns a panicking handler into the standard 500 envelope instead
+// of a dropped connection.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w}
+
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+
+			logger.FromContext(r.Context()).ErrorContext(r.Context(), "panic recovered",
+				"panic", fmt.Sprint(rec),
+				"stack", string(debug.Stack()),
+			)
+
+			if sw.status == 0 {
+				response.Error(response.StatusInternalServerError, exception.ErrInternalServer).JSON(w)
+			}
+		}()
+
+		next.ServeHTTP(sw, r)
+	})
+}
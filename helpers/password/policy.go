@@ -0,0 +1,111 @@
+package password
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Risuii/helpers/exception"
+)
+
+const (
+	MinLength = 8
+	// MaxLength is where bcrypt stops reading, longer passwords would be
+	// silently truncated.
+	MaxLength = 72
+)
+
+// common lists passwords that satisfy the length and character rules but
+// are among the first ones guessed.
+var common = map[string]struct{}{
+	"password1":   {},
+	"password12":  {},
+	"password123": {},
+	"passw0rd":    {},
+	"p4ssw0rd":    {},
+	"qwerty123":   {},
+	"qwertyuiop1": {},
+	"abc12345":    {},
+	"abcd1234":    {},
+	"1q2w3e4r":    {},
+	"1qaz2wsx":    {},
+	"zaq12wsx":    {},
+	"iloveyou1":   {},
+	"welcome1":    {},
+	"welcome123":  {},
+	"letmein1":    {},
+	"admin123":    {},
+	"sunshine1":   {},
+	"football1":   {},
+	"monkey123":   {},
+	"dragon123":   {},
+	"master123":   {},
+	"bismillah1":  {},
+	"indonesia1":  {},
+	"jakarta123":  {},
+}
+
+// Check applies the password policy to value, the content of the request
+// field named field. Personal holds strings tied to the account, such as
+// its email and name, which the password must not contain. The error lists
+// every rule that failed as a field error of exception.ErrValidation.
+func Check(field, value string, personal ...string) error {
+	var fields []exception.FieldError
+	fail := func(tag, param, message string) {
+		fields = append(fields, exception.FieldError{Field: field, Tag: tag, Param: param, Message: message})
+	}
+
+	length := len([]rune(value))
+	switch {
+	case length < MinLength:
+		fail("password_min", strconv.Itoa(MinLength), field+" must be at least "+strconv.Itoa(MinLength)+" characters long")
+	case len(value) > MaxLength:
+		fail("password_max", strconv.Itoa(MaxLength), field+" must be at most "+strconv.Itoa(MaxLength)+" bytes long")
+	}
+
+	var letter, digit bool
+	for _, r := range value {
+		letter = letter || unicode.IsLetter(r)
+		digit = digit || unicode.IsDigit(r) || unicode.IsPunct(r) || unicode.IsSymbol(r)
+	}
+	if !letter || !digit {
+		fail("password_mix", "", field+" must mix letters with digits or symbols")
+	}
+
+	lower := strings.ToLower(value)
+	if _, found := common[lower]; found {
+		fail("password_common", "", field+" is too common")
+	}
+
+	if containsPersonal(lower, personal) {
+		fail("password_personal", "", field+" must not contain your name or email")
+	}
+
+	if len(fields) > 0 {
+		return exception.ErrValidation.WithFields(fields...)
+	}
+
+	return nil
+}
+
+// containsPersonal reports whether lower contains a word of personal, where
+// an email only counts with its local part. Words shorter than four letters
+// are too likely to appear by chance.
+func containsPersonal(lower string, personal []string) bool {
+	for _, p := range personal {
+		p = strings.ToLower(p)
+		if at := strings.IndexByte(p, '@'); at >= 0 {
+			p = p[:at]
+		}
+
+		for _, word := range strings.FieldsFunc(p, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if len(word) >= 4 && strings.Contains(lower, word) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
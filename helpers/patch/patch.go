@@ -0,0 +1,155 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+
+	"github.com/Risuii/helpers/exception"
+)
+
+const MediaType = "application/merge-patch+json"
+
+// Field is one member of an RFC 7396 merge patch. Set is false when the
+// member was absent from the patch, and Null is true when it was sent as
+// null, which asks for the value to be removed.
+type Field[T any] struct {
+	Value T
+	Set   bool
+	Null  bool
+}
+
+type (
+	String  = Field[string]
+	Int64   = Field[int64]
+	Float64 = Field[float64]
+)
+
+func (f *Field[T]) UnmarshalJSON(b []byte) error {
+	f.Set = true
+	if bytes.Equal(b, []byte("null")) {
+		f.Null = true
+		return nil
+	}
+
+	return json.Unmarshal(b, &f.Value)
+}
+
+// Apply writes the patched value into dst when the member was present.
+// A null member resets dst to its zero value.
+func (f Field[T]) Apply(dst *T) {
+	if f.Set {
+		*dst = f.Value
+	}
+}
+
+// ApplyPtr is Apply for optional values: a null member sets dst to nil.
+func (f Field[T]) ApplyPtr(dst **T) {
+	if !f.Set {
+		return
+	}
+
+	if f.Null {
+		*dst = nil
+		return
+	}
+
+	v := f.Value
+	*dst = &v
+}
+
+func (f Field[T]) isNull() bool {
+	return f.Null
+}
+
+// pointer exposes the value to the validator: absent and null members are
+// nil so that "omitempty" skips them, anything else is validated as is.
+func (f Field[T]) pointer() interface{} {
+	if !f.Set || f.Null {
+		return (*T)(nil)
+	}
+
+	return &f.Value
+}
+
+type (
+	nullable interface{ isNull() bool }
+	valuer   interface{ pointer() interface{} }
+)
+
+// RegisterValidator teaches v to validate the value carried by a Field.
+func RegisterValidator(v *validator.Validate) {
+	v.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		if f, ok := field.Interface().(valuer); ok {
+			return f.pointer()
+		}
+		return nil
+	}, String{}, Int64{}, Float64{})
+}
+
+// Decode reads a merge patch document into dst, a pointer to a struct of
+// Fields. Only members tagged `patch:"nullable"` may be sent as null.
+func Decode(r io.Reader, dst interface{}) error {
+	var doc map[string]json.RawMessage
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return exception.ErrUnprocessableEntity.Wrap(err)
+	}
+
+	// RFC 7396 replaces the whole target when the patch is not an object,
+	// which is never what a partial update wants.
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return exception.ErrUnprocessableEntity.Wrap(err)
+	}
+	if doc == nil {
+		return exception.ErrUnprocessableEntity.Wrap(errors.New("the patch is null"))
+	}
+
+	if err := json.Unmarshal(raw, dst); err != nil {
+		return exception.ErrUnprocessableEntity.Wrap(err)
+	}
+
+	fields := notNull(reflect.Indirect(reflect.ValueOf(dst)), "")
+
+	if len(fields) > 0 {
+		return exception.ErrValidation.WithFields(fields...)
+	}
+
+	return nil
+}
+
+// notNull reports the members of rv sent as null without being nullable.
+// Nested structs are patches of nested objects and are checked as well,
+// their members named by path, e.g. "location.city".
+func notNull(rv reflect.Value, prefix string) []exception.FieldError {
+	var fields []exception.FieldError
+	for i := 0; i < rv.NumField(); i++ {
+		sf := rv.Type().Field(i)
+		name := prefix + strings.SplitN(sf.Tag.Get("json"), ",", 2)[0]
+
+		f, ok := rv.Field(i).Interface().(nullable)
+		if !ok {
+			if rv.Field(i).Kind() == reflect.Struct {
+				fields = append(fields, notNull(rv.Field(i), name+".")...)
+			}
+			continue
+		}
+		if !f.isNull() || sf.Tag.Get("patch") == "nullable" {
+			continue
+		}
+
+		fields = append(fields, exception.FieldError{
+			Field:   name,
+			Tag:     "notnull",
+			Message: fmt.Sprintf("%s cannot be removed", name),
+		})
+	}
+
+	return fields
+}
@@ -0,0 +1,45 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows bursts of up to Burst requests and refills the bucket at
+// Burst tokens per Per, e.g. 5/1m.
+type Limit struct {
+	Burst int
+	Per   time.Duration
+}
+
+// ParseLimit reads a limit written as <requests>/<duration>, e.g. 5/1m or
+// 100/1s.
+func ParseLimit(s string) (Limit, error) {
+	requests, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("%q must look like 5/1m", s)
+	}
+
+	burst, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil || burst < 1 {
+		return Limit{}, fmt.Errorf("%q must start with a positive number of requests", s)
+	}
+
+	d, err := time.ParseDuration(strings.TrimSpace(per))
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("%q must end with a positive duration such as 1m", s)
+	}
+
+	return Limit{Burst: burst, Per: d}, nil
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Burst, l.Per)
+}
+
+// interval is the time it takes to refill one token.
+func (l Limit) interval() time.Duration {
+	return l.Per / time.Duration(l.Burst)
+}
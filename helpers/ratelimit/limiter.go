@@ -0,0 +1,155 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Risuii/config/jwt"
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/metrics"
+	"github.com/Risuii/helpers/response"
+)
+
+// Limiter is one named set of token buckets sharing a limit.
+type Limiter struct {
+	store Store
+	name  string
+	limit Limit
+}
+
+func NewLimiter(store Store, name string, limit Limit) *Limiter {
+	return &Limiter{
+		store: store,
+		name:  name,
+		limit: limit,
+	}
+}
+
+// Allow takes a token from the bucket of key and returns ErrTooManyRequests
+// when it is empty. Limits fail open: when the store is unreachable the
+// request is let through.
+func (l *Limiter) Allow(ctx context.Context, key string) error {
+	ok, retryAfter, err := l.store.Take(ctx, "rl:"+l.name+":"+key, l.limit)
+	if err != nil {
+		logger.FromContext(ctx).WarnContext(ctx, "rate limit store", "limiter", l.name, "error", err)
+		return nil
+	}
+
+	if !ok {
+		metrics.RateLimited.WithLabelValues(l.name).Inc()
+		return exception.ErrTooManyRequests.WithRetryAfter(retryAfter)
+	}
+
+	return nil
+}
+
+// KeyFunc picks the bucket a request is counted against. An empty key
+// skips the limit.
+type KeyFunc func(r *http.Request) string
+
+// ByIP counts requests per client address.
+func ByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// ByUser counts requests per logged in account and skips anonymous ones.
+func ByUser(r *http.Request) string {
+	c, err := r.Cookie("token")
+	if err != nil {
+		return ""
+	}
+
+	claims, err := jwt.ParseClaims(c.Value)
+	if err != nil || claims.UserID == 0 {
+		return ""
+	}
+
+	return strconv.FormatInt(claims.UserID, 10)
+}
+
+// ByJSONField counts requests per value of a field of the JSON body, such
+// as the email a login is attempted for. The body is left intact for the
+// handler.
+func ByJSONField(name string) KeyFunc {
+	return func(r *http.Request) string {
+		if r.Body == nil {
+			return ""
+		}
+
+		raw, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(raw))
+		if err != nil {
+			return ""
+		}
+
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(raw, &fields) != nil {
+			return ""
+		}
+
+		var value string
+		if json.Unmarshal(fields[name], &value) != nil {
+			return ""
+		}
+
+		return strings.ToLower(strings.TrimSpace(value))
+	}
+}
+
+// Rule applies a limiter to the key chosen by Key.
+type Rule struct {
+	Limiter *Limiter
+	Key     KeyFunc
+}
+
+// DefaultRoute holds the rules of every route that has none of its own.
+const DefaultRoute = "*"
+
+// Middleware applies the rules listed for the matched route template. A
+// route listed without rules is not limited at all. It has to run after
+// routing, use it with router.Use.
+func Middleware(routes map[string][]Rule) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rules, ok := routes[DefaultRoute]
+			if route := mux.CurrentRoute(r); route != nil {
+				if template, err := route.GetPathTemplate(); err == nil {
+					if own, found := routes[template]; found {
+						rules, ok = own, true
+					}
+				}
+			}
+
+			if ok {
+				for _, rule := range rules {
+					key := rule.Key(r)
+					if key == "" {
+						continue
+					}
+
+					if err := rule.Limiter.Allow(r.Context(), key); err != nil {
+						response.Fail(err).JSON(w)
+						return
+					}
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
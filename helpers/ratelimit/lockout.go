@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/logger"
+)
+
+// Lockout locks an account for window once it collected max failed logins
+// within window of the first one.
+type Lockout struct {
+	store  Store
+	max    int64
+	window time.Duration
+}
+
+func NewLockout(store Store, max int, window time.Duration) *Lockout {
+	return &Lockout{
+		store:  store,
+		max:    int64(max),
+		window: window,
+	}
+}
+
+func lockoutKey(account string) string {
+	return "lockout:" + strings.ToLower(strings.TrimSpace(account))
+}
+
+// Check returns ErrAccountLocked while account is locked.
+func (l *Lockout) Check(ctx context.Context, account string) error {
+	count, remaining, err := l.store.Count(ctx, lockoutKey(account))
+	if err != nil {
+		logger.FromContext(ctx).WarnContext(ctx, "lockout store", "error", err)
+		return nil
+	}
+
+	if count >= l.max {
+		return exception.ErrAccountLocked.WithRetryAfter(remaining)
+	}
+
+	return nil
+}
+
+// Fail records a failed login and returns ErrAccountLocked when it was the
+// one that locked the account.
+func (l *Lockout) Fail(ctx context.Context, account string) error {
+	count, remaining, err := l.store.Incr(ctx, lockoutKey(account), l.window)
+	if err != nil {
+		logger.FromContext(ctx).WarnContext(ctx, "lockout store", "error", err)
+		return nil
+	}
+
+	if count >= l.max {
+		logger.FromContext(ctx).WarnContext(ctx, "account locked after failed logins", "failures", count)
+		return exception.ErrAccountLocked.WithRetryAfter(remaining)
+	}
+
+	return nil
+}
+
+// Reset forgets the failures of account after a successful login.
+func (l *Lockout) Reset(ctx context.Context, account string) {
+	if err := l.store.Reset(ctx, lockoutKey(account)); err != nil {
+		logger.FromContext(ctx).WarnContext(ctx, "lockout store", "error", err)
+	}
+}
@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store keeps token buckets and failure counters. The in-memory store is
+// per instance; use the Redis store to share limits between instances.
+type Store interface {
+	// Take removes a token from the bucket at key. When the bucket is
+	// empty it reports how long until the next token is available.
+	Take(ctx context.Context, key string, limit Limit) (ok bool, retryAfter time.Duration, err error)
+	// Incr adds one to the counter at key, which expires ttl after the
+	// first increment, and returns the count and its remaining lifetime.
+	Incr(ctx context.Context, key string, ttl time.Duration) (count int64, remaining time.Duration, err error)
+	// Count returns the counter at key, zero when it does not exist.
+	Count(ctx context.Context, key string) (count int64, remaining time.Duration, err error)
+	Reset(ctx context.Context, key string) error
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	expires time.Time
+}
+
+type counter struct {
+	count   int64
+	expires time.Time
+}
+
+type memoryStore struct {
+	mu       sync.Mutex
+	buckets  map[string]*bucket
+	counters map[string]*counter
+	now      func() time.Time
+	sweepAt  time.Time
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{
+		buckets:  make(map[string]*bucket),
+		counters: make(map[string]*counter),
+		now:      time.Now,
+	}
+}
+
+func (s *memoryStore) Take(ctx context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+
+	b.tokens += float64(now.Sub(b.updated)) / float64(limit.interval())
+	if b.tokens > float64(limit.Burst) {
+		b.tokens = float64(limit.Burst)
+	}
+	b.updated = now
+	// A bucket left alone for Per is full again and can be forgotten.
+	b.expires = now.Add(limit.Per)
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) * float64(limit.interval())), nil
+	}
+
+	b.tokens--
+
+	return true, 0, nil
+}
+
+func (s *memoryStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	c, ok := s.counters[key]
+	if !ok || !now.Before(c.expires) {
+		c = &counter{expires: now.Add(ttl)}
+		s.counters[key] = c
+	}
+	c.count++
+
+	return c.count, c.expires.Sub(now), nil
+}
+
+func (s *memoryStore) Count(ctx context.Context, key string) (int64, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	c, ok := s.counters[key]
+	if !ok || !now.Before(c.expires) {
+		return 0, 0, nil
+	}
+
+	return c.count, c.expires.Sub(now), nil
+}
+
+func (s *memoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.buckets, key)
+	delete(s.counters, key)
+
+	return nil
+}
+
+// sweep drops expired entries at most once a minute so that the maps do
+// not grow with every client ever seen.
+func (s *memoryStore) sweep(now time.Time) {
+	if now.Before(s.sweepAt) {
+		return
+	}
+	s.sweepAt = now.Add(time.Minute)
+
+	for key, b := range s.buckets {
+		if !now.Before(b.expires) {
+			delete(s.buckets, key)
+		}
+	}
+	for key, c := range s.counters {
+		if !now.Before(c.expires) {
+			delete(s.counters, key)
+		}
+	}
+}
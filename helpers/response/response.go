@@ -3,17 +3,51 @@ package response
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"time"
+
+	ut "github.com/go-playground/universal-translator"
+
+	"github.com/Risuii/helpers/etag"
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/i18n"
+	"github.com/Risuii/helpers/requestid"
+	"github.com/Risuii/helpers/tracing"
 )
 
 type Response interface {
 	Err() (err error)
 	JSON(w http.ResponseWriter) (err error)
+	WithETag(tag string) Response
 }
 
 type ResponseImpl struct {
 	err    error
 	Status string      `json:"status"`
 	Data   interface{} `json:"data"`
+	Error  *ErrorBody  `json:"error,omitempty"`
+	etag   string
+	// retryAfter is copied from the error and sent as Retry-After.
+	retryAfter time.Duration
+}
+
+// ErrorBody is the error envelope sent with every failed response.
+type ErrorBody struct {
+	Code      string                 `json:"code"`
+	Message   string                 `json:"message"`
+	Fields    []exception.FieldError `json:"fields,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+	TraceID   string                 `json:"trace_id,omitempty"`
+}
+
+func (body *ErrorBody) translate(trans ut.Translator) {
+	body.Message = i18n.Message(trans, body.Code, body.Message)
+
+	fields := make([]exception.FieldError, 0, len(body.Fields))
+	for _, field := range body.Fields {
+		fields = append(fields, field.Translate(trans))
+	}
+	body.Fields = fields
 }
 
 func Success(status string, data interface{}) (resp Response) {
@@ -25,35 +59,34 @@ func Success(status string, data interface{}) (resp Response) {
 }
 
 func Error(status string, err error) (resp Response) {
+	e := exception.From(err)
+
 	return &ResponseImpl{
 		err:    err,
 		Status: status,
 		Data:   nil,
+		Error: &ErrorBody{
+			Code:    e.Code,
+			Message: e.Message,
+			Fields:  e.Fields,
+		},
+		retryAfter: e.RetryAfter,
 	}
 }
 
+// Fail builds an error response whose status is taken from the kind of err.
+func Fail(err error) (resp Response) {
+	return Error(string(exception.KindOf(err)), err)
+}
+
 func (r *ResponseImpl) getStatusCode(status string) (statusCode int) {
 	switch status {
 	case StatusOK:
 		return http.StatusOK
 	case StatusCreated:
 		return http.StatusCreated
-	case StatusBadRequest:
-		return http.StatusBadRequest
-	case StatusUnauthorized:
-		return http.StatusUnauthorized
-	case StatusForbiddend:
-		return http.StatusForbidden
-	case StatusNotFound:
-		return http.StatusNotFound
-	case StatusConflicted:
-		return http.StatusConflict
-	case StatusUnprocessableEntity:
-		return http.StatusUnprocessableEntity
-	case StatusInternalServerError:
-		return http.StatusInternalServerError
 	default:
-		return http.StatusInternalServerError
+		return exception.Kind(status).HTTPStatus()
 	}
 }
 
@@ -61,8 +94,29 @@ func (r *ResponseImpl) Err() (err error) {
 	return r.err
 }
 
+// WithETag sets the entity tag sent along with a successful response.
+func (r *ResponseImpl) WithETag(tag string) Response {
+	r.etag = tag
+	return r
+}
+
 func (r *ResponseImpl) JSON(w http.ResponseWriter) error {
 	statusCode := r.getStatusCode(r.Status)
+	if r.Error != nil {
+		r.Error.RequestID = w.Header().Get(requestid.Header)
+		r.Error.TraceID = w.Header().Get(tracing.HeaderTraceID)
+		r.Error.translate(i18n.Translator(w.Header().Get(i18n.HeaderContentLanguage)))
+	}
+
+	if r.etag != "" && r.Error == nil {
+		w.Header().Set(etag.HeaderETag, r.etag)
+	}
+
+	if r.retryAfter > 0 {
+		seconds := int64((r.retryAfter + time.Second - 1) / time.Second)
+		w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 
@@ -1,13 +1,21 @@
 package response
 
+import "github.com/Risuii/helpers/exception"
+
 const (
-	StatusOK                  = "OK"
-	StatusCreated             = "CREATED"
-	StatusBadRequest          = "BAD_REQUEST"
-	StatusUnauthorized        = "UNAUTHORIZED"
-	StatusForbiddend          = "FORBIDDEN"
-	StatusNotFound            = "NOT_FOUND"
-	StatusConflicted          = "CONFLICTED"
-	StatusUnprocessableEntity = "UNPROCESSABLE_ENTITY"
-	StatusInternalServerError = "INTERNAL_SERVER_ERROR"
+	StatusOK                   = "OK"
+	StatusCreated              = "CREATED"
+	StatusBadRequest           = string(exception.KindBadRequest)
+	StatusUnauthorized         = string(exception.KindUnauthorized)
+	StatusForbiddend           = string(exception.KindForbidden)
+	StatusNotFound             = string(exception.KindNotFound)
+	StatusConflicted           = string(exception.KindConflicted)
+	StatusUnprocessableEntity  = string(exception.KindUnprocessableEntity)
+	StatusPreconditionFailed   = string(exception.KindPreconditionFailed)
+	StatusPreconditionNeeded   = string(exception.KindPreconditionNeeded)
+	StatusTooManyRequests      = string(exception.KindTooManyRequests)
+	StatusPayloadTooLarge      = string(exception.KindPayloadTooLarge)
+	StatusUnsupportedMediaType = string(exception.KindUnsupportedMediaType)
+	StatusServiceUnavailable   = string(exception.KindServiceUnavailable)
+	StatusInternalServerError  = string(exception.KindInternalServer)
 )
@@ -0,0 +1,127 @@
+package retry
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Policy retries an operation with exponential backoff and full jitter.
+type Policy struct {
+	// Attempts is the maximum number of calls, zero means until the
+	// context is done.
+	Attempts int
+	Initial  time.Duration
+	Max      time.Duration
+	// Retryable decides which errors are worth another attempt, nil
+	// retries every error.
+	Retryable func(error) bool
+}
+
+// Default is used by the repositories and is set from the configuration at
+// startup. It only retries errors after which the statement is known not to
+// have been applied, so that it is safe around INSERTs and whole
+// transactions alike, as long as their Commit is marked Final.
+var Default = Policy{
+	Attempts:  3,
+	Initial:   50 * time.Millisecond,
+	Max:       time.Second,
+	Retryable: Transient,
+}
+
+func Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return Default.Do(ctx, fn)
+}
+
+func (p Policy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	backoff := p.Initial
+
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+
+		var f final
+		if errors.As(err, &f) {
+			return f.err
+		}
+
+		if p.Retryable != nil && !p.Retryable(err) {
+			return err
+		}
+		if p.Attempts > 0 && attempt >= p.Attempts {
+			return err
+		}
+
+		timer := time.NewTimer(time.Duration(rand.Int63n(int64(backoff) + 1)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if backoff > p.Max {
+			backoff = p.Max
+		}
+	}
+}
+
+// final is an error that is returned without another attempt.
+type final struct{ err error }
+
+func (f final) Error() string { return f.err.Error() }
+func (f final) Unwrap() error { return f.err }
+
+// Final marks err as not to be retried whatever it is. It is meant for the
+// Commit of a transaction: once it is sent, a broken connection does not
+// tell whether it was applied, and running the transaction again could
+// apply it twice.
+func Final(err error) error {
+	if err == nil {
+		return nil
+	}
+	return final{err}
+}
+
+// MySQL errors after which the server rolled the statement back, or
+// refused the connection before any statement was sent.
+const (
+	errLockWaitTimeout    = 1205
+	errDeadlock           = 1213
+	errTooManyConnections = 1040
+)
+
+// Transient reports whether err is a failure to reach the server or a
+// MySQL error that is expected to go away on its own, after which the
+// statement was not applied. mysql.ErrInvalidConn is left out: the
+// connection broke once the statement was sent, which may or may not have
+// been applied, and an INSERT run again would be applied twice.
+func Transient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case errLockWaitTimeout, errDeadlock, errTooManyConnections:
+			return true
+		}
+		return false
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
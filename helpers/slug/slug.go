@@ -0,0 +1,45 @@
+package slug
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxLength leaves room in a slug for the suffix that makes it unique.
+const MaxLength = 64
+
+// Make turns s into a URL slug: lower case ASCII letters and digits joined
+// by single dashes. Accents are dropped, so "Kopi Café" becomes
+// "kopi-cafe". It returns fallback when nothing of s is left.
+func Make(s string, fallback string) string {
+	var b strings.Builder
+	dash := false
+
+	for _, r := range norm.NFKD.String(s) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			dash = true
+		}
+
+		if b.Len() >= MaxLength {
+			break
+		}
+	}
+
+	out := strings.TrimRight(b.String()[:min(b.Len(), MaxLength)], "-")
+	if out == "" {
+		return fallback
+	}
+
+	return out
+}
@@ -0,0 +1,103 @@
+package stmtcache
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// Cache prepares each query the first time it is used and hands out the
+// same *sql.Stmt afterwards. database/sql re-prepares a statement on every
+// pooled connection it ends up running on, so one Stmt per query is enough.
+type Cache struct {
+	db    *sql.DB
+	mu    sync.RWMutex
+	stmts map[string]*sql.Stmt
+}
+
+func New(db *sql.DB) *Cache {
+	return &Cache{
+		db:    db,
+		stmts: make(map[string]*sql.Stmt),
+	}
+}
+
+var (
+	sharedMu sync.Mutex
+	shared   = make(map[*sql.DB]*Cache)
+)
+
+// For returns the cache shared by every repository of db, so that all of
+// its statements can be closed at once with Release.
+func For(db *sql.DB) *Cache {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+
+	c, ok := shared[db]
+	if !ok {
+		c = New(db)
+		shared[db] = c
+	}
+
+	return c
+}
+
+// Release closes the statements of the shared cache of db and forgets it.
+// It is meant for shutdown, once no request can use them anymore.
+func Release(db *sql.DB) error {
+	sharedMu.Lock()
+	c, ok := shared[db]
+	delete(shared, db)
+	sharedMu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	return c.Close()
+}
+
+// Prepare returns the cached statement for query, preparing it on first
+// use. Failed prepares are not cached. The lock is not held while the
+// database prepares, so callers racing on a new query may each prepare it;
+// the first one cached wins and the others are closed.
+func (c *Cache) Prepare(ctx context.Context, query string) (*sql.Stmt, error) {
+	c.mu.RLock()
+	stmt, ok := c.stmts[query]
+	c.mu.RUnlock()
+	if ok {
+		return stmt, nil
+	}
+
+	stmt, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.stmts[query]; ok {
+		stmt.Close()
+		return cached, nil
+	}
+	c.stmts[query] = stmt
+
+	return stmt, nil
+}
+
+// Close closes every cached statement.
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var first error
+	for query, stmt := range c.stmts {
+		if err := stmt.Close(); err != nil && first == nil {
+			first = err
+		}
+		delete(c.stmts, query)
+	}
+
+	return first
+}
@@ -0,0 +1,86 @@
+package account
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Risuii/helpers/i18n"
+	"github.com/Risuii/helpers/mailer"
+	"github.com/Risuii/helpers/signedtoken"
+	"github.com/Risuii/models/account"
+)
+
+// EmailChanges mails signed links that confirm a new email address. A link
+// names both the old and the new address, so it stops working once the
+// account has moved to either.
+type EmailChanges struct {
+	signer    *signedtoken.Signer
+	mailer    mailer.Mailer
+	publicURL string
+	ttl       time.Duration
+}
+
+type emailChangeClaims struct {
+	UserID int64  `json:"uid"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+func NewEmailChanges(secret []byte, mail mailer.Mailer, publicURL string, ttl time.Duration) *EmailChanges {
+	return &EmailChanges{
+		signer:    signedtoken.New(secret, "email-change"),
+		mailer:    mail,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		ttl:       ttl,
+	}
+}
+
+// Send mails a confirmation link for moving user to the address to, in the
+// language of the request.
+func (c *EmailChanges) Send(ctx context.Context, user account.Account, to string) error {
+	token, err := c.signer.Sign(emailChangeClaims{UserID: user.ID, From: user.Email, To: to}, c.ttl)
+	if err != nil {
+		return err
+	}
+
+	link := c.publicURL + "/confirm-email?token=" + url.QueryEscape(token)
+	trans := i18n.Translator(i18n.FromContext(ctx))
+	hours := strconv.Itoa(int(c.ttl.Hours()))
+
+	return c.mailer.Send(ctx, mailer.Message{
+		To:      to,
+		Subject: i18n.Message(trans, "MAIL_CHANGE_EMAIL_SUBJECT", "Confirm your new email address"),
+		// {0} is the name of the user, {1} the link and {2} its lifetime in hours.
+		Body: i18n.Message(trans, "MAIL_CHANGE_EMAIL_BODY",
+			"Hi {0},\n\nPlease confirm that this is the new email address of your account by opening the link below:\n\n{1}\n\nThe link is valid for {2} hours.\n",
+			user.Name, link, hours),
+	})
+}
+
+// Notify tells the previous address of user that the account moved away
+// from it, in case the change was not wanted.
+func (c *EmailChanges) Notify(ctx context.Context, user account.Account, from string) error {
+	trans := i18n.Translator(i18n.FromContext(ctx))
+
+	return c.mailer.Send(ctx, mailer.Message{
+		To:      from,
+		Subject: i18n.Message(trans, "MAIL_EMAIL_CHANGED_SUBJECT", "Your email address was changed"),
+		// {0} is the name of the user and {1} the new address.
+		Body: i18n.Message(trans, "MAIL_EMAIL_CHANGED_BODY",
+			"Hi {0},\n\nThe email address of your account was changed to {1}.\n\nIf you did not do this, reset your password and contact us.\n",
+			user.Name, user.Email),
+	})
+}
+
+// Check returns the account and the addresses a token was issued for.
+func (c *EmailChanges) Check(token string) (int64, string, string, error) {
+	var claims emailChangeClaims
+	if err := c.signer.Verify(token, &claims); err != nil {
+		return 0, "", "", err
+	}
+
+	return claims.UserID, claims.From, claims.To, nil
+}
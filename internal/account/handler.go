@@ -4,12 +4,13 @@ import (
 	"encoding/json"
 	"net/http"
 
-	newJWT "github.com/dgrijalva/jwt-go"
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 
 	"github.com/Risuii/config/jwt"
+	"github.com/Risuii/helpers/etag"
 	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/patch"
 	"github.com/Risuii/helpers/response"
 	"github.com/Risuii/models/account"
 )
@@ -29,7 +30,19 @@ func NewAbsensiHandler(router *mux.Router, validate *validator.Validate, usecase
 
 	router.HandleFunc("/register", handler.Register).Methods(http.MethodPost)
 	router.HandleFunc("/login", handler.Login).Methods(http.MethodPost)
+	router.HandleFunc("/login/mfa", handler.LoginMFA).Methods(http.MethodPost)
+	router.HandleFunc("/verify-email", handler.VerifyEmail).Methods(http.MethodGet)
+	router.HandleFunc("/forgot-password", handler.ForgotPassword).Methods(http.MethodPost)
+	router.HandleFunc("/reset-password", handler.ResetPassword).Methods(http.MethodPost)
+	router.HandleFunc("/confirm-email", handler.ConfirmEmail).Methods(http.MethodGet)
+	api.HandleFunc("/verify-email/resend", handler.ResendVerification).Methods(http.MethodPost)
 	api.HandleFunc("/update", handler.Update).Methods(http.MethodPatch)
+	api.HandleFunc("/password", handler.ChangePassword).Methods(http.MethodPut)
+	api.HandleFunc("/email", handler.ChangeEmail).Methods(http.MethodPost)
+	api.HandleFunc("/mfa/enroll", handler.EnrollMFA).Methods(http.MethodPost)
+	api.HandleFunc("/mfa/confirm", handler.ConfirmMFA).Methods(http.MethodPost)
+	api.HandleFunc("/mfa/disable", handler.DisableMFA).Methods(http.MethodPost)
+	api.HandleFunc("/mfa/recovery-codes", handler.RegenerateRecoveryCodes).Methods(http.MethodPost)
 	api.HandleFunc("/profile", handler.ReadOne).Methods(http.MethodGet)
 	api.HandleFunc("/delete", handler.Delete).Methods(http.MethodDelete)
 	api.HandleFunc("/logout", handler.Logout).Methods(http.MethodGet)
@@ -42,14 +55,14 @@ func (handler *AccountHandler) Register(w http.ResponseWriter, r *http.Request)
 	ctx := r.Context()
 
 	if err := json.NewDecoder(r.Body).Decode(&userInput); err != nil {
-		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity)
+		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity.Wrap(err))
 		res.JSON(w)
 		return
 	}
 
 	err := handler.Validate.StructCtx(ctx, userInput)
 	if err != nil {
-		res = response.Error(response.StatusBadRequest, exception.ErrBadRequest)
+		res = response.Error(response.StatusBadRequest, exception.Validation(err))
 		res.JSON(w)
 		return
 	}
@@ -66,14 +79,14 @@ func (handler *AccountHandler) Login(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	if err := json.NewDecoder(r.Body).Decode(&userInput); err != nil {
-		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity)
+		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity.Wrap(err))
 		res.JSON(w)
 		return
 	}
 
 	err := handler.Validate.StructCtx(ctx, userInput)
 	if err != nil {
-		res = response.Error(response.StatusBadRequest, exception.ErrBadRequest)
+		res = response.Error(response.StatusBadRequest, exception.Validation(err))
 		res.JSON(w)
 		return
 	}
@@ -102,7 +115,7 @@ func (handler *AccountHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 func (handler *AccountHandler) Update(w http.ResponseWriter, r *http.Request) {
 	var res response.Response
-	var userInput account.Account
+	var userInput account.AccountUpdate
 
 	c, err := r.Cookie("token")
 	if err != nil {
@@ -111,29 +124,36 @@ func (handler *AccountHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tokenString := c.Value
-	claims := &jwt.JWTclaim{}
+	claims, err := jwt.ParseClaims(c.Value)
+	if err != nil {
+		res = response.Error(response.StatusUnauthorized, exception.ErrUnauthorized)
+		res.JSON(w)
+		return
+	}
 
-	newJWT.ParseWithClaims(tokenString, claims, func(t *newJWT.Token) (interface{}, error) {
-		return jwt.JWT_KEY, nil
-	})
+	version, err := etag.IfMatch(r)
+	if err != nil {
+		res = response.Fail(err)
+		res.JSON(w)
+		return
+	}
 
 	ctx := r.Context()
 
-	if err := json.NewDecoder(r.Body).Decode(&userInput); err != nil {
-		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity)
+	if err := patch.Decode(r.Body, &userInput); err != nil {
+		res = response.Fail(err)
 		res.JSON(w)
 		return
 	}
 
 	err = handler.Validate.StructCtx(ctx, userInput)
 	if err != nil {
-		res = response.Error(response.StatusBadRequest, exception.ErrBadRequest)
+		res = response.Error(response.StatusBadRequest, exception.Validation(err))
 		res.JSON(w)
 		return
 	}
 
-	res = handler.UseCase.Update(ctx, claims.ID, userInput)
+	res = handler.UseCase.Update(ctx, claims.ID, version, userInput)
 
 	res.JSON(w)
 }
@@ -150,12 +170,12 @@ func (handler *AccountHandler) ReadOne(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tokenString := c.Value
-	claims := &jwt.JWTclaim{}
-
-	newJWT.ParseWithClaims(tokenString, claims, func(t *newJWT.Token) (interface{}, error) {
-		return jwt.JWT_KEY, nil
-	})
+	claims, err := jwt.ParseClaims(c.Value)
+	if err != nil {
+		res = response.Error(response.StatusUnauthorized, exception.ErrUnauthorized)
+		res.JSON(w)
+		return
+	}
 
 	res = handler.UseCase.ReadOne(ctx, claims.ID)
 
@@ -174,14 +194,21 @@ func (handler *AccountHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tokenString := c.Value
-	claims := &jwt.JWTclaim{}
+	claims, err := jwt.ParseClaims(c.Value)
+	if err != nil {
+		res = response.Error(response.StatusUnauthorized, exception.ErrUnauthorized)
+		res.JSON(w)
+		return
+	}
 
-	newJWT.ParseWithClaims(tokenString, claims, func(t *newJWT.Token) (interface{}, error) {
-		return jwt.JWT_KEY, nil
-	})
+	version, err := etag.IfMatch(r)
+	if err != nil {
+		res = response.Fail(err)
+		res.JSON(w)
+		return
+	}
 
-	res = handler.UseCase.Delete(ctx, claims.ID)
+	res = handler.UseCase.Delete(ctx, claims.ID, version)
 
 	res.JSON(w)
 }
@@ -210,3 +237,322 @@ func (handler *AccountHandler) Logout(w http.ResponseWriter, r *http.Request) {
 
 	res.JSON(w)
 }
+
+func (handler *AccountHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		res = response.Fail(exception.ErrInvalidToken)
+		res.JSON(w)
+		return
+	}
+
+	res = handler.UseCase.VerifyEmail(ctx, token)
+
+	res.JSON(w)
+}
+
+func (handler *AccountHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	c, err := r.Cookie("token")
+	if err != nil {
+		res = response.Error(response.StatusUnauthorized, exception.ErrUnauthorized)
+		res.JSON(w)
+		return
+	}
+
+	claims, err := jwt.ParseClaims(c.Value)
+	if err != nil {
+		res = response.Error(response.StatusUnauthorized, exception.ErrUnauthorized)
+		res.JSON(w)
+		return
+	}
+
+	res = handler.UseCase.ResendVerification(ctx, claims.ID)
+
+	res.JSON(w)
+}
+
+func (handler *AccountHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+	var userInput account.ForgotPassword
+
+	ctx := r.Context()
+
+	if err := json.NewDecoder(r.Body).Decode(&userInput); err != nil {
+		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity.Wrap(err))
+		res.JSON(w)
+		return
+	}
+
+	err := handler.Validate.StructCtx(ctx, userInput)
+	if err != nil {
+		res = response.Error(response.StatusBadRequest, exception.Validation(err))
+		res.JSON(w)
+		return
+	}
+
+	res = handler.UseCase.ForgotPassword(ctx, userInput)
+
+	res.JSON(w)
+}
+
+func (handler *AccountHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+	var userInput account.ResetPassword
+
+	ctx := r.Context()
+
+	if err := json.NewDecoder(r.Body).Decode(&userInput); err != nil {
+		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity.Wrap(err))
+		res.JSON(w)
+		return
+	}
+
+	err := handler.Validate.StructCtx(ctx, userInput)
+	if err != nil {
+		res = response.Error(response.StatusBadRequest, exception.Validation(err))
+		res.JSON(w)
+		return
+	}
+
+	res = handler.UseCase.ResetPassword(ctx, userInput)
+
+	res.JSON(w)
+}
+
+func (handler *AccountHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+	var userInput account.ChangePassword
+
+	ctx := r.Context()
+
+	c, err := r.Cookie("token")
+	if err != nil {
+		res = response.Error(response.StatusUnauthorized, exception.ErrUnauthorized)
+		res.JSON(w)
+		return
+	}
+
+	claims, err := jwt.ParseClaims(c.Value)
+	if err != nil {
+		res = response.Error(response.StatusUnauthorized, exception.ErrUnauthorized)
+		res.JSON(w)
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&userInput); err != nil {
+		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity.Wrap(err))
+		res.JSON(w)
+		return
+	}
+
+	err = handler.Validate.StructCtx(ctx, userInput)
+	if err != nil {
+		res = response.Error(response.StatusBadRequest, exception.Validation(err))
+		res.JSON(w)
+		return
+	}
+
+	res, token := handler.UseCase.ChangePassword(ctx, claims.ID, userInput)
+
+	// Every other session was logged out, this one goes on with a new token.
+	if token.Token != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     "token",
+			Path:     "/",
+			Value:    token.Token,
+			HttpOnly: true,
+		})
+	}
+
+	res.JSON(w)
+}
+
+func (handler *AccountHandler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+	var userInput account.ChangeEmail
+
+	ctx := r.Context()
+
+	c, err := r.Cookie("token")
+	if err != nil {
+		res = response.Error(response.StatusUnauthorized, exception.ErrUnauthorized)
+		res.JSON(w)
+		return
+	}
+
+	claims, err := jwt.ParseClaims(c.Value)
+	if err != nil {
+		res = response.Error(response.StatusUnauthorized, exception.ErrUnauthorized)
+		res.JSON(w)
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&userInput); err != nil {
+		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity.Wrap(err))
+		res.JSON(w)
+		return
+	}
+
+	err = handler.Validate.StructCtx(ctx, userInput)
+	if err != nil {
+		res = response.Error(response.StatusBadRequest, exception.Validation(err))
+		res.JSON(w)
+		return
+	}
+
+	res = handler.UseCase.ChangeEmail(ctx, claims.ID, userInput)
+
+	res.JSON(w)
+}
+
+func (handler *AccountHandler) ConfirmEmail(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		res = response.Fail(exception.ErrInvalidToken)
+		res.JSON(w)
+		return
+	}
+
+	res = handler.UseCase.ConfirmEmail(ctx, token)
+
+	res.JSON(w)
+}
+
+func (handler *AccountHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+	var userInput account.MFALogin
+
+	ctx := r.Context()
+
+	if err := json.NewDecoder(r.Body).Decode(&userInput); err != nil {
+		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity.Wrap(err))
+		res.JSON(w)
+		return
+	}
+
+	err := handler.Validate.StructCtx(ctx, userInput)
+	if err != nil {
+		res = response.Error(response.StatusBadRequest, exception.Validation(err))
+		res.JSON(w)
+		return
+	}
+
+	res, token := handler.UseCase.LoginMFA(ctx, userInput)
+
+	if token.Token != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     "token",
+			Path:     "/",
+			Value:    token.Token,
+			HttpOnly: true,
+		})
+	}
+
+	res.JSON(w)
+}
+
+func (handler *AccountHandler) EnrollMFA(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	c, err := r.Cookie("token")
+	if err != nil {
+		res = response.Error(response.StatusUnauthorized, exception.ErrUnauthorized)
+		res.JSON(w)
+		return
+	}
+
+	claims, err := jwt.ParseClaims(c.Value)
+	if err != nil {
+		res = response.Error(response.StatusUnauthorized, exception.ErrUnauthorized)
+		res.JSON(w)
+		return
+	}
+
+	res = handler.UseCase.EnrollMFA(ctx, claims.ID)
+
+	res.JSON(w)
+}
+
+func (handler *AccountHandler) ConfirmMFA(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+	var userInput account.MFACode
+
+	ctx := r.Context()
+
+	claims, ok := handler.decodeAuthenticated(w, r, &userInput)
+	if !ok {
+		return
+	}
+
+	res = handler.UseCase.ConfirmMFA(ctx, claims.ID, userInput)
+
+	res.JSON(w)
+}
+
+func (handler *AccountHandler) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+	var userInput account.MFADisable
+
+	ctx := r.Context()
+
+	claims, ok := handler.decodeAuthenticated(w, r, &userInput)
+	if !ok {
+		return
+	}
+
+	res = handler.UseCase.DisableMFA(ctx, claims.ID, userInput)
+
+	res.JSON(w)
+}
+
+func (handler *AccountHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+	var userInput account.MFACode
+
+	ctx := r.Context()
+
+	claims, ok := handler.decodeAuthenticated(w, r, &userInput)
+	if !ok {
+		return
+	}
+
+	res = handler.UseCase.RegenerateRecoveryCodes(ctx, claims.ID, userInput)
+
+	res.JSON(w)
+}
+
+// decodeAuthenticated reads the session claims and the JSON body into dst
+// and validates it. It answers the request itself and returns false when
+// any of that fails.
+func (handler *AccountHandler) decodeAuthenticated(w http.ResponseWriter, r *http.Request, dst interface{}) (*jwt.JWTclaim, bool) {
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity.Wrap(err)).JSON(w)
+		return nil, false
+	}
+
+	if err := handler.Validate.StructCtx(r.Context(), dst); err != nil {
+		response.Error(response.StatusBadRequest, exception.Validation(err)).JSON(w)
+		return nil, false
+	}
+
+	return claims, true
+}
@@ -0,0 +1,243 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/retry"
+	"github.com/Risuii/helpers/stmtcache"
+	"github.com/Risuii/models/account"
+)
+
+type (
+	IdentityRepository interface {
+		Create(ctx context.Context, params account.Identity) error
+		Register(ctx context.Context, user account.Account, identity account.Identity) (int64, error)
+		FindBySubject(ctx context.Context, provider, subject string) (account.Identity, error)
+		FindByUserID(ctx context.Context, userID int64) ([]account.Identity, error)
+		Delete(ctx context.Context, userID int64, provider string) error
+	}
+
+	identityRepositoryImpl struct {
+		db            *sql.DB
+		tableName     string
+		accountsTable string
+		stmts         *stmtcache.Cache
+	}
+)
+
+func NewIdentityRepositoryImpl(db *sql.DB, tableName string, accountsTable string) IdentityRepository {
+	return &identityRepositoryImpl{
+		db:            db,
+		tableName:     tableName,
+		accountsTable: accountsTable,
+		stmts:         stmtcache.For(db),
+	}
+}
+
+// Create fails with ErrIdentityLinked when the subject is linked already,
+// or when the account has an identity at the provider.
+func (ir *identityRepositoryImpl) Create(ctx context.Context, params account.Identity) error {
+	query := fmt.Sprintf(`INSERT INTO %s (userID, provider, subject, email, created_at) VALUES (?, ?, ?, ?, ?)`, ir.tableName)
+	stmt, err := ir.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		_, err := stmt.ExecContext(
+			ctx,
+			params.UserID,
+			params.Provider,
+			params.Subject,
+			params.Email,
+			params.CreatedAt,
+		)
+		return err
+	})
+	if isDuplicate(err) {
+		return exception.ErrIdentityLinked
+	}
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	return nil
+}
+
+// Register creates user with its email verified at user.CreatedAt and links
+// identity to it, in one transaction so that a failed link leaves no
+// account behind. It returns the ID of the account, and fails with
+// ErrEmailTaken or ErrIdentityLinked.
+func (ir *identityRepositoryImpl) Register(ctx context.Context, user account.Account, identity account.Identity) (int64, error) {
+	accountStmt, err := ir.stmts.Prepare(ctx, fmt.Sprintf(`INSERT INTO %s(name, password, email, address, created_at, verified_at) VALUES (?, ?, ?, ?, ?, ?)`, ir.accountsTable))
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.accountsTable, "error", err)
+		return 0, exception.ErrInternalServer
+	}
+
+	identityStmt, err := ir.stmts.Prepare(ctx, fmt.Sprintf(`INSERT INTO %s (userID, provider, subject, email, created_at) VALUES (?, ?, ?, ?, ?)`, ir.tableName))
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+		return 0, exception.ErrInternalServer
+	}
+
+	var userID int64
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		tx, err := ir.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		result, err := tx.StmtContext(ctx, accountStmt).ExecContext(
+			ctx,
+			user.Name,
+			user.Password,
+			user.Email,
+			user.Address,
+			user.CreatedAt,
+			user.CreatedAt,
+		)
+		if isDuplicate(err) {
+			return exception.ErrEmailTaken
+		}
+		if err != nil {
+			return err
+		}
+		userID, _ = result.LastInsertId()
+
+		_, err = tx.StmtContext(ctx, identityStmt).ExecContext(
+			ctx,
+			userID,
+			identity.Provider,
+			identity.Subject,
+			identity.Email,
+			identity.CreatedAt,
+		)
+		if isDuplicate(err) {
+			return exception.ErrIdentityLinked
+		}
+		if err != nil {
+			return err
+		}
+
+		return retry.Final(tx.Commit())
+	})
+
+	var known *exception.Error
+	if err != nil && !errors.As(err, &known) {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+		return 0, exception.ErrInternalServer
+	}
+
+	return userID, err
+}
+
+func (ir *identityRepositoryImpl) FindBySubject(ctx context.Context, provider, subject string) (account.Identity, error) {
+	var identity account.Identity
+	query := fmt.Sprintf(`SELECT id, userID, provider, subject, email, created_at FROM %s WHERE provider = ? AND subject = ?`, ir.tableName)
+	stmt, err := ir.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+		return identity, exception.ErrInternalServer
+	}
+
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		return stmt.QueryRowContext(ctx, provider, subject).Scan(
+			&identity.ID,
+			&identity.UserID,
+			&identity.Provider,
+			&identity.Subject,
+			&identity.Email,
+			&identity.CreatedAt,
+		)
+	})
+	if err == sql.ErrNoRows {
+		return identity, exception.ErrNotFound
+	}
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+		return identity, exception.ErrInternalServer
+	}
+
+	return identity, nil
+}
+
+func (ir *identityRepositoryImpl) FindByUserID(ctx context.Context, userID int64) ([]account.Identity, error) {
+	identities := []account.Identity{}
+
+	query := fmt.Sprintf(`SELECT id, userID, provider, subject, email, created_at FROM %s WHERE userID = ? ORDER BY provider`, ir.tableName)
+	stmt, err := ir.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+		return identities, exception.ErrInternalServer
+	}
+
+	var rows *sql.Rows
+	err = retry.Do(ctx, func(ctx context.Context) (err error) {
+		rows, err = stmt.QueryContext(ctx, userID)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+		return identities, exception.ErrInternalServer
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var identity account.Identity
+		if err := rows.Scan(
+			&identity.ID,
+			&identity.UserID,
+			&identity.Provider,
+			&identity.Subject,
+			&identity.Email,
+			&identity.CreatedAt,
+		); err != nil {
+			logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+			return identities, exception.ErrInternalServer
+		}
+		identities = append(identities, identity)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+		return identities, exception.ErrInternalServer
+	}
+
+	return identities, nil
+}
+
+func (ir *identityRepositoryImpl) Delete(ctx context.Context, userID int64, provider string) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE userID = ? AND provider = ?`, ir.tableName)
+	stmt, err := ir.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	var result sql.Result
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		result, err = stmt.ExecContext(ctx, userID, provider)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected < 1 {
+		return exception.ErrNotFound
+	}
+
+	return nil
+}
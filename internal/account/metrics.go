@@ -0,0 +1,105 @@
+package account
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/metrics"
+	"github.com/Risuii/helpers/oidc"
+	"github.com/Risuii/helpers/response"
+	"github.com/Risuii/models/account"
+	"github.com/Risuii/models/token"
+)
+
+type (
+	accountRepositoryMetrics struct {
+		AccountRepository
+	}
+
+	identityRepositoryMetrics struct {
+		IdentityRepository
+	}
+
+	accountUseCaseMetrics struct {
+		AccountUseCase
+	}
+)
+
+// NewAccountRepositoryMetrics counts successful registrations.
+func NewAccountRepositoryMetrics(repo AccountRepository) AccountRepository {
+	return &accountRepositoryMetrics{
+		AccountRepository: repo,
+	}
+}
+
+func (m *accountRepositoryMetrics) Register(ctx context.Context, params account.Account) (int64, error) {
+	ID, err := m.AccountRepository.Register(ctx, params)
+	if err == nil {
+		metrics.Registrations.Inc()
+	}
+
+	return ID, err
+}
+
+// NewIdentityRepositoryMetrics counts the registrations made by signing in
+// with a provider.
+func NewIdentityRepositoryMetrics(repo IdentityRepository) IdentityRepository {
+	return &identityRepositoryMetrics{
+		IdentityRepository: repo,
+	}
+}
+
+func (m *identityRepositoryMetrics) Register(ctx context.Context, user account.Account, identity account.Identity) (int64, error) {
+	ID, err := m.IdentityRepository.Register(ctx, user, identity)
+	if err == nil {
+		metrics.Registrations.Inc()
+	}
+
+	return ID, err
+}
+
+// NewAccountUseCaseMetrics counts login attempts by result. Logins are
+// decided by the password check, which never reaches the repository.
+func NewAccountUseCaseMetrics(usecase AccountUseCase) AccountUseCase {
+	return &accountUseCaseMetrics{
+		AccountUseCase: usecase,
+	}
+}
+
+func (m *accountUseCaseMetrics) Login(ctx context.Context, params account.AccountLogin) (response.Response, token.Token) {
+	res, newToken := m.AccountUseCase.Login(ctx, params)
+	countLogin(res, newToken)
+
+	return res, newToken
+}
+
+// LoginMFA counts the second step of logins with two-factor authentication,
+// the first one is counted as mfa_pending.
+func (m *accountUseCaseMetrics) LoginMFA(ctx context.Context, params account.MFALogin) (response.Response, token.Token) {
+	res, newToken := m.AccountUseCase.LoginMFA(ctx, params)
+	countLogin(res, newToken)
+
+	return res, newToken
+}
+
+// LoginSSO counts logins through an identity provider like password ones.
+func (m *accountUseCaseMetrics) LoginSSO(ctx context.Context, provider string, claims oidc.Claims) (response.Response, token.Token) {
+	res, newToken := m.AccountUseCase.LoginSSO(ctx, provider, claims)
+	countLogin(res, newToken)
+
+	return res, newToken
+}
+
+func countLogin(res response.Response, newToken token.Token) {
+	result := "success"
+	switch {
+	case errors.Is(res.Err(), exception.ErrAccountLocked):
+		result = "locked"
+	case res.Err() != nil:
+		result = "fail"
+	case newToken.Token == "":
+		result = "mfa_pending"
+	}
+	metrics.Logins.WithLabelValues(result).Inc()
+}
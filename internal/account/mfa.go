@@ -0,0 +1,184 @@
+package account
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/Risuii/helpers/signedtoken"
+	"github.com/Risuii/helpers/totp"
+	"github.com/Risuii/models/account"
+)
+
+// RecoveryCodeCount is the size of every set of recovery codes.
+const RecoveryCodeCount = 10
+
+// recoveryEncoding is Crockford's base32, which leaves out letters that
+// are easily mistaken for digits.
+var recoveryEncoding = base32.NewEncoding("0123456789abcdefghjkmnpqrstvwxyz").WithPadding(base32.NoPadding)
+
+// MFA holds what two-factor authentication needs besides the account row:
+// the key sealing TOTP secrets at rest, the signer of "mfa pending" tokens
+// and the recovery codes.
+type MFA struct {
+	codes      RecoveryCodeRepository
+	aead       cipher.AEAD
+	pending    *signedtoken.Signer
+	issuer     string
+	pendingTTL time.Duration
+}
+
+type mfaPendingClaims struct {
+	UserID       int64 `json:"uid"`
+	SessionEpoch int64 `json:"epoch"`
+}
+
+// NewMFA derives the sealing key from secret, so changing the secret makes
+// every enrolled authenticator unusable.
+func NewMFA(secret []byte, codes RecoveryCodeRepository, issuer string, pendingTTL time.Duration) *MFA {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("totp-secret"))
+
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+
+	return &MFA{
+		codes:      codes,
+		aead:       aead,
+		pending:    signedtoken.New(secret, "mfa-pending"),
+		issuer:     issuer,
+		pendingTTL: pendingTTL,
+	}
+}
+
+// Enroll creates a TOTP secret for user. The enrolment is shown to the
+// user and the sealed secret is stored.
+func (m *MFA) Enroll(user account.Account) (account.MFAEnrolment, string, error) {
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return account.MFAEnrolment{}, "", err
+	}
+
+	nonce := make([]byte, m.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return account.MFAEnrolment{}, "", err
+	}
+	sealed := m.aead.Seal(nonce, nonce, []byte(secret), nil)
+
+	enrolment := account.MFAEnrolment{
+		Secret: secret,
+		URI:    totp.URI(m.issuer, user.Email, secret),
+	}
+
+	return enrolment, base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Validate checks a code of the authenticator app of user and returns its
+// time step, which the caller records to prevent replays.
+func (m *MFA) Validate(user account.Account, code string, now time.Time) (int64, bool) {
+	if user.TOTPSecret == nil {
+		return 0, false
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(*user.TOTPSecret)
+	if err != nil || len(sealed) < m.aead.NonceSize() {
+		return 0, false
+	}
+
+	nonce, ciphertext := sealed[:m.aead.NonceSize()], sealed[m.aead.NonceSize():]
+	secret, err := m.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return 0, false
+	}
+
+	return totp.Validate(string(secret), code, now)
+}
+
+// PendingToken is issued after the password of user was checked. It only
+// works at /login/mfa, and only until the sessions of user are revoked.
+func (m *MFA) PendingToken(user account.Account) (account.MFAChallenge, error) {
+	token, err := m.pending.Sign(mfaPendingClaims{UserID: user.ID, SessionEpoch: user.SessionEpoch}, m.pendingTTL)
+	if err != nil {
+		return account.MFAChallenge{}, err
+	}
+
+	return account.MFAChallenge{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresIn:   int64(m.pendingTTL.Seconds()),
+	}, nil
+}
+
+// CheckPending returns the account and session epoch a pending token was
+// issued for.
+func (m *MFA) CheckPending(token string) (int64, int64, error) {
+	var claims mfaPendingClaims
+	if err := m.pending.Verify(token, &claims); err != nil {
+		return 0, 0, err
+	}
+
+	return claims.UserID, claims.SessionEpoch, nil
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewRecoveryCodes replaces the recovery codes of the account and returns
+// the new ones, which are never shown again.
+func (m *MFA) NewRecoveryCodes(ctx context.Context, userID int64) ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+
+		encoded := recoveryEncoding.EncodeToString(raw)
+		codes[i] = encoded[:4] + "-" + encoded[4:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+
+	if err := m.codes.Replace(ctx, userID, hashes, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// UseRecoveryCode consumes code, which is accepted with or without its dash
+// and in any case.
+func (m *MFA) UseRecoveryCode(ctx context.Context, userID int64, code string) error {
+	return m.codes.Consume(ctx, userID, hashRecoveryCode(code), time.Now())
+}
+
+func (m *MFA) DeleteRecoveryCodes(ctx context.Context, userID int64) error {
+	return m.codes.DeleteAll(ctx, userID)
+}
+
+// isTOTPCode tells codes of the app from recovery codes, which are longer.
+func isTOTPCode(code string) bool {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != totp.Digits {
+		return false
+	}
+
+	return strings.Trim(code, "0123456789") == ""
+}
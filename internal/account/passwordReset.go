@@ -0,0 +1,101 @@
+package account
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Risuii/helpers/i18n"
+	"github.com/Risuii/helpers/mailer"
+	"github.com/Risuii/models/account"
+)
+
+// PasswordResets issues random single-use reset tokens. The token itself
+// is only ever mailed; the database keeps its SHA-256.
+type PasswordResets struct {
+	repo      PasswordResetRepository
+	mailer    mailer.Mailer
+	publicURL string
+	ttl       time.Duration
+	// pending tracks the mails sent in the background, see Go.
+	pending sync.WaitGroup
+}
+
+func NewPasswordResets(repo PasswordResetRepository, mail mailer.Mailer, publicURL string, ttl time.Duration) *PasswordResets {
+	return &PasswordResets{
+		repo:      repo,
+		mailer:    mail,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		ttl:       ttl,
+	}
+}
+
+// Go runs fn in the background. Wait blocks until every fn has returned,
+// so that shutdown does not close the database under a mail being sent.
+func (p *PasswordResets) Go(fn func()) {
+	p.pending.Add(1)
+	go func() {
+		defer p.pending.Done()
+		fn()
+	}()
+}
+
+func (p *PasswordResets) Wait() {
+	p.pending.Wait()
+}
+
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Issue stores a new token for user and mails it, in the language of the
+// request.
+func (p *PasswordResets) Issue(ctx context.Context, user account.Account) error {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now()
+	err := p.repo.Create(ctx, account.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: hashResetToken(token),
+		ExpiresAt: now.Add(p.ttl),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return err
+	}
+
+	link := p.publicURL + "/reset-password?token=" + url.QueryEscape(token)
+	trans := i18n.Translator(i18n.FromContext(ctx))
+	minutes := strconv.Itoa(int(p.ttl.Minutes()))
+
+	return p.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: i18n.Message(trans, "MAIL_RESET_PASSWORD_SUBJECT", "Reset your password"),
+		// {0} is the name of the user, {1} the link and {2} its lifetime in minutes.
+		Body: i18n.Message(trans, "MAIL_RESET_PASSWORD_BODY",
+			"Hi {0},\n\nSomeone asked to reset the password of your account. Open the link below to choose a new one:\n\n{1}\n\nThe link is valid for {2} minutes and works once.\n",
+			user.Name, link, minutes),
+	})
+}
+
+// Consume uses up token and returns the account it was issued for.
+func (p *PasswordResets) Consume(ctx context.Context, token string) (int64, error) {
+	return p.repo.Consume(ctx, hashResetToken(token), time.Now())
+}
+
+// RevokeAll invalidates every token still outstanding for the account.
+func (p *PasswordResets) RevokeAll(ctx context.Context, userID int64) error {
+	return p.repo.RevokeAll(ctx, userID, time.Now())
+}
@@ -0,0 +1,142 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/retry"
+	"github.com/Risuii/helpers/stmtcache"
+	"github.com/Risuii/models/account"
+)
+
+type (
+	PasswordResetRepository interface {
+		Create(ctx context.Context, params account.PasswordReset) error
+		Consume(ctx context.Context, tokenHash string, now time.Time) (int64, error)
+		RevokeAll(ctx context.Context, userID int64, now time.Time) error
+	}
+
+	passwordResetRepositoryImpl struct {
+		db        *sql.DB
+		tableName string
+		stmts     *stmtcache.Cache
+	}
+)
+
+func NewPasswordResetRepositoryImpl(db *sql.DB, tableName string) PasswordResetRepository {
+	return &passwordResetRepositoryImpl{
+		db:        db,
+		tableName: tableName,
+		stmts:     stmtcache.For(db),
+	}
+}
+
+func (pr *passwordResetRepositoryImpl) Create(ctx context.Context, params account.PasswordReset) error {
+	query := fmt.Sprintf(`INSERT INTO %s (userID, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?)`, pr.tableName)
+	stmt, err := pr.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", pr.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		_, err := stmt.ExecContext(
+			ctx,
+			params.UserID,
+			params.TokenHash,
+			params.ExpiresAt,
+			params.CreatedAt,
+		)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", pr.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	return nil
+}
+
+// Consume marks the unexpired, unused token with the given hash as used and
+// returns its account. The update is conditional on the token still being
+// unused, so two concurrent resets cannot both succeed.
+func (pr *passwordResetRepositoryImpl) Consume(ctx context.Context, tokenHash string, now time.Time) (int64, error) {
+	query := fmt.Sprintf(`SELECT id, userID FROM %s WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?`, pr.tableName)
+	stmt, err := pr.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", pr.tableName, "error", err)
+		return 0, exception.ErrInternalServer
+	}
+
+	var reset account.PasswordReset
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		return stmt.QueryRowContext(ctx, tokenHash, now).Scan(
+			&reset.ID,
+			&reset.UserID,
+		)
+	})
+	if err == sql.ErrNoRows {
+		return 0, exception.ErrInvalidToken
+	}
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", pr.tableName, "error", err)
+		return 0, exception.ErrInternalServer
+	}
+
+	query = fmt.Sprintf(`UPDATE %s SET used_at = ? WHERE id = ? AND used_at IS NULL`, pr.tableName)
+	stmt, err = pr.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", pr.tableName, "error", err)
+		return 0, exception.ErrInternalServer
+	}
+
+	var result sql.Result
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		result, err = stmt.ExecContext(
+			ctx,
+			now,
+			reset.ID,
+		)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", pr.tableName, "error", err)
+		return 0, exception.ErrInternalServer
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected < 1 {
+		return 0, exception.ErrInvalidToken
+	}
+
+	return reset.UserID, nil
+}
+
+// RevokeAll marks every outstanding token of the account as used.
+func (pr *passwordResetRepositoryImpl) RevokeAll(ctx context.Context, userID int64, now time.Time) error {
+	query := fmt.Sprintf(`UPDATE %s SET used_at = ? WHERE userID = ? AND used_at IS NULL`, pr.tableName)
+	stmt, err := pr.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", pr.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		_, err := stmt.ExecContext(
+			ctx,
+			now,
+			userID,
+		)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", pr.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/retry"
+	"github.com/Risuii/helpers/stmtcache"
+)
+
+type (
+	RecoveryCodeRepository interface {
+		Replace(ctx context.Context, userID int64, codeHashes []string, now time.Time) error
+		Consume(ctx context.Context, userID int64, codeHash string, now time.Time) error
+		DeleteAll(ctx context.Context, userID int64) error
+	}
+
+	recoveryCodeRepositoryImpl struct {
+		db        *sql.DB
+		tableName string
+		stmts     *stmtcache.Cache
+	}
+)
+
+func NewRecoveryCodeRepositoryImpl(db *sql.DB, tableName string) RecoveryCodeRepository {
+	return &recoveryCodeRepositoryImpl{
+		db:        db,
+		tableName: tableName,
+		stmts:     stmtcache.For(db),
+	}
+}
+
+// Replace drops every code of the account, used or not, and stores the new
+// set in the same transaction, so the old codes stop working exactly when
+// the new ones start.
+func (rr *recoveryCodeRepositoryImpl) Replace(ctx context.Context, userID int64, codeHashes []string, now time.Time) error {
+	deleteStmt, err := rr.stmts.Prepare(ctx, fmt.Sprintf(`DELETE FROM %s WHERE userID = ?`, rr.tableName))
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", rr.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	insertStmt, err := rr.stmts.Prepare(ctx, fmt.Sprintf(`INSERT INTO %s (userID, code_hash, created_at) VALUES (?, ?, ?)`, rr.tableName))
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", rr.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		tx, err := rr.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.StmtContext(ctx, deleteStmt).ExecContext(ctx, userID); err != nil {
+			return err
+		}
+
+		insert := tx.StmtContext(ctx, insertStmt)
+		for _, hash := range codeHashes {
+			if _, err := insert.ExecContext(ctx, userID, hash, now); err != nil {
+				return err
+			}
+		}
+
+		return retry.Final(tx.Commit())
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", rr.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	return nil
+}
+
+// Consume marks the unused code with the given hash as used. It fails with
+// ErrInvalidCode when there is no such code.
+func (rr *recoveryCodeRepositoryImpl) Consume(ctx context.Context, userID int64, codeHash string, now time.Time) error {
+	query := fmt.Sprintf(`UPDATE %s SET used_at = ? WHERE userID = ? AND code_hash = ? AND used_at IS NULL`, rr.tableName)
+	stmt, err := rr.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", rr.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	var result sql.Result
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		result, err = stmt.ExecContext(
+			ctx,
+			now,
+			userID,
+			codeHash,
+		)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", rr.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected < 1 {
+		return exception.ErrInvalidCode
+	}
+
+	return nil
+}
+
+func (rr *recoveryCodeRepositoryImpl) DeleteAll(ctx context.Context, userID int64) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE userID = ?`, rr.tableName)
+	stmt, err := rr.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", rr.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		_, err := stmt.ExecContext(ctx, userID)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", rr.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	return nil
+}
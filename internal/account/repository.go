@@ -3,10 +3,16 @@ package account
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
 
 	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/retry"
+	"github.com/Risuii/helpers/stmtcache"
 	"github.com/Risuii/models/account"
 )
 
@@ -16,12 +22,20 @@ type (
 		FindByEmail(ctx context.Context, email string) (account.Account, error)
 		FindByID(ctx context.Context, id int64) (account.Account, error)
 		Update(ctx context.Context, id int64, params account.Account) error
-		Delete(ctx context.Context, id int64) error
+		Delete(ctx context.Context, id int64, version int64) error
+		MarkVerified(ctx context.Context, id int64, verifiedAt time.Time) error
+		SetPassword(ctx context.Context, id int64, password string, updateAt time.Time) error
+		ChangeEmail(ctx context.Context, id int64, from, to string, verifiedAt time.Time) error
+		SetTOTPSecret(ctx context.Context, id int64, sealed string) error
+		EnableTOTP(ctx context.Context, id int64, counter int64, enabledAt time.Time) error
+		DisableTOTP(ctx context.Context, id int64) error
+		UseTOTPCounter(ctx context.Context, id int64, counter int64) error
 	}
 
 	accountRepositoryImpl struct {
 		db        *sql.DB
 		tableName string
+		stmts     *stmtcache.Cache
 	}
 )
 
@@ -29,28 +43,35 @@ func NewAccountRepositoryImpl(db *sql.DB, tableName string) AccountRepository {
 	return &accountRepositoryImpl{
 		db:        db,
 		tableName: tableName,
+		stmts:     stmtcache.For(db),
 	}
 }
 
 func (ar *accountRepositoryImpl) Register(ctx context.Context, params account.Account) (int64, error) {
 	query := fmt.Sprintf(`INSERT INTO %s(name, password, email, address, created_at) VALUES (?, ?, ?, ?, ?)`, ar.tableName)
-	stmt, err := ar.db.PrepareContext(ctx, query)
+	stmt, err := ar.stmts.Prepare(ctx, query)
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
 		return 0, exception.ErrInternalServer
 	}
-	defer stmt.Close()
 
-	result, err := stmt.ExecContext(
-		ctx,
-		params.Name,
-		params.Password,
-		params.Email,
-		params.Address,
-		params.CreatedAt,
-	)
+	var result sql.Result
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		result, err = stmt.ExecContext(
+			ctx,
+			params.Name,
+			params.Password,
+			params.Email,
+			params.Address,
+			params.CreatedAt,
+		)
+		return err
+	})
+	if isDuplicate(err) {
+		return 0, exception.ErrEmailTaken
+	}
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
 		return 0, exception.ErrInternalServer
 	}
 
@@ -61,27 +82,32 @@ func (ar *accountRepositoryImpl) Register(ctx context.Context, params account.Ac
 
 func (ar *accountRepositoryImpl) FindByEmail(ctx context.Context, email string) (account.Account, error) {
 	var user account.Account
-	query := fmt.Sprintf(`SELECT id, name, password, email, address, created_at, update_at FROM %s WHERE email = ?`, ar.tableName)
-	stmt, err := ar.db.PrepareContext(ctx, query)
+	query := fmt.Sprintf(`SELECT id, name, password, email, address, created_at, update_at, version, verified_at, session_epoch, totp_secret, totp_enabled_at, totp_last_counter FROM %s WHERE email = ?`, ar.tableName)
+	stmt, err := ar.stmts.Prepare(ctx, query)
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
 		return user, err
 	}
-	defer stmt.Close()
-
-	row := stmt.QueryRowContext(ctx, email)
 
-	err = row.Scan(
-		&user.ID,
-		&user.Name,
-		&user.Password,
-		&user.Email,
-		&user.Address,
-		&user.CreatedAt,
-		&user.UpdateAt,
-	)
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		return stmt.QueryRowContext(ctx, email).Scan(
+			&user.ID,
+			&user.Name,
+			&user.Password,
+			&user.Email,
+			&user.Address,
+			&user.CreatedAt,
+			&user.UpdateAt,
+			&user.Version,
+			&user.VerifiedAt,
+			&user.SessionEpoch,
+			&user.TOTPSecret,
+			&user.MFAEnabledAt,
+			&user.TOTPCounter,
+		)
+	})
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
 		return user, exception.ErrNotFound
 	}
 
@@ -90,27 +116,32 @@ func (ar *accountRepositoryImpl) FindByEmail(ctx context.Context, email string)
 
 func (ar *accountRepositoryImpl) FindByID(ctx context.Context, id int64) (account.Account, error) {
 	var user account.Account
-	query := fmt.Sprintf(`SELECT id, name, password, email, address, created_at, update_at FROM %s WHERE id = ?`, ar.tableName)
-	stmt, err := ar.db.PrepareContext(ctx, query)
+	query := fmt.Sprintf(`SELECT id, name, password, email, address, created_at, update_at, version, verified_at, session_epoch, totp_secret, totp_enabled_at, totp_last_counter FROM %s WHERE id = ?`, ar.tableName)
+	stmt, err := ar.stmts.Prepare(ctx, query)
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
 		return user, err
 	}
-	defer stmt.Close()
 
-	row := stmt.QueryRowContext(ctx, id)
-
-	err = row.Scan(
-		&user.ID,
-		&user.Name,
-		&user.Password,
-		&user.Email,
-		&user.Address,
-		&user.CreatedAt,
-		&user.UpdateAt,
-	)
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		return stmt.QueryRowContext(ctx, id).Scan(
+			&user.ID,
+			&user.Name,
+			&user.Password,
+			&user.Email,
+			&user.Address,
+			&user.CreatedAt,
+			&user.UpdateAt,
+			&user.Version,
+			&user.VerifiedAt,
+			&user.SessionEpoch,
+			&user.TOTPSecret,
+			&user.MFAEnabledAt,
+			&user.TOTPCounter,
+		)
+	})
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
 		return user, exception.ErrNotFound
 	}
 
@@ -118,51 +149,124 @@ func (ar *accountRepositoryImpl) FindByID(ctx context.Context, id int64) (accoun
 }
 
 func (ar *accountRepositoryImpl) Update(ctx context.Context, id int64, params account.Account) error {
-	query := fmt.Sprintf(`UPDATE %s SET name = ?, password = ?, email = ?, address = ?, update_at = ? WHERE id = %d`, ar.tableName, id)
-	stmt, err := ar.db.PrepareContext(ctx, query)
+	query := fmt.Sprintf(`UPDATE %s SET name = ?, address = ?, update_at = ?, version = version + 1 WHERE id = ? AND version = ?`, ar.tableName)
+	stmt, err := ar.stmts.Prepare(ctx, query)
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
 		return exception.ErrInternalServer
 	}
-	defer stmt.Close()
 
-	result, err := stmt.ExecContext(
-		ctx,
-		params.Name,
-		params.Password,
-		params.Email,
-		params.Address,
-		params.UpdateAt,
-	)
+	var result sql.Result
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		result, err = stmt.ExecContext(
+			ctx,
+			params.Name,
+			params.Address,
+			params.UpdateAt,
+			id,
+			params.Version,
+		)
+		return err
+	})
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
 		return exception.ErrInternalServer
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 
 	if rowsAffected < 1 {
-		return exception.ErrNotFound
+		return exception.ErrPreconditionFailed
+	}
+
+	return nil
+}
+
+func (ar *accountRepositoryImpl) Delete(ctx context.Context, id int64, version int64) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE id = ? AND version = ?`, ar.tableName)
+	stmt, err := ar.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	var result sql.Result
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		result, err = stmt.ExecContext(
+			ctx,
+			id,
+			version,
+		)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected < 1 {
+		return exception.ErrPreconditionFailed
 	}
 
 	return nil
 }
 
-func (ar *accountRepositoryImpl) Delete(ctx context.Context, id int64) error {
-	query := fmt.Sprintf(`DELETE FROM %s WHERE id = %d`, ar.tableName, id)
-	stmt, err := ar.db.PrepareContext(ctx, query)
+// MarkVerified records that the email of the account was confirmed. It
+// fails with ErrAlreadyVerified when that happened before, which makes
+// every verification link usable only once.
+func (ar *accountRepositoryImpl) MarkVerified(ctx context.Context, id int64, verifiedAt time.Time) error {
+	query := fmt.Sprintf(`UPDATE %s SET verified_at = ?, version = version + 1 WHERE id = ? AND verified_at IS NULL`, ar.tableName)
+	stmt, err := ar.stmts.Prepare(ctx, query)
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
 		return exception.ErrInternalServer
 	}
 
-	defer stmt.Close()
+	var result sql.Result
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		result, err = stmt.ExecContext(
+			ctx,
+			verifiedAt,
+			id,
+		)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected < 1 {
+		return exception.ErrAlreadyVerified
+	}
+
+	return nil
+}
 
-	result, err := stmt.ExecContext(
-		ctx,
-	)
+// SetPassword replaces the password regardless of the version and raises
+// the session epoch, which logs out every session of the account.
+func (ar *accountRepositoryImpl) SetPassword(ctx context.Context, id int64, password string, updateAt time.Time) error {
+	query := fmt.Sprintf(`UPDATE %s SET password = ?, update_at = ?, session_epoch = session_epoch + 1, version = version + 1 WHERE id = ?`, ar.tableName)
+	stmt, err := ar.stmts.Prepare(ctx, query)
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	var result sql.Result
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		result, err = stmt.ExecContext(
+			ctx,
+			password,
+			updateAt,
+			id,
+		)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
 		return exception.ErrInternalServer
 	}
 
@@ -173,3 +277,109 @@ func (ar *accountRepositoryImpl) Delete(ctx context.Context, id int64) error {
 
 	return nil
 }
+
+// ChangeEmail moves the account from one confirmed address to another. It
+// fails with ErrInvalidToken when the address is no longer from, and with
+// ErrEmailTaken when another account has claimed to in the meantime.
+func (ar *accountRepositoryImpl) ChangeEmail(ctx context.Context, id int64, from, to string, verifiedAt time.Time) error {
+	query := fmt.Sprintf(`UPDATE %s SET email = ?, verified_at = ?, update_at = ?, version = version + 1 WHERE id = ? AND email = ?`, ar.tableName)
+	stmt, err := ar.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	var result sql.Result
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		result, err = stmt.ExecContext(
+			ctx,
+			to,
+			verifiedAt,
+			verifiedAt,
+			id,
+			from,
+		)
+		return err
+	})
+	if isDuplicate(err) {
+		return exception.ErrEmailTaken
+	}
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected < 1 {
+		return exception.ErrInvalidToken
+	}
+
+	return nil
+}
+
+// SetTOTPSecret starts, or restarts, the enrolment of an account that has
+// no two-factor authentication yet.
+func (ar *accountRepositoryImpl) SetTOTPSecret(ctx context.Context, id int64, sealed string) error {
+	query := fmt.Sprintf(`UPDATE %s SET totp_secret = ?, totp_last_counter = 0 WHERE id = ? AND totp_enabled_at IS NULL`, ar.tableName)
+
+	return ar.execTOTP(ctx, query, exception.ErrMFAEnabled, sealed, id)
+}
+
+// EnableTOTP finishes the enrolment, counter is the step of the code that
+// confirmed it.
+func (ar *accountRepositoryImpl) EnableTOTP(ctx context.Context, id int64, counter int64, enabledAt time.Time) error {
+	query := fmt.Sprintf(`UPDATE %s SET totp_enabled_at = ?, totp_last_counter = ?, version = version + 1 WHERE id = ? AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL`, ar.tableName)
+
+	return ar.execTOTP(ctx, query, exception.ErrMFAEnabled, enabledAt, counter, id)
+}
+
+func (ar *accountRepositoryImpl) DisableTOTP(ctx context.Context, id int64) error {
+	query := fmt.Sprintf(`UPDATE %s SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_counter = 0, version = version + 1 WHERE id = ? AND totp_enabled_at IS NOT NULL`, ar.tableName)
+
+	return ar.execTOTP(ctx, query, exception.ErrMFANotEnabled, id)
+}
+
+// UseTOTPCounter records that the code of the given step was used. It
+// fails with ErrInvalidCode when that step or a later one was used before,
+// which makes every code single-use even under concurrent requests.
+func (ar *accountRepositoryImpl) UseTOTPCounter(ctx context.Context, id int64, counter int64) error {
+	query := fmt.Sprintf(`UPDATE %s SET totp_last_counter = ? WHERE id = ? AND totp_last_counter < ?`, ar.tableName)
+
+	return ar.execTOTP(ctx, query, exception.ErrInvalidCode, counter, id, counter)
+}
+
+// execTOTP runs one of the conditional TOTP updates and returns
+// errNoRows when its condition did not hold.
+func (ar *accountRepositoryImpl) execTOTP(ctx context.Context, query string, errNoRows error, args ...interface{}) error {
+	stmt, err := ar.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	var result sql.Result
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		result, err = stmt.ExecContext(ctx, args...)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected < 1 {
+		return errNoRows
+	}
+
+	return nil
+}
+
+// isDuplicate reports whether err is a violation of a unique index, which
+// for this table can only be the one on email.
+func isDuplicate(err error) bool {
+	const errDuplicateEntry = 1062
+
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == errDuplicateEntry
+}
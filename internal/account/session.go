@@ -0,0 +1,67 @@
+package account
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Risuii/config/jwt"
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/response"
+)
+
+// NewSessionGuard drops the session cookie of accounts that were deleted or
+// whose sessions were revoked, e.g. by a password reset, since the cookie
+// was issued. A session is revoked when its epoch no longer matches the
+// account's. The request then continues as an anonymous one, so protected
+// handlers answer 401 while logging in again still works. Cookies that do
+// not verify are left to the handlers.
+func NewSessionGuard(repo AccountRepository) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c, err := r.Cookie("token")
+			if err != nil || c.Value == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			claims, err := jwt.ParseClaims(c.Value)
+			if err != nil || claims.UserID == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, err := repo.FindByID(r.Context(), claims.UserID)
+			if err != nil && err != exception.ErrNotFound {
+				response.Error(response.StatusInternalServerError, exception.ErrInternalServer).JSON(w)
+				return
+			}
+
+			if err == exception.ErrNotFound || user.SessionEpoch != claims.SessionEpoch {
+				http.SetCookie(w, &http.Cookie{
+					Name:     "token",
+					Path:     "/",
+					Value:    "",
+					HttpOnly: true,
+					MaxAge:   -1,
+				})
+				r = withoutCookie(r, "token")
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func withoutCookie(r *http.Request, name string) *http.Request {
+	r = r.Clone(r.Context())
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	for _, c := range cookies {
+		if c.Name != name {
+			r.AddCookie(c)
+		}
+	}
+
+	return r
+}
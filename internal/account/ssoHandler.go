@@ -0,0 +1,252 @@
+package account
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Risuii/config/jwt"
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/oidc"
+	"github.com/Risuii/helpers/response"
+	"github.com/Risuii/helpers/signedtoken"
+)
+
+const ssoStateCookie = "sso_state"
+
+// SSOHandler logs in through OpenID Connect providers. What the callback
+// needs to finish the flow is kept in a signed cookie, bound to the browser
+// that started it.
+type SSOHandler struct {
+	UseCase   AccountUseCase
+	Providers map[string]*oidc.Provider
+	State     *signedtoken.Signer
+	StateTTL  time.Duration
+}
+
+type ssoState struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	// LinkUserID is set when a logged in user links the provider to the
+	// account instead of logging in.
+	LinkUserID int64 `json:"link,omitempty"`
+}
+
+func NewSSOHandler(router *mux.Router, usecase AccountUseCase, providers []*oidc.Provider, secret []byte, stateTTL time.Duration) {
+	handler := &SSOHandler{
+		UseCase:   usecase,
+		Providers: make(map[string]*oidc.Provider, len(providers)),
+		State:     signedtoken.New(secret, "sso-state"),
+		StateTTL:  stateTTL,
+	}
+	for _, provider := range providers {
+		handler.Providers[provider.Name()] = provider
+	}
+
+	router.HandleFunc("/auth/{provider}/login", handler.Login).Methods(http.MethodGet)
+	router.HandleFunc("/auth/{provider}/callback", handler.Callback).Methods(http.MethodGet)
+	router.HandleFunc("/account/identities", handler.List).Methods(http.MethodGet)
+	router.HandleFunc("/account/identities/{provider}/link", handler.Link).Methods(http.MethodGet)
+	router.HandleFunc("/account/identities/{provider}", handler.Unlink).Methods(http.MethodDelete)
+}
+
+func (handler *SSOHandler) Login(w http.ResponseWriter, r *http.Request) {
+	handler.start(w, r, 0)
+}
+
+func (handler *SSOHandler) Link(w http.ResponseWriter, r *http.Request) {
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	handler.start(w, r, claims.ID)
+}
+
+// start redirects the browser to the provider.
+func (handler *SSOHandler) start(w http.ResponseWriter, r *http.Request, linkUserID int64) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	provider, found := handler.Providers[mux.Vars(r)["provider"]]
+	if !found {
+		res = response.Error(response.StatusNotFound, exception.ErrNotFound)
+		res.JSON(w)
+		return
+	}
+
+	state := ssoState{Provider: provider.Name(), LinkUserID: linkUserID}
+	var err error
+	if state.State, err = oidc.NewState(); err == nil {
+		if state.Nonce, err = oidc.NewState(); err == nil {
+			state.Verifier, err = oidc.NewVerifier()
+		}
+	}
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "create sso state", "error", err)
+		res = response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
+		res.JSON(w)
+		return
+	}
+
+	target, err := provider.AuthCodeURL(ctx, state.State, state.Nonce, state.Verifier)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "sso discovery", "provider", provider.Name(), "error", err)
+		res = response.Error(response.StatusServiceUnavailable, exception.ErrUnavailable)
+		res.JSON(w)
+		return
+	}
+
+	cookie, err := handler.State.Sign(state, handler.StateTTL)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "sign sso state", "error", err)
+		res = response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
+		res.JSON(w)
+		return
+	}
+
+	// Lax, since the provider sends the browser back with a top-level GET.
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoStateCookie,
+		Path:     "/auth/",
+		Value:    cookie,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(handler.StateTTL.Seconds()),
+	})
+
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+func (handler *SSOHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	provider, found := handler.Providers[mux.Vars(r)["provider"]]
+	if !found {
+		res = response.Error(response.StatusNotFound, exception.ErrNotFound)
+		res.JSON(w)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoStateCookie,
+		Path:     "/auth/",
+		Value:    "",
+		HttpOnly: true,
+		MaxAge:   -1,
+	})
+
+	var state ssoState
+	c, err := r.Cookie(ssoStateCookie)
+	if err == nil {
+		err = handler.State.Verify(c.Value, &state)
+	}
+	if err != nil || state.Provider != provider.Name() ||
+		subtle.ConstantTimeCompare([]byte(state.State), []byte(query.Get("state"))) != 1 {
+		res = response.Fail(exception.ErrInvalidToken)
+		res.JSON(w)
+		return
+	}
+
+	if query.Get("error") != "" || query.Get("code") == "" {
+		logger.FromContext(ctx).WarnContext(ctx, "sso declined", "provider", provider.Name(), "error", query.Get("error"))
+		res = response.Fail(exception.ErrSSOFailed)
+		res.JSON(w)
+		return
+	}
+
+	claims, err := provider.Exchange(ctx, query.Get("code"), state.Verifier, state.Nonce)
+	if err != nil {
+		logger.FromContext(ctx).WarnContext(ctx, "sso exchange", "provider", provider.Name(), "error", err)
+		res = response.Fail(exception.ErrSSOFailed)
+		res.JSON(w)
+		return
+	}
+
+	if state.LinkUserID != 0 {
+		// The session must still belong to whoever started linking.
+		session, ok := sessionClaims(w, r)
+		if !ok {
+			return
+		}
+		if session.ID != state.LinkUserID {
+			res = response.Fail(exception.ErrInvalidToken)
+			res.JSON(w)
+			return
+		}
+
+		res = handler.UseCase.LinkIdentity(ctx, session.ID, provider.Name(), claims)
+		res.JSON(w)
+		return
+	}
+
+	res, token := handler.UseCase.LoginSSO(ctx, provider.Name(), claims)
+
+	if token.Token != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     "token",
+			Path:     "/",
+			Value:    token.Token,
+			HttpOnly: true,
+		})
+	}
+
+	res.JSON(w)
+}
+
+func (handler *SSOHandler) List(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	res = handler.UseCase.ListIdentities(ctx, claims.ID)
+
+	res.JSON(w)
+}
+
+func (handler *SSOHandler) Unlink(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	res = handler.UseCase.UnlinkIdentity(ctx, claims.ID, mux.Vars(r)["provider"])
+
+	res.JSON(w)
+}
+
+// sessionClaims reads the claims of the session cookie, answering 401
+// itself when there is no valid one.
+func sessionClaims(w http.ResponseWriter, r *http.Request) (*jwt.JWTclaim, bool) {
+	c, err := r.Cookie("token")
+	if err != nil {
+		response.Error(response.StatusUnauthorized, exception.ErrUnauthorized).JSON(w)
+		return nil, false
+	}
+
+	claims, err := jwt.ParseClaims(c.Value)
+	if err != nil {
+		response.Error(response.StatusUnauthorized, exception.ErrUnauthorized).JSON(w)
+		return nil, false
+	}
+
+	return claims, true
+}
@@ -0,0 +1,190 @@
+package account
+
+import (
+	"context"
+
+	"github.com/Risuii/helpers/oidc"
+	"github.com/Risuii/helpers/response"
+	"github.com/Risuii/helpers/tracing"
+	"github.com/Risuii/models/account"
+	"github.com/Risuii/models/token"
+)
+
+type accountUseCaseTracing struct {
+	AccountUseCase
+}
+
+// NewAccountUseCaseTracing starts one span per use case method.
+func NewAccountUseCaseTracing(usecase AccountUseCase) AccountUseCase {
+	return &accountUseCaseTracing{
+		AccountUseCase: usecase,
+	}
+}
+
+func (t *accountUseCaseTracing) Register(ctx context.Context, params account.Account) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "AccountUseCase.Register")
+	res := t.AccountUseCase.Register(ctx, params)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *accountUseCaseTracing) Login(ctx context.Context, params account.AccountLogin) (response.Response, token.Token) {
+	ctx, span := tracing.Tracer().Start(ctx, "AccountUseCase.Login")
+	res, newToken := t.AccountUseCase.Login(ctx, params)
+	tracing.End(span, res.Err())
+
+	return res, newToken
+}
+
+func (t *accountUseCaseTracing) Update(ctx context.Context, id int64, version int64, params account.AccountUpdate) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "AccountUseCase.Update")
+	res := t.AccountUseCase.Update(ctx, id, version, params)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *accountUseCaseTracing) ReadOne(ctx context.Context, id int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "AccountUseCase.ReadOne")
+	res := t.AccountUseCase.ReadOne(ctx, id)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *accountUseCaseTracing) Delete(ctx context.Context, id int64, version int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "AccountUseCase.Delete")
+	res := t.AccountUseCase.Delete(ctx, id, version)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *accountUseCaseTracing) VerifyEmail(ctx context.Context, token string) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "AccountUseCase.VerifyEmail")
+	res := t.AccountUseCase.VerifyEmail(ctx, token)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *accountUseCaseTracing) ResendVerification(ctx context.Context, id int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "AccountUseCase.ResendVerification")
+	res := t.AccountUseCase.ResendVerification(ctx, id)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *accountUseCaseTracing) ForgotPassword(ctx context.Context, params account.ForgotPassword) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "AccountUseCase.ForgotPassword")
+	res := t.AccountUseCase.ForgotPassword(ctx, params)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *accountUseCaseTracing) ResetPassword(ctx context.Context, params account.ResetPassword) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "AccountUseCase.ResetPassword")
+	res := t.AccountUseCase.ResetPassword(ctx, params)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *accountUseCaseTracing) ChangePassword(ctx context.Context, id int64, params account.ChangePassword) (response.Response, token.Token) {
+	ctx, span := tracing.Tracer().Start(ctx, "AccountUseCase.ChangePassword")
+	res, newToken := t.AccountUseCase.ChangePassword(ctx, id, params)
+	tracing.End(span, res.Err())
+
+	return res, newToken
+}
+
+func (t *accountUseCaseTracing) ChangeEmail(ctx context.Context, id int64, params account.ChangeEmail) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "AccountUseCase.ChangeEmail")
+	res := t.AccountUseCase.ChangeEmail(ctx, id, params)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *accountUseCaseTracing) ConfirmEmail(ctx context.Context, token string) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "AccountUseCase.ConfirmEmail")
+	res := t.AccountUseCase.ConfirmEmail(ctx, token)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *accountUseCaseTracing) LoginMFA(ctx context.Context, params account.MFALogin) (response.Response, token.Token) {
+	ctx, span := tracing.Tracer().Start(ctx, "AccountUseCase.LoginMFA")
+	res, newToken := t.AccountUseCase.LoginMFA(ctx, params)
+	tracing.End(span, res.Err())
+
+	return res, newToken
+}
+
+func (t *accountUseCaseTracing) EnrollMFA(ctx context.Context, id int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "AccountUseCase.EnrollMFA")
+	res := t.AccountUseCase.EnrollMFA(ctx, id)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *accountUseCaseTracing) ConfirmMFA(ctx context.Context, id int64, params account.MFACode) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "AccountUseCase.ConfirmMFA")
+	res := t.AccountUseCase.ConfirmMFA(ctx, id, params)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *accountUseCaseTracing) DisableMFA(ctx context.Context, id int64, params account.MFADisable) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "AccountUseCase.DisableMFA")
+	res := t.AccountUseCase.DisableMFA(ctx, id, params)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *accountUseCaseTracing) RegenerateRecoveryCodes(ctx context.Context, id int64, params account.MFACode) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "AccountUseCase.RegenerateRecoveryCodes")
+	res := t.AccountUseCase.RegenerateRecoveryCodes(ctx, id, params)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *accountUseCaseTracing) LoginSSO(ctx context.Context, provider string, claims oidc.Claims) (response.Response, token.Token) {
+	ctx, span := tracing.Tracer().Start(ctx, "AccountUseCase.LoginSSO")
+	res, newToken := t.AccountUseCase.LoginSSO(ctx, provider, claims)
+	tracing.End(span, res.Err())
+
+	return res, newToken
+}
+
+func (t *accountUseCaseTracing) LinkIdentity(ctx context.Context, id int64, provider string, claims oidc.Claims) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "AccountUseCase.LinkIdentity")
+	res := t.AccountUseCase.LinkIdentity(ctx, id, provider, claims)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *accountUseCaseTracing) ListIdentities(ctx context.Context, id int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "AccountUseCase.ListIdentities")
+	res := t.AccountUseCase.ListIdentities(ctx, id)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *accountUseCaseTracing) UnlinkIdentity(ctx context.Context, id int64, provider string) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "AccountUseCase.UnlinkIdentity")
+	res := t.AccountUseCase.UnlinkIdentity(ctx, id, provider)
+	tracing.End(span, res.Err())
+
+	return res
+}
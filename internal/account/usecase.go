@@ -2,13 +2,19 @@ package account
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	newJWT "github.com/dgrijalva/jwt-go"
 
 	"github.com/Risuii/config/bcrypt"
 	"github.com/Risuii/config/jwt"
+	"github.com/Risuii/helpers/etag"
 	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/oidc"
+	"github.com/Risuii/helpers/password"
+	"github.com/Risuii/helpers/ratelimit"
 	"github.com/Risuii/helpers/response"
 	"github.com/Risuii/models/account"
 	"github.com/Risuii/models/token"
@@ -18,25 +24,57 @@ type (
 	AccountUseCase interface {
 		Register(ctx context.Context, params account.Account) response.Response
 		Login(ctx context.Context, params account.AccountLogin) (response.Response, token.Token)
-		Update(ctx context.Context, id int64, params account.Account) response.Response
+		Update(ctx context.Context, id int64, version int64, params account.AccountUpdate) response.Response
 		ReadOne(ctx context.Context, id int64) response.Response
-		Delete(ctx context.Context, id int64) response.Response
+		Delete(ctx context.Context, id int64, version int64) response.Response
+		VerifyEmail(ctx context.Context, token string) response.Response
+		ResendVerification(ctx context.Context, id int64) response.Response
+		ForgotPassword(ctx context.Context, params account.ForgotPassword) response.Response
+		ResetPassword(ctx context.Context, params account.ResetPassword) response.Response
+		ChangePassword(ctx context.Context, id int64, params account.ChangePassword) (response.Response, token.Token)
+		ChangeEmail(ctx context.Context, id int64, params account.ChangeEmail) response.Response
+		ConfirmEmail(ctx context.Context, token string) response.Response
+		LoginMFA(ctx context.Context, params account.MFALogin) (response.Response, token.Token)
+		EnrollMFA(ctx context.Context, id int64) response.Response
+		ConfirmMFA(ctx context.Context, id int64, params account.MFACode) response.Response
+		DisableMFA(ctx context.Context, id int64, params account.MFADisable) response.Response
+		RegenerateRecoveryCodes(ctx context.Context, id int64, params account.MFACode) response.Response
+		LoginSSO(ctx context.Context, provider string, claims oidc.Claims) (response.Response, token.Token)
+		LinkIdentity(ctx context.Context, id int64, provider string, claims oidc.Claims) response.Response
+		ListIdentities(ctx context.Context, id int64) response.Response
+		UnlinkIdentity(ctx context.Context, id int64, provider string) response.Response
 	}
 
 	accountUseCaseImpl struct {
-		repo   AccountRepository
-		bcrypt bcrypt.Bcrypt
+		repo       AccountRepository
+		identities IdentityRepository
+		bcrypt     bcrypt.Bcrypt
+		lockout    *ratelimit.Lockout
+		verifier   *EmailVerifier
+		resets     *PasswordResets
+		changes    *EmailChanges
+		mfa        *MFA
 	}
 )
 
-func NewAccountUseCaseImpl(repo AccountRepository, bcrypt bcrypt.Bcrypt) AccountUseCase {
+func NewAccountUseCaseImpl(repo AccountRepository, identities IdentityRepository, bcrypt bcrypt.Bcrypt, lockout *ratelimit.Lockout, verifier *EmailVerifier, resets *PasswordResets, changes *EmailChanges, mfa *MFA) AccountUseCase {
 	return &accountUseCaseImpl{
-		repo:   repo,
-		bcrypt: bcrypt,
+		repo:       repo,
+		identities: identities,
+		bcrypt:     bcrypt,
+		lockout:    lockout,
+		verifier:   verifier,
+		resets:     resets,
+		changes:    changes,
+		mfa:        mfa,
 	}
 }
 
 func (au *accountUseCaseImpl) Register(ctx context.Context, params account.Account) response.Response {
+	if err := password.Check("password", params.Password, params.Email, params.Name); err != nil {
+		return response.Fail(err)
+	}
+
 	_, err := au.repo.FindByEmail(ctx, params.Email)
 
 	if err == nil {
@@ -45,6 +83,7 @@ func (au *accountUseCaseImpl) Register(ctx context.Context, params account.Accou
 
 	hashedPassword, err := au.bcrypt.HashPassword(params.Password)
 	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "hash password", "error", err)
 		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
 	}
 
@@ -58,6 +97,9 @@ func (au *accountUseCaseImpl) Register(ctx context.Context, params account.Accou
 	}
 
 	userID, err := au.repo.Register(ctx, user)
+	if err == exception.ErrEmailTaken {
+		return response.Error(response.StatusConflicted, exception.ErrConflicted)
+	}
 	if err != nil {
 		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
 	}
@@ -65,12 +107,24 @@ func (au *accountUseCaseImpl) Register(ctx context.Context, params account.Accou
 	user.ID = userID
 	user.Password = ""
 
+	// The account exists either way; a lost email can be sent again.
+	if err := au.verifier.Send(ctx, user); err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "send verification email", "error", err)
+	}
+
 	return response.Success(response.StatusCreated, user)
 }
 
 func (au *accountUseCaseImpl) Login(ctx context.Context, params account.AccountLogin) (response.Response, token.Token) {
+	if err := au.lockout.Check(ctx, params.Email); err != nil {
+		return response.Fail(err), token.Token{}
+	}
+
 	user, err := au.repo.FindByEmail(ctx, params.Email)
 	if err == exception.ErrNotFound {
+		if err := au.lockout.Fail(ctx, params.Email); err != nil {
+			return response.Fail(err), token.Token{}
+		}
 		return response.Error(response.StatusNotFound, exception.ErrNotFound), token.Token{}
 	}
 	if err != nil {
@@ -80,37 +134,459 @@ func (au *accountUseCaseImpl) Login(ctx context.Context, params account.AccountL
 	isPasswordValid := au.bcrypt.ComparePasswordHash(params.Password, user.Password)
 
 	if !isPasswordValid {
+		if err := au.lockout.Fail(ctx, params.Email); err != nil {
+			return response.Fail(err), token.Token{}
+		}
 		return response.Error(response.StatusUnauthorized, exception.ErrUnauthorized), token.Token{}
 	}
 
+	// Failed logins are only forgiven once the second factor is passed too,
+	// otherwise every correct password would allow more code guesses.
+	if user.MFAEnabledAt != nil {
+		challenge, err := au.mfa.PendingToken(user)
+		if err != nil {
+			logger.FromContext(ctx).ErrorContext(ctx, "sign mfa pending token", "error", err)
+			return response.Error(response.StatusInternalServerError, exception.ErrInternalServer), token.Token{}
+		}
+
+		return response.Success(response.StatusOK, challenge), token.Token{}
+	}
+
+	au.lockout.Reset(ctx, params.Email)
+
 	user.Password = ""
 
+	newToken, err := au.sign(ctx, user)
+	if err != nil {
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer), token.Token{}
+	}
+
+	return response.Success(response.StatusOK, user), newToken
+}
+
+// sign issues the session token of user.
+func (au *accountUseCaseImpl) sign(ctx context.Context, user account.Account) (token.Token, error) {
 	claims := &jwt.JWTclaim{
-		ID:     user.ID,
-		UserID: user.ID,
-		Email:  user.Email,
-		Name:   user.Name,
+		ID:           user.ID,
+		UserID:       user.ID,
+		Email:        user.Email,
+		Name:         user.Name,
+		SessionEpoch: user.SessionEpoch,
 		StandardClaims: newJWT.StandardClaims{
 			IssuedAt:  time.Now().Unix(),
-			ExpiresAt: time.Now().Add(time.Hour * 24 * 1).Unix(),
+			ExpiresAt: time.Now().Add(jwt.TokenTTL).Unix(),
 		},
 	}
 
 	tokenAlgo := newJWT.NewWithClaims(newJWT.SigningMethodHS256, claims)
 
 	tokenJWT, err := tokenAlgo.SignedString(jwt.JWT_KEY)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "sign session token", "error", err)
+		return token.Token{}, err
+	}
+
+	return token.Token{Token: tokenJWT}, nil
+}
+
+func (au *accountUseCaseImpl) Update(ctx context.Context, id int64, version int64, params account.AccountUpdate) response.Response {
+	user, err := au.repo.FindByID(ctx, id)
+	if err == exception.ErrNotFound {
+		return response.Error(response.StatusNotFound, exception.ErrNotFound)
+	}
+	if err != nil {
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
+	}
+
+	if user.Version != version {
+		return response.Error(response.StatusPreconditionFailed, exception.ErrPreconditionFailed)
+	}
+
+	params.Name.Apply(&user.Name)
+	params.Address.Apply(&user.Address)
+	user.UpdateAt = time.Now()
+
+	err = au.repo.Update(ctx, id, user)
+	if err == exception.ErrPreconditionFailed {
+		return response.Error(response.StatusPreconditionFailed, exception.ErrPreconditionFailed)
+	}
+	if err != nil {
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
+	}
+
+	user.Password = ""
+	user.Version++
+
+	return response.Success(response.StatusOK, user).WithETag(etag.Version(user.Version))
+}
+
+func (au *accountUseCaseImpl) ReadOne(ctx context.Context, id int64) response.Response {
+	user, err := au.repo.FindByID(ctx, id)
+	if err == exception.ErrNotFound {
+		return response.Error(response.StatusNotFound, exception.ErrNotFound)
+	}
+
+	if err != nil {
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
+	}
+
+	user.Password = ""
+
+	return response.Success(response.StatusOK, user).WithETag(etag.Version(user.Version))
+}
+
+func (au *accountUseCaseImpl) Delete(ctx context.Context, id int64, version int64) response.Response {
+	user, err := au.repo.FindByID(ctx, id)
+	if err == exception.ErrNotFound {
+		return response.Error(response.StatusNotFound, exception.ErrNotFound)
+	}
+	if err != nil {
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
+	}
+
+	if user.Version != version {
+		return response.Error(response.StatusPreconditionFailed, exception.ErrPreconditionFailed)
+	}
+
+	err = au.repo.Delete(ctx, user.ID, version)
+	if err == exception.ErrPreconditionFailed {
+		return response.Error(response.StatusPreconditionFailed, exception.ErrPreconditionFailed)
+	}
+	if err != nil {
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
+	}
+
+	msg := "Success Delete Account"
+
+	return response.Success(response.StatusOK, msg)
+}
+
+func (au *accountUseCaseImpl) VerifyEmail(ctx context.Context, token string) response.Response {
+	id, email, err := au.verifier.Check(token)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	user, err := au.repo.FindByID(ctx, id)
+	if err == exception.ErrNotFound || (err == nil && user.Email != email) {
+		return response.Fail(exception.ErrInvalidToken)
+	}
+	if err != nil {
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
+	}
+
+	err = au.repo.MarkVerified(ctx, id, time.Now())
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	msg := "Success Verify Email"
+
+	return response.Success(response.StatusOK, msg)
+}
+
+func (au *accountUseCaseImpl) ResendVerification(ctx context.Context, id int64) response.Response {
+	user, err := au.repo.FindByID(ctx, id)
+	if err == exception.ErrNotFound {
+		return response.Error(response.StatusNotFound, exception.ErrNotFound)
+	}
+	if err != nil {
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
+	}
+
+	if user.VerifiedAt != nil {
+		return response.Fail(exception.ErrAlreadyVerified)
+	}
+
+	if err := au.verifier.Send(ctx, user); err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "send verification email", "error", err)
+		return response.Error(response.StatusServiceUnavailable, exception.ErrUnavailable)
+	}
+
+	msg := "Success Send Verification Email"
+
+	return response.Success(response.StatusOK, msg)
+}
+
+// ForgotPassword answers the same whether or not the email belongs to an
+// account. The lookup and the mail happen in the background so that the
+// response time does not tell either.
+func (au *accountUseCaseImpl) ForgotPassword(ctx context.Context, params account.ForgotPassword) response.Response {
+	ctx = context.WithoutCancel(ctx)
+	au.resets.Go(func() { au.sendPasswordReset(ctx, params.Email) })
+
+	msg := "If the email belongs to an account, a reset link has been sent to it"
+
+	return response.Success(response.StatusOK, msg)
+}
+
+func (au *accountUseCaseImpl) sendPasswordReset(ctx context.Context, email string) {
+	user, err := au.repo.FindByEmail(ctx, email)
+	if err != nil {
+		return
+	}
+
+	if err := au.resets.Issue(ctx, user); err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "send password reset", "error", err)
+	}
+}
+
+// ResetPassword applies the password policy before the token is used up,
+// which leaves out the rule on personal data since the account is not known
+// until then.
+func (au *accountUseCaseImpl) ResetPassword(ctx context.Context, params account.ResetPassword) response.Response {
+	if err := password.Check("password", params.Password); err != nil {
+		return response.Fail(err)
+	}
+
+	userID, err := au.resets.Consume(ctx, params.Token)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	user, err := au.repo.FindByID(ctx, userID)
+	if err == exception.ErrNotFound {
+		return response.Fail(exception.ErrInvalidToken)
+	}
+	if err != nil {
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
+	}
+
+	hashedPassword, err := au.bcrypt.HashPassword(params.Password)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "hash password", "error", err)
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
+	}
+
+	err = au.repo.SetPassword(ctx, user.ID, hashedPassword, time.Now())
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	if err := au.resets.RevokeAll(ctx, user.ID); err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "revoke password resets", "error", err)
+	}
+	au.lockout.Reset(ctx, user.Email)
+
+	msg := "Success Reset Password"
+
+	return response.Success(response.StatusOK, msg)
+}
+
+// checkPassword verifies the current password of user. Failures count
+// towards the login lockout, so that a hijacked session cannot be used to
+// guess the password.
+func (au *accountUseCaseImpl) checkPassword(ctx context.Context, user account.Account, plain string) error {
+	if err := au.lockout.Check(ctx, user.Email); err != nil {
+		return err
+	}
+
+	if !au.bcrypt.ComparePasswordHash(plain, user.Password) {
+		if err := au.lockout.Fail(ctx, user.Email); err != nil {
+			return err
+		}
+		return exception.ErrWrongPassword
+	}
+
+	au.lockout.Reset(ctx, user.Email)
+
+	return nil
+}
+
+// ChangePassword logs out every other session of the account and returns
+// a new token for the current one.
+func (au *accountUseCaseImpl) ChangePassword(ctx context.Context, id int64, params account.ChangePassword) (response.Response, token.Token) {
+	user, err := au.repo.FindByID(ctx, id)
+	if err == exception.ErrNotFound {
+		return response.Error(response.StatusNotFound, exception.ErrNotFound), token.Token{}
+	}
+	if err != nil {
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer), token.Token{}
+	}
+
+	if err := au.checkPassword(ctx, user, params.CurrentPassword); err != nil {
+		return response.Fail(err), token.Token{}
+	}
+
+	if err := password.Check("new_password", params.NewPassword, user.Email, user.Name); err != nil {
+		return response.Fail(err), token.Token{}
+	}
+
+	if au.bcrypt.ComparePasswordHash(params.NewPassword, user.Password) {
+		return response.Fail(exception.ErrValidation.WithFields(exception.FieldError{
+			Field:   "new_password",
+			Tag:     "password_reused",
+			Message: "new_password must differ from the current password",
+		})), token.Token{}
+	}
+
+	hashedPassword, err := au.bcrypt.HashPassword(params.NewPassword)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "hash password", "error", err)
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer), token.Token{}
+	}
+
+	err = au.repo.SetPassword(ctx, user.ID, hashedPassword, time.Now())
+	if err != nil {
+		return response.Fail(err), token.Token{}
+	}
+
+	if err := au.resets.RevokeAll(ctx, user.ID); err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "revoke password resets", "error", err)
+	}
+
+	user.Password = ""
+	user.SessionEpoch++
+
+	newToken, err := au.sign(ctx, user)
+	if err != nil {
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer), token.Token{}
+	}
+
+	msg := "Success Change Password"
+
+	return response.Success(response.StatusOK, msg), newToken
+}
+
+// ChangeEmail mails a confirmation link to the new address; the account
+// keeps its current address until the link is opened.
+func (au *accountUseCaseImpl) ChangeEmail(ctx context.Context, id int64, params account.ChangeEmail) response.Response {
+	user, err := au.repo.FindByID(ctx, id)
+	if err == exception.ErrNotFound {
+		return response.Error(response.StatusNotFound, exception.ErrNotFound)
+	}
+	if err != nil {
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
+	}
+
+	if err := au.checkPassword(ctx, user, params.Password); err != nil {
+		return response.Fail(err)
+	}
+
+	if strings.EqualFold(params.Email, user.Email) {
+		return response.Fail(exception.ErrValidation.WithFields(exception.FieldError{
+			Field:   "email",
+			Tag:     "email_unchanged",
+			Message: "email is already the address of the account",
+		}))
+	}
+
+	_, err = au.repo.FindByEmail(ctx, params.Email)
+	if err == nil {
+		return response.Fail(exception.ErrEmailTaken)
+	}
+	if err != exception.ErrNotFound {
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
+	}
+
+	if err := au.changes.Send(ctx, user, params.Email); err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "send email change confirmation", "error", err)
+		return response.Error(response.StatusServiceUnavailable, exception.ErrUnavailable)
+	}
+
+	msg := "A confirmation link has been sent to the new email address"
+
+	return response.Success(response.StatusOK, msg)
+}
+
+// ConfirmEmail moves the account to the address the link was sent to. The
+// new address counts as verified since the link proves it is reachable.
+func (au *accountUseCaseImpl) ConfirmEmail(ctx context.Context, token string) response.Response {
+	id, from, to, err := au.changes.Check(token)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	user, err := au.repo.FindByID(ctx, id)
+	if err == exception.ErrNotFound || (err == nil && user.Email != from) {
+		return response.Fail(exception.ErrInvalidToken)
+	}
+	if err != nil {
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
+	}
+
+	err = au.repo.ChangeEmail(ctx, id, from, to, time.Now())
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	user.Email = to
+	if err := au.changes.Notify(ctx, user, from); err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "send email change notice", "error", err)
+	}
+
+	msg := "Success Change Email"
+
+	return response.Success(response.StatusOK, msg)
+}
+
+// verifyMFACode checks a code of the authenticator app of user, or one of
+// the recovery codes when allowRecovery is set. Every code works once, and
+// wrong ones count towards the login lockout.
+func (au *accountUseCaseImpl) verifyMFACode(ctx context.Context, user account.Account, code string, allowRecovery bool) error {
+	if err := au.lockout.Check(ctx, user.Email); err != nil {
+		return err
+	}
+
+	var err error
+	switch {
+	case isTOTPCode(code):
+		counter, ok := au.mfa.Validate(user, code, time.Now())
+		if !ok {
+			err = exception.ErrInvalidCode
+			break
+		}
+		err = au.repo.UseTOTPCounter(ctx, user.ID, counter)
+	case allowRecovery:
+		err = au.mfa.UseRecoveryCode(ctx, user.ID, code)
+	default:
+		err = exception.ErrInvalidCode
+	}
+
+	if err == exception.ErrInvalidCode {
+		if err := au.lockout.Fail(ctx, user.Email); err != nil {
+			return err
+		}
+	}
+
+	return err
+}
+
+// LoginMFA exchanges the token issued by Login and a code for a session.
+func (au *accountUseCaseImpl) LoginMFA(ctx context.Context, params account.MFALogin) (response.Response, token.Token) {
+	id, epoch, err := au.mfa.CheckPending(params.MFAToken)
+	if err != nil {
+		return response.Fail(err), token.Token{}
+	}
+
+	user, err := au.repo.FindByID(ctx, id)
+	if err == exception.ErrNotFound || (err == nil && (user.SessionEpoch != epoch || user.MFAEnabledAt == nil)) {
+		return response.Fail(exception.ErrInvalidToken), token.Token{}
+	}
 	if err != nil {
 		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer), token.Token{}
 	}
 
-	newToken := token.Token{
-		Token: tokenJWT,
+	if err := au.verifyMFACode(ctx, user, params.Code, true); err != nil {
+		return response.Fail(err), token.Token{}
+	}
+
+	au.lockout.Reset(ctx, user.Email)
+
+	user.Password = ""
+
+	newToken, err := au.sign(ctx, user)
+	if err != nil {
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer), token.Token{}
 	}
 
 	return response.Success(response.StatusOK, user), newToken
 }
 
-func (au *accountUseCaseImpl) Update(ctx context.Context, id int64, params account.Account) response.Response {
+// EnrollMFA starts the enrolment with a new secret. Two-factor
+// authentication stays off until a code is confirmed with ConfirmMFA, and
+// enrolling again replaces the secret.
+func (au *accountUseCaseImpl) EnrollMFA(ctx context.Context, id int64) response.Response {
 	user, err := au.repo.FindByID(ctx, id)
 	if err == exception.ErrNotFound {
 		return response.Error(response.StatusNotFound, exception.ErrNotFound)
@@ -119,38 +595,211 @@ func (au *accountUseCaseImpl) Update(ctx context.Context, id int64, params accou
 		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
 	}
 
-	hashedPassword, err := au.bcrypt.HashPassword(params.Password)
+	if user.MFAEnabledAt != nil {
+		return response.Fail(exception.ErrMFAEnabled)
+	}
+
+	enrolment, sealed, err := au.mfa.Enroll(user)
 	if err != nil {
-		return response.Error(response.StatusUnauthorized, exception.ErrUnauthorized)
+		logger.FromContext(ctx).ErrorContext(ctx, "create totp secret", "error", err)
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
 	}
 
-	user.Name = params.Name
-	user.Password = hashedPassword
-	user.Email = params.Email
-	user.Address = params.Address
-	user.UpdateAt = time.Now()
+	err = au.repo.SetTOTPSecret(ctx, user.ID, sealed)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusOK, enrolment)
+}
+
+// ConfirmMFA turns two-factor authentication on and returns the first set
+// of recovery codes.
+func (au *accountUseCaseImpl) ConfirmMFA(ctx context.Context, id int64, params account.MFACode) response.Response {
+	user, err := au.repo.FindByID(ctx, id)
+	if err == exception.ErrNotFound {
+		return response.Error(response.StatusNotFound, exception.ErrNotFound)
+	}
+	if err != nil {
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
+	}
+
+	if user.MFAEnabledAt != nil {
+		return response.Fail(exception.ErrMFAEnabled)
+	}
+	if user.TOTPSecret == nil {
+		return response.Fail(exception.ErrMFANotEnrolled)
+	}
+
+	counter, ok := au.mfa.Validate(user, params.Code, time.Now())
+	if !ok {
+		return response.Fail(exception.ErrInvalidCode)
+	}
+
+	err = au.repo.EnableTOTP(ctx, user.ID, counter, time.Now())
+	if err != nil {
+		return response.Fail(err)
+	}
 
-	if err := au.repo.Update(ctx, id, user); err != nil {
+	codes, err := au.mfa.NewRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		// Two-factor authentication is on, the codes can be regenerated.
+		logger.FromContext(ctx).ErrorContext(ctx, "create recovery codes", "error", err)
 		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
 	}
 
-	return response.Success(response.StatusOK, user)
+	return response.Success(response.StatusOK, account.RecoveryCodes{Codes: codes})
 }
 
-func (au *accountUseCaseImpl) ReadOne(ctx context.Context, id int64) response.Response {
+func (au *accountUseCaseImpl) DisableMFA(ctx context.Context, id int64, params account.MFADisable) response.Response {
 	user, err := au.repo.FindByID(ctx, id)
 	if err == exception.ErrNotFound {
 		return response.Error(response.StatusNotFound, exception.ErrNotFound)
 	}
+	if err != nil {
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
+	}
+
+	if user.MFAEnabledAt == nil {
+		return response.Fail(exception.ErrMFANotEnabled)
+	}
 
+	if err := au.checkPassword(ctx, user, params.Password); err != nil {
+		return response.Fail(err)
+	}
+
+	if err := au.verifyMFACode(ctx, user, params.Code, true); err != nil {
+		return response.Fail(err)
+	}
+
+	err = au.repo.DisableTOTP(ctx, user.ID)
 	if err != nil {
+		return response.Fail(err)
+	}
+
+	if err := au.mfa.DeleteRecoveryCodes(ctx, user.ID); err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "delete recovery codes", "error", err)
+	}
+
+	msg := "Success Disable Two-Factor Authentication"
+
+	return response.Success(response.StatusOK, msg)
+}
+
+// RegenerateRecoveryCodes replaces every recovery code, used or not. It
+// takes a code of the app, since running out of recovery codes is the
+// usual reason to call it.
+func (au *accountUseCaseImpl) RegenerateRecoveryCodes(ctx context.Context, id int64, params account.MFACode) response.Response {
+	user, err := au.repo.FindByID(ctx, id)
+	if err == exception.ErrNotFound {
+		return response.Error(response.StatusNotFound, exception.ErrNotFound)
+	}
+	if err != nil {
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
+	}
+
+	if user.MFAEnabledAt == nil {
+		return response.Fail(exception.ErrMFANotEnabled)
+	}
+
+	if err := au.verifyMFACode(ctx, user, params.Code, false); err != nil {
+		return response.Fail(err)
+	}
+
+	codes, err := au.mfa.NewRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "create recovery codes", "error", err)
 		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
 	}
 
-	return response.Success(response.StatusOK, user)
+	return response.Success(response.StatusOK, account.RecoveryCodes{Codes: codes})
+}
+
+// LoginSSO logs in the account linked to the identity, creating one when
+// the identity is new. An existing account with the same email is never
+// linked implicitly, since that would hand it to whoever controls the
+// address at the provider; its owner links the provider after logging in.
+func (au *accountUseCaseImpl) LoginSSO(ctx context.Context, provider string, claims oidc.Claims) (response.Response, token.Token) {
+	var user account.Account
+
+	identity, err := au.identities.FindBySubject(ctx, provider, claims.Subject)
+	switch err {
+	case nil:
+		user, err = au.repo.FindByID(ctx, identity.UserID)
+		if err != nil {
+			return response.Error(response.StatusInternalServerError, exception.ErrInternalServer), token.Token{}
+		}
+	case exception.ErrNotFound:
+		user, err = au.registerIdentity(ctx, provider, claims)
+		if err != nil {
+			return response.Fail(err), token.Token{}
+		}
+	default:
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer), token.Token{}
+	}
+
+	if user.MFAEnabledAt != nil {
+		challenge, err := au.mfa.PendingToken(user)
+		if err != nil {
+			logger.FromContext(ctx).ErrorContext(ctx, "sign mfa pending token", "error", err)
+			return response.Error(response.StatusInternalServerError, exception.ErrInternalServer), token.Token{}
+		}
+
+		return response.Success(response.StatusOK, challenge), token.Token{}
+	}
+
+	user.Password = ""
+
+	newToken, err := au.sign(ctx, user)
+	if err != nil {
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer), token.Token{}
+	}
+
+	return response.Success(response.StatusOK, user), newToken
 }
 
-func (au *accountUseCaseImpl) Delete(ctx context.Context, id int64) response.Response {
+// registerIdentity creates an account without a password for a new
+// identity. Its email counts as verified, which the provider vouches for.
+func (au *accountUseCaseImpl) registerIdentity(ctx context.Context, provider string, claims oidc.Claims) (account.Account, error) {
+	if claims.Email == "" || !claims.EmailVerified {
+		return account.Account{}, exception.ErrSSOEmailUnverified
+	}
+
+	_, err := au.repo.FindByEmail(ctx, claims.Email)
+	if err == nil {
+		return account.Account{}, exception.ErrAccountExists
+	}
+	if err != exception.ErrNotFound {
+		return account.Account{}, exception.ErrInternalServer
+	}
+
+	name := claims.Name
+	if name == "" {
+		name, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	now := time.Now()
+	userID, err := au.identities.Register(ctx, account.Account{
+		Name:      name,
+		Email:     claims.Email,
+		CreatedAt: now,
+	}, account.Identity{
+		Provider:  provider,
+		Subject:   claims.Subject,
+		Email:     claims.Email,
+		CreatedAt: now,
+	})
+	if err == exception.ErrEmailTaken {
+		return account.Account{}, exception.ErrAccountExists
+	}
+	if err != nil {
+		return account.Account{}, err
+	}
+
+	return au.repo.FindByID(ctx, userID)
+}
+
+func (au *accountUseCaseImpl) LinkIdentity(ctx context.Context, id int64, provider string, claims oidc.Claims) response.Response {
 	user, err := au.repo.FindByID(ctx, id)
 	if err == exception.ErrNotFound {
 		return response.Error(response.StatusNotFound, exception.ErrNotFound)
@@ -159,11 +808,62 @@ func (au *accountUseCaseImpl) Delete(ctx context.Context, id int64) response.Res
 		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
 	}
 
-	if err := au.repo.Delete(ctx, user.ID); err != nil {
+	identity := account.Identity{
+		UserID:    user.ID,
+		Provider:  provider,
+		Subject:   claims.Subject,
+		Email:     claims.Email,
+		CreatedAt: time.Now(),
+	}
+
+	err = au.identities.Create(ctx, identity)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusCreated, identity)
+}
+
+func (au *accountUseCaseImpl) ListIdentities(ctx context.Context, id int64) response.Response {
+	identities, err := au.identities.FindByUserID(ctx, id)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusOK, identities)
+}
+
+// UnlinkIdentity keeps at least one way to log in: accounts created through
+// a provider have no password until one is set with the forgot password
+// flow.
+func (au *accountUseCaseImpl) UnlinkIdentity(ctx context.Context, id int64, provider string) response.Response {
+	user, err := au.repo.FindByID(ctx, id)
+	if err == exception.ErrNotFound {
+		return response.Error(response.StatusNotFound, exception.ErrNotFound)
+	}
+	if err != nil {
 		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
 	}
 
-	msg := "Success Delete Account"
+	if user.Password == "" {
+		identities, err := au.identities.FindByUserID(ctx, id)
+		if err != nil {
+			return response.Fail(err)
+		}
+		if len(identities) <= 1 {
+			return response.Fail(exception.ErrLastLoginMethod)
+		}
+	}
+
+	err = au.identities.Delete(ctx, id, provider)
+	if err == exception.ErrNotFound {
+		return response.Error(response.StatusNotFound, exception.ErrNotFound)
+	}
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	msg := "Success Unlink Identity"
 
 	return response.Success(response.StatusOK, msg)
 }
@@ -0,0 +1,70 @@
+package account
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Risuii/helpers/i18n"
+	"github.com/Risuii/helpers/mailer"
+	"github.com/Risuii/helpers/signedtoken"
+	"github.com/Risuii/models/account"
+)
+
+// EmailVerifier mails signed verification links. A link names the account
+// and the address it was sent to, so it stops working once the address
+// changes, and it can only be used once since verifying is recorded.
+type EmailVerifier struct {
+	signer    *signedtoken.Signer
+	mailer    mailer.Mailer
+	publicURL string
+	ttl       time.Duration
+}
+
+type verificationClaims struct {
+	UserID int64  `json:"uid"`
+	Email  string `json:"email"`
+}
+
+func NewEmailVerifier(secret []byte, mail mailer.Mailer, publicURL string, ttl time.Duration) *EmailVerifier {
+	return &EmailVerifier{
+		signer:    signedtoken.New(secret, "email-verification"),
+		mailer:    mail,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		ttl:       ttl,
+	}
+}
+
+// Send mails a verification link for the current email of user, in the
+// language of the request.
+func (v *EmailVerifier) Send(ctx context.Context, user account.Account) error {
+	token, err := v.signer.Sign(verificationClaims{UserID: user.ID, Email: user.Email}, v.ttl)
+	if err != nil {
+		return err
+	}
+
+	link := v.publicURL + "/verify-email?token=" + url.QueryEscape(token)
+	trans := i18n.Translator(i18n.FromContext(ctx))
+	hours := strconv.Itoa(int(v.ttl.Hours()))
+
+	return v.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: i18n.Message(trans, "MAIL_VERIFY_EMAIL_SUBJECT", "Confirm your email address"),
+		// {0} is the name of the user, {1} the link and {2} its lifetime in hours.
+		Body: i18n.Message(trans, "MAIL_VERIFY_EMAIL_BODY",
+			"Hi {0},\n\nPlease confirm your email address by opening the link below:\n\n{1}\n\nThe link is valid for {2} hours.\n",
+			user.Name, link, hours),
+	})
+}
+
+// Check returns the account and address a token was issued for.
+func (v *EmailVerifier) Check(token string) (int64, string, error) {
+	var claims verificationClaims
+	if err := v.signer.Verify(token, &claims); err != nil {
+		return 0, "", err
+	}
+
+	return claims.UserID, claims.Email, nil
+}
@@ -0,0 +1,198 @@
+package address
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+
+	"github.com/Risuii/config/jwt"
+	"github.com/Risuii/helpers/etag"
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/patch"
+	"github.com/Risuii/helpers/response"
+	"github.com/Risuii/models/address"
+)
+
+type AddressHandler struct {
+	validate *validator.Validate
+	UseCase  AddressUseCase
+}
+
+func NewAddressHandler(router *mux.Router, validate *validator.Validate, usecase AddressUseCase) {
+	handler := &AddressHandler{
+		validate: validate,
+		UseCase:  usecase,
+	}
+
+	api := router.PathPrefix("/account/addresses").Subrouter()
+
+	api.HandleFunc("", handler.Create).Methods(http.MethodPost)
+	api.HandleFunc("", handler.List).Methods(http.MethodGet)
+	api.HandleFunc("/{id}", handler.Get).Methods(http.MethodGet)
+	api.HandleFunc("/{id}", handler.Update).Methods(http.MethodPatch)
+	api.HandleFunc("/{id}", handler.Delete).Methods(http.MethodDelete)
+	api.HandleFunc("/{id}/default", handler.SetDefault).Methods(http.MethodPut)
+}
+
+func (handler *AddressHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+	var userInput address.Address
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&userInput); err != nil {
+		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity.Wrap(err))
+		res.JSON(w)
+		return
+	}
+
+	if err := handler.validate.StructCtx(ctx, userInput); err != nil {
+		res = response.Error(response.StatusBadRequest, exception.Validation(err))
+		res.JSON(w)
+		return
+	}
+
+	res = handler.UseCase.Create(ctx, claims.UserID, userInput)
+
+	res.JSON(w)
+}
+
+func (handler *AddressHandler) List(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	res = handler.UseCase.List(ctx, claims.UserID)
+
+	res.JSON(w)
+}
+
+func (handler *AddressHandler) Get(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	params := mux.Vars(r)
+	id, _ := strconv.ParseInt(params["id"], 10, 64)
+
+	res = handler.UseCase.Get(ctx, claims.UserID, id)
+
+	res.JSON(w)
+}
+
+func (handler *AddressHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+	var userInput address.AddressUpdate
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	version, err := etag.IfMatch(r)
+	if err != nil {
+		res = response.Fail(err)
+		res.JSON(w)
+		return
+	}
+
+	params := mux.Vars(r)
+	id, _ := strconv.ParseInt(params["id"], 10, 64)
+
+	if err := patch.Decode(r.Body, &userInput); err != nil {
+		res = response.Fail(err)
+		res.JSON(w)
+		return
+	}
+
+	if err := handler.validate.StructCtx(ctx, userInput); err != nil {
+		res = response.Error(response.StatusBadRequest, exception.Validation(err))
+		res.JSON(w)
+		return
+	}
+
+	res = handler.UseCase.Update(ctx, claims.UserID, id, version, userInput)
+
+	res.JSON(w)
+}
+
+func (handler *AddressHandler) SetDefault(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	params := mux.Vars(r)
+	id, _ := strconv.ParseInt(params["id"], 10, 64)
+
+	res = handler.UseCase.SetDefault(ctx, claims.UserID, id)
+
+	res.JSON(w)
+}
+
+func (handler *AddressHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	version, err := etag.IfMatch(r)
+	if err != nil {
+		res = response.Fail(err)
+		res.JSON(w)
+		return
+	}
+
+	params := mux.Vars(r)
+	id, _ := strconv.ParseInt(params["id"], 10, 64)
+
+	res = handler.UseCase.Delete(ctx, claims.UserID, id, version)
+
+	res.JSON(w)
+}
+
+// sessionClaims reads the claims of the session cookie, answering 401
+// itself when there is no valid one.
+func sessionClaims(w http.ResponseWriter, r *http.Request) (*jwt.JWTclaim, bool) {
+	c, err := r.Cookie("token")
+	if err != nil {
+		response.Error(response.StatusUnauthorized, exception.ErrUnauthorized).JSON(w)
+		return nil, false
+	}
+
+	claims, err := jwt.ParseClaims(c.Value)
+	if err != nil || claims.UserID == 0 {
+		response.Error(response.StatusUnauthorized, exception.ErrUnauthorized).JSON(w)
+		return nil, false
+	}
+
+	return claims, true
+}
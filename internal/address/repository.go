@@ -0,0 +1,354 @@
+package address
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/retry"
+	"github.com/Risuii/helpers/stmtcache"
+	"github.com/Risuii/models/address"
+)
+
+// MaxAddresses is the size of an address book.
+const MaxAddresses = 20
+
+type (
+	// AddressRepository keeps exactly one default address per account that
+	// has any: the first address becomes the default, and deleting the
+	// default promotes the oldest remaining one.
+	AddressRepository interface {
+		Create(ctx context.Context, params address.Address) (address.Address, error)
+		FindByUserID(ctx context.Context, userID int64) ([]address.Address, error)
+		FindByID(ctx context.Context, userID int64, id int64) (address.Address, error)
+		FindDefault(ctx context.Context, userID int64) (address.Address, error)
+		Update(ctx context.Context, id int64, params address.Address) error
+		SetDefault(ctx context.Context, userID int64, id int64) error
+		Delete(ctx context.Context, userID int64, id int64, version int64) error
+	}
+
+	addressRepositoryImpl struct {
+		db        *sql.DB
+		tableName string
+		stmts     *stmtcache.Cache
+	}
+)
+
+func NewAddressRepositoryImpl(db *sql.DB, tableName string) AddressRepository {
+	return &addressRepositoryImpl{
+		db:        db,
+		tableName: tableName,
+		stmts:     stmtcache.For(db),
+	}
+}
+
+const columns = `id, userID, label, recipient, phone, street, city, province, postal_code, country, is_default, created_at, update_at, version`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAddress(row rowScanner, a *address.Address) error {
+	return row.Scan(
+		&a.ID,
+		&a.UserID,
+		&a.Label,
+		&a.Recipient,
+		&a.Phone,
+		&a.Street,
+		&a.City,
+		&a.Province,
+		&a.PostalCode,
+		&a.Country,
+		&a.Default,
+		&a.CreatedAt,
+		&a.UpdateAt,
+		&a.Version,
+	)
+}
+
+// inTx runs fn in a transaction. Errors of the exception package are
+// returned as they are, any other one is logged.
+func (ar *addressRepositoryImpl) inTx(ctx context.Context, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	err := retry.Do(ctx, func(ctx context.Context) error {
+		tx, err := ar.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := fn(ctx, tx); err != nil {
+			return err
+		}
+
+		return retry.Final(tx.Commit())
+	})
+
+	var known *exception.Error
+	if err != nil && !errors.As(err, &known) {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	return err
+}
+
+// prepare prepares queries through the statement cache. Transactions bind
+// them with tx.StmtContext.
+func (ar *addressRepositoryImpl) prepare(ctx context.Context, queries ...string) ([]*sql.Stmt, error) {
+	stmts := make([]*sql.Stmt, len(queries))
+	for i, query := range queries {
+		stmt, err := ar.stmts.Prepare(ctx, query)
+		if err != nil {
+			logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+			return nil, exception.ErrInternalServer
+		}
+		stmts[i] = stmt
+	}
+
+	return stmts, nil
+}
+
+// Create adds params to the address book and returns it as stored. It
+// fails with ErrAddressBookFull once the book holds MaxAddresses.
+func (ar *addressRepositoryImpl) Create(ctx context.Context, params address.Address) (address.Address, error) {
+	count := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE userID = ? FOR UPDATE`, ar.tableName)
+	unset := fmt.Sprintf(`UPDATE %s SET is_default = FALSE, version = version + 1 WHERE userID = ? AND is_default`, ar.tableName)
+	insert := fmt.Sprintf(`INSERT INTO %s (userID, label, recipient, phone, street, city, province, postal_code, country, is_default, created_at, update_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, ar.tableName)
+	stmts, err := ar.prepare(ctx, count, unset, insert)
+	if err != nil {
+		return params, err
+	}
+
+	created := params
+	err = ar.inTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		var n int
+		if err := tx.StmtContext(ctx, stmts[0]).QueryRowContext(ctx, params.UserID).Scan(&n); err != nil {
+			return err
+		}
+		if n >= MaxAddresses {
+			return exception.ErrAddressBookFull
+		}
+
+		created.Default = params.Default || n == 0
+		if created.Default {
+			if _, err := tx.StmtContext(ctx, stmts[1]).ExecContext(ctx, params.UserID); err != nil {
+				return err
+			}
+		}
+
+		result, err := tx.StmtContext(ctx, stmts[2]).ExecContext(
+			ctx,
+			created.UserID,
+			created.Label,
+			created.Recipient,
+			created.Phone,
+			created.Street,
+			created.City,
+			created.Province,
+			created.PostalCode,
+			created.Country,
+			created.Default,
+			created.CreatedAt,
+			created.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		created.ID, _ = result.LastInsertId()
+		created.UpdateAt = created.CreatedAt
+		created.Version = 1
+
+		return nil
+	})
+
+	return created, err
+}
+
+func (ar *addressRepositoryImpl) FindByUserID(ctx context.Context, userID int64) ([]address.Address, error) {
+	addresses := []address.Address{}
+
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE userID = ? ORDER BY is_default DESC, id`, columns, ar.tableName)
+	stmt, err := ar.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return addresses, exception.ErrInternalServer
+	}
+
+	var rows *sql.Rows
+	err = retry.Do(ctx, func(ctx context.Context) (err error) {
+		rows, err = stmt.QueryContext(ctx, userID)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return addresses, exception.ErrInternalServer
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var a address.Address
+		if err := scanAddress(rows, &a); err != nil {
+			logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+			return addresses, exception.ErrInternalServer
+		}
+		addresses = append(addresses, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return addresses, exception.ErrInternalServer
+	}
+
+	return addresses, nil
+}
+
+func (ar *addressRepositoryImpl) findOne(ctx context.Context, where string, args ...interface{}) (address.Address, error) {
+	var a address.Address
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE %s`, columns, ar.tableName, where)
+	stmt, err := ar.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return a, exception.ErrInternalServer
+	}
+
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		return scanAddress(stmt.QueryRowContext(ctx, args...), &a)
+	})
+	if err == sql.ErrNoRows {
+		return a, exception.ErrNotFound
+	}
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return a, exception.ErrInternalServer
+	}
+
+	return a, nil
+}
+
+// FindByID only finds addresses of userID.
+func (ar *addressRepositoryImpl) FindByID(ctx context.Context, userID int64, id int64) (address.Address, error) {
+	return ar.findOne(ctx, `userID = ? AND id = ?`, userID, id)
+}
+
+// FindDefault fails with ErrNotFound when the address book is empty.
+func (ar *addressRepositoryImpl) FindDefault(ctx context.Context, userID int64) (address.Address, error) {
+	return ar.findOne(ctx, `userID = ? AND is_default`, userID)
+}
+
+func (ar *addressRepositoryImpl) Update(ctx context.Context, id int64, params address.Address) error {
+	query := fmt.Sprintf(`UPDATE %s SET label = ?, recipient = ?, phone = ?, street = ?, city = ?, province = ?, postal_code = ?, country = ?, update_at = ?, version = version + 1 WHERE id = ? AND userID = ? AND version = ?`, ar.tableName)
+	stmt, err := ar.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	var result sql.Result
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		result, err = stmt.ExecContext(
+			ctx,
+			params.Label,
+			params.Recipient,
+			params.Phone,
+			params.Street,
+			params.City,
+			params.Province,
+			params.PostalCode,
+			params.Country,
+			params.UpdateAt,
+			id,
+			params.UserID,
+			params.Version,
+		)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected < 1 {
+		return exception.ErrPreconditionFailed
+	}
+
+	return nil
+}
+
+// SetDefault makes id the default address of userID, and the previous
+// default a regular one.
+func (ar *addressRepositoryImpl) SetDefault(ctx context.Context, userID int64, id int64) error {
+	set := fmt.Sprintf(`UPDATE %s SET is_default = TRUE, version = version + 1 WHERE id = ? AND userID = ? AND NOT is_default`, ar.tableName)
+	exists := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE id = ? AND userID = ?`, ar.tableName)
+	unset := fmt.Sprintf(`UPDATE %s SET is_default = FALSE, version = version + 1 WHERE userID = ? AND is_default AND id <> ?`, ar.tableName)
+	stmts, err := ar.prepare(ctx, set, exists, unset)
+	if err != nil {
+		return err
+	}
+
+	return ar.inTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		result, err := tx.StmtContext(ctx, stmts[0]).ExecContext(ctx, id, userID)
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected, _ := result.RowsAffected(); rowsAffected < 1 {
+			// Either it is the default already or it is not in the book.
+			var n int
+			if err := tx.StmtContext(ctx, stmts[1]).QueryRowContext(ctx, id, userID).Scan(&n); err != nil {
+				return err
+			}
+			if n == 0 {
+				return exception.ErrNotFound
+			}
+			return nil
+		}
+
+		_, err = tx.StmtContext(ctx, stmts[2]).ExecContext(ctx, userID, id)
+
+		return err
+	})
+}
+
+func (ar *addressRepositoryImpl) Delete(ctx context.Context, userID int64, id int64, version int64) error {
+	find := fmt.Sprintf(`SELECT is_default FROM %s WHERE id = ? AND userID = ? FOR UPDATE`, ar.tableName)
+	remove := fmt.Sprintf(`DELETE FROM %s WHERE id = ? AND version = ?`, ar.tableName)
+	promote := fmt.Sprintf(`UPDATE %s SET is_default = TRUE, version = version + 1 WHERE userID = ? ORDER BY id LIMIT 1`, ar.tableName)
+	stmts, err := ar.prepare(ctx, find, remove, promote)
+	if err != nil {
+		return err
+	}
+
+	return ar.inTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		var wasDefault bool
+		err := tx.StmtContext(ctx, stmts[0]).QueryRowContext(ctx, id, userID).Scan(&wasDefault)
+		if err == sql.ErrNoRows {
+			return exception.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.StmtContext(ctx, stmts[1]).ExecContext(ctx, id, version)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, _ := result.RowsAffected(); rowsAffected < 1 {
+			return exception.ErrPreconditionFailed
+		}
+
+		if !wasDefault {
+			return nil
+		}
+
+		_, err = tx.StmtContext(ctx, stmts[2]).ExecContext(ctx, userID)
+
+		return err
+	})
+}
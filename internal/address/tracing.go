@@ -0,0 +1,68 @@
+package address
+
+import (
+	"context"
+
+	"github.com/Risuii/helpers/response"
+	"github.com/Risuii/helpers/tracing"
+	"github.com/Risuii/models/address"
+)
+
+type addressUseCaseTracing struct {
+	AddressUseCase
+}
+
+// NewAddressUseCaseTracing starts one span per use case method.
+func NewAddressUseCaseTracing(usecase AddressUseCase) AddressUseCase {
+	return &addressUseCaseTracing{
+		AddressUseCase: usecase,
+	}
+}
+
+func (t *addressUseCaseTracing) Create(ctx context.Context, userID int64, params address.Address) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "AddressUseCase.Create")
+	res := t.AddressUseCase.Create(ctx, userID, params)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *addressUseCaseTracing) List(ctx context.Context, userID int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "AddressUseCase.List")
+	res := t.AddressUseCase.List(ctx, userID)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *addressUseCaseTracing) Get(ctx context.Context, userID int64, id int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "AddressUseCase.Get")
+	res := t.AddressUseCase.Get(ctx, userID, id)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *addressUseCaseTracing) Update(ctx context.Context, userID int64, id int64, version int64, params address.AddressUpdate) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "AddressUseCase.Update")
+	res := t.AddressUseCase.Update(ctx, userID, id, version, params)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *addressUseCaseTracing) SetDefault(ctx context.Context, userID int64, id int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "AddressUseCase.SetDefault")
+	res := t.AddressUseCase.SetDefault(ctx, userID, id)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *addressUseCaseTracing) Delete(ctx context.Context, userID int64, id int64, version int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "AddressUseCase.Delete")
+	res := t.AddressUseCase.Delete(ctx, userID, id, version)
+	tracing.End(span, res.Err())
+
+	return res
+}
@@ -0,0 +1,134 @@
+package address
+
+import (
+	"context"
+	"time"
+
+	"github.com/Risuii/helpers/etag"
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/response"
+	"github.com/Risuii/models/address"
+)
+
+type (
+	AddressUseCase interface {
+		Create(ctx context.Context, userID int64, params address.Address) response.Response
+		List(ctx context.Context, userID int64) response.Response
+		Get(ctx context.Context, userID int64, id int64) response.Response
+		Update(ctx context.Context, userID int64, id int64, version int64, params address.AddressUpdate) response.Response
+		SetDefault(ctx context.Context, userID int64, id int64) response.Response
+		Delete(ctx context.Context, userID int64, id int64, version int64) response.Response
+	}
+
+	addressUseCaseImpl struct {
+		repository AddressRepository
+	}
+)
+
+func NewAddressUseCaseImpl(repo AddressRepository) AddressUseCase {
+	return &addressUseCaseImpl{
+		repository: repo,
+	}
+}
+
+func (au *addressUseCaseImpl) Create(ctx context.Context, userID int64, params address.Address) response.Response {
+	params.ID = 0
+	params.UserID = userID
+	params.CreatedAt = time.Now()
+
+	data, err := au.repository.Create(ctx, params)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusCreated, data).WithETag(etag.Version(data.Version))
+}
+
+func (au *addressUseCaseImpl) List(ctx context.Context, userID int64) response.Response {
+	data, err := au.repository.FindByUserID(ctx, userID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	versions := make([][2]int64, 0, len(data))
+	for _, a := range data {
+		versions = append(versions, [2]int64{a.ID, a.Version})
+	}
+
+	return response.Success(response.StatusOK, data).WithETag(etag.List(versions...))
+}
+
+func (au *addressUseCaseImpl) Get(ctx context.Context, userID int64, id int64) response.Response {
+	data, err := au.repository.FindByID(ctx, userID, id)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusOK, data).WithETag(etag.Version(data.Version))
+}
+
+func (au *addressUseCaseImpl) Update(ctx context.Context, userID int64, id int64, version int64, params address.AddressUpdate) response.Response {
+	data, err := au.repository.FindByID(ctx, userID, id)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	if data.Version != version {
+		return response.Error(response.StatusPreconditionFailed, exception.ErrPreconditionFailed)
+	}
+
+	params.Label.Apply(&data.Label)
+	params.Recipient.Apply(&data.Recipient)
+	params.Phone.Apply(&data.Phone)
+	params.Street.Apply(&data.Street)
+	params.City.Apply(&data.City)
+	params.Province.Apply(&data.Province)
+	params.PostalCode.Apply(&data.PostalCode)
+	params.Country.Apply(&data.Country)
+	data.UpdateAt = time.Now()
+
+	if err := au.repository.Update(ctx, id, data); err != nil {
+		return response.Fail(err)
+	}
+
+	data.Version++
+
+	return response.Success(response.StatusOK, data).WithETag(etag.Version(data.Version))
+}
+
+func (au *addressUseCaseImpl) SetDefault(ctx context.Context, userID int64, id int64) response.Response {
+	if err := au.repository.SetDefault(ctx, userID, id); err != nil {
+		return response.Fail(err)
+	}
+
+	return au.Get(ctx, userID, id)
+}
+
+// Delete removes the address. When it was the default, the oldest of the
+// remaining addresses becomes the default.
+func (au *addressUseCaseImpl) Delete(ctx context.Context, userID int64, id int64, version int64) response.Response {
+	if err := au.repository.Delete(ctx, userID, id, version); err != nil {
+		return response.Fail(err)
+	}
+
+	msg := "Success Delete Address"
+
+	return response.Success(response.StatusOK, msg)
+}
+
+// ShippingAddress picks the address an order ships to: the address id of
+// the book of userID, or its default one when id is 0. It fails with
+// ErrNoShippingAddress when the book is empty. Orders keep a copy of the
+// address, since it can be edited or deleted afterwards.
+func ShippingAddress(ctx context.Context, repo AddressRepository, userID int64, id int64) (address.Address, error) {
+	if id != 0 {
+		return repo.FindByID(ctx, userID, id)
+	}
+
+	data, err := repo.FindDefault(ctx, userID)
+	if err == exception.ErrNotFound {
+		return data, exception.ErrNoShippingAddress
+	}
+
+	return data, err
+}
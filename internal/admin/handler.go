@@ -0,0 +1,145 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/response"
+	"github.com/Risuii/models/store"
+)
+
+type (
+	AdminHandler struct {
+		token    string
+		validate *validator.Validate
+		UseCase  AdminUseCase
+		stores   StoreModeration
+	}
+
+	// StoreModeration is the part of the store use case that admins
+	// use to moderate stores.
+	StoreModeration interface {
+		Moderate(ctx context.Context, id int64, params store.Moderation) response.Response
+		ListByStatus(ctx context.Context, status store.Status) response.Response
+		ModerationLog(ctx context.Context, id int64) response.Response
+	}
+)
+
+func NewAdminHandler(router *mux.Router, token string, validate *validator.Validate, usecase AdminUseCase, stores StoreModeration) {
+	handler := &AdminHandler{
+		token:    token,
+		validate: validate,
+		UseCase:  usecase,
+		stores:   stores,
+	}
+
+	api := router.PathPrefix("/admin").Subrouter()
+	api.Use(handler.authorize)
+
+	api.HandleFunc("/db/stats", handler.PoolStats).Methods(http.MethodGet)
+	api.HandleFunc("/stores", handler.ListStores).Methods(http.MethodGet)
+	api.HandleFunc("/stores/{id}/moderation", handler.ModerateStore).Methods(http.MethodPost)
+	api.HandleFunc("/stores/{id}/moderation", handler.ModerationLog).Methods(http.MethodGet)
+}
+
+// authorize only lets requests carrying the configured admin bearer token
+// through. Without a configured token every request is refused.
+func (handler *AdminHandler) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if handler.token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(handler.token)) != 1 {
+			response.Error(response.StatusUnauthorized, exception.ErrUnauthorized).JSON(w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (handler *AdminHandler) PoolStats(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	res = handler.UseCase.PoolStats(ctx)
+
+	res.JSON(w)
+}
+
+// ListStores lists the stores in the state of ?status=, suspended ones by
+// default.
+func (handler *AdminHandler) ListStores(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	status := store.Status(r.URL.Query().Get("status"))
+	if status == "" {
+		status = store.StatusSuspended
+	}
+
+	switch status {
+	case store.StatusDraft, store.StatusActive, store.StatusVacation, store.StatusSuspended, store.StatusClosed:
+	default:
+		res = response.Fail(exception.ErrValidation.WithFields(exception.FieldError{
+			Field:   "status",
+			Tag:     "oneof",
+			Param:   "draft active vacation suspended closed",
+			Message: "status failed on the 'oneof' rule",
+		}))
+		res.JSON(w)
+		return
+	}
+
+	res = handler.stores.ListByStatus(ctx, status)
+
+	res.JSON(w)
+}
+
+// ModerateStore suspends, reinstates or closes a store, see
+// store.Moderation. The owner is mailed about it.
+func (handler *AdminHandler) ModerateStore(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+	var userInput store.Moderation
+
+	ctx := r.Context()
+
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	if err := json.NewDecoder(r.Body).Decode(&userInput); err != nil {
+		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity.Wrap(err))
+		res.JSON(w)
+		return
+	}
+
+	if err := handler.validate.StructCtx(ctx, userInput); err != nil {
+		res = response.Error(response.StatusBadRequest, exception.Validation(err))
+		res.JSON(w)
+		return
+	}
+
+	res = handler.stores.Moderate(ctx, id, userInput)
+
+	res.JSON(w)
+}
+
+// ModerationLog is the audit trail of a store, moderation included.
+func (handler *AdminHandler) ModerationLog(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	res = handler.stores.ModerationLog(ctx, id)
+
+	res.JSON(w)
+}
@@ -0,0 +1,41 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Risuii/helpers/response"
+	"github.com/Risuii/models/admin"
+)
+
+type (
+	AdminUseCase interface {
+		PoolStats(ctx context.Context) response.Response
+	}
+
+	adminUseCaseImpl struct {
+		db *sql.DB
+	}
+)
+
+func NewAdminUseCaseImpl(db *sql.DB) AdminUseCase {
+	return &adminUseCaseImpl{
+		db: db,
+	}
+}
+
+func (au *adminUseCaseImpl) PoolStats(ctx context.Context) response.Response {
+	stats := au.db.Stats()
+
+	return response.Success(response.StatusOK, admin.PoolStats{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDuration:       stats.WaitDuration.String(),
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	})
+}
@@ -0,0 +1,104 @@
+package item
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Risuii/helpers/cache"
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/models/item"
+)
+
+type itemRepositoryCache struct {
+	ItemRepository
+	loader *cache.Loader
+}
+
+// NewItemRepositoryCache serves item reads from c and drops the affected
+// entries whenever an item is written.
+func NewItemRepositoryCache(repo ItemRepository, c cache.Cache, ttl time.Duration) ItemRepository {
+	return &itemRepositoryCache{
+		ItemRepository: repo,
+		loader:         cache.NewLoader(c, ttl),
+	}
+}
+
+func itemKey(id int64) string {
+	return fmt.Sprintf("item:%d", id)
+}
+
+func storeItemsKey(storeID int64) string {
+	return fmt.Sprintf("items:store:%d", storeID)
+}
+
+func (c *itemRepositoryCache) GetAllItem(ctx context.Context, storeID int64) ([]item.Item, error) {
+	return cache.Load(ctx, c.loader, storeItemsKey(storeID), func(ctx context.Context) ([]item.Item, error) {
+		return c.ItemRepository.GetAllItem(ctx, storeID)
+	})
+}
+
+func (c *itemRepositoryCache) FindByID(ctx context.Context, id int64) (item.Item, error) {
+	return cache.Load(ctx, c.loader, itemKey(id), func(ctx context.Context) (item.Item, error) {
+		return c.ItemRepository.FindByID(ctx, id)
+	})
+}
+
+func (c *itemRepositoryCache) FindByIDWithStoreID(ctx context.Context, id int64, storeID int64) (item.Item, error) {
+	data, err := c.FindByID(ctx, id)
+	if err != nil {
+		return data, err
+	}
+
+	if data.StoreID != storeID {
+		return item.Item{}, exception.ErrNotFound
+	}
+
+	return data, nil
+}
+
+func (c *itemRepositoryCache) AddItem(ctx context.Context, params item.Item) (int64, error) {
+	ID, err := c.ItemRepository.AddItem(ctx, params)
+	if err == nil {
+		c.loader.Invalidate(ctx, storeItemsKey(params.StoreID))
+	}
+
+	return ID, err
+}
+
+func (c *itemRepositoryCache) UpdateItem(ctx context.Context, id int64, params item.Item) error {
+	return c.write(ctx, id, func() error {
+		return c.ItemRepository.UpdateItem(ctx, id, params)
+	})
+}
+
+func (c *itemRepositoryCache) UpdateKuantitas(ctx context.Context, id int64, params item.Item) error {
+	return c.write(ctx, id, func() error {
+		return c.ItemRepository.UpdateKuantitas(ctx, id, params)
+	})
+}
+
+func (c *itemRepositoryCache) DeleteItem(ctx context.Context, id int64, version int64) error {
+	return c.write(ctx, id, func() error {
+		return c.ItemRepository.DeleteItem(ctx, id, version)
+	})
+}
+
+// write runs fn and invalidates the item and the item list of its store,
+// whatever fn returns: a failed write, ErrPreconditionFailed above all,
+// tells that the cached entry may be stale. An item never moves between
+// stores, so the store is looked up through the cache before the row is
+// changed or gone.
+func (c *itemRepositoryCache) write(ctx context.Context, id int64, fn func() error) error {
+	current, lookupErr := c.FindByID(ctx, id)
+
+	err := fn()
+
+	keys := []string{itemKey(id)}
+	if lookupErr == nil {
+		keys = append(keys, storeItemsKey(current.StoreID))
+	}
+	c.loader.Invalidate(ctx, keys...)
+
+	return err
+}
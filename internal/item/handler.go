@@ -1,29 +1,47 @@
 package item
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"strconv"
 
-	newJWT "github.com/dgrijalva/jwt-go"
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 
 	"github.com/Risuii/config/jwt"
+	"github.com/Risuii/helpers/apikey"
+	"github.com/Risuii/helpers/etag"
 	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/media"
+	"github.com/Risuii/helpers/patch"
 	"github.com/Risuii/helpers/response"
 	"github.com/Risuii/models/item"
+	"github.com/Risuii/models/store"
 )
 
-type ItemHandler struct {
-	validate *validator.Validate
-	UseCase  ItemUseCase
-}
+type (
+	ItemHandler struct {
+		validate *validator.Validate
+		UseCase  ItemUseCase
+		access   StoreAccess
+		uploads  media.Limits
+	}
 
-func NewItemHandler(router *mux.Router, validate *validator.Validate, usecase ItemUseCase) {
+	// StoreAccess tells whether an account may act on the items of a
+	// store, see store.Access.
+	StoreAccess interface {
+		Authorize(ctx context.Context, storeID int64, userID int64, p store.Permission) (store.Role, error)
+		Permits(ctx context.Context, storeID int64, p store.Permission) error
+	}
+)
+
+func NewItemHandler(router *mux.Router, validate *validator.Validate, usecase ItemUseCase, access StoreAccess, uploads media.Limits) {
 	handler := ItemHandler{
 		validate: validate,
 		UseCase:  usecase,
+		access:   access,
+		uploads:  uploads,
 	}
 
 	api := router.PathPrefix("/store").Subrouter()
@@ -33,6 +51,10 @@ func NewItemHandler(router *mux.Router, validate *validator.Validate, usecase It
 	api.HandleFunc("/items/{itemID}", handler.GetOneItem).Methods(http.MethodGet)
 	api.HandleFunc("/items/{id}", handler.UpdateItem).Methods(http.MethodPatch)
 	api.HandleFunc("/items/{id}", handler.DeleteItem).Methods(http.MethodDelete)
+	api.HandleFunc("/items/{id}/images", handler.AddImage).Methods(http.MethodPost)
+	api.HandleFunc("/items/{id}/images", handler.GetImages).Methods(http.MethodGet)
+	api.HandleFunc("/items/{id}/images/order", handler.ReorderImages).Methods(http.MethodPut)
+	api.HandleFunc("/items/{id}/images/{imageID}", handler.DeleteImage).Methods(http.MethodDelete)
 }
 
 func (handler *ItemHandler) AddItem(w http.ResponseWriter, r *http.Request) {
@@ -41,33 +63,24 @@ func (handler *ItemHandler) AddItem(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 
-	c, err := r.Cookie("Store-token")
-	if err != nil {
-		res = response.Error(response.StatusUnauthorized, exception.ErrUnauthorized)
-		res.JSON(w)
+	storeID, ok := handler.storeOf(w, r, apikey.ScopeItemsWrite, store.PermissionItemsWrite)
+	if !ok {
 		return
 	}
 
-	tokenString := c.Value
-	claims := &jwt.JWTclaim{}
-
-	newJWT.ParseWithClaims(tokenString, claims, func(t *newJWT.Token) (interface{}, error) {
-		return jwt.JWT_KEY, nil
-	})
-
 	if err := json.NewDecoder(r.Body).Decode(&userInput); err != nil {
-		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity)
+		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity.Wrap(err))
 		res.JSON(w)
 		return
 	}
 
 	if err := handler.validate.StructCtx(ctx, userInput); err != nil {
-		res = response.Error(response.StatusBadRequest, exception.ErrBadRequest)
+		res = response.Error(response.StatusBadRequest, exception.Validation(err))
 		res.JSON(w)
 		return
 	}
 
-	res = handler.UseCase.AddItem(ctx, claims.StoreID, userInput)
+	res = handler.UseCase.AddItem(ctx, storeID, userInput)
 
 	res.JSON(w)
 }
@@ -77,101 +90,228 @@ func (handler *ItemHandler) GetAllItems(w http.ResponseWriter, r *http.Request)
 
 	ctx := r.Context()
 
-	c, err := r.Cookie("Store-token")
-	if err != nil {
-		res = response.Error(response.StatusUnauthorized, exception.ErrUnauthorized)
-		res.JSON(w)
+	storeID, ok := handler.storeOf(w, r, apikey.ScopeItemsRead, store.PermissionItemsRead)
+	if !ok {
 		return
 	}
 
-	tokenString := c.Value
-	claims := &jwt.JWTclaim{}
+	res = handler.UseCase.GetAllItems(ctx, storeID)
+
+	res.JSON(w)
+}
+
+func (handler *ItemHandler) GetOneItem(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
 
-	newJWT.ParseWithClaims(tokenString, claims, func(t *newJWT.Token) (interface{}, error) {
-		return jwt.JWT_KEY, nil
-	})
+	storeID, ok := handler.storeOf(w, r, apikey.ScopeItemsRead, store.PermissionItemsRead)
+	if !ok {
+		return
+	}
 
-	res = handler.UseCase.GetAllItems(ctx, claims.StoreID)
+	params := mux.Vars(r)
+	itemID, _ := strconv.ParseInt(params["itemID"], 10, 64)
+
+	res = handler.UseCase.GetOneItem(ctx, itemID, storeID)
 
 	res.JSON(w)
 }
 
-func (handler *ItemHandler) GetOneItem(w http.ResponseWriter, r *http.Request) {
+func (handler *ItemHandler) UpdateItem(w http.ResponseWriter, r *http.Request) {
 	var res response.Response
+	var userInput item.ItemUpdate
 
 	ctx := r.Context()
 
-	c, err := r.Cookie("Store-token")
+	params := mux.Vars(r)
+	id, _ := strconv.ParseInt(params["id"], 10, 64)
+
+	storeID, ok := handler.storeOf(w, r, apikey.ScopeItemsWrite, store.PermissionItemsWrite)
+	if !ok {
+		return
+	}
+
+	version, err := etag.IfMatch(r)
 	if err != nil {
-		res = response.Error(response.StatusUnauthorized, exception.ErrUnauthorized)
+		res = response.Fail(err)
 		res.JSON(w)
 		return
 	}
 
-	tokenString := c.Value
-	claims := &jwt.JWTclaim{}
+	if err := patch.Decode(r.Body, &userInput); err != nil {
+		res = response.Fail(err)
+		res.JSON(w)
+		return
+	}
 
-	newJWT.ParseWithClaims(tokenString, claims, func(t *newJWT.Token) (interface{}, error) {
-		return jwt.JWT_KEY, nil
-	})
+	if err := handler.validate.StructCtx(ctx, userInput); err != nil {
+		res = response.Error(response.StatusBadRequest, exception.Validation(err))
+		res.JSON(w)
+		return
+	}
+
+	res = handler.UseCase.UpdateItem(ctx, id, storeID, version, userInput)
+
+	res.JSON(w)
+}
+
+func (handler *ItemHandler) DeleteItem(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
 
 	params := mux.Vars(r)
-	itemID, _ := strconv.ParseInt(params["itemID"], 10, 64)
+	id, _ := strconv.ParseInt(params["id"], 10, 64)
+
+	ctx := r.Context()
 
-	res = handler.UseCase.GetOneItem(ctx, itemID, claims.StoreID)
+	storeID, ok := handler.storeOf(w, r, apikey.ScopeItemsWrite, store.PermissionItemsWrite)
+	if !ok {
+		return
+	}
+
+	version, err := etag.IfMatch(r)
+	if err != nil {
+		res = response.Fail(err)
+		res.JSON(w)
+		return
+	}
+
+	res = handler.UseCase.DeleteItem(ctx, id, storeID, version)
 
 	res.JSON(w)
 }
 
-func (handler *ItemHandler) UpdateItem(w http.ResponseWriter, r *http.Request) {
+// AddImage takes the image in the "image" field of a multipart form.
+func (handler *ItemHandler) AddImage(w http.ResponseWriter, r *http.Request) {
 	var res response.Response
-	var userInput item.Item
 
 	ctx := r.Context()
 
-	params := mux.Vars(r)
-	id, _ := strconv.ParseInt(params["id"], 10, 64)
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
 
-	c, err := r.Cookie("Store-token")
+	storeID, ok := handler.storeOf(w, r, apikey.ScopeItemsWrite, store.PermissionItemsWrite)
+	if !ok {
+		return
+	}
+
+	data, err := media.Read(w, r, "image", handler.uploads)
 	if err != nil {
-		res = response.Error(response.StatusUnauthorized, exception.ErrUnauthorized)
+		res = response.Fail(err)
 		res.JSON(w)
 		return
 	}
 
-	tokenString := c.Value
-	claims := &jwt.JWTclaim{}
+	res = handler.UseCase.AddImage(ctx, id, storeID, data)
+
+	res.JSON(w)
+}
+
+func (handler *ItemHandler) GetImages(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	storeID, ok := handler.storeOf(w, r, apikey.ScopeItemsRead, store.PermissionItemsRead)
+	if !ok {
+		return
+	}
+
+	res = handler.UseCase.GetImages(ctx, id, storeID)
+
+	res.JSON(w)
+}
+
+func (handler *ItemHandler) ReorderImages(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+	var userInput item.ImageOrder
+
+	ctx := r.Context()
+
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
 
-	newJWT.ParseWithClaims(tokenString, claims, func(t *newJWT.Token) (interface{}, error) {
-		return jwt.JWT_KEY, nil
-	})
+	storeID, ok := handler.storeOf(w, r, apikey.ScopeItemsWrite, store.PermissionItemsWrite)
+	if !ok {
+		return
+	}
 
 	if err := json.NewDecoder(r.Body).Decode(&userInput); err != nil {
-		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity)
+		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity.Wrap(err))
 		res.JSON(w)
 		return
 	}
 
 	if err := handler.validate.StructCtx(ctx, userInput); err != nil {
-		res = response.Error(response.StatusBadRequest, exception.ErrBadRequest)
+		res = response.Error(response.StatusBadRequest, exception.Validation(err))
 		res.JSON(w)
 		return
 	}
 
-	res = handler.UseCase.UpdateItem(ctx, id, userInput)
+	res = handler.UseCase.ReorderImages(ctx, id, storeID, userInput)
 
 	res.JSON(w)
 }
 
-func (handler *ItemHandler) DeleteItem(w http.ResponseWriter, r *http.Request) {
+func (handler *ItemHandler) DeleteImage(w http.ResponseWriter, r *http.Request) {
 	var res response.Response
 
+	ctx := r.Context()
+
 	params := mux.Vars(r)
 	id, _ := strconv.ParseInt(params["id"], 10, 64)
+	imageID, _ := strconv.ParseInt(params["imageID"], 10, 64)
 
-	ctx := r.Context()
+	storeID, ok := handler.storeOf(w, r, apikey.ScopeItemsWrite, store.PermissionItemsWrite)
+	if !ok {
+		return
+	}
 
-	res = handler.UseCase.DeleteItem(ctx, id)
+	res = handler.UseCase.DeleteImage(ctx, id, storeID, imageID)
 
 	res.JSON(w)
 }
+
+// storeOf returns the store the request acts for. Requests authenticated
+// with an API key act for the key's store and need scope; others need the
+// Store-token cookie of an account whose role in the store has permission.
+// Either way the state of the store must allow permission; suspended and
+// closed stores are frozen. It answers 401 or 403 itself.
+func (handler *ItemHandler) storeOf(w http.ResponseWriter, r *http.Request, scope string, permission store.Permission) (int64, bool) {
+	if principal, ok := apikey.FromContext(r.Context()); ok {
+		if !principal.Allows(scope) {
+			response.Fail(exception.ErrInsufficientScope).JSON(w)
+			return 0, false
+		}
+
+		if err := handler.access.Permits(r.Context(), principal.StoreID, permission); err != nil {
+			response.Fail(err).JSON(w)
+			return 0, false
+		}
+
+		return principal.StoreID, true
+	}
+
+	c, err := r.Cookie("Store-token")
+	if err != nil {
+		response.Error(response.StatusUnauthorized, exception.ErrUnauthorized).JSON(w)
+		return 0, false
+	}
+
+	claims, err := jwt.ParseClaims(c.Value)
+	if err != nil || claims.StoreID == 0 || claims.UserID == 0 {
+		response.Error(response.StatusUnauthorized, exception.ErrUnauthorized).JSON(w)
+		return 0, false
+	}
+
+	// Members who were removed keep their cookie until it expires.
+	if _, err := handler.access.Authorize(r.Context(), claims.StoreID, claims.UserID, permission); err != nil {
+		if err == exception.ErrNotFound {
+			err = exception.ErrForbidden
+		}
+		response.Fail(err).JSON(w)
+		return 0, false
+	}
+
+	return claims.StoreID, true
+}
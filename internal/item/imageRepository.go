@@ -0,0 +1,248 @@
+package item
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/retry"
+	"github.com/Risuii/helpers/stmtcache"
+	"github.com/Risuii/models/item"
+)
+
+// MaxImages is the size of the gallery of an item.
+const MaxImages = 10
+
+type (
+	// ImageRepository keeps the galleries of items. Positions of a gallery
+	// always run from 0 without gaps: images are added last, and deleting
+	// one moves up those after it. The files themselves are in a
+	// blob.BlobStore, under Key and ThumbnailKey.
+	ImageRepository interface {
+		Create(ctx context.Context, params item.Image) (item.Image, error)
+		FindByItemID(ctx context.Context, itemID int64) ([]item.Image, error)
+		Reorder(ctx context.Context, itemID int64, ids []int64) error
+		Delete(ctx context.Context, itemID int64, id int64) (item.Image, error)
+	}
+
+	imageRepositoryImpl struct {
+		db        *sql.DB
+		tableName string
+		stmts     *stmtcache.Cache
+	}
+)
+
+func NewImageRepositoryImpl(db *sql.DB, tableName string) ImageRepository {
+	return &imageRepositoryImpl{
+		db:        db,
+		tableName: tableName,
+		stmts:     stmtcache.For(db),
+	}
+}
+
+const imageColumns = `id, itemID, position, blob_key, thumbnail_key, content_type, width, height, size, created_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanImage(row rowScanner, i *item.Image) error {
+	return row.Scan(
+		&i.ID,
+		&i.ItemID,
+		&i.Position,
+		&i.Key,
+		&i.ThumbnailKey,
+		&i.ContentType,
+		&i.Width,
+		&i.Height,
+		&i.Size,
+		&i.CreatedAt,
+	)
+}
+
+// inTx runs fn in a transaction. Errors of the exception package are
+// returned as they are, any other one is logged.
+func (ir *imageRepositoryImpl) inTx(ctx context.Context, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	err := retry.Do(ctx, func(ctx context.Context) error {
+		tx, err := ir.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := fn(ctx, tx); err != nil {
+			return err
+		}
+
+		return retry.Final(tx.Commit())
+	})
+
+	var known *exception.Error
+	if err != nil && !errors.As(err, &known) {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	return err
+}
+
+// Create adds params at the end of the gallery of its item and returns it
+// as stored. It fails with ErrGalleryFull once the gallery holds
+// MaxImages.
+func (ir *imageRepositoryImpl) Create(ctx context.Context, params item.Image) (item.Image, error) {
+	count := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE itemID = ? FOR UPDATE`, ir.tableName)
+	insert := fmt.Sprintf(`INSERT INTO %s (itemID, position, blob_key, thumbnail_key, content_type, width, height, size, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`, ir.tableName)
+
+	created := params
+	err := ir.inTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		var n int
+		if err := tx.QueryRowContext(ctx, count, params.ItemID).Scan(&n); err != nil {
+			return err
+		}
+		if n >= MaxImages {
+			return exception.ErrGalleryFull
+		}
+
+		created.Position = n
+		result, err := tx.ExecContext(
+			ctx,
+			insert,
+			created.ItemID,
+			created.Position,
+			created.Key,
+			created.ThumbnailKey,
+			created.ContentType,
+			created.Width,
+			created.Height,
+			created.Size,
+			created.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		created.ID, _ = result.LastInsertId()
+
+		return nil
+	})
+
+	return created, err
+}
+
+func (ir *imageRepositoryImpl) FindByItemID(ctx context.Context, itemID int64) ([]item.Image, error) {
+	images := []item.Image{}
+
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE itemID = ? ORDER BY position`, imageColumns, ir.tableName)
+	stmt, err := ir.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+		return images, exception.ErrInternalServer
+	}
+
+	var rows *sql.Rows
+	err = retry.Do(ctx, func(ctx context.Context) (err error) {
+		rows, err = stmt.QueryContext(ctx, itemID)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+		return images, exception.ErrInternalServer
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var i item.Image
+		if err := scanImage(rows, &i); err != nil {
+			logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+			return images, exception.ErrInternalServer
+		}
+		images = append(images, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+		return images, exception.ErrInternalServer
+	}
+
+	return images, nil
+}
+
+// Reorder gives the images of itemID the positions of their IDs in ids. It
+// fails with ErrImageOrder unless ids lists every image of the item once.
+func (ir *imageRepositoryImpl) Reorder(ctx context.Context, itemID int64, ids []int64) error {
+	find := fmt.Sprintf(`SELECT id FROM %s WHERE itemID = ? FOR UPDATE`, ir.tableName)
+	move := fmt.Sprintf(`UPDATE %s SET position = ? WHERE id = ?`, ir.tableName)
+
+	return ir.inTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, find, itemID)
+		if err != nil {
+			return err
+		}
+
+		current := map[int64]bool{}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			current[id] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(ids) != len(current) {
+			return exception.ErrImageOrder
+		}
+		for _, id := range ids {
+			if !current[id] {
+				return exception.ErrImageOrder
+			}
+			delete(current, id)
+		}
+
+		for position, id := range ids {
+			if _, err := tx.ExecContext(ctx, move, position, id); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Delete removes image id from the gallery of itemID and returns it, so
+// that its files can be deleted as well.
+func (ir *imageRepositoryImpl) Delete(ctx context.Context, itemID int64, id int64) (item.Image, error) {
+	find := fmt.Sprintf(`SELECT %s FROM %s WHERE id = ? AND itemID = ? FOR UPDATE`, imageColumns, ir.tableName)
+	remove := fmt.Sprintf(`DELETE FROM %s WHERE id = ?`, ir.tableName)
+	shift := fmt.Sprintf(`UPDATE %s SET position = position - 1 WHERE itemID = ? AND position > ?`, ir.tableName)
+
+	var deleted item.Image
+	err := ir.inTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		err := scanImage(tx.QueryRowContext(ctx, find, id, itemID), &deleted)
+		if err == sql.ErrNoRows {
+			return exception.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, remove, id); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, shift, itemID, deleted.Position)
+
+		return err
+	})
+
+	return deleted, err
+}
@@ -0,0 +1,46 @@
+package item
+
+import (
+	"context"
+
+	"github.com/Risuii/helpers/metrics"
+	"github.com/Risuii/models/item"
+)
+
+type itemRepositoryMetrics struct {
+	ItemRepository
+}
+
+// NewItemRepositoryMetrics counts created items and quantity writes.
+func NewItemRepositoryMetrics(repo ItemRepository) ItemRepository {
+	return &itemRepositoryMetrics{
+		ItemRepository: repo,
+	}
+}
+
+func (m *itemRepositoryMetrics) AddItem(ctx context.Context, params item.Item) (int64, error) {
+	ID, err := m.ItemRepository.AddItem(ctx, params)
+	if err == nil {
+		metrics.ItemsCreated.Inc()
+	}
+
+	return ID, err
+}
+
+func (m *itemRepositoryMetrics) UpdateItem(ctx context.Context, id int64, params item.Item) error {
+	err := m.ItemRepository.UpdateItem(ctx, id, params)
+	if err == nil {
+		metrics.StockChanges.WithLabelValues("edit").Inc()
+	}
+
+	return err
+}
+
+func (m *itemRepositoryMetrics) UpdateKuantitas(ctx context.Context, id int64, params item.Item) error {
+	err := m.ItemRepository.UpdateKuantitas(ctx, id, params)
+	if err == nil {
+		metrics.StockChanges.WithLabelValues("restock").Inc()
+	}
+
+	return err
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"log"
 
 	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/retry"
+	"github.com/Risuii/helpers/stmtcache"
 	"github.com/Risuii/models/item"
 )
 
@@ -16,15 +18,16 @@ type (
 		GetAllItem(ctx context.Context, storeID int64) ([]item.Item, error)
 		FindByIDWithStoreID(ctx context.Context, id int64, storeID int64) (item.Item, error)
 		FindByID(ctx context.Context, id int64) (item.Item, error)
-		FindByName(ctx context.Context, name string) (item.Item, error)
+		FindByName(ctx context.Context, storeID int64, name string) (item.Item, error)
 		UpdateItem(ctx context.Context, id int64, params item.Item) error
 		UpdateKuantitas(ctx context.Context, id int64, params item.Item) error
-		DeleteItem(ctx context.Context, id int64) error
+		DeleteItem(ctx context.Context, id int64, version int64) error
 	}
 
 	itemRepositoryImpl struct {
 		DB        *sql.DB
 		tableName string
+		stmts     *stmtcache.Cache
 	}
 )
 
@@ -32,29 +35,32 @@ func NewItemRepositoryImpl(db *sql.DB, tableName string) ItemRepository {
 	return &itemRepositoryImpl{
 		DB:        db,
 		tableName: tableName,
+		stmts:     stmtcache.For(db),
 	}
 }
 
 func (repo *itemRepositoryImpl) AddItem(ctx context.Context, params item.Item) (int64, error) {
 	query := fmt.Sprintf(`INSERT INTO %s (storeID, name, description, quantity, created_at) VALUES (?,?,?,?,?)`, repo.tableName)
-	stmt, err := repo.DB.PrepareContext(ctx, query)
+	stmt, err := repo.stmts.Prepare(ctx, query)
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 		return 0, exception.ErrInternalServer
 	}
 
-	defer stmt.Close()
-
-	result, err := stmt.ExecContext(
-		ctx,
-		params.StoreID,
-		params.Name,
-		params.Description,
-		params.Quantity,
-		params.CreatedAt,
-	)
+	var result sql.Result
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		result, err = stmt.ExecContext(
+			ctx,
+			params.StoreID,
+			params.Name,
+			params.Description,
+			params.Quantity,
+			params.CreatedAt,
+		)
+		return err
+	})
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 		return 0, exception.ErrInternalServer
 	}
 
@@ -66,9 +72,20 @@ func (repo *itemRepositoryImpl) AddItem(ctx context.Context, params item.Item) (
 func (repo *itemRepositoryImpl) GetAllItem(ctx context.Context, storeID int64) ([]item.Item, error) {
 	var items []item.Item
 
-	rows, err := repo.DB.Query(fmt.Sprintf(`SELECT id, storeID, name, description, quantity, created_at, update_at FROM %s WHERE storeID = %d`, repo.tableName, storeID))
+	query := fmt.Sprintf(`SELECT id, storeID, name, description, quantity, created_at, update_at, version FROM %s WHERE storeID = ?`, repo.tableName)
+	stmt, err := repo.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
+		return items, exception.ErrInternalServer
+	}
+
+	var rows *sql.Rows
+	err = retry.Do(ctx, func(ctx context.Context) (err error) {
+		rows, err = stmt.QueryContext(ctx, storeID)
+		return err
+	})
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 		return items, exception.ErrInternalServer
 	}
 
@@ -84,8 +101,9 @@ func (repo *itemRepositoryImpl) GetAllItem(ctx context.Context, storeID int64) (
 			&c.Quantity,
 			&c.CreatedAt,
 			&c.UpdateAt,
+			&c.Version,
 		); err != nil {
-			log.Println(err)
+			logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 			return items, exception.ErrInternalServer
 		}
 		items = append(items, c)
@@ -97,30 +115,29 @@ func (repo *itemRepositoryImpl) GetAllItem(ctx context.Context, storeID int64) (
 func (repo *itemRepositoryImpl) FindByIDWithStoreID(ctx context.Context, id int64, storeID int64) (item.Item, error) {
 	var items item.Item
 
-	query := fmt.Sprintf(`SELECT id, storeID, name, description, quantity, created_at, update_at FROM %s WHERE storeID = ? AND id = ?`, repo.tableName)
+	query := fmt.Sprintf(`SELECT id, storeID, name, description, quantity, created_at, update_at, version FROM %s WHERE storeID = ? AND id = ?`, repo.tableName)
 
-	stmt, err := repo.DB.PrepareContext(ctx, query)
+	stmt, err := repo.stmts.Prepare(ctx, query)
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 		return items, exception.ErrInternalServer
 	}
 
-	defer stmt.Close()
-
-	row := stmt.QueryRowContext(ctx, storeID, id)
-
-	err = row.Scan(
-		&items.ID,
-		&items.StoreID,
-		&items.Name,
-		&items.Description,
-		&items.Quantity,
-		&items.CreatedAt,
-		&items.UpdateAt,
-	)
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		return stmt.QueryRowContext(ctx, storeID, id).Scan(
+			&items.ID,
+			&items.StoreID,
+			&items.Name,
+			&items.Description,
+			&items.Quantity,
+			&items.CreatedAt,
+			&items.UpdateAt,
+			&items.Version,
+		)
+	})
 
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 		return items, exception.ErrNotFound
 	}
 
@@ -130,62 +147,60 @@ func (repo *itemRepositoryImpl) FindByIDWithStoreID(ctx context.Context, id int6
 func (repo *itemRepositoryImpl) FindByID(ctx context.Context, id int64) (item.Item, error) {
 	var items item.Item
 
-	query := fmt.Sprintf(`SELECT id, storeID, name, description, quantity, created_at, update_at FROM %s WHERE id = ?`, repo.tableName)
+	query := fmt.Sprintf(`SELECT id, storeID, name, description, quantity, created_at, update_at, version FROM %s WHERE id = ?`, repo.tableName)
 
-	stmt, err := repo.DB.PrepareContext(ctx, query)
+	stmt, err := repo.stmts.Prepare(ctx, query)
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 		return items, exception.ErrInternalServer
 	}
 
-	defer stmt.Close()
-
-	row := stmt.QueryRowContext(ctx, id)
-
-	err = row.Scan(
-		&items.ID,
-		&items.StoreID,
-		&items.Name,
-		&items.Description,
-		&items.Quantity,
-		&items.CreatedAt,
-		&items.UpdateAt,
-	)
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		return stmt.QueryRowContext(ctx, id).Scan(
+			&items.ID,
+			&items.StoreID,
+			&items.Name,
+			&items.Description,
+			&items.Quantity,
+			&items.CreatedAt,
+			&items.UpdateAt,
+			&items.Version,
+		)
+	})
 
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 		return items, exception.ErrNotFound
 	}
 
 	return items, nil
 }
 
-func (repo *itemRepositoryImpl) FindByName(ctx context.Context, name string) (item.Item, error) {
+func (repo *itemRepositoryImpl) FindByName(ctx context.Context, storeID int64, name string) (item.Item, error) {
 	var items item.Item
 
-	query := fmt.Sprintf(`SELECT id, storeID, name, description, quantity, created_at, update_at FROM %s WHERE name = ?`, repo.tableName)
-	stmt, err := repo.DB.PrepareContext(ctx, query)
+	query := fmt.Sprintf(`SELECT id, storeID, name, description, quantity, created_at, update_at, version FROM %s WHERE storeID = ? AND name = ?`, repo.tableName)
+	stmt, err := repo.stmts.Prepare(ctx, query)
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 		return items, exception.ErrInternalServer
 	}
 
-	defer stmt.Close()
-
-	row := stmt.QueryRowContext(ctx, name)
-
-	err = row.Scan(
-		&items.ID,
-		&items.StoreID,
-		&items.Name,
-		&items.Description,
-		&items.Quantity,
-		&items.CreatedAt,
-		&items.UpdateAt,
-	)
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		return stmt.QueryRowContext(ctx, storeID, name).Scan(
+			&items.ID,
+			&items.StoreID,
+			&items.Name,
+			&items.Description,
+			&items.Quantity,
+			&items.CreatedAt,
+			&items.UpdateAt,
+			&items.Version,
+		)
+	})
 
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 		return items, exception.ErrNotFound
 	}
 
@@ -193,53 +208,65 @@ func (repo *itemRepositoryImpl) FindByName(ctx context.Context, name string) (it
 }
 
 func (repo *itemRepositoryImpl) UpdateItem(ctx context.Context, id int64, params item.Item) error {
-	query := fmt.Sprintf(`UPDATE %s SET name = ?, description = ? WHERE id = %d`, repo.tableName, id)
-	stmt, err := repo.DB.PrepareContext(ctx, query)
+	query := fmt.Sprintf(`UPDATE %s SET name = ?, description = ?, quantity = ?, update_at = ?, version = version + 1 WHERE id = ? AND version = ?`, repo.tableName)
+	stmt, err := repo.stmts.Prepare(ctx, query)
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 		return exception.ErrInternalServer
 	}
 
-	defer stmt.Close()
-
-	result, err := stmt.ExecContext(
-		ctx,
-		params.Name,
-		params.Description,
-	)
+	var result sql.Result
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		result, err = stmt.ExecContext(
+			ctx,
+			params.Name,
+			params.Description,
+			params.Quantity,
+			params.UpdateAt,
+			id,
+			params.Version,
+		)
+		return err
+	})
 
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 		return exception.ErrInternalServer
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected < 1 {
-		return exception.ErrNotFound
+		return exception.ErrPreconditionFailed
 	}
 
 	return nil
 }
 
+// UpdateKuantitas adds params.Quantity to the stock of item id in a single
+// statement, so that concurrent restocks all count whatever the version
+// they read. It fails with ErrNotFound when the item is gone.
 func (repo *itemRepositoryImpl) UpdateKuantitas(ctx context.Context, id int64, params item.Item) error {
-	query := fmt.Sprintf(`UPDATE %s SET quantity = ?, update_at = ? WHERE id = %d`, repo.tableName, id)
+	query := fmt.Sprintf(`UPDATE %s SET quantity = quantity + ?, update_at = ?, version = version + 1 WHERE id = ?`, repo.tableName)
 
-	stmt, err := repo.DB.PrepareContext(ctx, query)
+	stmt, err := repo.stmts.Prepare(ctx, query)
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 		return exception.ErrInternalServer
 	}
 
-	defer stmt.Close()
-
-	result, err := stmt.ExecContext(
-		ctx,
-		params.Quantity,
-		params.UpdateAt,
-	)
+	var result sql.Result
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		result, err = stmt.ExecContext(
+			ctx,
+			params.Quantity,
+			params.UpdateAt,
+			id,
+		)
+		return err
+	})
 
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 		return exception.ErrInternalServer
 	}
 
@@ -251,28 +278,32 @@ func (repo *itemRepositoryImpl) UpdateKuantitas(ctx context.Context, id int64, p
 	return nil
 }
 
-func (repo *itemRepositoryImpl) DeleteItem(ctx context.Context, id int64) error {
-	query := fmt.Sprintf(`DELETE FROM %s WHERE id = %d`, repo.tableName, id)
-	stmt, err := repo.DB.PrepareContext(ctx, query)
+func (repo *itemRepositoryImpl) DeleteItem(ctx context.Context, id int64, version int64) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE id = ? AND version = ?`, repo.tableName)
+	stmt, err := repo.stmts.Prepare(ctx, query)
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 		return exception.ErrInternalServer
 	}
 
-	defer stmt.Close()
-
-	result, err := stmt.ExecContext(
-		ctx,
-	)
+	var result sql.Result
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		result, err = stmt.ExecContext(
+			ctx,
+			id,
+			version,
+		)
+		return err
+	})
 
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 		return exception.ErrInternalServer
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected < 1 {
-		return exception.ErrNotFound
+		return exception.ErrPreconditionFailed
 	}
 
 	return nil
@@ -0,0 +1,92 @@
+package item
+
+import (
+	"context"
+
+	"github.com/Risuii/helpers/response"
+	"github.com/Risuii/helpers/tracing"
+	"github.com/Risuii/models/item"
+)
+
+type itemUseCaseTracing struct {
+	ItemUseCase
+}
+
+// NewItemUseCaseTracing starts one span per use case method.
+func NewItemUseCaseTracing(usecase ItemUseCase) ItemUseCase {
+	return &itemUseCaseTracing{
+		ItemUseCase: usecase,
+	}
+}
+
+func (t *itemUseCaseTracing) AddItem(ctx context.Context, storeID int64, params item.Item) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "ItemUseCase.AddItem")
+	res := t.ItemUseCase.AddItem(ctx, storeID, params)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *itemUseCaseTracing) GetAllItems(ctx context.Context, storeID int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "ItemUseCase.GetAllItems")
+	res := t.ItemUseCase.GetAllItems(ctx, storeID)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *itemUseCaseTracing) GetOneItem(ctx context.Context, id int64, storeID int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "ItemUseCase.GetOneItem")
+	res := t.ItemUseCase.GetOneItem(ctx, id, storeID)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *itemUseCaseTracing) UpdateItem(ctx context.Context, id int64, storeID int64, version int64, params item.ItemUpdate) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "ItemUseCase.UpdateItem")
+	res := t.ItemUseCase.UpdateItem(ctx, id, storeID, version, params)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *itemUseCaseTracing) DeleteItem(ctx context.Context, id int64, storeID int64, version int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "ItemUseCase.DeleteItem")
+	res := t.ItemUseCase.DeleteItem(ctx, id, storeID, version)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *itemUseCaseTracing) AddImage(ctx context.Context, id int64, storeID int64, data []byte) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "ItemUseCase.AddImage")
+	res := t.ItemUseCase.AddImage(ctx, id, storeID, data)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *itemUseCaseTracing) GetImages(ctx context.Context, id int64, storeID int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "ItemUseCase.GetImages")
+	res := t.ItemUseCase.GetImages(ctx, id, storeID)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *itemUseCaseTracing) ReorderImages(ctx context.Context, id int64, storeID int64, params item.ImageOrder) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "ItemUseCase.ReorderImages")
+	res := t.ItemUseCase.ReorderImages(ctx, id, storeID, params)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *itemUseCaseTracing) DeleteImage(ctx context.Context, id int64, storeID int64, imageID int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "ItemUseCase.DeleteImage")
+	res := t.ItemUseCase.DeleteImage(ctx, id, storeID, imageID)
+	tracing.End(span, res.Err())
+
+	return res
+}
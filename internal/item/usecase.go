@@ -2,9 +2,13 @@ package item
 
 import (
 	"context"
+	"fmt"
 	"time"
 
+	"github.com/Risuii/helpers/cache"
+	"github.com/Risuii/helpers/etag"
 	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/media"
 	"github.com/Risuii/helpers/response"
 	"github.com/Risuii/models/item"
 )
@@ -14,40 +18,48 @@ type (
 		AddItem(ctx context.Context, storeID int64, params item.Item) response.Response
 		GetAllItems(ctx context.Context, storeID int64) response.Response
 		GetOneItem(ctx context.Context, id int64, storeID int64) response.Response
-		UpdateItem(ctx context.Context, id int64, params item.Item) response.Response
-		DeleteItem(ctx context.Context, id int64) response.Response
+		UpdateItem(ctx context.Context, id int64, storeID int64, version int64, params item.ItemUpdate) response.Response
+		DeleteItem(ctx context.Context, id int64, storeID int64, version int64) response.Response
+		AddImage(ctx context.Context, id int64, storeID int64, data []byte) response.Response
+		GetImages(ctx context.Context, id int64, storeID int64) response.Response
+		ReorderImages(ctx context.Context, id int64, storeID int64, params item.ImageOrder) response.Response
+		DeleteImage(ctx context.Context, id int64, storeID int64, imageID int64) response.Response
 	}
 
 	itemUseCaseImpl struct {
 		repository ItemRepository
+		images     ImageRepository
+		media      *media.Storage
 	}
 )
 
-func NewItemUseCaseImpl(repo ItemRepository) ItemUseCase {
+func NewItemUseCaseImpl(repo ItemRepository, images ImageRepository, storage *media.Storage) ItemUseCase {
 	return &itemUseCaseImpl{
 		repository: repo,
+		images:     images,
+		media:      storage,
 	}
 }
 
 func (iu *itemUseCaseImpl) AddItem(ctx context.Context, storeID int64, params item.Item) response.Response {
-	data, err := iu.repository.FindByName(ctx, params.Name)
+	ctx = cache.Fresh(ctx)
+
+	data, err := iu.repository.FindByName(ctx, storeID, params.Name)
 
 	if err == nil {
-		data = item.Item{
-			ID:          data.ID,
-			StoreID:     data.ID,
-			Name:        data.Name,
-			Description: data.Description,
-			Quantity:    data.Quantity + params.Quantity,
-			UpdateAt:    data.UpdateAt,
+		// The stock is added to in the database, the item is read again
+		// for its quantity and version.
+		err := iu.repository.UpdateKuantitas(ctx, data.ID, item.Item{Quantity: params.Quantity, UpdateAt: time.Now()})
+		if err != nil {
+			return response.Fail(err)
 		}
 
-		err := iu.repository.UpdateKuantitas(ctx, data.ID, data)
+		data, err = iu.repository.FindByIDWithStoreID(ctx, data.ID, storeID)
 		if err != nil {
-			return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
+			return response.Fail(err)
 		}
 
-		return response.Success(response.StatusOK, data)
+		return response.Success(response.StatusOK, data).WithETag(etag.Version(data.Version))
 	}
 
 	item := item.Item{
@@ -65,7 +77,9 @@ func (iu *itemUseCaseImpl) AddItem(ctx context.Context, storeID int64, params it
 
 	item.ID = ID
 
-	return response.Success(response.StatusCreated, item)
+	item.Version = 1
+
+	return response.Success(response.StatusCreated, item).WithETag(etag.Version(item.Version))
 }
 
 func (iu *itemUseCaseImpl) GetAllItems(ctx context.Context, storeID int64) response.Response {
@@ -80,7 +94,12 @@ func (iu *itemUseCaseImpl) GetAllItems(ctx context.Context, storeID int64) respo
 		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
 	}
 
-	return response.Success(response.StatusOK, data)
+	versions := make([][2]int64, 0, len(data))
+	for _, i := range data {
+		versions = append(versions, [2]int64{i.ID, i.Version})
+	}
+
+	return response.Success(response.StatusOK, data).WithETag(etag.List(versions...))
 }
 
 func (iu *itemUseCaseImpl) GetOneItem(ctx context.Context, id int64, storeID int64) response.Response {
@@ -94,11 +113,13 @@ func (iu *itemUseCaseImpl) GetOneItem(ctx context.Context, id int64, storeID int
 	if err != nil {
 		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
 	}
-	return response.Success(response.StatusOK, data)
+	return response.Success(response.StatusOK, data).WithETag(etag.Version(data.Version))
 }
 
-func (iu *itemUseCaseImpl) UpdateItem(ctx context.Context, id int64, params item.Item) response.Response {
-	data, err := iu.repository.FindByID(ctx, id)
+func (iu *itemUseCaseImpl) UpdateItem(ctx context.Context, id int64, storeID int64, version int64, params item.ItemUpdate) response.Response {
+	ctx = cache.Fresh(ctx)
+
+	data, err := iu.repository.FindByIDWithStoreID(ctx, id, storeID)
 
 	if err == exception.ErrNotFound {
 		return response.Error(response.StatusNotFound, exception.ErrNotFound)
@@ -108,25 +129,33 @@ func (iu *itemUseCaseImpl) UpdateItem(ctx context.Context, id int64, params item
 		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
 	}
 
-	data = item.Item{
-		ID:          data.ID,
-		StoreID:     data.StoreID,
-		Name:        params.Name,
-		Description: params.Description,
-		Quantity:    params.Quantity,
-		UpdateAt:    time.Now(),
+	if data.Version != version {
+		return response.Error(response.StatusPreconditionFailed, exception.ErrPreconditionFailed)
+	}
+
+	params.Name.Apply(&data.Name)
+	params.Description.Apply(&data.Description)
+	params.Quantity.Apply(&data.Quantity)
+	data.UpdateAt = time.Now()
+
+	err = iu.repository.UpdateItem(ctx, id, data)
+	if err == exception.ErrPreconditionFailed {
+		return response.Error(response.StatusPreconditionFailed, exception.ErrPreconditionFailed)
 	}
 
-	if err := iu.repository.UpdateItem(ctx, id, data); err != nil {
+	if err != nil {
 		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
 	}
 
-	return response.Success(response.StatusOK, data)
+	data.Version++
+
+	return response.Success(response.StatusOK, data).WithETag(etag.Version(data.Version))
 }
 
-func (iu *itemUseCaseImpl) DeleteItem(ctx context.Context, id int64) response.Response {
+func (iu *itemUseCaseImpl) DeleteItem(ctx context.Context, id int64, storeID int64, version int64) response.Response {
+	ctx = cache.Fresh(ctx)
 
-	data, err := iu.repository.FindByID(ctx, id)
+	data, err := iu.repository.FindByIDWithStoreID(ctx, id, storeID)
 
 	if err == exception.ErrNotFound {
 		return response.Error(response.StatusNotFound, exception.ErrNotFound)
@@ -136,11 +165,122 @@ func (iu *itemUseCaseImpl) DeleteItem(ctx context.Context, id int64) response.Re
 		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
 	}
 
-	if err := iu.repository.DeleteItem(ctx, data.ID); err != nil {
+	if data.Version != version {
+		return response.Error(response.StatusPreconditionFailed, exception.ErrPreconditionFailed)
+	}
+
+	// The rows of the gallery go with the item, its files are deleted
+	// afterwards.
+	images, err := iu.images.FindByItemID(ctx, data.ID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	err = iu.repository.DeleteItem(ctx, data.ID, version)
+	if err == exception.ErrPreconditionFailed {
+		return response.Error(response.StatusPreconditionFailed, exception.ErrPreconditionFailed)
+	}
+
+	if err != nil {
 		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
 	}
 
+	for _, i := range images {
+		iu.media.Delete(ctx, i.Key, i.ThumbnailKey)
+	}
+
 	msg := "Success Delete Data"
 
 	return response.Success(response.StatusOK, msg)
 }
+
+// AddImage adds the image in data at the end of the gallery of the item.
+func (iu *itemUseCaseImpl) AddImage(ctx context.Context, id int64, storeID int64, data []byte) response.Response {
+	ctx = cache.Fresh(ctx)
+
+	if _, err := iu.repository.FindByIDWithStoreID(ctx, id, storeID); err != nil {
+		return response.Fail(err)
+	}
+
+	saved, err := iu.media.Save(ctx, fmt.Sprintf("items/%d", id), data, true)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	image, err := iu.images.Create(ctx, item.Image{
+		ItemID:       id,
+		ContentType:  saved.ContentType,
+		Width:        saved.Width,
+		Height:       saved.Height,
+		Size:         saved.Size,
+		Key:          saved.Key,
+		ThumbnailKey: saved.ThumbnailKey,
+		CreatedAt:    time.Now(),
+	})
+	if err != nil {
+		iu.media.Delete(ctx, saved.Key, saved.ThumbnailKey)
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusCreated, iu.withURLs(image))
+}
+
+func (iu *itemUseCaseImpl) GetImages(ctx context.Context, id int64, storeID int64) response.Response {
+	if _, err := iu.repository.FindByIDWithStoreID(ctx, id, storeID); err != nil {
+		return response.Fail(err)
+	}
+
+	return iu.gallery(ctx, id)
+}
+
+// ReorderImages gives the gallery of the item the order of params.
+func (iu *itemUseCaseImpl) ReorderImages(ctx context.Context, id int64, storeID int64, params item.ImageOrder) response.Response {
+	ctx = cache.Fresh(ctx)
+
+	if _, err := iu.repository.FindByIDWithStoreID(ctx, id, storeID); err != nil {
+		return response.Fail(err)
+	}
+
+	if err := iu.images.Reorder(ctx, id, params.IDs); err != nil {
+		return response.Fail(err)
+	}
+
+	return iu.gallery(ctx, id)
+}
+
+func (iu *itemUseCaseImpl) DeleteImage(ctx context.Context, id int64, storeID int64, imageID int64) response.Response {
+	ctx = cache.Fresh(ctx)
+
+	if _, err := iu.repository.FindByIDWithStoreID(ctx, id, storeID); err != nil {
+		return response.Fail(err)
+	}
+
+	image, err := iu.images.Delete(ctx, id, imageID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	iu.media.Delete(ctx, image.Key, image.ThumbnailKey)
+
+	return iu.gallery(ctx, id)
+}
+
+func (iu *itemUseCaseImpl) gallery(ctx context.Context, id int64) response.Response {
+	images, err := iu.images.FindByItemID(ctx, id)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	for n := range images {
+		images[n] = iu.withURLs(images[n])
+	}
+
+	return response.Success(response.StatusOK, images)
+}
+
+func (iu *itemUseCaseImpl) withURLs(image item.Image) item.Image {
+	image.URL = iu.media.URL(image.Key)
+	image.ThumbnailURL = iu.media.URL(image.ThumbnailKey)
+
+	return image
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/models/store"
+)
+
+// Access decides what an account may do in a store from its role there.
+// It reads the membership on every call, so removing a member takes effect
+// on their next request whatever tokens they still hold.
+type Access struct {
+	stores  StoreRepository
+	members MemberRepository
+}
+
+func NewAccess(stores StoreRepository, members MemberRepository) *Access {
+	return &Access{
+		stores:  stores,
+		members: members,
+	}
+}
+
+// Role returns the store and the role of userID in it. Stores the account
+// is not a member of are reported as not found.
+func (a *Access) Role(ctx context.Context, storeID int64, userID int64) (store.Store, store.Role, error) {
+	s, err := a.stores.FindByID(ctx, storeID)
+	if err != nil {
+		return s, "", err
+	}
+
+	if s.UserID == userID {
+		return s, store.RoleOwner, nil
+	}
+
+	m, err := a.members.Find(ctx, storeID, userID)
+	if err != nil {
+		return s, "", err
+	}
+
+	return s, m.Role, nil
+}
+
+// Authorize is Role, failing with ErrForbidden when the role lacks p and
+// with ErrStoreUnavailable when the state of the store rules p out.
+func (a *Access) Authorize(ctx context.Context, storeID int64, userID int64, p store.Permission) (store.Role, error) {
+	s, role, err := a.Role(ctx, storeID, userID)
+	if err != nil {
+		return role, err
+	}
+
+	if !role.Can(p) {
+		return role, exception.ErrForbidden
+	}
+
+	if !s.Status.Allows(p) {
+		return role, exception.ErrStoreUnavailable
+	}
+
+	return role, nil
+}
+
+// Permits fails with ErrStoreUnavailable when the state of the store rules
+// p out. It is for callers that act for a store without an account, such
+// as API keys.
+func (a *Access) Permits(ctx context.Context, storeID int64, p store.Permission) error {
+	s, err := a.stores.FindByID(ctx, storeID)
+	if err != nil {
+		return err
+	}
+
+	if !s.Status.Allows(p) {
+		return exception.ErrStoreUnavailable
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Risuii/helpers/apikey"
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/response"
+)
+
+// lastUsedPrecision keeps busy integrations from writing the key row on
+// every request.
+const lastUsedPrecision = time.Minute
+
+// NewAPIKeyAuth authenticates requests that carry an API key in the
+// Authorization header and puts its apikey.Principal in the context. The
+// handlers of the store's endpoints check the scopes. Requests without a key
+// pass unchanged, and a key that is unknown, expired or revoked is
+// answered with 401 right away.
+func NewAPIKeyAuth(keys APIKeyRepository) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw, ok := apikey.FromRequest(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+			now := time.Now()
+
+			key, err := keys.FindByHash(ctx, apikey.Hash(raw))
+			if err == exception.ErrNotFound || (err == nil && key.ExpiresAt != nil && !now.Before(*key.ExpiresAt)) {
+				unauthorized(w)
+				return
+			}
+			if err != nil {
+				response.Fail(err).JSON(w)
+				return
+			}
+
+			if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedPrecision {
+				// Tracking must not fail the request.
+				if err := keys.Touch(context.WithoutCancel(ctx), key.ID, now); err != nil {
+					logger.FromContext(ctx).WarnContext(ctx, "track api key use", "key", key.ID, "error", err)
+				}
+			}
+
+			ctx = apikey.NewContext(ctx, apikey.Principal{
+				KeyID:   key.ID,
+				StoreID: key.StoreID,
+				Scopes:  key.Scopes,
+			})
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	response.Fail(exception.ErrInvalidAPIKey).JSON(w)
+}
@@ -0,0 +1,201 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/retry"
+	"github.com/Risuii/helpers/stmtcache"
+	"github.com/Risuii/models/store"
+)
+
+type (
+	APIKeyRepository interface {
+		Create(ctx context.Context, params store.APIKey) (int64, error)
+		FindByHash(ctx context.Context, keyHash string) (store.APIKey, error)
+		FindByStoreID(ctx context.Context, storeID int64) ([]store.APIKey, error)
+		Touch(ctx context.Context, id int64, usedAt time.Time) error
+		Delete(ctx context.Context, storeID int64, id int64) error
+	}
+
+	apiKeyRepositoryImpl struct {
+		db        *sql.DB
+		tableName string
+		stmts     *stmtcache.Cache
+	}
+)
+
+func NewAPIKeyRepositoryImpl(db *sql.DB, tableName string) APIKeyRepository {
+	return &apiKeyRepositoryImpl{
+		db:        db,
+		tableName: tableName,
+		stmts:     stmtcache.For(db),
+	}
+}
+
+func (ar *apiKeyRepositoryImpl) Create(ctx context.Context, params store.APIKey) (int64, error) {
+	query := fmt.Sprintf(`INSERT INTO %s (storeID, name, hint, key_hash, scopes, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`, ar.tableName)
+	stmt, err := ar.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return 0, exception.ErrInternalServer
+	}
+
+	var result sql.Result
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		result, err = stmt.ExecContext(
+			ctx,
+			params.StoreID,
+			params.Name,
+			params.Hint,
+			params.KeyHash,
+			strings.Join(params.Scopes, " "),
+			params.ExpiresAt,
+			params.CreatedAt,
+		)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return 0, exception.ErrInternalServer
+	}
+
+	ID, _ := result.LastInsertId()
+	return ID, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row rowScanner, key *store.APIKey) error {
+	var scopes string
+	err := row.Scan(
+		&key.ID,
+		&key.StoreID,
+		&key.Name,
+		&key.Hint,
+		&key.KeyHash,
+		&scopes,
+		&key.LastUsedAt,
+		&key.ExpiresAt,
+		&key.CreatedAt,
+	)
+	key.Scopes = strings.Fields(scopes)
+
+	return err
+}
+
+func (ar *apiKeyRepositoryImpl) FindByHash(ctx context.Context, keyHash string) (store.APIKey, error) {
+	var key store.APIKey
+	query := fmt.Sprintf(`SELECT id, storeID, name, hint, key_hash, scopes, last_used_at, expires_at, created_at FROM %s WHERE key_hash = ?`, ar.tableName)
+	stmt, err := ar.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return key, exception.ErrInternalServer
+	}
+
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		return scanAPIKey(stmt.QueryRowContext(ctx, keyHash), &key)
+	})
+	if err == sql.ErrNoRows {
+		return key, exception.ErrNotFound
+	}
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return key, exception.ErrInternalServer
+	}
+
+	return key, nil
+}
+
+func (ar *apiKeyRepositoryImpl) FindByStoreID(ctx context.Context, storeID int64) ([]store.APIKey, error) {
+	keys := []store.APIKey{}
+
+	query := fmt.Sprintf(`SELECT id, storeID, name, hint, key_hash, scopes, last_used_at, expires_at, created_at FROM %s WHERE storeID = ? ORDER BY id`, ar.tableName)
+	stmt, err := ar.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return keys, exception.ErrInternalServer
+	}
+
+	var rows *sql.Rows
+	err = retry.Do(ctx, func(ctx context.Context) (err error) {
+		rows, err = stmt.QueryContext(ctx, storeID)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return keys, exception.ErrInternalServer
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var key store.APIKey
+		if err := scanAPIKey(rows, &key); err != nil {
+			logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+			return keys, exception.ErrInternalServer
+		}
+		keys = append(keys, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return keys, exception.ErrInternalServer
+	}
+
+	return keys, nil
+}
+
+// Touch records when the key was last used.
+func (ar *apiKeyRepositoryImpl) Touch(ctx context.Context, id int64, usedAt time.Time) error {
+	query := fmt.Sprintf(`UPDATE %s SET last_used_at = ? WHERE id = ?`, ar.tableName)
+	stmt, err := ar.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		_, err := stmt.ExecContext(ctx, usedAt, id)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	return nil
+}
+
+func (ar *apiKeyRepositoryImpl) Delete(ctx context.Context, storeID int64, id int64) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE storeID = ? AND id = ?`, ar.tableName)
+	stmt, err := ar.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	var result sql.Result
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		result, err = stmt.ExecContext(ctx, storeID, id)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected < 1 {
+		return exception.ErrNotFound
+	}
+
+	return nil
+}
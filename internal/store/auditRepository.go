@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/retry"
+	"github.com/Risuii/helpers/stmtcache"
+	"github.com/Risuii/models/store"
+)
+
+type (
+	// AuditRepository reads the audit trail of stores. Entries are written
+	// by the repositories that make the change, in the same transaction.
+	AuditRepository interface {
+		FindByStoreID(ctx context.Context, storeID int64) ([]store.AuditEntry, error)
+	}
+
+	auditRepositoryImpl struct {
+		db        *sql.DB
+		tableName string
+		stmts     *stmtcache.Cache
+	}
+)
+
+func NewAuditRepositoryImpl(db *sql.DB, tableName string) AuditRepository {
+	return &auditRepositoryImpl{
+		db:        db,
+		tableName: tableName,
+		stmts:     stmtcache.For(db),
+	}
+}
+
+// recordAudit adds entry to the audit trail in tableName within tx.
+func recordAudit(ctx context.Context, tx *sql.Tx, tableName string, entry store.AuditEntry) error {
+	var details []byte
+	if len(entry.Details) > 0 {
+		var err error
+		if details, err = json.Marshal(entry.Details); err != nil {
+			return err
+		}
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %s (storeID, actorID, action, details, created_at) VALUES (?, ?, ?, ?, ?)`, tableName)
+	_, err := tx.ExecContext(ctx, query, entry.StoreID, entry.ActorID, entry.Action, details, entry.CreatedAt)
+
+	return err
+}
+
+// FindByStoreID returns the trail of a store, newest first.
+func (ar *auditRepositoryImpl) FindByStoreID(ctx context.Context, storeID int64) ([]store.AuditEntry, error) {
+	entries := []store.AuditEntry{}
+
+	query := fmt.Sprintf(`SELECT id, storeID, actorID, action, details, created_at FROM %s WHERE storeID = ? ORDER BY created_at DESC, id DESC`, ar.tableName)
+	stmt, err := ar.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return entries, exception.ErrInternalServer
+	}
+
+	var rows *sql.Rows
+	err = retry.Do(ctx, func(ctx context.Context) (err error) {
+		rows, err = stmt.QueryContext(ctx, storeID)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return entries, exception.ErrInternalServer
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry store.AuditEntry
+		var details []byte
+		err := rows.Scan(
+			&entry.ID,
+			&entry.StoreID,
+			&entry.ActorID,
+			&entry.Action,
+			&details,
+			&entry.CreatedAt,
+		)
+		if err == nil && len(details) > 0 {
+			err = json.Unmarshal(details, &entry.Details)
+		}
+		if err != nil {
+			logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+			return entries, exception.ErrInternalServer
+		}
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ar.tableName, "error", err)
+		return entries, exception.ErrInternalServer
+	}
+
+	return entries, nil
+}
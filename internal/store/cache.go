@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Risuii/helpers/cache"
+	"github.com/Risuii/models/store"
+)
+
+type storeRepositoryCache struct {
+	StoreRepository
+	loader *cache.Loader
+}
+
+// NewStoreRepositoryCache serves store reads by ID, slug and owner from c and
+// drops the affected entries whenever a store is written. Lookups by name
+// guard uniqueness and always go to the database.
+func NewStoreRepositoryCache(repo StoreRepository, c cache.Cache, ttl time.Duration) StoreRepository {
+	return &storeRepositoryCache{
+		StoreRepository: repo,
+		loader:          cache.NewLoader(c, ttl),
+	}
+}
+
+func storeKey(id int64) string {
+	return fmt.Sprintf("store:%d", id)
+}
+
+func slugKey(slug string) string {
+	return "store:slug:" + slug
+}
+
+func userStoresKey(userID int64) string {
+	return fmt.Sprintf("stores:user:%d", userID)
+}
+
+func (c *storeRepositoryCache) FindByID(ctx context.Context, id int64) (store.Store, error) {
+	return cache.Load(ctx, c.loader, storeKey(id), func(ctx context.Context) (store.Store, error) {
+		return c.StoreRepository.FindByID(ctx, id)
+	})
+}
+
+// FindBySlug only caches the ID of the store, so the store itself is
+// dropped with its other reads. Slugs of deleted stores can be taken
+// again, so an ID that no longer matches is looked up anew.
+func (c *storeRepositoryCache) FindBySlug(ctx context.Context, slug string) (store.Store, error) {
+	key := slugKey(slug)
+	id, err := cache.Load(ctx, c.loader, key, func(ctx context.Context) (int64, error) {
+		s, err := c.StoreRepository.FindBySlug(ctx, slug)
+		return s.ID, err
+	})
+	if err != nil {
+		return store.Store{}, err
+	}
+
+	s, err := c.FindByID(ctx, id)
+	if err == nil && s.Slug == slug {
+		return s, nil
+	}
+
+	c.loader.Invalidate(ctx, key)
+
+	return c.StoreRepository.FindBySlug(ctx, slug)
+}
+
+func (c *storeRepositoryCache) FindByUserID(ctx context.Context, userID int64) ([]store.Store, error) {
+	return cache.Load(ctx, c.loader, userStoresKey(userID), func(ctx context.Context) ([]store.Store, error) {
+		return c.StoreRepository.FindByUserID(ctx, userID)
+	})
+}
+
+func (c *storeRepositoryCache) Create(ctx context.Context, params store.Store) (int64, error) {
+	ID, err := c.StoreRepository.Create(ctx, params)
+	if err == nil {
+		c.loader.Invalidate(ctx, userStoresKey(params.UserID))
+	}
+
+	return ID, err
+}
+
+func (c *storeRepositoryCache) Update(ctx context.Context, id int64, params store.Store) error {
+	// The owner may change with the update, so both the previous and the
+	// new owner's list are dropped.
+	return c.write(ctx, id, func() error {
+		return c.StoreRepository.Update(ctx, id, params)
+	}, userStoresKey(params.UserID))
+}
+
+func (c *storeRepositoryCache) Delete(ctx context.Context, id int64, version int64) error {
+	return c.write(ctx, id, func() error {
+		return c.StoreRepository.Delete(ctx, id, version)
+	})
+}
+
+// Forget drops the store and the store lists of userIDs.
+func (c *storeRepositoryCache) Forget(ctx context.Context, id int64, userIDs ...int64) {
+	keys := []string{storeKey(id)}
+	for _, userID := range userIDs {
+		keys = append(keys, userStoresKey(userID))
+	}
+	c.loader.Invalidate(ctx, keys...)
+}
+
+// write runs fn and invalidates the store and the store lists of its
+// owners whatever fn returns, since a failed write tells that the cached
+// entry may be stale.
+func (c *storeRepositoryCache) write(ctx context.Context, id int64, fn func() error, keys ...string) error {
+	current, lookupErr := c.FindByID(ctx, id)
+
+	err := fn()
+
+	keys = append(keys, storeKey(id))
+	if lookupErr == nil {
+		keys = append(keys, userStoresKey(current.UserID))
+	}
+	c.loader.Invalidate(ctx, keys...)
+
+	return err
+}
@@ -1,16 +1,19 @@
 package store
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"strconv"
 
-	newJWT "github.com/dgrijalva/jwt-go"
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 
 	"github.com/Risuii/config/jwt"
+	"github.com/Risuii/helpers/etag"
 	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/media"
+	"github.com/Risuii/helpers/patch"
 	"github.com/Risuii/helpers/response"
 	"github.com/Risuii/models/store"
 )
@@ -18,12 +21,14 @@ import (
 type StoreHandler struct {
 	validate *validator.Validate
 	UseCase  StoreUseCase
+	uploads  media.Limits
 }
 
-func NewStoreHandler(router *mux.Router, validate *validator.Validate, usecase StoreUseCase) {
+func NewStoreHandler(router *mux.Router, validate *validator.Validate, usecase StoreUseCase, uploads media.Limits) {
 	handler := &StoreHandler{
 		validate: validate,
 		UseCase:  usecase,
+		uploads:  uploads,
 	}
 
 	api := router.PathPrefix("/account").Subrouter()
@@ -32,8 +37,31 @@ func NewStoreHandler(router *mux.Router, validate *validator.Validate, usecase S
 	api.HandleFunc("/store", handler.GetStore).Methods(http.MethodGet)
 	api.HandleFunc("/store/{id}", handler.EditStore).Methods(http.MethodPatch)
 	api.HandleFunc("/store/{id}", handler.DeleteStore).Methods(http.MethodDelete)
+	api.HandleFunc("/store/{id}/hours", handler.SetHours).Methods(http.MethodPut)
+	api.HandleFunc("/store/{id}/logo", handler.SetLogo).Methods(http.MethodPut)
+	api.HandleFunc("/store/{id}/banner", handler.SetBanner).Methods(http.MethodPut)
+	api.HandleFunc("/store/{id}/status", handler.SetStatus).Methods(http.MethodPut)
+	api.HandleFunc("/store/{id}/api-keys", handler.CreateAPIKey).Methods(http.MethodPost)
+	api.HandleFunc("/store/{id}/api-keys", handler.ListAPIKeys).Methods(http.MethodGet)
+	api.HandleFunc("/store/{id}/api-keys/{keyID}", handler.RevokeAPIKey).Methods(http.MethodDelete)
+	api.HandleFunc("/store/{id}/members", handler.ListMembers).Methods(http.MethodGet)
+	api.HandleFunc("/store/{id}/members/{userID}", handler.RemoveMember).Methods(http.MethodDelete)
+	api.HandleFunc("/store/{id}/invitations", handler.Invite).Methods(http.MethodPost)
+	api.HandleFunc("/store/{id}/invitations", handler.ListInvitations).Methods(http.MethodGet)
+	api.HandleFunc("/store/{id}/invitations/{invitationID}", handler.CancelInvitation).Methods(http.MethodDelete)
+	api.HandleFunc("/store/{id}/transfer", handler.StartTransfer).Methods(http.MethodPost)
+	api.HandleFunc("/store/{id}/transfer", handler.PendingTransfer).Methods(http.MethodGet)
+	api.HandleFunc("/store/{id}/transfer", handler.CancelTransfer).Methods(http.MethodDelete)
+	api.HandleFunc("/store/{id}/audit", handler.AuditLog).Methods(http.MethodGet)
+	api.HandleFunc("/store-transfers", handler.IncomingTransfers).Methods(http.MethodGet)
+	api.HandleFunc("/store-transfers/{id}/accept", handler.AcceptTransfer).Methods(http.MethodPost)
+	api.HandleFunc("/store-transfers/{id}/decline", handler.DeclineTransfer).Methods(http.MethodPost)
+
+	router.HandleFunc("/invitations/accept", handler.AcceptInvitation).Methods(http.MethodPost)
+	router.HandleFunc("/invitations/decline", handler.DeclineInvitation).Methods(http.MethodPost)
 
 	router.HandleFunc("/store/{userID}", handler.Store).Methods(http.MethodGet)
+	router.HandleFunc("/stores/{slug}", handler.StoreBySlug).Methods(http.MethodGet)
 }
 
 func (handler *StoreHandler) CreateStore(w http.ResponseWriter, r *http.Request) {
@@ -42,29 +70,20 @@ func (handler *StoreHandler) CreateStore(w http.ResponseWriter, r *http.Request)
 
 	ctx := r.Context()
 
-	c, err := r.Cookie("token")
-	if err != nil {
-		res = response.Error(response.StatusUnauthorized, exception.ErrUnauthorized)
-		res.JSON(w)
+	claims, ok := sessionClaims(w, r)
+	if !ok {
 		return
 	}
 
-	tokenString := c.Value
-	claims := &jwt.JWTclaim{}
-
-	newJWT.ParseWithClaims(tokenString, claims, func(t *newJWT.Token) (interface{}, error) {
-		return jwt.JWT_KEY, nil
-	})
-
 	if err := json.NewDecoder(r.Body).Decode(&userInput); err != nil {
-		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity)
+		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity.Wrap(err))
 		res.JSON(w)
 		return
 	}
 
-	err = handler.validate.StructCtx(ctx, userInput)
+	err := handler.validate.StructCtx(ctx, userInput)
 	if err != nil {
-		res = response.Error(response.StatusBadRequest, exception.ErrBadRequest)
+		res = response.Error(response.StatusBadRequest, exception.Validation(err))
 		res.JSON(w)
 		return
 	}
@@ -74,32 +93,30 @@ func (handler *StoreHandler) CreateStore(w http.ResponseWriter, r *http.Request)
 	res.JSON(w)
 }
 
+// GetStore lists the stores of the account and sets the Store-token
+// cookie for the one picked with ?store=, or the first one.
 func (handler *StoreHandler) GetStore(w http.ResponseWriter, r *http.Request) {
 	var res response.Response
 
 	ctx := r.Context()
-	c, err := r.Cookie("token")
-	if err != nil {
-		res = response.Error(response.StatusUnauthorized, exception.ErrUnauthorized)
-		res.JSON(w)
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
 		return
 	}
 
-	tokenString := c.Value
-	claims := &jwt.JWTclaim{}
+	storeID, _ := strconv.ParseInt(r.URL.Query().Get("store"), 10, 64)
 
-	newJWT.ParseWithClaims(tokenString, claims, func(t *newJWT.Token) (interface{}, error) {
-		return jwt.JWT_KEY, nil
-	})
+	res, token := handler.UseCase.Memberships(ctx, claims.UserID, storeID)
 
-	res, token := handler.UseCase.Read(ctx, claims.UserID)
-
-	http.SetCookie(w, &http.Cookie{
-		Name:     "Store-token",
-		Path:     "/",
-		Value:    token.Token,
-		HttpOnly: true,
-	})
+	if token.Token != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     "Store-token",
+			Path:     "/",
+			Value:    token.Token,
+			HttpOnly: true,
+		})
+	}
 
 	res.JSON(w)
 }
@@ -112,50 +129,176 @@ func (handler *StoreHandler) Store(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	userID, _ := strconv.ParseInt(params["userID"], 10, 64)
 
-	res, _ = handler.UseCase.Read(ctx, userID)
+	res = handler.UseCase.Read(ctx, userID)
+
+	res.JSON(w)
+}
+
+// StoreBySlug is the public profile of a store.
+func (handler *StoreHandler) StoreBySlug(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	res = handler.UseCase.ReadBySlug(ctx, mux.Vars(r)["slug"])
 
 	res.JSON(w)
 }
 
 func (handler *StoreHandler) EditStore(w http.ResponseWriter, r *http.Request) {
 	var res response.Response
-	var userInput store.Store
+	var userInput store.StoreUpdate
 
 	ctx := r.Context()
 
-	c, err := r.Cookie("token")
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	version, err := etag.IfMatch(r)
 	if err != nil {
-		res = response.Error(response.StatusUnauthorized, exception.ErrUnauthorized)
+		res = response.Fail(err)
 		res.JSON(w)
 		return
 	}
 
-	tokenString := c.Value
-	claims := &jwt.JWTclaim{}
-
-	newJWT.ParseWithClaims(tokenString, claims, func(t *newJWT.Token) (interface{}, error) {
-		return jwt.JWT_KEY, nil
-	})
-
 	params := mux.Vars(r)
 	id, _ := strconv.ParseInt(params["id"], 10, 64)
 
+	if err := patch.Decode(r.Body, &userInput); err != nil {
+		res = response.Fail(err)
+		res.JSON(w)
+		return
+	}
+
+	err = handler.validate.StructCtx(ctx, userInput)
+	if err != nil {
+		res = response.Error(response.StatusBadRequest, exception.Validation(err))
+		res.JSON(w)
+		return
+	}
+
+	res = handler.UseCase.UpdateStore(ctx, id, version, claims.UserID, userInput)
+
+	res.JSON(w)
+}
+
+// SetHours replaces the business hours of the store as a whole.
+func (handler *StoreHandler) SetHours(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+	var userInput store.Hours
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	version, err := etag.IfMatch(r)
+	if err != nil {
+		res = response.Fail(err)
+		res.JSON(w)
+		return
+	}
+
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
 	if err := json.NewDecoder(r.Body).Decode(&userInput); err != nil {
-		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity)
+		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity.Wrap(err))
 		res.JSON(w)
 		return
 	}
 
 	err = handler.validate.StructCtx(ctx, userInput)
 	if err != nil {
-		res = response.Error(response.StatusBadRequest, exception.ErrBadRequest)
+		res = response.Error(response.StatusBadRequest, exception.Validation(err))
 		res.JSON(w)
 		return
 	}
 
-	userInput.UserID = claims.UserID
+	res = handler.UseCase.SetHours(ctx, id, version, claims.UserID, userInput)
 
-	res = handler.UseCase.UpdateStore(ctx, id, userInput)
+	res.JSON(w)
+}
+
+// SetLogo takes the logo in the "image" field of a multipart form.
+func (handler *StoreHandler) SetLogo(w http.ResponseWriter, r *http.Request) {
+	handler.setImage(w, r, handler.UseCase.SetLogo)
+}
+
+// SetBanner takes the banner in the "image" field of a multipart form.
+func (handler *StoreHandler) SetBanner(w http.ResponseWriter, r *http.Request) {
+	handler.setImage(w, r, handler.UseCase.SetBanner)
+}
+
+func (handler *StoreHandler) setImage(w http.ResponseWriter, r *http.Request, set func(ctx context.Context, id int64, version int64, userID int64, data []byte) response.Response) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	version, err := etag.IfMatch(r)
+	if err != nil {
+		res = response.Fail(err)
+		res.JSON(w)
+		return
+	}
+
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	data, err := media.Read(w, r, "image", handler.uploads)
+	if err != nil {
+		res = response.Fail(err)
+		res.JSON(w)
+		return
+	}
+
+	res = set(ctx, id, version, claims.UserID, data)
+
+	res.JSON(w)
+}
+
+// SetStatus moves the store to another state, see store.StatusChange.
+func (handler *StoreHandler) SetStatus(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+	var userInput store.StatusChange
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	version, err := etag.IfMatch(r)
+	if err != nil {
+		res = response.Fail(err)
+		res.JSON(w)
+		return
+	}
+
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	if err := json.NewDecoder(r.Body).Decode(&userInput); err != nil {
+		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity.Wrap(err))
+		res.JSON(w)
+		return
+	}
+
+	err = handler.validate.StructCtx(ctx, userInput)
+	if err != nil {
+		res = response.Error(response.StatusBadRequest, exception.Validation(err))
+		res.JSON(w)
+		return
+	}
+
+	res = handler.UseCase.SetStatus(ctx, id, version, claims.UserID, userInput)
 
 	res.JSON(w)
 }
@@ -165,9 +308,14 @@ func (handler *StoreHandler) DeleteStore(w http.ResponseWriter, r *http.Request)
 
 	ctx := r.Context()
 
-	_, err := r.Cookie("token")
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	version, err := etag.IfMatch(r)
 	if err != nil {
-		res = response.Error(response.StatusUnauthorized, exception.ErrUnauthorized)
+		res = response.Fail(err)
 		res.JSON(w)
 		return
 	}
@@ -175,7 +323,388 @@ func (handler *StoreHandler) DeleteStore(w http.ResponseWriter, r *http.Request)
 	params := mux.Vars(r)
 	id, _ := strconv.ParseInt(params["id"], 10, 64)
 
-	res = handler.UseCase.DeleteStore(ctx, id)
+	res = handler.UseCase.DeleteStore(ctx, id, version, claims.UserID)
+
+	res.JSON(w)
+}
+
+func (handler *StoreHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+	var userInput store.APIKeyCreate
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	params := mux.Vars(r)
+	id, _ := strconv.ParseInt(params["id"], 10, 64)
+
+	if err := json.NewDecoder(r.Body).Decode(&userInput); err != nil {
+		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity.Wrap(err))
+		res.JSON(w)
+		return
+	}
+
+	if err := handler.validate.StructCtx(ctx, userInput); err != nil {
+		res = response.Error(response.StatusBadRequest, exception.Validation(err))
+		res.JSON(w)
+		return
+	}
+
+	res = handler.UseCase.CreateAPIKey(ctx, id, claims.UserID, userInput)
+
+	res.JSON(w)
+}
+
+func (handler *StoreHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	params := mux.Vars(r)
+	id, _ := strconv.ParseInt(params["id"], 10, 64)
+
+	res = handler.UseCase.ListAPIKeys(ctx, id, claims.UserID)
 
 	res.JSON(w)
 }
+
+func (handler *StoreHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	params := mux.Vars(r)
+	id, _ := strconv.ParseInt(params["id"], 10, 64)
+	keyID, _ := strconv.ParseInt(params["keyID"], 10, 64)
+
+	res = handler.UseCase.RevokeAPIKey(ctx, id, claims.UserID, keyID)
+
+	res.JSON(w)
+}
+
+func (handler *StoreHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	params := mux.Vars(r)
+	id, _ := strconv.ParseInt(params["id"], 10, 64)
+
+	res = handler.UseCase.ListMembers(ctx, id, claims.UserID)
+
+	res.JSON(w)
+}
+
+func (handler *StoreHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	params := mux.Vars(r)
+	id, _ := strconv.ParseInt(params["id"], 10, 64)
+	memberID, _ := strconv.ParseInt(params["userID"], 10, 64)
+
+	res = handler.UseCase.RemoveMember(ctx, id, claims.UserID, memberID)
+
+	res.JSON(w)
+}
+
+func (handler *StoreHandler) Invite(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+	var userInput store.InvitationCreate
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	params := mux.Vars(r)
+	id, _ := strconv.ParseInt(params["id"], 10, 64)
+
+	if err := json.NewDecoder(r.Body).Decode(&userInput); err != nil {
+		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity.Wrap(err))
+		res.JSON(w)
+		return
+	}
+
+	if err := handler.validate.StructCtx(ctx, userInput); err != nil {
+		res = response.Error(response.StatusBadRequest, exception.Validation(err))
+		res.JSON(w)
+		return
+	}
+
+	res = handler.UseCase.Invite(ctx, id, claims.UserID, userInput)
+
+	res.JSON(w)
+}
+
+func (handler *StoreHandler) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	params := mux.Vars(r)
+	id, _ := strconv.ParseInt(params["id"], 10, 64)
+
+	res = handler.UseCase.ListInvitations(ctx, id, claims.UserID)
+
+	res.JSON(w)
+}
+
+func (handler *StoreHandler) CancelInvitation(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	params := mux.Vars(r)
+	id, _ := strconv.ParseInt(params["id"], 10, 64)
+	invitationID, _ := strconv.ParseInt(params["invitationID"], 10, 64)
+
+	res = handler.UseCase.CancelInvitation(ctx, id, claims.UserID, invitationID)
+
+	res.JSON(w)
+}
+
+// AcceptInvitation needs the session of the account the invitation was
+// sent to.
+func (handler *StoreHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+	var userInput store.InvitationAnswer
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&userInput); err != nil {
+		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity.Wrap(err))
+		res.JSON(w)
+		return
+	}
+
+	if err := handler.validate.StructCtx(ctx, userInput); err != nil {
+		res = response.Error(response.StatusBadRequest, exception.Validation(err))
+		res.JSON(w)
+		return
+	}
+
+	res = handler.UseCase.AcceptInvitation(ctx, claims.UserID, userInput)
+
+	res.JSON(w)
+}
+
+func (handler *StoreHandler) DeclineInvitation(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+	var userInput store.InvitationAnswer
+
+	ctx := r.Context()
+
+	if err := json.NewDecoder(r.Body).Decode(&userInput); err != nil {
+		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity.Wrap(err))
+		res.JSON(w)
+		return
+	}
+
+	if err := handler.validate.StructCtx(ctx, userInput); err != nil {
+		res = response.Error(response.StatusBadRequest, exception.Validation(err))
+		res.JSON(w)
+		return
+	}
+
+	res = handler.UseCase.DeclineInvitation(ctx, userInput)
+
+	res.JSON(w)
+}
+
+func (handler *StoreHandler) StartTransfer(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+	var userInput store.TransferCreate
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	params := mux.Vars(r)
+	id, _ := strconv.ParseInt(params["id"], 10, 64)
+
+	if err := json.NewDecoder(r.Body).Decode(&userInput); err != nil {
+		res = response.Error(response.StatusUnprocessableEntity, exception.ErrUnprocessableEntity.Wrap(err))
+		res.JSON(w)
+		return
+	}
+
+	if err := handler.validate.StructCtx(ctx, userInput); err != nil {
+		res = response.Error(response.StatusBadRequest, exception.Validation(err))
+		res.JSON(w)
+		return
+	}
+
+	res = handler.UseCase.StartTransfer(ctx, id, claims.UserID, userInput)
+
+	res.JSON(w)
+}
+
+func (handler *StoreHandler) PendingTransfer(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	params := mux.Vars(r)
+	id, _ := strconv.ParseInt(params["id"], 10, 64)
+
+	res = handler.UseCase.PendingTransfer(ctx, id, claims.UserID)
+
+	res.JSON(w)
+}
+
+func (handler *StoreHandler) CancelTransfer(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	params := mux.Vars(r)
+	id, _ := strconv.ParseInt(params["id"], 10, 64)
+
+	res = handler.UseCase.CancelTransfer(ctx, id, claims.UserID)
+
+	res.JSON(w)
+}
+
+// IncomingTransfers lists the stores offered to the account.
+func (handler *StoreHandler) IncomingTransfers(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	res = handler.UseCase.IncomingTransfers(ctx, claims.UserID)
+
+	res.JSON(w)
+}
+
+func (handler *StoreHandler) AcceptTransfer(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	params := mux.Vars(r)
+	id, _ := strconv.ParseInt(params["id"], 10, 64)
+
+	res = handler.UseCase.AcceptTransfer(ctx, id, claims.UserID)
+
+	res.JSON(w)
+}
+
+func (handler *StoreHandler) DeclineTransfer(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	params := mux.Vars(r)
+	id, _ := strconv.ParseInt(params["id"], 10, 64)
+
+	res = handler.UseCase.DeclineTransfer(ctx, id, claims.UserID)
+
+	res.JSON(w)
+}
+
+func (handler *StoreHandler) AuditLog(w http.ResponseWriter, r *http.Request) {
+	var res response.Response
+
+	ctx := r.Context()
+
+	claims, ok := sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	params := mux.Vars(r)
+	id, _ := strconv.ParseInt(params["id"], 10, 64)
+
+	res = handler.UseCase.AuditLog(ctx, id, claims.UserID)
+
+	res.JSON(w)
+}
+
+// sessionClaims reads the claims of the session cookie, answering 401
+// itself when there is no valid one. API keys cannot manage stores.
+func sessionClaims(w http.ResponseWriter, r *http.Request) (*jwt.JWTclaim, bool) {
+	c, err := r.Cookie("token")
+	if err != nil {
+		response.Error(response.StatusUnauthorized, exception.ErrUnauthorized).JSON(w)
+		return nil, false
+	}
+
+	claims, err := jwt.ParseClaims(c.Value)
+	if err != nil || claims.UserID == 0 {
+		response.Error(response.StatusUnauthorized, exception.ErrUnauthorized).JSON(w)
+		return nil, false
+	}
+
+	return claims, true
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/i18n"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/mailer"
+	"github.com/Risuii/models/store"
+)
+
+// Invitations issues the random single-use tokens that invite an email
+// address into a store. The token itself is only ever mailed; the database
+// keeps its SHA-256.
+type Invitations struct {
+	repo      InvitationRepository
+	mailer    mailer.Mailer
+	publicURL string
+	ttl       time.Duration
+}
+
+func NewInvitations(repo InvitationRepository, mail mailer.Mailer, publicURL string, ttl time.Duration) *Invitations {
+	return &Invitations{
+		repo:      repo,
+		mailer:    mail,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		ttl:       ttl,
+	}
+}
+
+func hashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Issue stores invitation with a new token and mails the token to its
+// email address, in the language of the request. When the mail cannot be
+// sent the invitation is deleted again: nobody could accept it, and it
+// would hold the address until it expires.
+func (i *Invitations) Issue(ctx context.Context, invitation store.Invitation, inviter string, storeName string) (store.Invitation, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return invitation, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now()
+	invitation.TokenHash = hashInvitationToken(token)
+	invitation.ExpiresAt = now.Add(i.ttl)
+	invitation.CreatedAt = now
+
+	var err error
+	invitation.ID, err = i.repo.Create(ctx, invitation)
+	if err != nil {
+		return invitation, err
+	}
+
+	link := i.publicURL + "/invitations?token=" + url.QueryEscape(token)
+	trans := i18n.Translator(i18n.FromContext(ctx))
+	hours := strconv.Itoa(int(i.ttl.Hours()))
+	role := i18n.Message(trans, "ROLE_"+strings.ToUpper(string(invitation.Role)), string(invitation.Role))
+
+	err = i.mailer.Send(ctx, mailer.Message{
+		To:      invitation.Email,
+		Subject: i18n.Message(trans, "MAIL_STORE_INVITATION_SUBJECT", "You are invited to join {0}", storeName),
+		// {0} is the name of the inviter, {1} the store, {2} the role, {3}
+		// the link and {4} its lifetime in hours.
+		Body: i18n.Message(trans, "MAIL_STORE_INVITATION_BODY",
+			"Hi,\n\n{0} invites you to join the store {1} as {2}. Open the link below to accept or decline the invitation:\n\n{3}\n\nThe link is valid for {4} hours.\n",
+			inviter, storeName, role, link, hours),
+	})
+	if err != nil {
+		if err := i.repo.Delete(context.WithoutCancel(ctx), invitation.StoreID, invitation.ID); err != nil {
+			logger.FromContext(ctx).ErrorContext(ctx, "delete unsent invitation", "error", err)
+		}
+		return invitation, err
+	}
+
+	return invitation, nil
+}
+
+// Find returns the pending invitation token was issued for. Unknown and
+// expired tokens fail with ErrInvalidToken.
+func (i *Invitations) Find(ctx context.Context, token string) (store.Invitation, error) {
+	invitation, err := i.repo.FindByHash(ctx, hashInvitationToken(token), time.Now())
+	if err == exception.ErrNotFound {
+		return invitation, exception.ErrInvalidToken
+	}
+
+	return invitation, err
+}
+
+func (i *Invitations) Pending(ctx context.Context, storeID int64) ([]store.Invitation, error) {
+	return i.repo.FindByStoreID(ctx, storeID, time.Now())
+}
+
+// Revoke deletes the invitation; its link stops working. Declining an
+// invitation revokes it too.
+func (i *Invitations) Revoke(ctx context.Context, storeID int64, id int64) error {
+	return i.repo.Delete(ctx, storeID, id)
+}
+
+// Accept makes userID a member with the role of the invitation.
+func (i *Invitations) Accept(ctx context.Context, invitation store.Invitation, userID int64) error {
+	return i.repo.Accept(ctx, invitation, userID, time.Now())
+}
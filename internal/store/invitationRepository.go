@@ -0,0 +1,216 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/retry"
+	"github.com/Risuii/helpers/stmtcache"
+	"github.com/Risuii/models/store"
+)
+
+type (
+	InvitationRepository interface {
+		Create(ctx context.Context, params store.Invitation) (int64, error)
+		FindByHash(ctx context.Context, tokenHash string, now time.Time) (store.Invitation, error)
+		FindByStoreID(ctx context.Context, storeID int64, now time.Time) ([]store.Invitation, error)
+		Delete(ctx context.Context, storeID int64, id int64) error
+		Accept(ctx context.Context, invitation store.Invitation, userID int64, now time.Time) error
+	}
+
+	invitationRepositoryImpl struct {
+		db           *sql.DB
+		tableName    string
+		membersTable string
+		stmts        *stmtcache.Cache
+	}
+)
+
+// NewInvitationRepositoryImpl adds the members of accepted invitations to
+// membersTable.
+func NewInvitationRepositoryImpl(db *sql.DB, tableName string, membersTable string) InvitationRepository {
+	return &invitationRepositoryImpl{
+		db:           db,
+		tableName:    tableName,
+		membersTable: membersTable,
+		stmts:        stmtcache.For(db),
+	}
+}
+
+const invitationColumns = `id, storeID, email, role, invited_by, token_hash, expires_at, created_at`
+
+func scanInvitation(row rowScanner, inv *store.Invitation) error {
+	return row.Scan(
+		&inv.ID,
+		&inv.StoreID,
+		&inv.Email,
+		&inv.Role,
+		&inv.InvitedBy,
+		&inv.TokenHash,
+		&inv.ExpiresAt,
+		&inv.CreatedAt,
+	)
+}
+
+// Create fails with ErrInvitationPending while an unexpired invitation to
+// the same email address is outstanding. Expired ones are replaced.
+func (ir *invitationRepositoryImpl) Create(ctx context.Context, params store.Invitation) (int64, error) {
+	purge := fmt.Sprintf(`DELETE FROM %s WHERE storeID = ? AND email = ? AND expires_at <= ?`, ir.tableName)
+	insert := fmt.Sprintf(`INSERT INTO %s (storeID, email, role, invited_by, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`, ir.tableName)
+
+	var result sql.Result
+	err := retry.Do(ctx, func(ctx context.Context) error {
+		stmt, err := ir.stmts.Prepare(ctx, purge)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.ExecContext(ctx, params.StoreID, params.Email, params.CreatedAt); err != nil {
+			return err
+		}
+
+		stmt, err = ir.stmts.Prepare(ctx, insert)
+		if err != nil {
+			return err
+		}
+		result, err = stmt.ExecContext(
+			ctx,
+			params.StoreID,
+			params.Email,
+			params.Role,
+			params.InvitedBy,
+			params.TokenHash,
+			params.ExpiresAt,
+			params.CreatedAt,
+		)
+		return err
+	})
+	if isDuplicate(err) {
+		return 0, exception.ErrInvitationPending
+	}
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+		return 0, exception.ErrInternalServer
+	}
+
+	ID, _ := result.LastInsertId()
+	return ID, nil
+}
+
+// FindByHash only finds invitations that have not expired.
+func (ir *invitationRepositoryImpl) FindByHash(ctx context.Context, tokenHash string, now time.Time) (store.Invitation, error) {
+	var inv store.Invitation
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE token_hash = ? AND expires_at > ?`, invitationColumns, ir.tableName)
+	stmt, err := ir.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+		return inv, exception.ErrInternalServer
+	}
+
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		return scanInvitation(stmt.QueryRowContext(ctx, tokenHash, now), &inv)
+	})
+	if err == sql.ErrNoRows {
+		return inv, exception.ErrNotFound
+	}
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+		return inv, exception.ErrInternalServer
+	}
+
+	return inv, nil
+}
+
+// FindByStoreID returns the invitations of a store that are still pending.
+func (ir *invitationRepositoryImpl) FindByStoreID(ctx context.Context, storeID int64, now time.Time) ([]store.Invitation, error) {
+	invitations := []store.Invitation{}
+
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE storeID = ? AND expires_at > ? ORDER BY id`, invitationColumns, ir.tableName)
+	stmt, err := ir.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+		return invitations, exception.ErrInternalServer
+	}
+
+	var rows *sql.Rows
+	err = retry.Do(ctx, func(ctx context.Context) (err error) {
+		rows, err = stmt.QueryContext(ctx, storeID, now)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+		return invitations, exception.ErrInternalServer
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var inv store.Invitation
+		if err := scanInvitation(rows, &inv); err != nil {
+			logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+			return invitations, exception.ErrInternalServer
+		}
+		invitations = append(invitations, inv)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+		return invitations, exception.ErrInternalServer
+	}
+
+	return invitations, nil
+}
+
+func (ir *invitationRepositoryImpl) Delete(ctx context.Context, storeID int64, id int64) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE storeID = ? AND id = ?`, ir.tableName)
+	stmt, err := ir.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	var result sql.Result
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		result, err = stmt.ExecContext(ctx, storeID, id)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", ir.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected < 1 {
+		return exception.ErrNotFound
+	}
+
+	return nil
+}
+
+// Accept uses up the invitation and makes userID a member with its role,
+// in one transaction. The delete is conditional on the invitation being
+// unexpired and still there, so it is accepted at most once.
+func (ir *invitationRepositoryImpl) Accept(ctx context.Context, invitation store.Invitation, userID int64, now time.Time) error {
+	remove := fmt.Sprintf(`DELETE FROM %s WHERE id = ? AND expires_at > ?`, ir.tableName)
+	insert := fmt.Sprintf(`INSERT INTO %s (storeID, userID, role, created_at) VALUES (?, ?, ?, ?)`, ir.membersTable)
+
+	return inTx(ctx, ir.db, ir.tableName, func(ctx context.Context, tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, remove, invitation.ID, now)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, _ := result.RowsAffected(); rowsAffected < 1 {
+			return exception.ErrInvalidToken
+		}
+
+		_, err = tx.ExecContext(ctx, insert, invitation.StoreID, userID, invitation.Role, now)
+		if isDuplicate(err) {
+			return exception.ErrAlreadyMember
+		}
+
+		return err
+	})
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/i18n"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/mailer"
+	"github.com/Risuii/models/account"
+	"github.com/Risuii/models/store"
+)
+
+// Lifecycle moves stores between states, for their owners and for the
+// admins of the platform. Owners are mailed about every moderation once
+// it is committed; a mail that cannot be sent is logged and does not undo
+// it.
+type Lifecycle struct {
+	repo   StatusRepository
+	mailer mailer.Mailer
+}
+
+func NewLifecycle(repo StatusRepository, mail mailer.Mailer) *Lifecycle {
+	return &Lifecycle{
+		repo:   repo,
+		mailer: mail,
+	}
+}
+
+// moderationAudit is the audit action of each moderation action.
+var moderationAudit = map[string]string{
+	store.ModerationSuspend:   store.AuditStoreSuspended,
+	store.ModerationReinstate: store.AuditStoreReinstated,
+	store.ModerationClose:     store.AuditStoreClosed,
+}
+
+// Move is a change of state of s made by actorID, a member of the store.
+// It fails with ErrStatusTransition when owners cannot make it.
+func (l *Lifecycle) Move(ctx context.Context, s store.Store, actorID int64, to store.Status) (store.Store, error) {
+	if !s.Status.OwnerCanMove(to) {
+		return s, exception.ErrStatusTransition
+	}
+
+	return l.set(ctx, s, to, "", store.AuditEntry{
+		ActorID: actorID,
+		Action:  store.AuditStatusChanged,
+		Details: map[string]interface{}{"from": s.Status, "to": to},
+	})
+}
+
+// Moderate applies the action of an admin to s and tells owner, who owns
+// s, about it. It fails with ErrStatusTransition when the action does not
+// apply to the state of s.
+func (l *Lifecycle) Moderate(ctx context.Context, s store.Store, owner account.Account, params store.Moderation) (store.Store, error) {
+	to, ok := params.Target(s.Status)
+	if !ok {
+		return s, exception.ErrStatusTransition
+	}
+
+	details := map[string]interface{}{"from": s.Status, "to": to, "reason": params.Reason}
+	if params.Note != "" {
+		details["note"] = params.Note
+	}
+
+	s, err := l.set(ctx, s, to, params.Reason, store.AuditEntry{
+		Action:  moderationAudit[params.Action],
+		Details: details,
+	})
+	if err != nil {
+		return s, err
+	}
+
+	l.notify(ctx, s, owner, params)
+
+	return s, nil
+}
+
+// FindByStatus lists the stores in status, for admins.
+func (l *Lifecycle) FindByStatus(ctx context.Context, status store.Status) ([]store.Store, error) {
+	return l.repo.FindByStatus(ctx, status)
+}
+
+func (l *Lifecycle) set(ctx context.Context, s store.Store, to store.Status, reason store.ReasonCode, entry store.AuditEntry) (store.Store, error) {
+	now := time.Now()
+	entry.StoreID = s.ID
+	entry.CreatedAt = now
+
+	err := l.repo.Set(ctx, store.StatusUpdate{
+		StoreID:   s.ID,
+		From:      s.Status,
+		To:        to,
+		Reason:    reason,
+		UpdatedAt: now,
+	}, entry)
+	if err != nil {
+		return s, err
+	}
+
+	s.Status = to
+	s.StatusReason = reason
+	s.UpdateAt = now
+	s.Version++
+
+	return s, nil
+}
+
+// notify mails owner the moderation of s, with the translation keys
+// MAIL_STORE_<STATE>_SUBJECT and _BODY in the language of the request.
+func (l *Lifecycle) notify(ctx context.Context, s store.Store, owner account.Account, params store.Moderation) {
+	trans := i18n.Translator(i18n.FromContext(ctx))
+	reason := i18n.Message(trans, "REASON_"+strings.ToUpper(string(params.Reason)), string(params.Reason))
+
+	key, subject, body := "MAIL_STORE_SUSPENDED", "{0} was suspended",
+		"Hi {0},\n\nYour store {1} was suspended for the following reason: {2}.\n\n{3}\n\nWhile it is suspended, it is hidden from buyers and its items cannot be changed. Reply to this email to appeal.\n"
+	switch s.Status {
+	case store.StatusActive:
+		key, subject, body = "MAIL_STORE_REINSTATED", "{0} is back",
+			"Hi {0},\n\nYour store {1} was reinstated and is visible to buyers again. Reason: {2}.\n\n{3}\n"
+	case store.StatusClosed:
+		key, subject, body = "MAIL_STORE_CLOSED", "{0} was closed",
+			"Hi {0},\n\nYour store {1} was closed by our team for the following reason: {2}.\n\n{3}\n\nReply to this email if you think this is a mistake.\n"
+	}
+
+	// {0} is the name of the owner, {1} the store, {2} the reason and {3}
+	// the note of the admin.
+	err := l.mailer.Send(ctx, mailer.Message{
+		To:      owner.Email,
+		Subject: i18n.Message(trans, key+"_SUBJECT", subject, s.NameStore),
+		Body:    i18n.Message(trans, key+"_BODY", body, owner.Name, s.NameStore, reason, params.Note),
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "send store moderation email", "store", s.ID, "action", params.Action, "error", err)
+	}
+}
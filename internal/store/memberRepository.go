@@ -0,0 +1,164 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/retry"
+	"github.com/Risuii/helpers/stmtcache"
+	"github.com/Risuii/models/store"
+)
+
+type (
+	// MemberRepository holds the managers and staff of stores. Owners are
+	// not members; they are the UserID of the store.
+	MemberRepository interface {
+		Find(ctx context.Context, storeID int64, userID int64) (store.Member, error)
+		FindByStoreID(ctx context.Context, storeID int64) ([]store.Member, error)
+		FindByUserID(ctx context.Context, userID int64) ([]store.Member, error)
+		Delete(ctx context.Context, storeID int64, userID int64) error
+	}
+
+	memberRepositoryImpl struct {
+		db           *sql.DB
+		tableName    string
+		accountTable string
+		stmts        *stmtcache.Cache
+	}
+)
+
+// NewMemberRepositoryImpl reads the name and email of members from
+// accountTable.
+func NewMemberRepositoryImpl(db *sql.DB, tableName string, accountTable string) MemberRepository {
+	return &memberRepositoryImpl{
+		db:           db,
+		tableName:    tableName,
+		accountTable: accountTable,
+		stmts:        stmtcache.For(db),
+	}
+}
+
+func scanMember(row rowScanner, m *store.Member) error {
+	return row.Scan(
+		&m.ID,
+		&m.StoreID,
+		&m.UserID,
+		&m.Name,
+		&m.Email,
+		&m.Role,
+		&m.CreatedAt,
+	)
+}
+
+func (mr *memberRepositoryImpl) query(where string) string {
+	return fmt.Sprintf(`SELECT m.id, m.storeID, m.userID, u.name, u.email, m.role, m.created_at FROM %s m JOIN %s u ON u.id = m.userID WHERE %s ORDER BY m.id`, mr.tableName, mr.accountTable, where)
+}
+
+func (mr *memberRepositoryImpl) Find(ctx context.Context, storeID int64, userID int64) (store.Member, error) {
+	var m store.Member
+	stmt, err := mr.stmts.Prepare(ctx, mr.query(`m.storeID = ? AND m.userID = ?`))
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", mr.tableName, "error", err)
+		return m, exception.ErrInternalServer
+	}
+
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		return scanMember(stmt.QueryRowContext(ctx, storeID, userID), &m)
+	})
+	if err == sql.ErrNoRows {
+		return m, exception.ErrNotFound
+	}
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", mr.tableName, "error", err)
+		return m, exception.ErrInternalServer
+	}
+
+	return m, nil
+}
+
+func (mr *memberRepositoryImpl) findAll(ctx context.Context, where string, arg int64) ([]store.Member, error) {
+	members := []store.Member{}
+
+	stmt, err := mr.stmts.Prepare(ctx, mr.query(where))
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", mr.tableName, "error", err)
+		return members, exception.ErrInternalServer
+	}
+
+	var rows *sql.Rows
+	err = retry.Do(ctx, func(ctx context.Context) (err error) {
+		rows, err = stmt.QueryContext(ctx, arg)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", mr.tableName, "error", err)
+		return members, exception.ErrInternalServer
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var m store.Member
+		if err := scanMember(rows, &m); err != nil {
+			logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", mr.tableName, "error", err)
+			return members, exception.ErrInternalServer
+		}
+		members = append(members, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", mr.tableName, "error", err)
+		return members, exception.ErrInternalServer
+	}
+
+	return members, nil
+}
+
+func (mr *memberRepositoryImpl) FindByStoreID(ctx context.Context, storeID int64) ([]store.Member, error) {
+	return mr.findAll(ctx, `m.storeID = ?`, storeID)
+}
+
+// FindByUserID returns the memberships of an account, one per store.
+func (mr *memberRepositoryImpl) FindByUserID(ctx context.Context, userID int64) ([]store.Member, error) {
+	return mr.findAll(ctx, `m.userID = ?`, userID)
+}
+
+func (mr *memberRepositoryImpl) Delete(ctx context.Context, storeID int64, userID int64) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE storeID = ? AND userID = ?`, mr.tableName)
+	stmt, err := mr.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", mr.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	var result sql.Result
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		result, err = stmt.ExecContext(ctx, storeID, userID)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", mr.tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected < 1 {
+		return exception.ErrNotFound
+	}
+
+	return nil
+}
+
+// isDuplicate reports whether err is a violation of a unique index.
+func isDuplicate(err error) bool {
+	const errDuplicateEntry = 1062
+
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == errDuplicateEntry
+}
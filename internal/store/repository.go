@@ -3,10 +3,13 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
-	"log"
 
 	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/retry"
+	"github.com/Risuii/helpers/stmtcache"
 	"github.com/Risuii/models/store"
 )
 
@@ -16,13 +19,18 @@ type (
 		FindByUserID(ctx context.Context, userID int64) ([]store.Store, error)
 		FindByName(ctx context.Context, nameStore string) (store.Store, error)
 		FindByID(ctx context.Context, id int64) (store.Store, error)
+		FindBySlug(ctx context.Context, slug string) (store.Store, error)
 		Update(ctx context.Context, id int64, params store.Store) error
-		Delete(ctx context.Context, id int64) error
+		Delete(ctx context.Context, id int64, version int64) error
+		// Forget is told about writes to the store made around the
+		// repository, such as ownership transfers, so caches can drop it.
+		Forget(ctx context.Context, id int64, userIDs ...int64)
 	}
 
 	storeRepositoryImpl struct {
 		DB        *sql.DB
 		tableName string
+		stmts     *stmtcache.Cache
 	}
 )
 
@@ -30,28 +38,87 @@ func NewStoreRepository(db *sql.DB, tableName string) StoreRepository {
 	return &storeRepositoryImpl{
 		DB:        db,
 		tableName: tableName,
+		stmts:     stmtcache.For(db),
 	}
 }
 
+const storeColumns = `id, userID, nameStore, slug, description, logo_url, banner_url, contact_email, contact_phone, address, city, country, latitude, longitude, timezone, hours, status, status_reason, created_at, update_at, version`
+
+func scanStore(row rowScanner, s *store.Store) error {
+	var hours []byte
+	err := row.Scan(
+		&s.ID,
+		&s.UserID,
+		&s.NameStore,
+		&s.Slug,
+		&s.Description,
+		&s.LogoURL,
+		&s.BannerURL,
+		&s.ContactEmail,
+		&s.ContactPhone,
+		&s.Location.Address,
+		&s.Location.City,
+		&s.Location.Country,
+		&s.Location.Latitude,
+		&s.Location.Longitude,
+		&s.Timezone,
+		&hours,
+		&s.Status,
+		&s.StatusReason,
+		&s.CreatedAt,
+		&s.UpdateAt,
+		&s.Version,
+	)
+	if err != nil || len(hours) == 0 {
+		return err
+	}
+
+	return json.Unmarshal(hours, &s.Hours)
+}
+
+// Create fails with ErrSlugTaken when another store has the slug.
 func (repo *storeRepositoryImpl) Create(ctx context.Context, params store.Store) (int64, error) {
-	query := fmt.Sprintf(`INSERT INTO %s (userID, nameStore, description, created_at) VALUES (?,?,?,?)`, repo.tableName)
-	stmt, err := repo.DB.PrepareContext(ctx, query)
+	hours, err := json.Marshal(params.Hours)
 	if err != nil {
-		log.Println(err)
-		return 0, exception.ErrInternalServer
+		return 0, exception.ErrInternalServer.Wrap(err)
 	}
 
-	defer stmt.Close()
+	query := fmt.Sprintf(`INSERT INTO %s (userID, nameStore, slug, description, logo_url, banner_url, contact_email, contact_phone, address, city, country, latitude, longitude, timezone, hours, status, created_at) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`, repo.tableName)
+	stmt, err := repo.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
+		return 0, exception.ErrInternalServer
+	}
 
-	result, err := stmt.ExecContext(
-		ctx,
-		params.UserID,
-		params.NameStore,
-		params.Description,
-		params.CreatedAt,
-	)
+	var result sql.Result
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		result, err = stmt.ExecContext(
+			ctx,
+			params.UserID,
+			params.NameStore,
+			params.Slug,
+			params.Description,
+			params.LogoURL,
+			params.BannerURL,
+			params.ContactEmail,
+			params.ContactPhone,
+			params.Location.Address,
+			params.Location.City,
+			params.Location.Country,
+			params.Location.Latitude,
+			params.Location.Longitude,
+			params.Timezone,
+			hours,
+			params.Status,
+			params.CreatedAt,
+		)
+		return err
+	})
+	if isDuplicate(err) {
+		return 0, exception.ErrSlugTaken
+	}
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 		return 0, exception.ErrInternalServer
 	}
 
@@ -62,9 +129,20 @@ func (repo *storeRepositoryImpl) Create(ctx context.Context, params store.Store)
 func (repo *storeRepositoryImpl) FindByUserID(ctx context.Context, userID int64) ([]store.Store, error) {
 	var stores []store.Store
 
-	rows, err := repo.DB.Query(fmt.Sprintf(`SELECT id, userID, nameStore, description, created_at, update_at FROM %s WHERE userID = %d`, repo.tableName, userID))
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE userID = ?`, storeColumns, repo.tableName)
+	stmt, err := repo.stmts.Prepare(ctx, query)
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
+		return stores, exception.ErrInternalServer
+	}
+
+	var rows *sql.Rows
+	err = retry.Do(ctx, func(ctx context.Context) (err error) {
+		rows, err = stmt.QueryContext(ctx, userID)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 		return stores, exception.ErrInternalServer
 	}
 
@@ -72,141 +150,136 @@ func (repo *storeRepositoryImpl) FindByUserID(ctx context.Context, userID int64)
 
 	for rows.Next() {
 		var c store.Store
-		if err := rows.Scan(
-			&c.ID,
-			&c.UserID,
-			&c.NameStore,
-			&c.Description,
-			&c.CreatedAt,
-			&c.UpdateAt,
-		); err != nil {
-			log.Println(err)
+		if err := scanStore(rows, &c); err != nil {
+			logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 			return stores, exception.ErrInternalServer
 		}
 		stores = append(stores, c)
 	}
 
 	if err = rows.Err(); err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 		return stores, exception.ErrInternalServer
 	}
 
 	return stores, nil
 }
 
-func (repo *storeRepositoryImpl) FindByName(ctx context.Context, nameStore string) (store.Store, error) {
+func (repo *storeRepositoryImpl) findOne(ctx context.Context, where string, arg interface{}) (store.Store, error) {
 	var store store.Store
-	query := fmt.Sprintf(`SELECT id, userID, nameStore, description, created_at, update_at FROM %s WHERE nameStore = ?`, repo.tableName)
-	stmt, err := repo.DB.PrepareContext(ctx, query)
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE %s`, storeColumns, repo.tableName, where)
+	stmt, err := repo.stmts.Prepare(ctx, query)
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 		return store, exception.ErrInternalServer
 	}
 
-	defer stmt.Close()
-
-	row := stmt.QueryRowContext(ctx, nameStore)
-
-	err = row.Scan(
-		&store.ID,
-		&store.UserID,
-		&store.NameStore,
-		&store.Description,
-		&store.CreatedAt,
-		&store.UpdateAt,
-	)
-
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		return scanStore(stmt.QueryRowContext(ctx, arg), &store)
+	})
+	if err == sql.ErrNoRows {
+		return store, exception.ErrNotFound
+	}
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 		return store, exception.ErrInternalServer
 	}
 
 	return store, nil
 }
 
-func (repo *storeRepositoryImpl) FindByID(ctx context.Context, id int64) (store.Store, error) {
-	var store store.Store
-	query := fmt.Sprintf(`SELECT id, userID, nameStore, description, created_at, update_at FROM %s WHERE id = ?`, repo.tableName)
-	stmt, err := repo.DB.PrepareContext(ctx, query)
-	if err != nil {
-		log.Println(err)
-		return store, exception.ErrInternalServer
-	}
-
-	defer stmt.Close()
+func (repo *storeRepositoryImpl) FindByName(ctx context.Context, nameStore string) (store.Store, error) {
+	return repo.findOne(ctx, `nameStore = ?`, nameStore)
+}
 
-	row := stmt.QueryRowContext(ctx, id)
+func (repo *storeRepositoryImpl) FindByID(ctx context.Context, id int64) (store.Store, error) {
+	return repo.findOne(ctx, `id = ?`, id)
+}
 
-	err = row.Scan(
-		&store.ID,
-		&store.UserID,
-		&store.NameStore,
-		&store.Description,
-		&store.CreatedAt,
-		&store.UpdateAt,
-	)
+func (repo *storeRepositoryImpl) FindBySlug(ctx context.Context, slug string) (store.Store, error) {
+	return repo.findOne(ctx, `slug = ?`, slug)
+}
 
+func (repo *storeRepositoryImpl) Update(ctx context.Context, id int64, params store.Store) error {
+	hours, err := json.Marshal(params.Hours)
 	if err != nil {
-		log.Println(err)
-		return store, exception.ErrInternalServer
+		return exception.ErrInternalServer.Wrap(err)
 	}
 
-	return store, nil
-}
-
-func (repo *storeRepositoryImpl) Update(ctx context.Context, id int64, params store.Store) error {
-	query := fmt.Sprintf(`UPDATE %s SET nameStore = ?, description = ?, update_at = ? WHERE id = %d`, repo.tableName, id)
-	stmt, err := repo.DB.PrepareContext(ctx, query)
+	query := fmt.Sprintf(`UPDATE %s SET nameStore = ?, description = ?, logo_url = ?, banner_url = ?, contact_email = ?, contact_phone = ?, address = ?, city = ?, country = ?, latitude = ?, longitude = ?, timezone = ?, hours = ?, update_at = ?, version = version + 1 WHERE id = ? AND version = ?`, repo.tableName)
+	stmt, err := repo.stmts.Prepare(ctx, query)
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 		return exception.ErrInternalServer
 	}
 
-	defer stmt.Close()
-
-	result, err := stmt.ExecContext(
-		ctx,
-		params.NameStore,
-		params.Description,
-		params.UpdateAt,
-	)
+	var result sql.Result
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		result, err = stmt.ExecContext(
+			ctx,
+			params.NameStore,
+			params.Description,
+			params.LogoURL,
+			params.BannerURL,
+			params.ContactEmail,
+			params.ContactPhone,
+			params.Location.Address,
+			params.Location.City,
+			params.Location.Country,
+			params.Location.Latitude,
+			params.Location.Longitude,
+			params.Timezone,
+			hours,
+			params.UpdateAt,
+			id,
+			params.Version,
+		)
+		return err
+	})
 
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 		return exception.ErrInternalServer
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 
 	if rowsAffected < 1 {
-		return exception.ErrNotFound
+		return exception.ErrPreconditionFailed
 	}
 
 	return nil
 }
 
-func (repo *storeRepositoryImpl) Delete(ctx context.Context, id int64) error {
-	query := fmt.Sprintf(`DELETE FROM %s WHERE id = %d`, repo.tableName, id)
-	stmt, err := repo.DB.PrepareContext(ctx, query)
+func (repo *storeRepositoryImpl) Delete(ctx context.Context, id int64, version int64) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE id = ? AND version = ?`, repo.tableName)
+	stmt, err := repo.stmts.Prepare(ctx, query)
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 		return exception.ErrInternalServer
 	}
 
-	defer stmt.Close()
-
-	result, err := stmt.ExecContext(
-		ctx,
-	)
+	var result sql.Result
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		result, err = stmt.ExecContext(
+			ctx,
+			id,
+			version,
+		)
+		return err
+	})
 	if err != nil {
-		log.Println(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", repo.tableName, "error", err)
 		return exception.ErrInternalServer
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected < 1 {
-		return exception.ErrNotFound
+		return exception.ErrPreconditionFailed
 	}
 
 	return nil
 }
+
+// Forget has nothing to drop; the database is always current.
+func (repo *storeRepositoryImpl) Forget(ctx context.Context, id int64, userIDs ...int64) {}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/retry"
+	"github.com/Risuii/helpers/stmtcache"
+	"github.com/Risuii/models/store"
+)
+
+type (
+	// StatusRepository moves stores between states and records every move
+	// in the audit trail, in the same transaction.
+	StatusRepository interface {
+		Set(ctx context.Context, update store.StatusUpdate, entry store.AuditEntry) error
+		FindByStatus(ctx context.Context, status store.Status) ([]store.Store, error)
+	}
+
+	statusRepositoryImpl struct {
+		db         *sql.DB
+		tableName  string
+		auditTable string
+		stmts      *stmtcache.Cache
+	}
+)
+
+// NewStatusRepositoryImpl changes the stores of tableName and writes the
+// trail to auditTable.
+func NewStatusRepositoryImpl(db *sql.DB, tableName string, auditTable string) StatusRepository {
+	return &statusRepositoryImpl{
+		db:         db,
+		tableName:  tableName,
+		auditTable: auditTable,
+		stmts:      stmtcache.For(db),
+	}
+}
+
+// Set fails with ErrConflicted when the store is no longer in
+// update.From, and with ErrNotFound when there is no such store.
+func (sr *statusRepositoryImpl) Set(ctx context.Context, update store.StatusUpdate, entry store.AuditEntry) error {
+	set := fmt.Sprintf(`UPDATE %s SET status = ?, status_reason = ?, update_at = ?, version = version + 1 WHERE id = ? AND status = ?`, sr.tableName)
+	exists := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE id = ?`, sr.tableName)
+
+	return inTx(ctx, sr.db, sr.tableName, func(ctx context.Context, tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, set, update.To, update.Reason, update.UpdatedAt, update.StoreID, update.From)
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected, _ := result.RowsAffected(); rowsAffected < 1 {
+			var n int
+			if err := tx.QueryRowContext(ctx, exists, update.StoreID).Scan(&n); err != nil {
+				return err
+			}
+			if n == 0 {
+				return exception.ErrNotFound
+			}
+			return exception.ErrConflicted
+		}
+
+		return recordAudit(ctx, tx, sr.auditTable, entry)
+	})
+}
+
+// FindByStatus returns the stores in status, most recently changed first.
+func (sr *statusRepositoryImpl) FindByStatus(ctx context.Context, status store.Status) ([]store.Store, error) {
+	stores := []store.Store{}
+
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE status = ? ORDER BY update_at DESC, id DESC`, storeColumns, sr.tableName)
+	stmt, err := sr.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", sr.tableName, "error", err)
+		return stores, exception.ErrInternalServer
+	}
+
+	var rows *sql.Rows
+	err = retry.Do(ctx, func(ctx context.Context) (err error) {
+		rows, err = stmt.QueryContext(ctx, status)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", sr.tableName, "error", err)
+		return stores, exception.ErrInternalServer
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var s store.Store
+		if err := scanStore(rows, &s); err != nil {
+			logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", sr.tableName, "error", err)
+			return stores, exception.ErrInternalServer
+		}
+		stores = append(stores, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", sr.tableName, "error", err)
+		return stores, exception.ErrInternalServer
+	}
+
+	return stores, nil
+}
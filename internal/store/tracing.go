@@ -0,0 +1,261 @@
+package store
+
+import (
+	"context"
+
+	"github.com/Risuii/helpers/response"
+	"github.com/Risuii/helpers/tracing"
+	"github.com/Risuii/models/store"
+	"github.com/Risuii/models/token"
+)
+
+type storeUseCaseTracing struct {
+	StoreUseCase
+}
+
+// NewStoreUseCaseTracing starts one span per use case method.
+func NewStoreUseCaseTracing(usecase StoreUseCase) StoreUseCase {
+	return &storeUseCaseTracing{
+		StoreUseCase: usecase,
+	}
+}
+
+func (t *storeUseCaseTracing) CreateStore(ctx context.Context, userid int64, params store.Store) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.CreateStore")
+	res := t.StoreUseCase.CreateStore(ctx, userid, params)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) Read(ctx context.Context, userID int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.Read")
+	res := t.StoreUseCase.Read(ctx, userID)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) ReadBySlug(ctx context.Context, slug string) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.ReadBySlug")
+	res := t.StoreUseCase.ReadBySlug(ctx, slug)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) Memberships(ctx context.Context, userID int64, storeID int64) (response.Response, token.Token) {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.Memberships")
+	res, newToken := t.StoreUseCase.Memberships(ctx, userID, storeID)
+	tracing.End(span, res.Err())
+
+	return res, newToken
+}
+
+func (t *storeUseCaseTracing) UpdateStore(ctx context.Context, id int64, version int64, userID int64, params store.StoreUpdate) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.UpdateStore")
+	res := t.StoreUseCase.UpdateStore(ctx, id, version, userID, params)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) SetHours(ctx context.Context, id int64, version int64, userID int64, hours store.Hours) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.SetHours")
+	res := t.StoreUseCase.SetHours(ctx, id, version, userID, hours)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) SetLogo(ctx context.Context, id int64, version int64, userID int64, data []byte) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.SetLogo")
+	res := t.StoreUseCase.SetLogo(ctx, id, version, userID, data)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) SetBanner(ctx context.Context, id int64, version int64, userID int64, data []byte) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.SetBanner")
+	res := t.StoreUseCase.SetBanner(ctx, id, version, userID, data)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) SetStatus(ctx context.Context, id int64, version int64, userID int64, params store.StatusChange) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.SetStatus")
+	res := t.StoreUseCase.SetStatus(ctx, id, version, userID, params)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) DeleteStore(ctx context.Context, id int64, version int64, userID int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.DeleteStore")
+	res := t.StoreUseCase.DeleteStore(ctx, id, version, userID)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) CreateAPIKey(ctx context.Context, storeID int64, userID int64, params store.APIKeyCreate) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.CreateAPIKey")
+	res := t.StoreUseCase.CreateAPIKey(ctx, storeID, userID, params)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) ListAPIKeys(ctx context.Context, storeID int64, userID int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.ListAPIKeys")
+	res := t.StoreUseCase.ListAPIKeys(ctx, storeID, userID)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) RevokeAPIKey(ctx context.Context, storeID int64, userID int64, id int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.RevokeAPIKey")
+	res := t.StoreUseCase.RevokeAPIKey(ctx, storeID, userID, id)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) ListMembers(ctx context.Context, storeID int64, userID int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.ListMembers")
+	res := t.StoreUseCase.ListMembers(ctx, storeID, userID)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) RemoveMember(ctx context.Context, storeID int64, userID int64, memberID int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.RemoveMember")
+	res := t.StoreUseCase.RemoveMember(ctx, storeID, userID, memberID)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) Invite(ctx context.Context, storeID int64, userID int64, params store.InvitationCreate) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.Invite")
+	res := t.StoreUseCase.Invite(ctx, storeID, userID, params)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) ListInvitations(ctx context.Context, storeID int64, userID int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.ListInvitations")
+	res := t.StoreUseCase.ListInvitations(ctx, storeID, userID)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) CancelInvitation(ctx context.Context, storeID int64, userID int64, id int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.CancelInvitation")
+	res := t.StoreUseCase.CancelInvitation(ctx, storeID, userID, id)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) AcceptInvitation(ctx context.Context, userID int64, params store.InvitationAnswer) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.AcceptInvitation")
+	res := t.StoreUseCase.AcceptInvitation(ctx, userID, params)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) DeclineInvitation(ctx context.Context, params store.InvitationAnswer) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.DeclineInvitation")
+	res := t.StoreUseCase.DeclineInvitation(ctx, params)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) StartTransfer(ctx context.Context, storeID int64, userID int64, params store.TransferCreate) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.StartTransfer")
+	res := t.StoreUseCase.StartTransfer(ctx, storeID, userID, params)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) PendingTransfer(ctx context.Context, storeID int64, userID int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.PendingTransfer")
+	res := t.StoreUseCase.PendingTransfer(ctx, storeID, userID)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) CancelTransfer(ctx context.Context, storeID int64, userID int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.CancelTransfer")
+	res := t.StoreUseCase.CancelTransfer(ctx, storeID, userID)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) IncomingTransfers(ctx context.Context, userID int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.IncomingTransfers")
+	res := t.StoreUseCase.IncomingTransfers(ctx, userID)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) AcceptTransfer(ctx context.Context, storeID int64, userID int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.AcceptTransfer")
+	res := t.StoreUseCase.AcceptTransfer(ctx, storeID, userID)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) DeclineTransfer(ctx context.Context, storeID int64, userID int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.DeclineTransfer")
+	res := t.StoreUseCase.DeclineTransfer(ctx, storeID, userID)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) AuditLog(ctx context.Context, storeID int64, userID int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.AuditLog")
+	res := t.StoreUseCase.AuditLog(ctx, storeID, userID)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) Moderate(ctx context.Context, id int64, params store.Moderation) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.Moderate")
+	res := t.StoreUseCase.Moderate(ctx, id, params)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) ListByStatus(ctx context.Context, status store.Status) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.ListByStatus")
+	res := t.StoreUseCase.ListByStatus(ctx, status)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (t *storeUseCaseTracing) ModerationLog(ctx context.Context, id int64) response.Response {
+	ctx, span := tracing.Tracer().Start(ctx, "StoreUseCase.ModerationLog")
+	res := t.StoreUseCase.ModerationLog(ctx, id)
+	tracing.End(span, res.Err())
+
+	return res
+}
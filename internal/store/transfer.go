@@ -0,0 +1,174 @@
+package store
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Risuii/helpers/i18n"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/mailer"
+	"github.com/Risuii/models/account"
+	"github.com/Risuii/models/store"
+)
+
+// Transfers runs ownership transfers and tells both accounts about every
+// step. Mails are sent after the change is committed; one that cannot be
+// sent is logged and does not undo the step.
+type Transfers struct {
+	repo      TransferRepository
+	mailer    mailer.Mailer
+	publicURL string
+	ttl       time.Duration
+}
+
+func NewTransfers(repo TransferRepository, mail mailer.Mailer, publicURL string, ttl time.Duration) *Transfers {
+	return &Transfers{
+		repo:      repo,
+		mailer:    mail,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		ttl:       ttl,
+	}
+}
+
+func transferDetails(from, to account.Account) map[string]interface{} {
+	return map[string]interface{}{
+		"from_user_id": from.ID,
+		"to_user_id":   to.ID,
+		"to_email":     to.Email,
+	}
+}
+
+// Start offers s to the account to. The owner from stays the owner until
+// to accepts.
+func (t *Transfers) Start(ctx context.Context, s store.Store, from, to account.Account) (store.Transfer, error) {
+	now := time.Now()
+	transfer := store.Transfer{
+		StoreID:    s.ID,
+		FromUserID: from.ID,
+		ToUserID:   to.ID,
+		ExpiresAt:  now.Add(t.ttl),
+		CreatedAt:  now,
+	}
+
+	err := t.repo.Create(ctx, transfer, store.AuditEntry{
+		StoreID:   s.ID,
+		ActorID:   from.ID,
+		Action:    store.AuditTransferStarted,
+		Details:   transferDetails(from, to),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return transfer, err
+	}
+
+	link := t.publicURL + "/store-transfers"
+	hours := strconv.Itoa(int(t.ttl.Hours()))
+
+	// {0} is the name of the recipient, {1} the owner, {2} the store, {3}
+	// the link and {4} the lifetime of the offer in hours.
+	t.notify(ctx, to, "MAIL_STORE_TRANSFER", "{0} wants to hand {1} over to you",
+		"Hi {0},\n\n{1} wants to make you the owner of the store {2}. Open the link below and log in to accept or decline:\n\n{3}\n\nThe offer is valid for {4} hours.\n",
+		[]string{from.Name, s.NameStore}, to.Name, from.Name, s.NameStore, link, hours)
+	// {0} is the name of the owner, {1} the store and {2} the recipient.
+	t.notify(ctx, from, "MAIL_STORE_TRANSFER_STARTED", "Transfer of {0} started",
+		"Hi {0},\n\nYou offered the store {1} to {2}. You stay the owner until they accept, and you can cancel the transfer until then.\n",
+		[]string{s.NameStore}, from.Name, s.NameStore, to.Email)
+
+	return transfer, nil
+}
+
+// Pending returns the transfer of the store that waits for an answer.
+func (t *Transfers) Pending(ctx context.Context, storeID int64) (store.Transfer, error) {
+	return t.repo.Find(ctx, storeID, time.Now())
+}
+
+// Incoming returns the transfers offered to userID.
+func (t *Transfers) Incoming(ctx context.Context, userID int64) ([]store.Transfer, error) {
+	return t.repo.FindByRecipient(ctx, userID, time.Now())
+}
+
+// Cancel withdraws the offer of s before the recipient to answers.
+func (t *Transfers) Cancel(ctx context.Context, s store.Store, from, to account.Account) error {
+	err := t.repo.Cancel(ctx, s.ID, store.AuditEntry{
+		StoreID:   s.ID,
+		ActorID:   from.ID,
+		Action:    store.AuditTransferCancelled,
+		Details:   transferDetails(from, to),
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// {0} is the name of the recipient, {1} the owner and {2} the store.
+	t.notify(ctx, to, "MAIL_STORE_TRANSFER_CANCELLED", "The transfer of {0} was cancelled",
+		"Hi {0},\n\n{1} cancelled the transfer of the store {2} to you.\n",
+		[]string{s.NameStore}, to.Name, from.Name, s.NameStore)
+
+	return nil
+}
+
+// Decline turns the offer of s down on behalf of the recipient to.
+func (t *Transfers) Decline(ctx context.Context, s store.Store, from, to account.Account) error {
+	err := t.repo.Cancel(ctx, s.ID, store.AuditEntry{
+		StoreID:   s.ID,
+		ActorID:   to.ID,
+		Action:    store.AuditTransferDeclined,
+		Details:   transferDetails(from, to),
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// {0} is the name of the owner, {1} the recipient and {2} the store.
+	t.notify(ctx, from, "MAIL_STORE_TRANSFER_DECLINED", "The transfer of {0} was declined",
+		"Hi {0},\n\n{1} declined to take over the store {2}. You are still its owner.\n",
+		[]string{s.NameStore}, from.Name, to.Name, s.NameStore)
+
+	return nil
+}
+
+// Accept makes to the owner of s in place of from.
+func (t *Transfers) Accept(ctx context.Context, s store.Store, transfer store.Transfer, from, to account.Account) error {
+	err := t.repo.Accept(ctx, transfer, store.AuditEntry{
+		StoreID:   s.ID,
+		ActorID:   to.ID,
+		Action:    store.AuditTransferAccepted,
+		Details:   transferDetails(from, to),
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// {0} is the name of the previous owner, {1} the new owner and {2} the
+	// store.
+	t.notify(ctx, from, "MAIL_STORE_TRANSFER_COMPLETED", "{0} has a new owner",
+		"Hi {0},\n\n{1} accepted the transfer and is now the owner of the store {2}. You no longer have access to it.\n\nIf you did not start this transfer, contact us right away.\n",
+		[]string{s.NameStore}, from.Name, to.Name, s.NameStore)
+	// {0} is the name of the new owner, {1} the store and {2} the previous
+	// owner.
+	t.notify(ctx, to, "MAIL_STORE_TRANSFER_RECEIVED", "You are now the owner of {0}",
+		"Hi {0},\n\nYou are now the owner of the store {1}, handed over by {2}. Its items, members and API keys are yours to manage.\n",
+		[]string{s.NameStore}, to.Name, s.NameStore, from.Name)
+
+	return nil
+}
+
+// notify mails user the message with the translation keys key+"_SUBJECT"
+// and key+"_BODY", in the language of the request.
+func (t *Transfers) notify(ctx context.Context, user account.Account, key, subject, body string, subjectParams []string, bodyParams ...string) {
+	trans := i18n.Translator(i18n.FromContext(ctx))
+
+	err := t.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: i18n.Message(trans, key+"_SUBJECT", subject, subjectParams...),
+		Body:    i18n.Message(trans, key+"_BODY", body, bodyParams...),
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "send store transfer email", "action", key, "error", err)
+	}
+}
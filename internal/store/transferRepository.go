@@ -0,0 +1,224 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/retry"
+	"github.com/Risuii/helpers/stmtcache"
+	"github.com/Risuii/models/store"
+)
+
+type (
+	// TransferRepository keeps the pending ownership transfers and records
+	// every step of them in the audit trail, in the same transaction.
+	TransferRepository interface {
+		Create(ctx context.Context, params store.Transfer, entry store.AuditEntry) error
+		Find(ctx context.Context, storeID int64, now time.Time) (store.Transfer, error)
+		FindByRecipient(ctx context.Context, userID int64, now time.Time) ([]store.Transfer, error)
+		Cancel(ctx context.Context, storeID int64, entry store.AuditEntry) error
+		Accept(ctx context.Context, transfer store.Transfer, entry store.AuditEntry) error
+	}
+
+	transferRepositoryImpl struct {
+		db           *sql.DB
+		tableName    string
+		storesTable  string
+		membersTable string
+		auditTable   string
+		stmts        *stmtcache.Cache
+	}
+)
+
+// NewTransferRepositoryImpl changes the owner in storesTable, drops the
+// new owner from membersTable and writes the trail to auditTable.
+func NewTransferRepositoryImpl(db *sql.DB, tableName string, storesTable string, membersTable string, auditTable string) TransferRepository {
+	return &transferRepositoryImpl{
+		db:           db,
+		tableName:    tableName,
+		storesTable:  storesTable,
+		membersTable: membersTable,
+		auditTable:   auditTable,
+		stmts:        stmtcache.For(db),
+	}
+}
+
+// inTx runs fn in a transaction. Errors of the exception package are
+// returned as they are, any other one is logged.
+func inTx(ctx context.Context, db *sql.DB, tableName string, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	err := retry.Do(ctx, func(ctx context.Context) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := fn(ctx, tx); err != nil {
+			return err
+		}
+
+		return retry.Final(tx.Commit())
+	})
+
+	var known *exception.Error
+	if err != nil && !errors.As(err, &known) {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", tableName, "error", err)
+		return exception.ErrInternalServer
+	}
+
+	return err
+}
+
+// Create fails with ErrTransferPending while another transfer of the store
+// is pending. Expired ones are replaced.
+func (tr *transferRepositoryImpl) Create(ctx context.Context, params store.Transfer, entry store.AuditEntry) error {
+	purge := fmt.Sprintf(`DELETE FROM %s WHERE storeID = ? AND expires_at <= ?`, tr.tableName)
+	insert := fmt.Sprintf(`INSERT INTO %s (storeID, from_user, to_user, expires_at, created_at) VALUES (?, ?, ?, ?, ?)`, tr.tableName)
+
+	return inTx(ctx, tr.db, tr.tableName, func(ctx context.Context, tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, purge, params.StoreID, params.CreatedAt); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, insert, params.StoreID, params.FromUserID, params.ToUserID, params.ExpiresAt, params.CreatedAt)
+		if isDuplicate(err) {
+			return exception.ErrTransferPending
+		}
+		if err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, tr.auditTable, entry)
+	})
+}
+
+func scanTransfer(row rowScanner, t *store.Transfer) error {
+	return row.Scan(
+		&t.StoreID,
+		&t.FromUserID,
+		&t.ToUserID,
+		&t.ExpiresAt,
+		&t.CreatedAt,
+	)
+}
+
+// Find only finds transfers that have not expired.
+func (tr *transferRepositoryImpl) Find(ctx context.Context, storeID int64, now time.Time) (store.Transfer, error) {
+	var t store.Transfer
+	query := fmt.Sprintf(`SELECT storeID, from_user, to_user, expires_at, created_at FROM %s WHERE storeID = ? AND expires_at > ?`, tr.tableName)
+	stmt, err := tr.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", tr.tableName, "error", err)
+		return t, exception.ErrInternalServer
+	}
+
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		return scanTransfer(stmt.QueryRowContext(ctx, storeID, now), &t)
+	})
+	if err == sql.ErrNoRows {
+		return t, exception.ErrNotFound
+	}
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", tr.tableName, "error", err)
+		return t, exception.ErrInternalServer
+	}
+
+	return t, nil
+}
+
+// FindByRecipient returns the pending transfers offered to userID.
+func (tr *transferRepositoryImpl) FindByRecipient(ctx context.Context, userID int64, now time.Time) ([]store.Transfer, error) {
+	transfers := []store.Transfer{}
+
+	query := fmt.Sprintf(`SELECT storeID, from_user, to_user, expires_at, created_at FROM %s WHERE to_user = ? AND expires_at > ? ORDER BY created_at`, tr.tableName)
+	stmt, err := tr.stmts.Prepare(ctx, query)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", tr.tableName, "error", err)
+		return transfers, exception.ErrInternalServer
+	}
+
+	var rows *sql.Rows
+	err = retry.Do(ctx, func(ctx context.Context) (err error) {
+		rows, err = stmt.QueryContext(ctx, userID, now)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", tr.tableName, "error", err)
+		return transfers, exception.ErrInternalServer
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var t store.Transfer
+		if err := scanTransfer(rows, &t); err != nil {
+			logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", tr.tableName, "error", err)
+			return transfers, exception.ErrInternalServer
+		}
+		transfers = append(transfers, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "database error", "table", tr.tableName, "error", err)
+		return transfers, exception.ErrInternalServer
+	}
+
+	return transfers, nil
+}
+
+// Cancel drops the transfer of the store, whether the owner cancels it or
+// the recipient declines it; entry tells which.
+func (tr *transferRepositoryImpl) Cancel(ctx context.Context, storeID int64, entry store.AuditEntry) error {
+	remove := fmt.Sprintf(`DELETE FROM %s WHERE storeID = ?`, tr.tableName)
+
+	return inTx(ctx, tr.db, tr.tableName, func(ctx context.Context, tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, remove, storeID)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, _ := result.RowsAffected(); rowsAffected < 1 {
+			return exception.ErrNotFound
+		}
+
+		return recordAudit(ctx, tx, tr.auditTable, entry)
+	})
+}
+
+// Accept makes the recipient the owner of the store, in one transaction
+// with using up the transfer and recording it. The owner only changes if
+// it is still the one who started the transfer. A recipient who was a
+// member of the store is one no longer; the previous owner keeps no role.
+func (tr *transferRepositoryImpl) Accept(ctx context.Context, transfer store.Transfer, entry store.AuditEntry) error {
+	remove := fmt.Sprintf(`DELETE FROM %s WHERE storeID = ? AND to_user = ? AND expires_at > ?`, tr.tableName)
+	owner := fmt.Sprintf(`UPDATE %s SET userID = ?, update_at = ?, version = version + 1 WHERE id = ? AND userID = ?`, tr.storesTable)
+	member := fmt.Sprintf(`DELETE FROM %s WHERE storeID = ? AND userID = ?`, tr.membersTable)
+
+	return inTx(ctx, tr.db, tr.tableName, func(ctx context.Context, tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, remove, transfer.StoreID, transfer.ToUserID, entry.CreatedAt)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, _ := result.RowsAffected(); rowsAffected < 1 {
+			return exception.ErrNotFound
+		}
+
+		result, err = tx.ExecContext(ctx, owner, transfer.ToUserID, entry.CreatedAt, transfer.StoreID, transfer.FromUserID)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, _ := result.RowsAffected(); rowsAffected < 1 {
+			return exception.ErrConflicted
+		}
+
+		if _, err := tx.ExecContext(ctx, member, transfer.StoreID, transfer.ToUserID); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, tr.auditTable, entry)
+	})
+}
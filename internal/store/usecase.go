@@ -2,13 +2,22 @@ package store
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
 	newJWT "github.com/dgrijalva/jwt-go"
 
 	"github.com/Risuii/config/jwt"
+	"github.com/Risuii/helpers/apikey"
+	"github.com/Risuii/helpers/cache"
+	"github.com/Risuii/helpers/etag"
 	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/media"
 	"github.com/Risuii/helpers/response"
+	"github.com/Risuii/helpers/slug"
+	"github.com/Risuii/models/account"
 	"github.com/Risuii/models/store"
 	"github.com/Risuii/models/token"
 )
@@ -16,63 +25,257 @@ import (
 type (
 	StoreUseCase interface {
 		CreateStore(ctx context.Context, userid int64, params store.Store) response.Response
-		Read(ctx context.Context, userID int64) (response.Response, token.Token)
-		UpdateStore(ctx context.Context, id int64, params store.Store) response.Response
-		DeleteStore(ctx context.Context, id int64) response.Response
+		Read(ctx context.Context, userID int64) response.Response
+		ReadBySlug(ctx context.Context, slug string) response.Response
+		Memberships(ctx context.Context, userID int64, storeID int64) (response.Response, token.Token)
+		UpdateStore(ctx context.Context, id int64, version int64, userID int64, params store.StoreUpdate) response.Response
+		SetHours(ctx context.Context, id int64, version int64, userID int64, hours store.Hours) response.Response
+		SetLogo(ctx context.Context, id int64, version int64, userID int64, data []byte) response.Response
+		SetBanner(ctx context.Context, id int64, version int64, userID int64, data []byte) response.Response
+		SetStatus(ctx context.Context, id int64, version int64, userID int64, params store.StatusChange) response.Response
+		DeleteStore(ctx context.Context, id int64, version int64, userID int64) response.Response
+		CreateAPIKey(ctx context.Context, storeID int64, userID int64, params store.APIKeyCreate) response.Response
+		ListAPIKeys(ctx context.Context, storeID int64, userID int64) response.Response
+		RevokeAPIKey(ctx context.Context, storeID int64, userID int64, id int64) response.Response
+		ListMembers(ctx context.Context, storeID int64, userID int64) response.Response
+		RemoveMember(ctx context.Context, storeID int64, userID int64, memberID int64) response.Response
+		Invite(ctx context.Context, storeID int64, userID int64, params store.InvitationCreate) response.Response
+		ListInvitations(ctx context.Context, storeID int64, userID int64) response.Response
+		CancelInvitation(ctx context.Context, storeID int64, userID int64, id int64) response.Response
+		AcceptInvitation(ctx context.Context, userID int64, params store.InvitationAnswer) response.Response
+		DeclineInvitation(ctx context.Context, params store.InvitationAnswer) response.Response
+		StartTransfer(ctx context.Context, storeID int64, userID int64, params store.TransferCreate) response.Response
+		PendingTransfer(ctx context.Context, storeID int64, userID int64) response.Response
+		CancelTransfer(ctx context.Context, storeID int64, userID int64) response.Response
+		IncomingTransfers(ctx context.Context, userID int64) response.Response
+		AcceptTransfer(ctx context.Context, storeID int64, userID int64) response.Response
+		DeclineTransfer(ctx context.Context, storeID int64, userID int64) response.Response
+		AuditLog(ctx context.Context, storeID int64, userID int64) response.Response
+		Moderate(ctx context.Context, id int64, params store.Moderation) response.Response
+		ListByStatus(ctx context.Context, status store.Status) response.Response
+		ModerationLog(ctx context.Context, id int64) response.Response
+	}
+
+	// AccountReader is the part of the account repository that stores
+	// depend on.
+	AccountReader interface {
+		FindByID(ctx context.Context, id int64) (account.Account, error)
+		FindByEmail(ctx context.Context, email string) (account.Account, error)
 	}
 
 	storeUseCaseimpl struct {
-		repository StoreRepository
+		repository  StoreRepository
+		accounts    AccountReader
+		apiKeys     APIKeyRepository
+		access      *Access
+		members     MemberRepository
+		invitations *Invitations
+		transfers   *Transfers
+		audit       AuditRepository
+		lifecycle   *Lifecycle
+		media       *media.Storage
 	}
 )
 
-func NewStoreUseCaseImpl(repo StoreRepository) StoreUseCase {
+func NewStoreUseCaseImpl(repo StoreRepository, accounts AccountReader, apiKeys APIKeyRepository, members MemberRepository, invitations *Invitations, transfers *Transfers, audit AuditRepository, lifecycle *Lifecycle, storage *media.Storage) StoreUseCase {
 	return &storeUseCaseimpl{
-		repository: repo,
+		repository:  repo,
+		accounts:    accounts,
+		apiKeys:     apiKeys,
+		access:      NewAccess(repo, members),
+		members:     members,
+		invitations: invitations,
+		transfers:   transfers,
+		audit:       audit,
+		lifecycle:   lifecycle,
+		media:       storage,
 	}
 }
 
 func (su *storeUseCaseimpl) CreateStore(ctx context.Context, userid int64, params store.Store) response.Response {
+	owner, err := su.accounts.FindByID(ctx, userid)
+	if err == exception.ErrNotFound {
+		return response.Error(response.StatusUnauthorized, exception.ErrUnauthorized)
+	}
+	if err != nil {
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
+	}
+
+	if owner.VerifiedAt == nil {
+		return response.Error(response.StatusForbiddend, exception.ErrEmailNotVerified)
+	}
 
-	_, err := su.repository.FindByName(ctx, params.NameStore)
+	_, err = su.repository.FindByName(ctx, params.NameStore)
 	if err == nil {
 		return response.Error(response.StatusConflicted, exception.ErrConflicted)
 	}
 
+	if err := su.checkMediaURLs(params, store.Store{}); err != nil {
+		return response.Fail(err)
+	}
+
+	if params.Status == "" {
+		params.Status = store.StatusActive
+	}
+
 	store := store.Store{
-		UserID:      userid,
-		NameStore:   params.NameStore,
-		Description: params.Description,
-		CreatedAt:   time.Now(),
+		UserID:       userid,
+		NameStore:    params.NameStore,
+		Description:  params.Description,
+		LogoURL:      params.LogoURL,
+		BannerURL:    params.BannerURL,
+		ContactEmail: params.ContactEmail,
+		ContactPhone: params.ContactPhone,
+		Location:     params.Location,
+		Timezone:     params.Timezone,
+		Hours:        params.Hours,
+		Status:       params.Status,
+		CreatedAt:    time.Now(),
+	}
+	if store.Timezone == "" {
+		store.Timezone = "UTC"
 	}
 
-	ID, err := su.repository.Create(ctx, store)
+	ID, err := su.create(ctx, &store)
 	if err != nil {
-		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
+		return response.Fail(err)
 	}
 
 	store.ID = ID
+	store.OpenNow = store.OpenAt(time.Now())
 
 	return response.Success(response.StatusCreated, store)
 }
 
-func (su *storeUseCaseimpl) Read(ctx context.Context, userID int64) (response.Response, token.Token) {
+// slugAttempts bounds the suffixes tried to make the slug of a store
+// unique, so that a run of look-alike names cannot loop for long.
+const slugAttempts = 20
+
+// create stores s under the slug of its name, adding -2, -3, ... to the
+// slug until it is unique.
+func (su *storeUseCaseimpl) create(ctx context.Context, s *store.Store) (int64, error) {
+	base := slug.Make(s.NameStore, "store")
+
+	for i := 1; i <= slugAttempts; i++ {
+		s.Slug = base
+		if i > 1 {
+			suffix := fmt.Sprintf("-%d", i)
+			s.Slug = strings.TrimRight(base[:min(len(base), slug.MaxLength-len(suffix))], "-") + suffix
+		}
+
+		ID, err := su.repository.Create(ctx, *s)
+		if err != exception.ErrSlugTaken {
+			return ID, err
+		}
+	}
+
+	return 0, exception.ErrSlugTaken
+}
+
+// Read lists the stores userID owns, for everyone to see. Stores that are
+// not listed, such as drafts and suspended ones, are left out.
+func (su *storeUseCaseimpl) Read(ctx context.Context, userID int64) response.Response {
 
-	store, err := su.repository.FindByUserID(ctx, userID)
+	owned, err := su.repository.FindByUserID(ctx, userID)
 
 	if err == exception.ErrNotFound {
-		return response.Error(response.StatusNotFound, exception.ErrNotFound), token.Token{}
+		return response.Error(response.StatusNotFound, exception.ErrNotFound)
 	}
 
 	if err != nil {
-		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer), token.Token{}
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
+	}
+
+	store := make([]store.Store, 0, len(owned))
+	for _, s := range owned {
+		if s.Status.Listed() {
+			store = append(store, s)
+		}
+	}
+
+	if len(store) == 0 {
+		return response.Error(response.StatusNotFound, exception.ErrNotFound)
+	}
+
+	now := time.Now()
+	versions := make([][2]int64, 0, len(store))
+	for i, s := range store {
+		store[i].OpenNow = s.OpenAt(now)
+		versions = append(versions, [2]int64{s.ID, s.Version})
+	}
+
+	return response.Success(response.StatusOK, store).WithETag(etag.List(versions...))
+}
+
+// ReadBySlug returns the store at slug, for everyone to see. Stores that
+// are not listed are not found.
+func (su *storeUseCaseimpl) ReadBySlug(ctx context.Context, slug string) response.Response {
+	data, err := su.repository.FindBySlug(ctx, slug)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	if !data.Status.Listed() {
+		return response.Error(response.StatusNotFound, exception.ErrNotFound)
+	}
+
+	data.OpenNow = data.OpenAt(time.Now())
+
+	return response.Success(response.StatusOK, data).WithETag(etag.Version(data.Version))
+}
+
+// Memberships lists the stores userID owns or works in, with its role in
+// each, and signs the Store-token for storeID, or for the first store when
+// storeID is 0. The item endpoints act for the store of that token.
+func (su *storeUseCaseimpl) Memberships(ctx context.Context, userID int64, storeID int64) (response.Response, token.Token) {
+	owned, err := su.repository.FindByUserID(ctx, userID)
+	if err != nil && err != exception.ErrNotFound {
+		return response.Fail(err), token.Token{}
+	}
+
+	joined, err := su.members.FindByUserID(ctx, userID)
+	if err != nil {
+		return response.Fail(err), token.Token{}
+	}
+
+	memberships := make([]store.Membership, 0, len(owned)+len(joined))
+	for _, s := range owned {
+		memberships = append(memberships, store.Membership{Store: s, Role: store.RoleOwner})
+	}
+	for _, m := range joined {
+		s, err := su.repository.FindByID(ctx, m.StoreID)
+		if err == exception.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return response.Fail(err), token.Token{}
+		}
+		memberships = append(memberships, store.Membership{Store: s, Role: m.Role})
+	}
+
+	if len(memberships) == 0 {
+		return response.Error(response.StatusNotFound, exception.ErrNotFound), token.Token{}
+	}
+
+	current := memberships[0].Store.ID
+	if storeID != 0 {
+		current = 0
+		for _, m := range memberships {
+			if m.Store.ID == storeID {
+				current = storeID
+			}
+		}
+		if current == 0 {
+			return response.Error(response.StatusNotFound, exception.ErrNotFound), token.Token{}
+		}
 	}
 
 	claims := &jwt.JWTclaim{
-		StoreID: store[0].ID,
+		UserID:  userID,
+		StoreID: current,
 		StandardClaims: newJWT.StandardClaims{
 			IssuedAt:  time.Now().Unix(),
-			ExpiresAt: time.Now().Add(time.Hour * 24 * 1).Unix(),
+			ExpiresAt: time.Now().Add(jwt.TokenTTL).Unix(),
 		},
 	}
 
@@ -80,6 +283,7 @@ func (su *storeUseCaseimpl) Read(ctx context.Context, userID int64) (response.Re
 
 	tokenString, err := tokenAlgo.SignedString(jwt.JWT_KEY)
 	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "sign store token", "error", err)
 		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer), token.Token{}
 	}
 
@@ -87,10 +291,23 @@ func (su *storeUseCaseimpl) Read(ctx context.Context, userID int64) (response.Re
 		Token: tokenString,
 	}
 
-	return response.Success(response.StatusOK, store), newToken
+	now := time.Now()
+	versions := make([][2]int64, 0, len(memberships))
+	for i, m := range memberships {
+		memberships[i].Store.OpenNow = m.Store.OpenAt(now)
+		versions = append(versions, [2]int64{m.Store.ID, m.Store.Version})
+	}
+
+	return response.Success(response.StatusOK, memberships).WithETag(etag.List(versions...)), newToken
 }
 
-func (su *storeUseCaseimpl) UpdateStore(ctx context.Context, id int64, params store.Store) response.Response {
+func (su *storeUseCaseimpl) UpdateStore(ctx context.Context, id int64, version int64, userID int64, params store.StoreUpdate) response.Response {
+	ctx = cache.Fresh(ctx)
+
+	if _, err := su.access.Authorize(ctx, id, userID, store.PermissionStoreEdit); err != nil {
+		return response.Fail(err)
+	}
+
 	stores, err := su.repository.FindByID(ctx, id)
 
 	if err == exception.ErrNotFound {
@@ -101,23 +318,186 @@ func (su *storeUseCaseimpl) UpdateStore(ctx context.Context, id int64, params st
 		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
 	}
 
-	stores = store.Store{
-		UserID:      params.UserID,
-		NameStore:   params.NameStore,
-		Description: params.Description,
-		UpdateAt:    time.Now(),
+	if stores.Version != version {
+		return response.Error(response.StatusPreconditionFailed, exception.ErrPreconditionFailed)
 	}
 
+	previous := stores
+	params.Apply(&stores)
+	if err := su.checkMediaURLs(stores, previous); err != nil {
+		return response.Fail(err)
+	}
+	stores.UpdateAt = time.Now()
+
 	err = su.repository.Update(ctx, id, stores)
+	if err == exception.ErrPreconditionFailed {
+		return response.Error(response.StatusPreconditionFailed, exception.ErrPreconditionFailed)
+	}
+
 	if err != nil {
 		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
 	}
 
-	return response.Success(response.StatusOK, stores)
+	stores.Version++
+	stores.OpenNow = stores.OpenAt(time.Now())
+
+	return response.Success(response.StatusOK, stores).WithETag(etag.Version(stores.Version))
+}
+
+// SetHours replaces the business hours of the store, holidays included.
+func (su *storeUseCaseimpl) SetHours(ctx context.Context, id int64, version int64, userID int64, hours store.Hours) response.Response {
+	ctx = cache.Fresh(ctx)
+
+	if _, err := su.access.Authorize(ctx, id, userID, store.PermissionStoreEdit); err != nil {
+		return response.Fail(err)
+	}
+
+	data, err := su.repository.FindByID(ctx, id)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	if data.Version != version {
+		return response.Error(response.StatusPreconditionFailed, exception.ErrPreconditionFailed)
+	}
+
+	data.Hours = hours
+	data.UpdateAt = time.Now()
+
+	if err := su.repository.Update(ctx, id, data); err != nil {
+		return response.Fail(err)
+	}
+
+	data.Version++
+	data.OpenNow = data.OpenAt(time.Now())
+
+	return response.Success(response.StatusOK, data).WithETag(etag.Version(data.Version))
+}
+
+// SetLogo replaces the logo of the store with the image in data.
+func (su *storeUseCaseimpl) SetLogo(ctx context.Context, id int64, version int64, userID int64, data []byte) response.Response {
+	return su.setImage(ctx, id, version, userID, data, func(s *store.Store) *string { return &s.LogoURL })
+}
+
+// SetBanner replaces the banner of the store with the image in data.
+func (su *storeUseCaseimpl) SetBanner(ctx context.Context, id int64, version int64, userID int64, data []byte) response.Response {
+	return su.setImage(ctx, id, version, userID, data, func(s *store.Store) *string { return &s.BannerURL })
+}
+
+// setImage stores data and points the URL that field returns at it. The
+// previous file is deleted once the store no longer points at it, when it
+// is an upload of this store.
+func (su *storeUseCaseimpl) setImage(ctx context.Context, id int64, version int64, userID int64, data []byte, field func(s *store.Store) *string) response.Response {
+	ctx = cache.Fresh(ctx)
+
+	if _, err := su.access.Authorize(ctx, id, userID, store.PermissionStoreEdit); err != nil {
+		return response.Fail(err)
+	}
+
+	stores, err := su.repository.FindByID(ctx, id)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	if stores.Version != version {
+		return response.Error(response.StatusPreconditionFailed, exception.ErrPreconditionFailed)
+	}
+
+	saved, err := su.media.Save(ctx, mediaDir(id), data, false)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	previous := *field(&stores)
+	*field(&stores) = su.media.URL(saved.Key)
+	stores.UpdateAt = time.Now()
+
+	if err := su.repository.Update(ctx, id, stores); err != nil {
+		su.media.Delete(ctx, saved.Key)
+		return response.Fail(err)
+	}
+
+	su.media.DeleteURL(ctx, mediaDir(id), previous)
+
+	stores.Version++
+	stores.OpenNow = stores.OpenAt(time.Now())
+
+	return response.Success(response.StatusOK, stores).WithETag(etag.Version(stores.Version))
+}
+
+// mediaDir is where the uploads of store id are kept.
+func mediaDir(id int64) string {
+	return fmt.Sprintf("stores/%d", id)
 }
 
-func (su *storeUseCaseimpl) DeleteStore(ctx context.Context, id int64) response.Response {
-	_, err := su.repository.FindByID(ctx, id)
+// checkMediaURLs rejects logo and banner URLs that point into the media
+// store unless they are unchanged from previous: those are only set by
+// uploads, which keep them under the directory of the store.
+func (su *storeUseCaseimpl) checkMediaURLs(s store.Store, previous store.Store) error {
+	var fields []exception.FieldError
+	for _, f := range []struct{ name, url, previous string }{
+		{"logo_url", s.LogoURL, previous.LogoURL},
+		{"banner_url", s.BannerURL, previous.BannerURL},
+	} {
+		if f.url != f.previous && su.media.Owns(f.url) {
+			fields = append(fields, exception.FieldError{
+				Field:   f.name,
+				Tag:     "uploaded",
+				Message: f.name + " must be uploaded, not set to a URL of an upload",
+			})
+		}
+	}
+
+	if len(fields) > 0 {
+		return exception.ErrValidation.WithFields(fields...)
+	}
+
+	return nil
+}
+
+// SetStatus moves the store to another state. Closing it is left to the
+// owner.
+func (su *storeUseCaseimpl) SetStatus(ctx context.Context, id int64, version int64, userID int64, params store.StatusChange) response.Response {
+	ctx = cache.Fresh(ctx)
+
+	permission := store.PermissionStoreEdit
+	if params.Status == store.StatusClosed {
+		permission = store.PermissionStoreDelete
+	}
+
+	if _, err := su.access.Authorize(ctx, id, userID, permission); err != nil {
+		return response.Fail(err)
+	}
+
+	data, err := su.repository.FindByID(ctx, id)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	if data.Version != version {
+		return response.Error(response.StatusPreconditionFailed, exception.ErrPreconditionFailed)
+	}
+
+	data, err = su.lifecycle.Move(ctx, data, userID, params.Status)
+	su.repository.Forget(ctx, id, data.UserID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	data.OpenNow = data.OpenAt(time.Now())
+
+	return response.Success(response.StatusOK, data).WithETag(etag.Version(data.Version))
+}
+
+// DeleteStore is left to the owner.
+func (su *storeUseCaseimpl) DeleteStore(ctx context.Context, id int64, version int64, userID int64) response.Response {
+	ctx = cache.Fresh(ctx)
+
+	if _, err := su.access.Authorize(ctx, id, userID, store.PermissionStoreDelete); err != nil {
+		return response.Fail(err)
+	}
+
+	stores, err := su.repository.FindByID(ctx, id)
 	if err == exception.ErrNotFound {
 		return response.Error(response.StatusNotFound, exception.ErrNotFound)
 	}
@@ -126,12 +506,535 @@ func (su *storeUseCaseimpl) DeleteStore(ctx context.Context, id int64) response.
 		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
 	}
 
-	err = su.repository.Delete(ctx, id)
+	if stores.Version != version {
+		return response.Error(response.StatusPreconditionFailed, exception.ErrPreconditionFailed)
+	}
+
+	err = su.repository.Delete(ctx, id, version)
+	if err == exception.ErrPreconditionFailed {
+		return response.Error(response.StatusPreconditionFailed, exception.ErrPreconditionFailed)
+	}
+
 	if err != nil {
 		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
 	}
 
+	su.media.DeleteURL(ctx, mediaDir(id), stores.LogoURL)
+	su.media.DeleteURL(ctx, mediaDir(id), stores.BannerURL)
+
 	msg := "Success Delete Store"
 
 	return response.Success(response.StatusOK, msg)
 }
+
+func (su *storeUseCaseimpl) CreateAPIKey(ctx context.Context, storeID int64, userID int64, params store.APIKeyCreate) response.Response {
+	ctx = cache.Fresh(ctx)
+
+	if _, err := su.access.Authorize(ctx, storeID, userID, store.PermissionAPIKeysManage); err != nil {
+		return response.Fail(err)
+	}
+
+	now := time.Now()
+	if params.ExpiresAt != nil && !params.ExpiresAt.After(now) {
+		return response.Fail(exception.ErrValidation.WithFields(exception.FieldError{
+			Field:   "expires_at",
+			Tag:     "future",
+			Message: "expires_at must be in the future",
+		}))
+	}
+
+	key, hint, hash, err := apikey.Generate()
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "generate api key", "error", err)
+		return response.Error(response.StatusInternalServerError, exception.ErrInternalServer)
+	}
+
+	// Keep the order of apikey.Scopes and drop duplicates.
+	var scopes []string
+	for _, scope := range apikey.Scopes {
+		for _, requested := range params.Scopes {
+			if requested == scope {
+				scopes = append(scopes, scope)
+				break
+			}
+		}
+	}
+
+	created := store.NewAPIKey{
+		APIKey: store.APIKey{
+			StoreID:   storeID,
+			Name:      params.Name,
+			Hint:      hint,
+			KeyHash:   hash,
+			Scopes:    scopes,
+			ExpiresAt: params.ExpiresAt,
+			CreatedAt: now,
+		},
+		Key: key,
+	}
+
+	created.ID, err = su.apiKeys.Create(ctx, created.APIKey)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusCreated, created)
+}
+
+func (su *storeUseCaseimpl) ListAPIKeys(ctx context.Context, storeID int64, userID int64) response.Response {
+	if _, err := su.access.Authorize(ctx, storeID, userID, store.PermissionAPIKeysManage); err != nil {
+		return response.Fail(err)
+	}
+
+	keys, err := su.apiKeys.FindByStoreID(ctx, storeID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusOK, keys)
+}
+
+// RevokeAPIKey deletes the key; requests made with it fail from then on.
+func (su *storeUseCaseimpl) RevokeAPIKey(ctx context.Context, storeID int64, userID int64, id int64) response.Response {
+	ctx = cache.Fresh(ctx)
+
+	if _, err := su.access.Authorize(ctx, storeID, userID, store.PermissionAPIKeysManage); err != nil {
+		return response.Fail(err)
+	}
+
+	if err := su.apiKeys.Delete(ctx, storeID, id); err != nil {
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusOK, "Success Revoke API Key")
+}
+
+// ListMembers lists the owner first, then the managers and staff. Every
+// member can see who else works in the store.
+func (su *storeUseCaseimpl) ListMembers(ctx context.Context, storeID int64, userID int64) response.Response {
+	stores, _, err := su.access.Role(ctx, storeID, userID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	owner, err := su.accounts.FindByID(ctx, stores.UserID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	members, err := su.members.FindByStoreID(ctx, storeID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	data := append([]store.Member{{
+		StoreID:   storeID,
+		UserID:    owner.ID,
+		Name:      owner.Name,
+		Email:     owner.Email,
+		Role:      store.RoleOwner,
+		CreatedAt: stores.CreatedAt,
+	}}, members...)
+
+	return response.Success(response.StatusOK, data)
+}
+
+// RemoveMember takes memberID out of the store. Members may leave on their
+// own; removing others needs a role that manages theirs. The owner cannot
+// be removed, only replaced by transferring the store.
+func (su *storeUseCaseimpl) RemoveMember(ctx context.Context, storeID int64, userID int64, memberID int64) response.Response {
+	ctx = cache.Fresh(ctx)
+
+	stores, role, err := su.access.Role(ctx, storeID, userID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	if memberID == stores.UserID {
+		return response.Fail(exception.ErrForbidden)
+	}
+
+	if memberID != userID {
+		if !role.Can(store.PermissionMembersManage) {
+			return response.Fail(exception.ErrForbidden)
+		}
+
+		member, err := su.members.Find(ctx, storeID, memberID)
+		if err != nil {
+			return response.Fail(err)
+		}
+
+		if !role.Manages(member.Role) {
+			return response.Fail(exception.ErrForbidden)
+		}
+	}
+
+	if err := su.members.Delete(ctx, storeID, memberID); err != nil {
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusOK, "Success Remove Member")
+}
+
+// Invite mails an invitation to join the store. Owners invite managers and
+// staff, managers invite staff.
+func (su *storeUseCaseimpl) Invite(ctx context.Context, storeID int64, userID int64, params store.InvitationCreate) response.Response {
+	ctx = cache.Fresh(ctx)
+
+	role, err := su.access.Authorize(ctx, storeID, userID, store.PermissionMembersManage)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	if !role.Manages(params.Role) {
+		return response.Fail(exception.ErrForbidden)
+	}
+
+	email := strings.ToLower(strings.TrimSpace(params.Email))
+
+	invitee, err := su.accounts.FindByEmail(ctx, email)
+	if err != nil && err != exception.ErrNotFound {
+		return response.Fail(err)
+	}
+	if err == nil {
+		if _, _, err := su.access.Role(ctx, storeID, invitee.ID); err == nil {
+			return response.Fail(exception.ErrAlreadyMember)
+		} else if err != exception.ErrNotFound {
+			return response.Fail(err)
+		}
+	}
+
+	stores, err := su.repository.FindByID(ctx, storeID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	inviter, err := su.accounts.FindByID(ctx, userID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	invitation, err := su.invitations.Issue(ctx, store.Invitation{
+		StoreID:   storeID,
+		Email:     email,
+		Role:      params.Role,
+		InvitedBy: userID,
+	}, inviter.Name, stores.NameStore)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusCreated, invitation)
+}
+
+func (su *storeUseCaseimpl) ListInvitations(ctx context.Context, storeID int64, userID int64) response.Response {
+	if _, err := su.access.Authorize(ctx, storeID, userID, store.PermissionMembersManage); err != nil {
+		return response.Fail(err)
+	}
+
+	invitations, err := su.invitations.Pending(ctx, storeID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusOK, invitations)
+}
+
+// CancelInvitation revokes an invitation before it is answered.
+func (su *storeUseCaseimpl) CancelInvitation(ctx context.Context, storeID int64, userID int64, id int64) response.Response {
+	ctx = cache.Fresh(ctx)
+
+	if _, err := su.access.Authorize(ctx, storeID, userID, store.PermissionMembersManage); err != nil {
+		return response.Fail(err)
+	}
+
+	if err := su.invitations.Revoke(ctx, storeID, id); err != nil {
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusOK, "Success Cancel Invitation")
+}
+
+// AcceptInvitation makes userID a member of the store it was invited to.
+// The invitation must have been sent to the verified email address of the
+// account, so a forwarded link cannot be used by someone else.
+func (su *storeUseCaseimpl) AcceptInvitation(ctx context.Context, userID int64, params store.InvitationAnswer) response.Response {
+	ctx = cache.Fresh(ctx)
+
+	invitation, err := su.invitations.Find(ctx, params.Token)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	user, err := su.accounts.FindByID(ctx, userID)
+	if err == exception.ErrNotFound {
+		return response.Error(response.StatusUnauthorized, exception.ErrUnauthorized)
+	}
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		return response.Fail(exception.ErrInvitationEmail)
+	}
+
+	if user.VerifiedAt == nil {
+		return response.Fail(exception.ErrEmailNotVerified)
+	}
+
+	stores, err := su.repository.FindByID(ctx, invitation.StoreID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	if stores.UserID == userID {
+		return response.Fail(exception.ErrAlreadyMember)
+	}
+
+	if err := su.invitations.Accept(ctx, invitation, userID); err != nil {
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusOK, store.Membership{Store: stores, Role: invitation.Role})
+}
+
+// DeclineInvitation revokes the invitation. Holding the token is enough,
+// so invitations sent to someone without an account can be declined too.
+func (su *storeUseCaseimpl) DeclineInvitation(ctx context.Context, params store.InvitationAnswer) response.Response {
+	invitation, err := su.invitations.Find(ctx, params.Token)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	if err := su.invitations.Revoke(ctx, invitation.StoreID, invitation.ID); err != nil {
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusOK, "Success Decline Invitation")
+}
+
+// StartTransfer offers the store to the account with the email address of
+// params. Only the owner can give the store away.
+func (su *storeUseCaseimpl) StartTransfer(ctx context.Context, storeID int64, userID int64, params store.TransferCreate) response.Response {
+	ctx = cache.Fresh(ctx)
+
+	if _, err := su.access.Authorize(ctx, storeID, userID, store.PermissionStoreTransfer); err != nil {
+		return response.Fail(err)
+	}
+
+	to, err := su.accounts.FindByEmail(ctx, strings.TrimSpace(params.Email))
+	if err != nil && err != exception.ErrNotFound {
+		return response.Fail(err)
+	}
+	if err == exception.ErrNotFound || to.VerifiedAt == nil || to.ID == userID {
+		return response.Fail(exception.ErrTransferRecipient)
+	}
+
+	from, err := su.accounts.FindByID(ctx, userID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	stores, err := su.repository.FindByID(ctx, storeID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	transfer, err := su.transfers.Start(ctx, stores, from, to)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusCreated, transfer)
+}
+
+func (su *storeUseCaseimpl) PendingTransfer(ctx context.Context, storeID int64, userID int64) response.Response {
+	if _, err := su.access.Authorize(ctx, storeID, userID, store.PermissionStoreTransfer); err != nil {
+		return response.Fail(err)
+	}
+
+	transfer, err := su.transfers.Pending(ctx, storeID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusOK, transfer)
+}
+
+// transferParties returns the store of a pending transfer and the accounts
+// on both sides of it.
+func (su *storeUseCaseimpl) transferParties(ctx context.Context, transfer store.Transfer) (store.Store, account.Account, account.Account, error) {
+	stores, err := su.repository.FindByID(ctx, transfer.StoreID)
+	if err != nil {
+		return stores, account.Account{}, account.Account{}, err
+	}
+
+	from, err := su.accounts.FindByID(ctx, transfer.FromUserID)
+	if err != nil {
+		return stores, from, account.Account{}, err
+	}
+
+	to, err := su.accounts.FindByID(ctx, transfer.ToUserID)
+
+	return stores, from, to, err
+}
+
+func (su *storeUseCaseimpl) CancelTransfer(ctx context.Context, storeID int64, userID int64) response.Response {
+	ctx = cache.Fresh(ctx)
+
+	if _, err := su.access.Authorize(ctx, storeID, userID, store.PermissionStoreTransfer); err != nil {
+		return response.Fail(err)
+	}
+
+	transfer, err := su.transfers.Pending(ctx, storeID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	stores, from, to, err := su.transferParties(ctx, transfer)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	if err := su.transfers.Cancel(ctx, stores, from, to); err != nil {
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusOK, "Success Cancel Transfer")
+}
+
+// IncomingTransfers lists the stores offered to userID.
+func (su *storeUseCaseimpl) IncomingTransfers(ctx context.Context, userID int64) response.Response {
+	transfers, err := su.transfers.Incoming(ctx, userID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusOK, transfers)
+}
+
+// incoming returns the pending transfer of the store to userID. Transfers
+// to others are reported as not found.
+func (su *storeUseCaseimpl) incoming(ctx context.Context, storeID int64, userID int64) (store.Transfer, error) {
+	transfer, err := su.transfers.Pending(ctx, storeID)
+	if err != nil {
+		return transfer, err
+	}
+
+	if transfer.ToUserID != userID {
+		return transfer, exception.ErrNotFound
+	}
+
+	return transfer, nil
+}
+
+// AcceptTransfer makes userID the owner of the store. The previous owner
+// loses access right away.
+func (su *storeUseCaseimpl) AcceptTransfer(ctx context.Context, storeID int64, userID int64) response.Response {
+	ctx = cache.Fresh(ctx)
+
+	transfer, err := su.incoming(ctx, storeID, userID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	stores, from, to, err := su.transferParties(ctx, transfer)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	err = su.transfers.Accept(ctx, stores, transfer, from, to)
+	su.repository.Forget(ctx, storeID, from.ID, to.ID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	stores, err = su.repository.FindByID(ctx, storeID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusOK, store.Membership{Store: stores, Role: store.RoleOwner}).WithETag(etag.Version(stores.Version))
+}
+
+func (su *storeUseCaseimpl) DeclineTransfer(ctx context.Context, storeID int64, userID int64) response.Response {
+	ctx = cache.Fresh(ctx)
+
+	transfer, err := su.incoming(ctx, storeID, userID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	stores, from, to, err := su.transferParties(ctx, transfer)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	if err := su.transfers.Decline(ctx, stores, from, to); err != nil {
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusOK, "Success Decline Transfer")
+}
+
+// AuditLog returns the audit trail of the store, newest first.
+func (su *storeUseCaseimpl) AuditLog(ctx context.Context, storeID int64, userID int64) response.Response {
+	if _, err := su.access.Authorize(ctx, storeID, userID, store.PermissionAuditRead); err != nil {
+		return response.Fail(err)
+	}
+
+	entries, err := su.audit.FindByStoreID(ctx, storeID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusOK, entries)
+}
+
+// Moderate applies the action of an admin to the store and mails its
+// owner about it.
+func (su *storeUseCaseimpl) Moderate(ctx context.Context, id int64, params store.Moderation) response.Response {
+	ctx = cache.Fresh(ctx)
+
+	data, err := su.repository.FindByID(ctx, id)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	owner, err := su.accounts.FindByID(ctx, data.UserID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	data, err = su.lifecycle.Moderate(ctx, data, owner, params)
+	su.repository.Forget(ctx, id, data.UserID)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusOK, data).WithETag(etag.Version(data.Version))
+}
+
+// ListByStatus lists the stores in status, for admins.
+func (su *storeUseCaseimpl) ListByStatus(ctx context.Context, status store.Status) response.Response {
+	data, err := su.lifecycle.FindByStatus(ctx, status)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusOK, data)
+}
+
+// ModerationLog is the audit trail of the store, for admins.
+func (su *storeUseCaseimpl) ModerationLog(ctx context.Context, id int64) response.Response {
+	if _, err := su.repository.FindByID(ctx, id); err != nil {
+		return response.Fail(err)
+	}
+
+	entries, err := su.audit.FindByStoreID(ctx, id)
+	if err != nil {
+		return response.Fail(err)
+	}
+
+	return response.Success(response.StatusOK, entries)
+}
@@ -7,7 +7,20 @@ type Account struct {
 	Name      string    `json:"name" validate:"required"`
 	Password  string    `json:"password" validate:"required"`
 	Email     string    `json:"email" validate:"email"`
-	Address   string    `json:"address" validate:"required"`
+	Address   string    `json:"address"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdateAt  time.Time `json:"update_at"`
+	Version   int64     `json:"version"`
+	// VerifiedAt is nil until the email address is confirmed.
+	VerifiedAt *time.Time `json:"verified_at"`
+	// SessionEpoch is raised to log out every session of the account.
+	SessionEpoch int64 `json:"-"`
+	// MFAEnabledAt is set once two-factor authentication is confirmed.
+	MFAEnabledAt *time.Time `json:"mfa_enabled_at"`
+	// TOTPSecret is sealed, see account.MFA. It is set from the start of
+	// the enrolment.
+	TOTPSecret *string `json:"-"`
+	// TOTPCounter is the last time step accepted, codes of that step or
+	// earlier are rejected as replays.
+	TOTPCounter int64 `json:"-"`
 }
@@ -0,0 +1,10 @@
+package account
+
+import "github.com/Risuii/helpers/patch"
+
+// AccountUpdate edits the profile. The password and the email have their
+// own flows, see ChangePassword and ChangeEmail.
+type AccountUpdate struct {
+	Name    patch.String `json:"name" validate:"omitempty,min=1"`
+	Address patch.String `json:"address" validate:"omitempty,min=1"`
+}
@@ -0,0 +1,15 @@
+package account
+
+// ChangePassword needs the current password so that a stolen session alone
+// cannot take the account over.
+type ChangePassword struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+// ChangeEmail starts moving the account to Email, which only happens once
+// the link mailed there is opened.
+type ChangeEmail struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
@@ -0,0 +1,13 @@
+package account
+
+import "time"
+
+// Identity links an account to its subject at an OpenID Connect provider.
+type Identity struct {
+	ID        int64     `json:"-"`
+	UserID    int64     `json:"-"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"-"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,50 @@
+package account
+
+import "time"
+
+// RecoveryCode is a single-use code as stored, only its SHA-256 is kept.
+type RecoveryCode struct {
+	ID        int64
+	UserID    int64
+	CodeHash  string
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// MFAEnrolment is shown once, when the enrolment starts. The secret is
+// for apps that cannot scan the URI.
+type MFAEnrolment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// MFAChallenge answers a login with a correct password on an account with
+// two-factor authentication. The token is exchanged for a session at
+// /login/mfa together with a code.
+type MFAChallenge struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+// MFACode carries a code of the authenticator app.
+type MFACode struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// MFALogin completes a login; Code may also be a recovery code.
+type MFALogin struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+// MFADisable needs the password and a code, a recovery code will do when
+// the device is lost.
+type MFADisable struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
@@ -0,0 +1,23 @@
+package account
+
+import "time"
+
+// PasswordReset is a reset token as stored; only the SHA-256 of the token
+// is kept so that a database leak does not expose usable tokens.
+type PasswordReset struct {
+	ID        int64
+	UserID    int64
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+type ForgotPassword struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPassword struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
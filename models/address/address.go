@@ -0,0 +1,22 @@
+package address
+
+import "time"
+
+// Address is an entry of the address book of an account. Default marks
+// the one checkout uses when no address is picked.
+type Address struct {
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"userID"`
+	Label      string    `json:"label" validate:"required,max=64"`
+	Recipient  string    `json:"recipient" validate:"required,max=128"`
+	Phone      string    `json:"phone" validate:"required,max=32"`
+	Street     string    `json:"street" validate:"required,max=255"`
+	City       string    `json:"city" validate:"required,max=128"`
+	Province   string    `json:"province" validate:"omitempty,max=128"`
+	PostalCode string    `json:"postal_code" validate:"required,max=16"`
+	Country    string    `json:"country" validate:"required,iso3166_1_alpha2"`
+	Default    bool      `json:"default"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdateAt   time.Time `json:"update_at"`
+	Version    int64     `json:"version"`
+}
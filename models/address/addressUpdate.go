@@ -0,0 +1,16 @@
+package address
+
+import "github.com/Risuii/helpers/patch"
+
+// AddressUpdate edits an address. The default address is changed with its
+// own endpoint, so that exactly one address stays the default.
+type AddressUpdate struct {
+	Label      patch.String `json:"label" validate:"omitempty,min=1,max=64"`
+	Recipient  patch.String `json:"recipient" validate:"omitempty,min=1,max=128"`
+	Phone      patch.String `json:"phone" validate:"omitempty,min=1,max=32"`
+	Street     patch.String `json:"street" validate:"omitempty,min=1,max=255"`
+	City       patch.String `json:"city" validate:"omitempty,min=1,max=128"`
+	Province   patch.String `json:"province" validate:"omitempty,max=128" patch:"nullable"`
+	PostalCode patch.String `json:"postal_code" validate:"omitempty,min=1,max=16"`
+	Country    patch.String `json:"country" validate:"omitempty,iso3166_1_alpha2"`
+}
@@ -0,0 +1,26 @@
+package item
+
+import "time"
+
+// Image is a picture of an item. The images of an item form a gallery
+// ordered by Position, from 0; the first one is its cover.
+type Image struct {
+	ID           int64     `json:"id"`
+	ItemID       int64     `json:"itemID"`
+	Position     int       `json:"position"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	ContentType  string    `json:"content_type"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	Size         int64     `json:"size"`
+	Key          string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ImageOrder is the new order of a gallery. It lists the ID of every image
+// of the item once.
+type ImageOrder struct {
+	IDs []int64 `json:"ids" validate:"required,min=1,unique"`
+}
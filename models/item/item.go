@@ -10,4 +10,5 @@ type Item struct {
 	Quantity    int64     `json:"quantity" validate:"required"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdateAt    time.Time `json:"update_at"`
+	Version     int64     `json:"version"`
 }
@@ -0,0 +1,9 @@
+package item
+
+import "github.com/Risuii/helpers/patch"
+
+type ItemUpdate struct {
+	Name        patch.String `json:"name" validate:"omitempty,min=1"`
+	Description patch.String `json:"description" patch:"nullable"`
+	Quantity    patch.Int64  `json:"quantity" validate:"omitempty,gte=0"`
+}
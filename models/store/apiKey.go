@@ -0,0 +1,31 @@
+package store
+
+import "time"
+
+// APIKey lets an integration call the store's endpoints without a login.
+// Only the SHA-256 of the key is stored; Hint is its first characters.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	StoreID    int64      `json:"storeID"`
+	Name       string     `json:"name"`
+	Hint       string     `json:"hint"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// APIKeyCreate asks for a new key. Without ExpiresAt the key works until it
+// is revoked.
+type APIKeyCreate struct {
+	Name      string     `json:"name" validate:"required,max=64"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=items:read items:write orders:read"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// NewAPIKey is returned once, when the key is created.
+type NewAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,28 @@
+package store
+
+import "time"
+
+// The actions recorded in the audit trail of a store.
+const (
+	AuditTransferStarted   = "transfer.started"
+	AuditTransferCancelled = "transfer.cancelled"
+	AuditTransferDeclined  = "transfer.declined"
+	AuditTransferAccepted  = "transfer.accepted"
+	AuditStatusChanged     = "status.changed"
+	// Moderation entries are made by admins of the platform, whose actor
+	// ID is 0.
+	AuditStoreSuspended  = "moderation.suspended"
+	AuditStoreReinstated = "moderation.reinstated"
+	AuditStoreClosed     = "moderation.closed"
+)
+
+// AuditEntry records who did what to a store. Entries outlive the store.
+type AuditEntry struct {
+	ID      int64  `json:"id"`
+	StoreID int64  `json:"storeID"`
+	ActorID int64  `json:"actor_id"`
+	Action  string `json:"action"`
+	// Details depend on the action, e.g. the accounts of a transfer.
+	Details   map[string]interface{} `json:"details,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
@@ -0,0 +1,92 @@
+package store
+
+import "time"
+
+// Hours are the business hours of a store: the same every week, except on
+// holidays.
+type Hours struct {
+	Weekly   []Period  `json:"weekly" validate:"max=28,dive"`
+	Holidays []Holiday `json:"holidays" validate:"max=366,dive"`
+}
+
+// Period is a span of opening time on a day, written as 15:04. A period
+// that closes at or before it opens runs past midnight; 00:00 to 00:00 is
+// the whole day. A day may have several periods, e.g. around lunch.
+type Period struct {
+	Day    string `json:"day" validate:"required,oneof=sunday monday tuesday wednesday thursday friday saturday"`
+	Opens  string `json:"opens" validate:"required,datetime=15:04"`
+	Closes string `json:"closes" validate:"required,datetime=15:04"`
+}
+
+// Holiday replaces the weekly hours on its date: the store is either
+// closed all day or open from Opens to Closes.
+type Holiday struct {
+	Date   string `json:"date" validate:"required,datetime=2006-01-02"`
+	Name   string `json:"name,omitempty" validate:"max=64"`
+	Closed bool   `json:"closed"`
+	Opens  string `json:"opens,omitempty" validate:"required_unless=Closed true,omitempty,datetime=15:04"`
+	Closes string `json:"closes,omitempty" validate:"required_unless=Closed true,omitempty,datetime=15:04"`
+}
+
+var weekdays = [...]string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+// span is a period as minutes since the midnight of its day; end is past
+// 24*60 for periods that run past midnight.
+type span struct{ start, end int }
+
+func minutes(clock string) int {
+	t, _ := time.Parse("15:04", clock)
+	return t.Hour()*60 + t.Minute()
+}
+
+func newSpan(opens, closes string) span {
+	sp := span{start: minutes(opens), end: minutes(closes)}
+	if sp.end <= sp.start {
+		sp.end += 24 * 60
+	}
+
+	return sp
+}
+
+// spans returns the periods of the date of day, holidays first.
+func (h Hours) spans(day time.Time) []span {
+	date := day.Format("2006-01-02")
+	for _, holiday := range h.Holidays {
+		if holiday.Date != date {
+			continue
+		}
+		if holiday.Closed {
+			return nil
+		}
+		return []span{newSpan(holiday.Opens, holiday.Closes)}
+	}
+
+	var spans []span
+	for _, p := range h.Weekly {
+		if p.Day == weekdays[day.Weekday()] {
+			spans = append(spans, newSpan(p.Opens, p.Closes))
+		}
+	}
+
+	return spans
+}
+
+// OpenAt reports whether t falls in a period of its own day, or in one of
+// the day before that runs past midnight. t is read in its own location.
+func (h Hours) OpenAt(t time.Time) bool {
+	now := t.Hour()*60 + t.Minute()
+
+	for _, sp := range h.spans(t) {
+		if now >= sp.start && now < sp.end {
+			return true
+		}
+	}
+
+	for _, sp := range h.spans(t.AddDate(0, 0, -1)) {
+		if now+24*60 < sp.end {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,106 @@
+package store
+
+import "time"
+
+// Role is what a member may do in a store. The owner is the account in
+// Store.UserID; managers and staff are members.
+type Role string
+
+const (
+	RoleOwner   Role = "owner"
+	RoleManager Role = "manager"
+	RoleStaff   Role = "staff"
+)
+
+// Permission is an action on a store that depends on the role.
+type Permission string
+
+const (
+	PermissionItemsRead     Permission = "items:read"
+	PermissionItemsWrite    Permission = "items:write"
+	PermissionStoreEdit     Permission = "store:edit"
+	PermissionStoreDelete   Permission = "store:delete"
+	PermissionMembersManage Permission = "members:manage"
+	PermissionAPIKeysManage Permission = "api_keys:manage"
+	PermissionStoreTransfer Permission = "store:transfer"
+	PermissionAuditRead     Permission = "audit:read"
+)
+
+var permissions = map[Role][]Permission{
+	RoleOwner: {
+		PermissionItemsRead, PermissionItemsWrite, PermissionStoreEdit, PermissionStoreDelete,
+		PermissionMembersManage, PermissionAPIKeysManage, PermissionStoreTransfer, PermissionAuditRead,
+	},
+	RoleManager: {
+		PermissionItemsRead, PermissionItemsWrite, PermissionStoreEdit,
+		PermissionMembersManage, PermissionAPIKeysManage, PermissionAuditRead,
+	},
+	RoleStaff: {
+		PermissionItemsRead, PermissionItemsWrite,
+	},
+}
+
+func (r Role) Can(p Permission) bool {
+	for _, granted := range permissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Manages reports whether r may invite and remove members of role other:
+// the owner manages managers and staff, managers manage staff.
+func (r Role) Manages(other Role) bool {
+	switch r {
+	case RoleOwner:
+		return other == RoleManager || other == RoleStaff
+	case RoleManager:
+		return other == RoleStaff
+	}
+
+	return false
+}
+
+// Member is a manager or staff member of a store. Name and Email are those
+// of the account.
+type Member struct {
+	ID        int64     `json:"-"`
+	StoreID   int64     `json:"storeID"`
+	UserID    int64     `json:"userID"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Membership is a store an account works in, with its role there.
+type Membership struct {
+	Store Store `json:"store"`
+	Role  Role  `json:"role"`
+}
+
+// Invitation asks the owner of Email to join a store. Only the SHA-256 of
+// its token is stored; the token is mailed.
+type Invitation struct {
+	ID        int64     `json:"id"`
+	StoreID   int64     `json:"storeID"`
+	Email     string    `json:"email"`
+	Role      Role      `json:"role"`
+	InvitedBy int64     `json:"invited_by"`
+	TokenHash string    `json:"-"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type InvitationCreate struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  Role   `json:"role" validate:"required,oneof=manager staff"`
+}
+
+// InvitationAnswer accepts or declines the invitation a token was mailed
+// for.
+type InvitationAnswer struct {
+	Token string `json:"token" validate:"required"`
+}
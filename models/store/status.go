@@ -0,0 +1,135 @@
+package store
+
+import "time"
+
+// Status is where a store is in its life. Owners move their store between
+// draft, active, vacation and closed; suspending is left to the admins of
+// the platform.
+type Status string
+
+const (
+	// StatusDraft is a store that is being set up and is not public yet.
+	StatusDraft  Status = "draft"
+	StatusActive Status = "active"
+	// StatusVacation is a store that is closed for a while. It stays in
+	// the catalog, but nothing can be bought from it.
+	StatusVacation  Status = "vacation"
+	StatusSuspended Status = "suspended"
+	StatusClosed    Status = "closed"
+)
+
+// ownerMoves lists the states owners can move their store to from each
+// state. Suspended and closed stores are out of their hands.
+var ownerMoves = map[Status][]Status{
+	StatusDraft:    {StatusActive, StatusClosed},
+	StatusActive:   {StatusVacation, StatusClosed},
+	StatusVacation: {StatusActive, StatusClosed},
+}
+
+// OwnerCanMove reports whether the owner may move a store from s to to.
+func (s Status) OwnerCanMove(to Status) bool {
+	for _, allowed := range ownerMoves[s.orActive()] {
+		if allowed == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Listed reports whether stores in s show up in the public catalog.
+func (s Status) Listed() bool {
+	return s.orActive() == StatusActive || s == StatusVacation
+}
+
+// Selling reports whether the items of stores in s can be bought.
+func (s Status) Selling() bool {
+	return s.orActive() == StatusActive
+}
+
+// Allows reports whether members may still do p in a store in s, whatever
+// their role. Suspended stores are frozen: their members can look, not
+// change. Closed stores can be deleted as well.
+func (s Status) Allows(p Permission) bool {
+	switch s {
+	case StatusSuspended:
+		return p == PermissionItemsRead || p == PermissionAuditRead
+	case StatusClosed:
+		return p == PermissionItemsRead || p == PermissionAuditRead || p == PermissionStoreDelete
+	}
+
+	return true
+}
+
+// orActive reads stores saved before they had a status as active.
+func (s Status) orActive() Status {
+	if s == "" {
+		return StatusActive
+	}
+
+	return s
+}
+
+// StatusChange is the state an owner moves their store to.
+type StatusChange struct {
+	Status Status `json:"status" validate:"required,oneof=active vacation closed"`
+}
+
+// ReasonCode tells the owner, and later admins, why a store was moderated.
+type ReasonCode string
+
+const (
+	ReasonProhibitedItems ReasonCode = "prohibited_items"
+	ReasonCounterfeit     ReasonCode = "counterfeit"
+	ReasonFraud           ReasonCode = "fraud"
+	ReasonSpam            ReasonCode = "spam"
+	ReasonPolicyViolation ReasonCode = "policy_violation"
+	ReasonOwnerRequest    ReasonCode = "owner_request"
+	ReasonAppealAccepted  ReasonCode = "appeal_accepted"
+	ReasonResolved        ReasonCode = "resolved"
+	ReasonOther           ReasonCode = "other"
+)
+
+// The moderation actions of admins.
+const (
+	ModerationSuspend   = "suspend"
+	ModerationReinstate = "reinstate"
+	ModerationClose     = "close"
+)
+
+// Moderation is an action of an admin on a store. Notes are sent to the
+// owner along with the reason; one is needed when the reason is "other".
+type Moderation struct {
+	Action string     `json:"action" validate:"required,oneof=suspend reinstate close"`
+	Reason ReasonCode `json:"reason" validate:"required,oneof=prohibited_items counterfeit fraud spam policy_violation owner_request appeal_accepted resolved other"`
+	Note   string     `json:"note" validate:"required_if=Reason other,max=1000"`
+}
+
+// Target returns the state the action moves a store in from to, and false
+// when it does not apply there: only suspended and closed stores can be
+// reinstated, and closed stores are not suspended.
+func (m Moderation) Target(from Status) (Status, bool) {
+	from = from.orActive()
+
+	switch m.Action {
+	case ModerationSuspend:
+		return StatusSuspended, from != StatusSuspended && from != StatusClosed
+	case ModerationReinstate:
+		return StatusActive, from == StatusSuspended || from == StatusClosed
+	case ModerationClose:
+		return StatusClosed, from != StatusClosed
+	}
+
+	return from, false
+}
+
+// StatusUpdate moves a store from one state to another. It only applies
+// while the store is still in From, so that two moves at once cannot both
+// succeed.
+type StatusUpdate struct {
+	StoreID   int64
+	From      Status
+	To        Status
+	Reason    ReasonCode
+	UpdatedAt time.Time
+}
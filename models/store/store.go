@@ -3,10 +3,58 @@ package store
 import "time"
 
 type Store struct {
-	ID          int64     `json:"id"`
-	UserID      int64     `json:"userID"`
-	NameStore   string    `json:"nameStore" validate:"required"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdateAt    time.Time `json:"update_at"`
+	ID          int64  `json:"id"`
+	UserID      int64  `json:"userID"`
+	NameStore   string `json:"nameStore" validate:"required"`
+	Slug        string `json:"slug"`
+	Description string `json:"description"`
+	LogoURL     string `json:"logo_url,omitempty" validate:"omitempty,url,max=512"`
+	BannerURL   string `json:"banner_url,omitempty" validate:"omitempty,url,max=512"`
+	// ContactEmail and ContactPhone are where buyers reach the store.
+	ContactEmail string   `json:"contact_email,omitempty" validate:"omitempty,email"`
+	ContactPhone string   `json:"contact_phone,omitempty" validate:"omitempty,max=32"`
+	Location     Location `json:"location"`
+	// Timezone is an IANA zone name, such as Asia/Jakarta; business hours
+	// are in it.
+	Timezone string `json:"timezone" validate:"omitempty,timezone"`
+	Hours    Hours  `json:"hours"`
+	// Status is picked on creation, draft or active, and changed later
+	// through StatusChange and Moderation.
+	Status Status `json:"status" validate:"omitempty,oneof=draft active"`
+	// StatusReason is why an admin last moderated the store.
+	StatusReason ReasonCode `json:"status_reason,omitempty"`
+	// OpenNow is computed on reads, see OpenAt.
+	OpenNow   bool      `json:"open_now"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdateAt  time.Time `json:"update_at"`
+	Version   int64     `json:"version"`
+}
+
+type Location struct {
+	Address   string   `json:"address,omitempty" validate:"max=255"`
+	City      string   `json:"city,omitempty" validate:"max=100"`
+	Country   string   `json:"country,omitempty" validate:"omitempty,iso3166_1_alpha2"`
+	Latitude  *float64 `json:"latitude,omitempty" validate:"omitempty,latitude"`
+	Longitude *float64 `json:"longitude,omitempty" validate:"omitempty,longitude"`
+}
+
+// Zone returns the time zone of the store, UTC when it has none.
+func (s Store) Zone() *time.Location {
+	if s.Timezone == "" {
+		return time.UTC
+	}
+
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+// OpenAt reports whether the store is open at t, by its business hours in
+// its own time zone. Stores that are not selling, such as those on
+// vacation, are never open.
+func (s Store) OpenAt(t time.Time) bool {
+	return s.Status.Selling() && s.Hours.OpenAt(t.In(s.Zone()))
 }
@@ -0,0 +1,38 @@
+package store
+
+import "github.com/Risuii/helpers/patch"
+
+type StoreUpdate struct {
+	NameStore    patch.String   `json:"nameStore" validate:"omitempty,min=1"`
+	Description  patch.String   `json:"description" patch:"nullable"`
+	LogoURL      patch.String   `json:"logo_url" patch:"nullable" validate:"omitempty,url,max=512"`
+	BannerURL    patch.String   `json:"banner_url" patch:"nullable" validate:"omitempty,url,max=512"`
+	ContactEmail patch.String   `json:"contact_email" patch:"nullable" validate:"omitempty,email"`
+	ContactPhone patch.String   `json:"contact_phone" patch:"nullable" validate:"omitempty,max=32"`
+	Location     LocationUpdate `json:"location"`
+	Timezone     patch.String   `json:"timezone" patch:"nullable" validate:"omitempty,timezone"`
+}
+
+type LocationUpdate struct {
+	Address   patch.String  `json:"address" patch:"nullable" validate:"omitempty,max=255"`
+	City      patch.String  `json:"city" patch:"nullable" validate:"omitempty,max=100"`
+	Country   patch.String  `json:"country" patch:"nullable" validate:"omitempty,iso3166_1_alpha2"`
+	Latitude  patch.Float64 `json:"latitude" patch:"nullable" validate:"omitempty,latitude"`
+	Longitude patch.Float64 `json:"longitude" patch:"nullable" validate:"omitempty,longitude"`
+}
+
+// Apply writes the members of the patch into s.
+func (u StoreUpdate) Apply(s *Store) {
+	u.NameStore.Apply(&s.NameStore)
+	u.Description.Apply(&s.Description)
+	u.LogoURL.Apply(&s.LogoURL)
+	u.BannerURL.Apply(&s.BannerURL)
+	u.ContactEmail.Apply(&s.ContactEmail)
+	u.ContactPhone.Apply(&s.ContactPhone)
+	u.Location.Address.Apply(&s.Location.Address)
+	u.Location.City.Apply(&s.Location.City)
+	u.Location.Country.Apply(&s.Location.Country)
+	u.Location.Latitude.ApplyPtr(&s.Location.Latitude)
+	u.Location.Longitude.ApplyPtr(&s.Location.Longitude)
+	u.Timezone.Apply(&s.Timezone)
+}
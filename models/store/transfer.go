@@ -0,0 +1,17 @@
+package store
+
+import "time"
+
+// Transfer hands a store over to another account once that account
+// accepts. A store has at most one pending transfer.
+type Transfer struct {
+	StoreID    int64     `json:"storeID"`
+	FromUserID int64     `json:"from_user_id"`
+	ToUserID   int64     `json:"to_user_id"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type TransferCreate struct {
+	Email string `json:"email" validate:"required,email"`
+}
@@ -0,0 +1,85 @@
+package account_test
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/password"
+	"github.com/Risuii/internal/account"
+	modelAccount "github.com/Risuii/models/account"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		tags     []string
+	}{
+		{"strong", "kopi-tubruk-42", nil},
+		{"too short", "ab1", []string{"password_min"}},
+		{"too long", "a1" + string(make([]byte, 80)), []string{"password_max"}},
+		{"letters only", "correcthorse", []string{"password_mix"}},
+		{"digits only", "1234567890", []string{"password_mix"}},
+		{"common", "Password123", []string{"password_common"}},
+		{"personal", "sari-2024!", []string{"password_personal"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := password.Check("new_password", tt.password, "sari@example.com", "Sari Dewi")
+			if tt.tags == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var e *exception.Error
+			require.ErrorAs(t, err, &e)
+			tags := make([]string, 0, len(e.Fields))
+			for _, f := range e.Fields {
+				assert.Equal(t, "new_password", f.Field)
+				tags = append(tags, f.Tag)
+			}
+			assert.Equal(t, tt.tags, tags)
+		})
+	}
+}
+
+var changeLinkPattern = regexp.MustCompile(`https://shop\.example\.com/confirm-email\?token=\S+`)
+
+func TestEmailChanges(t *testing.T) {
+	box := &outbox{}
+	changes := account.NewEmailChanges([]byte("secret-secret-secret-secret-secret"), box, "https://shop.example.com", time.Hour)
+	user := modelAccount.Account{ID: 7, Name: "Sari", Email: "sari@example.com"}
+
+	require.NoError(t, changes.Send(context.Background(), user, "sari@new.example.com"))
+	require.Len(t, box.sent, 1)
+	assert.Equal(t, "sari@new.example.com", box.sent[0].To, "the confirmation goes to the new address")
+
+	link := changeLinkPattern.FindString(box.sent[0].Body)
+	require.NotEmpty(t, link)
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+
+	id, from, to, err := changes.Check(u.Query().Get("token"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), id)
+	assert.Equal(t, "sari@example.com", from)
+	assert.Equal(t, "sari@new.example.com", to)
+
+	verifier := account.NewEmailVerifier([]byte("secret-secret-secret-secret-secret"), box, "https://shop.example.com", time.Hour)
+	_, _, err = verifier.Check(u.Query().Get("token"))
+	assert.Equal(t, exception.ErrInvalidToken, err, "change tokens are no verification tokens")
+
+	user.Email = to
+	require.NoError(t, changes.Notify(context.Background(), user, from))
+	require.Len(t, box.sent, 2)
+	assert.Equal(t, "sari@example.com", box.sent[1].To)
+	assert.Contains(t, box.sent[1].Body, "sari@new.example.com")
+}
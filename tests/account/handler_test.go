@@ -1 +1,59 @@
 package account_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/config/bcrypt"
+	"github.com/Risuii/config/jwt"
+	"github.com/Risuii/internal/account"
+	modelAccount "github.com/Risuii/models/account"
+)
+
+func sign(t *testing.T, key string, claims *jwt.JWTclaim) string {
+	signed, err := jwtgo.NewWithClaims(jwtgo.SigningMethodHS256, claims).SignedString([]byte(key))
+	require.NoError(t, err)
+	return signed
+}
+
+func TestAccountRoutesVerifyTheToken(t *testing.T) {
+	jwt.JWT_KEY = []byte("secret-secret-secret-secret-secret")
+	repo := &mfaRepo{user: modelAccount.Account{ID: 7, Email: "sari@example.com", Password: "$2a$04$hash", Version: 1}}
+	router := mux.NewRouter()
+	account.NewAbsensiHandler(router, validator.New(), account.NewAccountUseCaseImpl(repo, nil, bcrypt.NewBcrypt(4), nil, nil, nil, nil, nil))
+
+	serve := func(method, token string) *httptest.ResponseRecorder {
+		path := map[string]string{
+			http.MethodGet:    "/account/profile",
+			http.MethodPatch:  "/account/update",
+			http.MethodDelete: "/account/delete",
+		}[method]
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("If-Match", `"1"`)
+		req.AddCookie(&http.Cookie{Name: "token", Value: token})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	forged := sign(t, "not-the-key-not-the-key-not-the-key", &jwt.JWTclaim{ID: 7, UserID: 7})
+	expired := sign(t, string(jwt.JWT_KEY), &jwt.JWTclaim{ID: 7, UserID: 7, StandardClaims: jwtgo.StandardClaims{ExpiresAt: time.Now().Add(-time.Hour).Unix()}})
+	for _, token := range []string{forged, expired, "garbage"} {
+		for _, method := range []string{http.MethodGet, http.MethodPatch, http.MethodDelete} {
+			assert.Equal(t, http.StatusUnauthorized, serve(method, token).Code, method)
+		}
+	}
+
+	rec := serve(http.MethodGet, sign(t, string(jwt.JWT_KEY), &jwt.JWTclaim{ID: 7, UserID: 7}))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "sari@example.com")
+	assert.NotContains(t, rec.Body.String(), "$2a$", "the password hash is never sent")
+}
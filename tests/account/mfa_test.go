@@ -0,0 +1,141 @@
+package account_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/config/bcrypt"
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/ratelimit"
+	"github.com/Risuii/helpers/response"
+	"github.com/Risuii/helpers/totp"
+	"github.com/Risuii/internal/account"
+	modelAccount "github.com/Risuii/models/account"
+)
+
+// mfaRepo keeps a single account in memory.
+type mfaRepo struct {
+	account.AccountRepository
+	user modelAccount.Account
+}
+
+func (r *mfaRepo) FindByEmail(ctx context.Context, email string) (modelAccount.Account, error) {
+	if email != r.user.Email {
+		return modelAccount.Account{}, exception.ErrNotFound
+	}
+	return r.user, nil
+}
+
+func (r *mfaRepo) FindByID(ctx context.Context, id int64) (modelAccount.Account, error) {
+	if id != r.user.ID {
+		return modelAccount.Account{}, exception.ErrNotFound
+	}
+	return r.user, nil
+}
+
+func (r *mfaRepo) SetTOTPSecret(ctx context.Context, id int64, sealed string) error {
+	r.user.TOTPSecret = &sealed
+	return nil
+}
+
+func (r *mfaRepo) EnableTOTP(ctx context.Context, id int64, counter int64, enabledAt time.Time) error {
+	r.user.MFAEnabledAt = &enabledAt
+	r.user.TOTPCounter = counter
+	return nil
+}
+
+func (r *mfaRepo) UseTOTPCounter(ctx context.Context, id int64, counter int64) error {
+	if counter <= r.user.TOTPCounter {
+		return exception.ErrInvalidCode
+	}
+	r.user.TOTPCounter = counter
+	return nil
+}
+
+type codeRepo struct {
+	hashes map[string]bool
+}
+
+func (r *codeRepo) Replace(ctx context.Context, userID int64, codeHashes []string, now time.Time) error {
+	r.hashes = map[string]bool{}
+	for _, h := range codeHashes {
+		r.hashes[h] = false
+	}
+	return nil
+}
+
+func (r *codeRepo) Consume(ctx context.Context, userID int64, codeHash string, now time.Time) error {
+	used, found := r.hashes[codeHash]
+	if !found || used {
+		return exception.ErrInvalidCode
+	}
+	r.hashes[codeHash] = true
+	return nil
+}
+
+func (r *codeRepo) DeleteAll(ctx context.Context, userID int64) error {
+	r.hashes = nil
+	return nil
+}
+
+func data(res response.Response) interface{} {
+	return res.(*response.ResponseImpl).Data
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	ctx := context.Background()
+	hasher := bcrypt.NewBcrypt(4)
+	hashed, err := hasher.HashPassword("kopi-tubruk-42")
+	require.NoError(t, err)
+
+	repo := &mfaRepo{user: modelAccount.Account{ID: 7, Name: "Sari", Email: "sari@example.com", Password: hashed}}
+	mfa := account.NewMFA([]byte("secret-secret-secret-secret-secret"), &codeRepo{}, "Mini Ecommerce", 5*time.Minute)
+	lockout := ratelimit.NewLockout(ratelimit.NewMemoryStore(), 5, time.Minute)
+	usecase := account.NewAccountUseCaseImpl(repo, nil, hasher, lockout, nil, nil, nil, mfa)
+
+	res := usecase.EnrollMFA(ctx, 7)
+	require.NoError(t, res.Err())
+	enrolment := data(res).(modelAccount.MFAEnrolment)
+	assert.True(t, strings.HasPrefix(enrolment.URI, "otpauth://totp/"))
+	assert.NotContains(t, *repo.user.TOTPSecret, enrolment.Secret, "the secret is sealed at rest")
+
+	// Confirm with the code of the previous step, so that the login below
+	// can use the current one.
+	previous, err := totp.Code(enrolment.Secret, totp.Counter(time.Now())-1)
+	require.NoError(t, err)
+	res = usecase.ConfirmMFA(ctx, 7, modelAccount.MFACode{Code: previous})
+	require.NoError(t, res.Err())
+	codes := data(res).(modelAccount.RecoveryCodes).Codes
+	require.Len(t, codes, account.RecoveryCodeCount)
+
+	res, session := usecase.Login(ctx, modelAccount.AccountLogin{Email: "sari@example.com", Password: "kopi-tubruk-42"})
+	require.NoError(t, res.Err())
+	assert.Empty(t, session.Token, "the password alone gives no session")
+	challenge := data(res).(modelAccount.MFAChallenge)
+	assert.True(t, challenge.MFARequired)
+
+	current, err := totp.Code(enrolment.Secret, totp.Counter(time.Now()))
+	require.NoError(t, err)
+	res, session = usecase.LoginMFA(ctx, modelAccount.MFALogin{MFAToken: challenge.MFAToken, Code: current})
+	require.NoError(t, res.Err())
+	assert.NotEmpty(t, session.Token)
+
+	res, _ = usecase.LoginMFA(ctx, modelAccount.MFALogin{MFAToken: challenge.MFAToken, Code: current})
+	assert.Equal(t, exception.ErrInvalidCode, res.Err(), "a code works once")
+
+	res, session = usecase.LoginMFA(ctx, modelAccount.MFALogin{MFAToken: challenge.MFAToken, Code: strings.ToUpper(codes[0])})
+	require.NoError(t, res.Err(), "recovery codes are accepted in any case")
+	assert.NotEmpty(t, session.Token)
+
+	res, _ = usecase.LoginMFA(ctx, modelAccount.MFALogin{MFAToken: challenge.MFAToken, Code: codes[0]})
+	assert.Equal(t, exception.ErrInvalidCode, res.Err(), "recovery codes work once")
+
+	repo.user.SessionEpoch++
+	res, _ = usecase.LoginMFA(ctx, modelAccount.MFALogin{MFAToken: challenge.MFAToken, Code: codes[1]})
+	assert.Equal(t, exception.ErrInvalidToken, res.Err(), "revoking sessions revokes pending logins")
+}
@@ -0,0 +1,146 @@
+package account_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/config/jwt"
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/internal/account"
+	modelAccount "github.com/Risuii/models/account"
+)
+
+type resetRepo struct {
+	resets []modelAccount.PasswordReset
+}
+
+func (r *resetRepo) Create(ctx context.Context, params modelAccount.PasswordReset) error {
+	r.resets = append(r.resets, params)
+	return nil
+}
+
+func (r *resetRepo) Consume(ctx context.Context, tokenHash string, now time.Time) (int64, error) {
+	for i, reset := range r.resets {
+		if reset.TokenHash == tokenHash && reset.UsedAt == nil && now.Before(reset.ExpiresAt) {
+			r.resets[i].UsedAt = &now
+			return reset.UserID, nil
+		}
+	}
+	return 0, exception.ErrInvalidToken
+}
+
+func (r *resetRepo) RevokeAll(ctx context.Context, userID int64, now time.Time) error {
+	for i, reset := range r.resets {
+		if reset.UserID == userID && reset.UsedAt == nil {
+			r.resets[i].UsedAt = &now
+		}
+	}
+	return nil
+}
+
+var resetLinkPattern = regexp.MustCompile(`https://shop\.example\.com/reset-password\?token=\S+`)
+
+func resetTokenFrom(t *testing.T, body string) string {
+	link := resetLinkPattern.FindString(body)
+	require.NotEmpty(t, link, "the mail contains a reset link")
+
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+
+	return u.Query().Get("token")
+}
+
+func TestPasswordResets(t *testing.T) {
+	repo := &resetRepo{}
+	box := &outbox{}
+	resets := account.NewPasswordResets(repo, box, "https://shop.example.com", 30*time.Minute)
+	user := modelAccount.Account{ID: 7, Name: "Sari", Email: "sari@example.com"}
+
+	require.NoError(t, resets.Issue(context.Background(), user))
+	require.Len(t, box.sent, 1)
+	assert.Contains(t, box.sent[0].Body, "30")
+
+	token := resetTokenFrom(t, box.sent[0].Body)
+	require.Len(t, repo.resets, 1)
+	assert.NotEqual(t, token, repo.resets[0].TokenHash, "only the hash is stored")
+
+	id, err := resets.Consume(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), id)
+
+	_, err = resets.Consume(context.Background(), token)
+	assert.Equal(t, exception.ErrInvalidToken, err, "a token works once")
+
+	require.NoError(t, resets.Issue(context.Background(), user))
+	second := resetTokenFrom(t, box.sent[1].Body)
+	require.NoError(t, resets.RevokeAll(context.Background(), 7))
+	_, err = resets.Consume(context.Background(), second)
+	assert.Equal(t, exception.ErrInvalidToken, err, "revoked tokens are rejected")
+}
+
+func TestForgotPasswordCanBeWaitedFor(t *testing.T) {
+	box := &outbox{}
+	resets := account.NewPasswordResets(&resetRepo{}, box, "https://shop.example.com", 30*time.Minute)
+	repo := &mfaRepo{user: modelAccount.Account{ID: 7, Name: "Sari", Email: "sari@example.com"}}
+	usecase := account.NewAccountUseCaseImpl(repo, nil, nil, nil, nil, resets, nil, nil)
+
+	for _, email := range []string{"sari@example.com", "nobody@example.com"} {
+		res := usecase.ForgotPassword(context.Background(), modelAccount.ForgotPassword{Email: email})
+		require.NoError(t, res.Err())
+	}
+
+	resets.Wait()
+	require.Len(t, box.sent, 1, "the mail is sent before Wait returns")
+	assert.Equal(t, "sari@example.com", box.sent[0].To)
+}
+
+type epochRepo struct {
+	account.AccountRepository
+	epoch int64
+}
+
+func (r *epochRepo) FindByID(ctx context.Context, id int64) (modelAccount.Account, error) {
+	if id != 7 {
+		return modelAccount.Account{}, exception.ErrNotFound
+	}
+	return modelAccount.Account{ID: 7, SessionEpoch: r.epoch}, nil
+}
+
+func TestSessionGuard(t *testing.T) {
+	jwt.JWT_KEY = []byte("secret-secret-secret-secret-secret")
+	signed, err := jwtgo.NewWithClaims(jwtgo.SigningMethodHS256, &jwt.JWTclaim{UserID: 7}).SignedString(jwt.JWT_KEY)
+	require.NoError(t, err)
+
+	repo := &epochRepo{}
+	guarded := account.NewSessionGuard(repo)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("token"); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/account", nil)
+		req.AddCookie(&http.Cookie{Name: "token", Value: signed})
+		rec := httptest.NewRecorder()
+		guarded.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, serve().Code)
+
+	repo.epoch = 1
+	rec := serve()
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "the session was revoked")
+	assert.Contains(t, rec.Header().Get("Set-Cookie"), "token=;")
+}
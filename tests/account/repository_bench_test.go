@@ -0,0 +1,70 @@
+package account_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+
+	"github.com/Risuii/helpers/constant"
+	"github.com/Risuii/internal/account"
+)
+
+// The benchmarks need a migrated local database, e.g.
+//
+//	BENCH_DATABASE_DSN='root:secret@tcp(127.0.0.1:3306)/ecommerce?parseTime=true' \
+//		go test ./tests/account -run '^$' -bench FindByEmail
+func openBenchDB(b *testing.B) *sql.DB {
+	dsn := os.Getenv("BENCH_DATABASE_DSN")
+	if dsn == "" {
+		b.Skip("BENCH_DATABASE_DSN is not set")
+	}
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { db.Close() })
+
+	if err := db.Ping(); err != nil {
+		b.Skipf("database not reachable: %v", err)
+	}
+
+	return db
+}
+
+// BenchmarkFindByEmailPreparePerCall is what every repository method used
+// to do: prepare, run and close the statement on each call.
+func BenchmarkFindByEmailPreparePerCall(b *testing.B) {
+	db := openBenchDB(b)
+	ctx := context.Background()
+	query := fmt.Sprintf(`SELECT id, name, password, email, address, created_at, update_at, version FROM %s WHERE email = ?`, constant.TableAccount)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stmt, err := db.PrepareContext(ctx, query)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var cols [8]sql.NullString
+		err = stmt.QueryRowContext(ctx, "bench@example.com").Scan(&cols[0], &cols[1], &cols[2], &cols[3], &cols[4], &cols[5], &cols[6], &cols[7])
+		if err != nil && err != sql.ErrNoRows {
+			b.Fatal(err)
+		}
+		stmt.Close()
+	}
+}
+
+func BenchmarkFindByEmailCached(b *testing.B) {
+	db := openBenchDB(b)
+	ctx := context.Background()
+	repo := account.NewAccountRepositoryImpl(db, constant.TableAccount)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		repo.FindByEmail(ctx, "bench@example.com")
+	}
+}
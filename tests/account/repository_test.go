@@ -1 +1,52 @@
 package account_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Risuii/helpers/constant"
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/internal/account"
+	modelAccount "github.com/Risuii/models/account"
+	"github.com/Risuii/tests/mock"
+)
+
+func TestRegisterIdentity(t *testing.T) {
+	db, m := mock.NewMock()
+	defer db.Close()
+	repo := account.NewIdentityRepositoryImpl(db, constant.TableIdentities, constant.TableAccount)
+	now := time.Now()
+
+	m.ExpectPrepare(regexp.QuoteMeta(`INSERT INTO users`))
+	m.ExpectPrepare(regexp.QuoteMeta(`INSERT INTO identities`))
+	m.ExpectBegin()
+	m.ExpectExec(regexp.QuoteMeta(`INSERT INTO users`)).
+		WithArgs("Sari", "", "sari@example.com", "", now, now).
+		WillReturnResult(sqlmock.NewResult(4, 1))
+	m.ExpectExec(regexp.QuoteMeta(`INSERT INTO identities`)).
+		WithArgs(4, "google", "sub-1", "sari@example.com", now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	m.ExpectCommit()
+
+	user := modelAccount.Account{Name: "Sari", Email: "sari@example.com", CreatedAt: now}
+	identity := modelAccount.Identity{Provider: "google", Subject: "sub-1", Email: "sari@example.com", CreatedAt: now}
+	id, err := repo.Register(context.Background(), user, identity)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), id)
+
+	m.ExpectBegin()
+	m.ExpectExec(regexp.QuoteMeta(`INSERT INTO users`)).WillReturnResult(sqlmock.NewResult(5, 1))
+	m.ExpectExec(regexp.QuoteMeta(`INSERT INTO identities`)).WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+	m.ExpectRollback()
+
+	_, err = repo.Register(context.Background(), user, identity)
+	assert.True(t, errors.Is(err, exception.ErrIdentityLinked), "the account is rolled back with the identity")
+	assert.NoError(t, m.ExpectationsWereMet())
+}
@@ -1 +1,29 @@
 package account_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Risuii/config/bcrypt"
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/internal/account"
+	modelAccount "github.com/Risuii/models/account"
+)
+
+func TestRegisterChecksThePassword(t *testing.T) {
+	// The repository is never reached: a nil one would panic.
+	usecase := account.NewAccountUseCaseImpl(nil, nil, bcrypt.NewBcrypt(4), nil, nil, nil, nil, nil)
+
+	for name, password := range map[string]string{
+		"weak":     "password123",
+		"personal": "sari-2024!",
+		"too long": strings.Repeat("kopi-42-", 10),
+	} {
+		res := usecase.Register(context.Background(), modelAccount.Account{Name: "Sari", Email: "sari@example.com", Password: password})
+		assert.True(t, errors.Is(res.Err(), exception.ErrValidation), name)
+	}
+}
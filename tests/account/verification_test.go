@@ -0,0 +1,95 @@
+package account_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/i18n"
+	"github.com/Risuii/helpers/mailer"
+	"github.com/Risuii/internal/account"
+	modelAccount "github.com/Risuii/models/account"
+)
+
+type outbox struct {
+	sent []mailer.Message
+}
+
+func (o *outbox) Send(ctx context.Context, msg mailer.Message) error {
+	o.sent = append(o.sent, msg)
+	return nil
+}
+
+var linkPattern = regexp.MustCompile(`https://shop\.example\.com/verify-email\?token=\S+`)
+
+func tokenFrom(t *testing.T, msg mailer.Message) string {
+	link := linkPattern.FindString(msg.Body)
+	require.NotEmpty(t, link, "the mail contains a verification link")
+
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+
+	return u.Query().Get("token")
+}
+
+func TestEmailVerifier(t *testing.T) {
+	box := &outbox{}
+	verifier := account.NewEmailVerifier([]byte("secret-secret-secret-secret-secret"), box, "https://shop.example.com/", time.Hour)
+	user := modelAccount.Account{ID: 7, Name: "Sari", Email: "sari@example.com"}
+
+	require.NoError(t, verifier.Send(context.Background(), user))
+	require.Len(t, box.sent, 1)
+	assert.Equal(t, "sari@example.com", box.sent[0].To)
+	assert.Contains(t, box.sent[0].Body, "Sari")
+
+	id, email, err := verifier.Check(tokenFrom(t, box.sent[0]))
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), id)
+	assert.Equal(t, "sari@example.com", email)
+
+	token := tokenFrom(t, box.sent[0])
+	_, _, err = verifier.Check(strings.Replace(token, ".", ".x", 1))
+	assert.True(t, errors.Is(err, exception.ErrInvalidToken), "a tampered token is rejected")
+
+	other := account.NewEmailVerifier([]byte("another-secret-another-secret-123"), box, "https://shop.example.com", time.Hour)
+	_, _, err = other.Check(token)
+	assert.True(t, errors.Is(err, exception.ErrInvalidToken), "a token signed with another secret is rejected")
+}
+
+func TestEmailVerifierTranslates(t *testing.T) {
+	box := &outbox{}
+	verifier := account.NewEmailVerifier([]byte("secret-secret-secret-secret-secret"), box, "https://shop.example.com", time.Hour)
+
+	ctx := i18n.NewContext(context.Background(), "id")
+	require.NoError(t, verifier.Send(ctx, modelAccount.Account{ID: 1, Name: "Budi", Email: "budi@example.com"}))
+
+	assert.Equal(t, "Konfirmasi alamat email Anda", box.sent[0].Subject)
+	assert.Contains(t, box.sent[0].Body, "Halo Budi")
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	mail := mailer.NewFile(dir, "no-reply@example.com")
+
+	require.NoError(t, mail.Send(context.Background(), mailer.Message{To: "sari@example.com", Subject: "Hi", Body: "line 1\nline 2"}))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	raw, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), "To: sari@example.com\r\n")
+	assert.Contains(t, string(raw), "Subject: Hi\r\n")
+	assert.True(t, strings.HasSuffix(string(raw), "line 1\r\nline 2"))
+}
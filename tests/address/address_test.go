@@ -0,0 +1,191 @@
+package address_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/helpers/constant"
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/internal/address"
+	modelAddress "github.com/Risuii/models/address"
+	"github.com/Risuii/tests/mock"
+)
+
+func home() modelAddress.Address {
+	return modelAddress.Address{
+		UserID:     1,
+		Label:      "Home",
+		Recipient:  "Sari",
+		Phone:      "+62 812 3456 7890",
+		Street:     "Jl. Merdeka 1",
+		City:       "Bandung",
+		Province:   "Jawa Barat",
+		PostalCode: "40111",
+		Country:    "ID",
+		CreatedAt:  time.Now(),
+	}
+}
+
+func TestValidation(t *testing.T) {
+	v := validator.New()
+	require.NoError(t, v.Struct(home()))
+
+	bad := home()
+	bad.Country = "Indonesia"
+	assert.Error(t, v.Struct(bad), "countries are ISO 3166-1 alpha-2 codes")
+
+	bad = home()
+	bad.Province = ""
+	assert.NoError(t, v.Struct(bad), "not every country has provinces")
+}
+
+func TestFirstAddressBecomesTheDefault(t *testing.T) {
+	db, m := mock.NewMock()
+	defer db.Close()
+	repo := address.NewAddressRepositoryImpl(db, constant.TableAddresses)
+
+	m.ExpectPrepare(regexp.QuoteMeta(`SELECT COUNT(*) FROM addresses`))
+	m.ExpectPrepare(regexp.QuoteMeta(`UPDATE addresses SET is_default = FALSE`))
+	m.ExpectPrepare(regexp.QuoteMeta(`INSERT INTO addresses`))
+	m.ExpectBegin()
+	m.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM addresses WHERE userID = ? FOR UPDATE`)).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(0))
+	m.ExpectExec(regexp.QuoteMeta(`UPDATE addresses SET is_default = FALSE`)).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	m.ExpectExec(regexp.QuoteMeta(`INSERT INTO addresses`)).WillReturnResult(sqlmock.NewResult(5, 1))
+	m.ExpectCommit()
+
+	created, err := repo.Create(context.Background(), home())
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), created.ID)
+	assert.True(t, created.Default)
+	assert.Equal(t, int64(1), created.Version)
+	assert.NoError(t, m.ExpectationsWereMet())
+}
+
+func TestAddressBookIsBounded(t *testing.T) {
+	db, m := mock.NewMock()
+	defer db.Close()
+	repo := address.NewAddressRepositoryImpl(db, constant.TableAddresses)
+
+	m.ExpectPrepare(regexp.QuoteMeta(`SELECT COUNT(*) FROM addresses`))
+	m.ExpectPrepare(regexp.QuoteMeta(`UPDATE addresses SET is_default = FALSE`))
+	m.ExpectPrepare(regexp.QuoteMeta(`INSERT INTO addresses`))
+	m.ExpectBegin()
+	m.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM addresses`)).WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(address.MaxAddresses))
+	m.ExpectRollback()
+
+	_, err := repo.Create(context.Background(), home())
+	assert.True(t, errors.Is(err, exception.ErrAddressBookFull))
+	assert.NoError(t, m.ExpectationsWereMet())
+}
+
+func TestDeletingTheDefaultPromotesAnother(t *testing.T) {
+	db, m := mock.NewMock()
+	defer db.Close()
+	repo := address.NewAddressRepositoryImpl(db, constant.TableAddresses)
+
+	m.ExpectPrepare(regexp.QuoteMeta(`SELECT is_default FROM addresses`))
+	m.ExpectPrepare(regexp.QuoteMeta(`DELETE FROM addresses`))
+	m.ExpectPrepare(regexp.QuoteMeta(`UPDATE addresses SET is_default = TRUE`))
+	m.ExpectBegin()
+	m.ExpectQuery(regexp.QuoteMeta(`SELECT is_default FROM addresses WHERE id = ? AND userID = ? FOR UPDATE`)).WithArgs(5, 1).WillReturnRows(sqlmock.NewRows([]string{"is_default"}).AddRow(true))
+	m.ExpectExec(regexp.QuoteMeta(`DELETE FROM addresses WHERE id = ? AND version = ?`)).WithArgs(5, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	m.ExpectExec(regexp.QuoteMeta(`UPDATE addresses SET is_default = TRUE, version = version + 1 WHERE userID = ? ORDER BY id LIMIT 1`)).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	m.ExpectCommit()
+
+	require.NoError(t, repo.Delete(context.Background(), 1, 5, 2))
+	assert.NoError(t, m.ExpectationsWereMet())
+}
+
+func TestDeleteChecksTheVersion(t *testing.T) {
+	db, m := mock.NewMock()
+	defer db.Close()
+	repo := address.NewAddressRepositoryImpl(db, constant.TableAddresses)
+
+	m.ExpectPrepare(regexp.QuoteMeta(`SELECT is_default FROM addresses`))
+	m.ExpectPrepare(regexp.QuoteMeta(`DELETE FROM addresses`))
+	m.ExpectPrepare(regexp.QuoteMeta(`UPDATE addresses SET is_default = TRUE`))
+	m.ExpectBegin()
+	m.ExpectQuery(regexp.QuoteMeta(`SELECT is_default FROM addresses`)).WillReturnRows(sqlmock.NewRows([]string{"is_default"}).AddRow(false))
+	m.ExpectExec(regexp.QuoteMeta(`DELETE FROM addresses`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	m.ExpectRollback()
+
+	err := repo.Delete(context.Background(), 1, 5, 1)
+	assert.True(t, errors.Is(err, exception.ErrPreconditionFailed))
+	assert.NoError(t, m.ExpectationsWereMet())
+}
+
+// book is an address book held in memory.
+type book struct {
+	address.AddressRepository
+	addresses []modelAddress.Address
+}
+
+func (b *book) FindByID(ctx context.Context, userID int64, id int64) (modelAddress.Address, error) {
+	for _, a := range b.addresses {
+		if a.UserID == userID && a.ID == id {
+			return a, nil
+		}
+	}
+	return modelAddress.Address{}, exception.ErrNotFound
+}
+
+func (b *book) FindDefault(ctx context.Context, userID int64) (modelAddress.Address, error) {
+	for _, a := range b.addresses {
+		if a.UserID == userID && a.Default {
+			return a, nil
+		}
+	}
+	return modelAddress.Address{}, exception.ErrNotFound
+}
+
+func TestShippingAddress(t *testing.T) {
+	ctx := context.Background()
+	office := home()
+	office.ID, office.Label = 2, "Office"
+	house := home()
+	house.ID, house.Default = 1, true
+	repo := &book{addresses: []modelAddress.Address{house, office}}
+
+	picked, err := address.ShippingAddress(ctx, repo, 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "Home", picked.Label, "without a pick the default is used")
+
+	picked, err = address.ShippingAddress(ctx, repo, 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "Office", picked.Label)
+
+	_, err = address.ShippingAddress(ctx, repo, 9, 2)
+	assert.True(t, errors.Is(err, exception.ErrNotFound), "addresses of others cannot be picked")
+
+	_, err = address.ShippingAddress(ctx, repo, 9, 0)
+	assert.True(t, errors.Is(err, exception.ErrNoShippingAddress))
+}
+
+func TestRoutesNeedASession(t *testing.T) {
+	router := mux.NewRouter()
+	address.NewAddressHandler(router, validator.New(), nil)
+
+	for _, route := range [][2]string{
+		{http.MethodPost, "/account/addresses"},
+		{http.MethodGet, "/account/addresses"},
+		{http.MethodGet, "/account/addresses/1"},
+		{http.MethodPatch, "/account/addresses/1"},
+		{http.MethodDelete, "/account/addresses/1"},
+		{http.MethodPut, "/account/addresses/1/default"},
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(route[0], route[1], nil))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, "%s %s", route[0], route[1])
+	}
+}
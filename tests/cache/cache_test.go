@@ -0,0 +1,319 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/helpers/cache"
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/internal/item"
+	"github.com/Risuii/internal/store"
+	modelItem "github.com/Risuii/models/item"
+	modelStore "github.com/Risuii/models/store"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewLRU(2)
+
+	require.NoError(t, c.Set(ctx, "a", []byte("1"), time.Minute))
+	require.NoError(t, c.Set(ctx, "b", []byte("2"), time.Minute))
+	_, ok, _ := c.Get(ctx, "a")
+	require.True(t, ok)
+	require.NoError(t, c.Set(ctx, "c", []byte("3"), time.Minute))
+
+	_, ok, _ = c.Get(ctx, "b")
+	assert.False(t, ok, "b was least recently used")
+	value, ok, _ := c.Get(ctx, "a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), value)
+}
+
+func TestLRUExpires(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewLRU(10)
+
+	require.NoError(t, c.Set(ctx, "a", []byte("1"), 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+
+	_, ok, err := c.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestRedis(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	c := cache.NewRedis(client, "test:")
+
+	_, ok, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, c.Set(ctx, "a", []byte("1"), time.Minute))
+	require.NoError(t, c.Set(ctx, "b", []byte("2"), time.Minute))
+	assert.True(t, server.Exists("test:a"), "keys are prefixed")
+
+	value, ok, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), value)
+
+	server.FastForward(2 * time.Minute)
+	_, ok, err = c.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, ok, "expired")
+
+	require.NoError(t, c.Set(ctx, "a", []byte("1"), time.Minute))
+	require.NoError(t, c.Delete(ctx, "a", "b"))
+	assert.False(t, server.Exists("test:a"))
+	assert.False(t, server.Exists("test:b"))
+}
+
+func TestRedisUnavailable(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	server.Close()
+
+	loader := cache.NewLoader(cache.NewRedis(client, "test:"), time.Minute)
+	value, err := cache.Load(ctx, loader, "a", func(ctx context.Context) (int, error) {
+		return 42, nil
+	})
+
+	require.NoError(t, err, "a broken cache falls back to the source")
+	assert.Equal(t, 42, value)
+}
+
+func TestLoaderSharesConcurrentMisses(t *testing.T) {
+	ctx := context.Background()
+	loader := cache.NewLoader(cache.NewLRU(10), time.Minute)
+
+	var loads int32
+	release := make(chan struct{})
+	load := func(ctx context.Context) (string, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return "value", nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]string, 20)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = cache.Load(ctx, loader, "hot", load)
+		}(i)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+	for _, result := range results {
+		assert.Equal(t, "value", result)
+	}
+}
+
+func TestLoadStartedBeforeAnInvalidationIsNotCached(t *testing.T) {
+	ctx := context.Background()
+	lru := cache.NewLRU(10)
+	loader := cache.NewLoader(lru, time.Minute)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan string)
+	go func() {
+		value, _ := cache.Load(ctx, loader, "store:7", func(ctx context.Context) (string, error) {
+			close(started)
+			<-release
+			return "before the write", nil
+		})
+		done <- value
+	}()
+
+	<-started
+	loader.Invalidate(ctx, "store:7")
+	close(release)
+	assert.Equal(t, "before the write", <-done, "the caller still gets what was read")
+
+	_, ok, err := lru.Get(ctx, "store:7")
+	require.NoError(t, err)
+	assert.False(t, ok, "the stale value is not cached")
+
+	value, err := cache.Load(ctx, loader, "store:7", func(ctx context.Context) (string, error) {
+		return "after the write", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "after the write", value)
+
+	_, ok, _ = lru.Get(ctx, "store:7")
+	assert.True(t, ok, "later loads are cached again")
+}
+
+type countingItemRepository struct {
+	item.ItemRepository
+	reads int32
+}
+
+func (r *countingItemRepository) GetAllItem(ctx context.Context, storeID int64) ([]modelItem.Item, error) {
+	atomic.AddInt32(&r.reads, 1)
+	return []modelItem.Item{{ID: 1, StoreID: storeID, Name: "kopi", Version: 1}}, nil
+}
+
+func (r *countingItemRepository) FindByID(ctx context.Context, id int64) (modelItem.Item, error) {
+	return modelItem.Item{ID: id, StoreID: 7, Version: 1}, nil
+}
+
+func (r *countingItemRepository) AddItem(ctx context.Context, params modelItem.Item) (int64, error) {
+	return 2, nil
+}
+
+func (r *countingItemRepository) UpdateKuantitas(ctx context.Context, id int64, params modelItem.Item) error {
+	return nil
+}
+
+func TestItemRepositoryCacheInvalidatesOnWrite(t *testing.T) {
+	ctx := context.Background()
+	source := &countingItemRepository{}
+	repo := item.NewItemRepositoryCache(source, cache.NewLRU(10), time.Minute)
+
+	for i := 0; i < 3; i++ {
+		items, err := repo.GetAllItem(ctx, 7)
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+	}
+	assert.Equal(t, int32(1), source.reads)
+
+	_, err := repo.AddItem(ctx, modelItem.Item{StoreID: 7, Name: "teh"})
+	require.NoError(t, err)
+	repo.GetAllItem(ctx, 7)
+	assert.Equal(t, int32(2), source.reads, "adding an item drops the store's list")
+
+	require.NoError(t, repo.UpdateKuantitas(ctx, 1, modelItem.Item{}))
+	repo.GetAllItem(ctx, 7)
+	assert.Equal(t, int32(3), source.reads, "a restock drops the list of the item's store")
+}
+
+// countingStoreRepository counts reads and owns store 7 by whoever owner
+// is.
+type countingStoreRepository struct {
+	store.StoreRepository
+	reads int32
+	owner int64
+}
+
+func (r *countingStoreRepository) FindByID(ctx context.Context, id int64) (modelStore.Store, error) {
+	atomic.AddInt32(&r.reads, 1)
+	return modelStore.Store{ID: id, UserID: r.owner}, nil
+}
+
+func TestStoreRepositoryCacheForgets(t *testing.T) {
+	ctx := context.Background()
+	source := &countingStoreRepository{owner: 1}
+	repo := store.NewStoreRepositoryCache(source, cache.NewLRU(10), time.Minute)
+
+	repo.FindByID(ctx, 7)
+	source.owner = 2
+	s, err := repo.FindByID(ctx, 7)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), s.UserID)
+
+	repo.Forget(ctx, 7, 1, 2)
+	s, err = repo.FindByID(ctx, 7)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), s.UserID, "a transfer made around the repository is seen once forgotten")
+	assert.Equal(t, int32(2), source.reads)
+}
+
+// slugStoreRepository holds stores by ID, looked up by slug as well.
+type slugStoreRepository struct {
+	store.StoreRepository
+	stores map[int64]modelStore.Store
+}
+
+func (r *slugStoreRepository) FindByID(ctx context.Context, id int64) (modelStore.Store, error) {
+	s, ok := r.stores[id]
+	if !ok {
+		return s, exception.ErrNotFound
+	}
+	return s, nil
+}
+
+func (r *slugStoreRepository) FindBySlug(ctx context.Context, slug string) (modelStore.Store, error) {
+	for _, s := range r.stores {
+		if s.Slug == slug {
+			return s, nil
+		}
+	}
+	return modelStore.Store{}, exception.ErrNotFound
+}
+
+func TestStoreRepositoryCacheSlugs(t *testing.T) {
+	ctx := context.Background()
+	source := &slugStoreRepository{stores: map[int64]modelStore.Store{7: {ID: 7, Slug: "toko-sari"}}}
+	repo := store.NewStoreRepositoryCache(source, cache.NewLRU(10), time.Minute)
+
+	s, err := repo.FindBySlug(ctx, "toko-sari")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), s.ID)
+
+	delete(source.stores, 7)
+	source.stores[8] = modelStore.Store{ID: 8, Slug: "toko-sari"}
+	repo.Forget(ctx, 7)
+
+	s, err = repo.FindBySlug(ctx, "toko-sari")
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), s.ID, "the slug of a deleted store can be taken again")
+}
+
+// versionedStoreRepository fails updates made against a stale version.
+type versionedStoreRepository struct {
+	countingStoreRepository
+	version int64
+}
+
+func (r *versionedStoreRepository) FindByID(ctx context.Context, id int64) (modelStore.Store, error) {
+	s, _ := r.countingStoreRepository.FindByID(ctx, id)
+	s.Version = r.version
+	return s, nil
+}
+
+func (r *versionedStoreRepository) Update(ctx context.Context, id int64, params modelStore.Store) error {
+	if params.Version != r.version {
+		return exception.ErrPreconditionFailed
+	}
+	r.version++
+	return nil
+}
+
+func TestStoreRepositoryCacheStaleWrites(t *testing.T) {
+	ctx := context.Background()
+	source := &versionedStoreRepository{countingStoreRepository: countingStoreRepository{owner: 1}, version: 1}
+	repo := store.NewStoreRepositoryCache(source, cache.NewLRU(10), time.Minute)
+
+	repo.FindByID(ctx, 7)
+	source.version = 2
+
+	s, _ := repo.FindByID(ctx, 7)
+	assert.Equal(t, int64(1), s.Version, "served from the cache")
+	s, _ = repo.FindByID(cache.Fresh(ctx), 7)
+	assert.Equal(t, int64(2), s.Version, "fresh reads go around the cache")
+
+	err := repo.Update(ctx, 7, modelStore.Store{ID: 7, UserID: 1, Version: 1})
+	assert.Equal(t, exception.ErrPreconditionFailed, err)
+	s, _ = repo.FindByID(ctx, 7)
+	assert.Equal(t, int64(2), s.Version, "a failed write drops the stale entry")
+}
@@ -0,0 +1,122 @@
+package config_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/config"
+)
+
+const secret = "0123456789abcdef0123456789abcdef"
+
+func write(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestPrecedence(t *testing.T) {
+	file := write(t, "config.yaml", `
+app:
+  port: "9000"
+  write_timeout: 30s
+log:
+  level: debug
+database:
+  username: shop
+  name: shop
+jwt:
+  secret: `+secret+`
+`)
+	t.Setenv("PORT", "9100")
+	t.Setenv("DB_DATABASE_NAME", "shop_env")
+	t.Setenv("LOG_LEVEL", "")
+
+	c, opts, err := config.Load([]string{"--config", file, "--database.name", "shop_flag"})
+	require.NoError(t, err)
+
+	assert.Equal(t, file, opts.File)
+	assert.Equal(t, 10*time.Second, c.App.ReadTimeout, "defaults fill what nothing sets")
+	assert.Equal(t, 30*time.Second, c.App.WriteTimeout, "the file overrides defaults")
+	assert.Equal(t, "debug", c.Log.Level, "an empty variable is ignored")
+	assert.Equal(t, "9100", c.App.Port, "the environment overrides the file")
+	assert.Equal(t, "shop_flag", c.Database.Name, "flags override the environment")
+}
+
+func TestTOML(t *testing.T) {
+	file := write(t, "config.toml", `
+[database]
+username = "shop"
+name = "shop"
+
+[cache]
+driver = "none"
+`)
+	t.Setenv("JWT_SECRET", secret)
+
+	c, _, err := config.Load([]string{"--config", file})
+	require.NoError(t, err)
+	assert.Equal(t, "none", c.Cache.Driver)
+	assert.Equal(t, secret, c.JWT.Secret)
+}
+
+func TestSecretFromFile(t *testing.T) {
+	t.Setenv("DB_USERNAME", "shop")
+	t.Setenv("DB_DATABASE_NAME", "shop")
+	t.Setenv("JWT_SECRET", "ignored when the file is given")
+	t.Setenv("JWT_SECRET_FILE", write(t, "jwt_secret", secret+"\n"))
+
+	c, _, err := config.Load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, secret, c.JWT.Secret)
+
+	var out bytes.Buffer
+	require.NoError(t, c.Print(&out))
+	assert.NotContains(t, out.String(), secret)
+	assert.Contains(t, out.String(), "[REDACTED]")
+
+	t.Setenv("JWT_SECRET_FILE", filepath.Join(t.TempDir(), "missing"))
+	_, _, err = config.Load(nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "JWT_SECRET_FILE")
+}
+
+func TestValidation(t *testing.T) {
+	t.Setenv("PORT", "0")
+	t.Setenv("LOG_LEVEL", "loud")
+	t.Setenv("JWT_SECRET", "short")
+	t.Setenv("DB_USERNAME", "")
+
+	_, _, err := config.Load(nil)
+	require.Error(t, err)
+	for _, msg := range []string{
+		`app.port (PORT): "0" must be a number between 1 and 65535`,
+		`log.level (LOG_LEVEL): "loud" must be one of debug, info, warn, error`,
+		"database.username (DB_USERNAME): is required",
+		"jwt.secret (JWT_SECRET or JWT_SECRET_FILE): must be at least 32 characters",
+	} {
+		assert.Contains(t, err.Error(), msg)
+	}
+	assert.NotContains(t, err.Error(), "short", "secrets are never echoed")
+}
+
+func TestUnparsableValues(t *testing.T) {
+	t.Setenv("HTTP_READ_TIMEOUT", "soon")
+	t.Setenv("DB_MAX_OPEN_CONNS", "many")
+
+	_, _, err := config.Load([]string{"--log.level", "info", "--bcrypt.hash_cost", "high"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `HTTP_READ_TIMEOUT: "soon" is not a duration such as 15s or 5m`)
+	assert.Contains(t, err.Error(), `DB_MAX_OPEN_CONNS: "many" is not a whole number`)
+	assert.Contains(t, err.Error(), `--bcrypt.hash_cost: "high" is not a whole number`)
+
+	_, _, err = config.Load([]string{"--config", write(t, "config.json", "{}")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported format")
+}
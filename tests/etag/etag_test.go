@@ -0,0 +1,77 @@
+package etag_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Risuii/helpers/etag"
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/response"
+)
+
+// current is the version of the only row served by the handlers below.
+const current = 3
+
+func get(w http.ResponseWriter, r *http.Request) {
+	response.Success(response.StatusOK, map[string]int{"version": current}).WithETag(etag.Version(current)).JSON(w)
+}
+
+func put(w http.ResponseWriter, r *http.Request) {
+	version, err := etag.IfMatch(r)
+	if err == nil && version != current {
+		err = exception.ErrPreconditionFailed
+	}
+	if err != nil {
+		response.Fail(err).JSON(w)
+		return
+	}
+
+	response.Success(response.StatusOK, nil).WithETag(etag.Version(current + 1)).JSON(w)
+}
+
+func serve(handler http.HandlerFunc, method string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/", nil)
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	etag.Middleware(handler).ServeHTTP(w, r)
+	return w
+}
+
+func TestNotModified(t *testing.T) {
+	w := serve(get, http.MethodGet, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"3"`, w.Header().Get(etag.HeaderETag))
+
+	for _, header := range []string{`"3"`, `W/"3"`, `"1", "3"`, `*`} {
+		w = serve(get, http.MethodGet, map[string]string{etag.HeaderIfNoneMatch: header})
+		assert.Equal(t, http.StatusNotModified, w.Code, header)
+		assert.Empty(t, w.Body.String(), header)
+		assert.Equal(t, `"3"`, w.Header().Get(etag.HeaderETag), header)
+	}
+
+	w = serve(get, http.MethodGet, map[string]string{etag.HeaderIfNoneMatch: `"2"`})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Body.String())
+}
+
+func TestIfMatch(t *testing.T) {
+	w := serve(put, http.MethodPut, nil)
+	assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+	assert.Contains(t, w.Body.String(), "IF_MATCH_REQUIRED")
+
+	for _, header := range []string{`"2"`, `2`, `"two"`, `W/"3"`} {
+		w = serve(put, http.MethodPut, map[string]string{etag.HeaderIfMatch: header})
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code, header)
+		assert.Contains(t, w.Body.String(), "VERSION_MISMATCH", header)
+		assert.Empty(t, w.Header().Get(etag.HeaderETag), header)
+	}
+
+	w = serve(put, http.MethodPut, map[string]string{etag.HeaderIfMatch: `"3"`, etag.HeaderIfNoneMatch: `"4"`})
+	assert.Equal(t, http.StatusOK, w.Code, "If-None-Match only applies to reads")
+	assert.Equal(t, `"4"`, w.Header().Get(etag.HeaderETag))
+}
@@ -0,0 +1,63 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Risuii/helpers/health"
+)
+
+func probe(handler http.HandlerFunc) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	return rec
+}
+
+func TestReadinessFailsWithADependency(t *testing.T) {
+	var down error
+	checker := health.New(time.Second)
+	checker.Add("database", func(ctx context.Context) error { return nil })
+	checker.Add("redis", func(ctx context.Context) error { return down })
+
+	assert.Equal(t, http.StatusOK, probe(checker.Readiness).Code)
+
+	down = errors.New("connection refused")
+	rec := probe(checker.Readiness)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"field":"redis"`)
+	assert.NotContains(t, rec.Body.String(), `"field":"database"`)
+	assert.NotContains(t, rec.Body.String(), "connection refused")
+	assert.Equal(t, http.StatusOK, probe(checker.Liveness).Code, "a dependency never makes the process look dead")
+}
+
+func TestReadinessChecksTimeOut(t *testing.T) {
+	checker := health.New(10 * time.Millisecond)
+	checker.Add("database", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	assert.Equal(t, http.StatusServiceUnavailable, probe(checker.Readiness).Code)
+}
+
+func TestDrain(t *testing.T) {
+	checks := 0
+	checker := health.New(time.Second)
+	checker.Add("database", func(ctx context.Context) error {
+		checks++
+		return nil
+	})
+
+	assert.Equal(t, http.StatusOK, probe(checker.Readiness).Code)
+
+	checker.Drain()
+	assert.Equal(t, http.StatusServiceUnavailable, probe(checker.Readiness).Code)
+	assert.Equal(t, 1, checks, "a draining instance is not ready whatever its dependencies say")
+	assert.Equal(t, http.StatusOK, probe(checker.Liveness).Code, "draining keeps the process alive")
+}
@@ -0,0 +1,85 @@
+package i18n_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/i18n"
+	"github.com/Risuii/helpers/response"
+)
+
+func TestNegotiate(t *testing.T) {
+	for header, locale := range map[string]string{
+		"id-ID,id;q=0.9,en;q=0.8": "id",
+		"id":                      "id",
+		"fr-FR, en;q=0.5":         "en",
+		"fr, id;q=0.5":            "id",
+		"":                        "en",
+		"ja":                      "en",
+		";;;q=nope":               "en",
+	} {
+		assert.Equal(t, locale, i18n.Negotiate(header), header)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var locale string
+	handler := i18n.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale = i18n.FromContext(r.Context())
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Language", "id-ID,id;q=0.9")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, "id", locale)
+	assert.Equal(t, "id", w.Header().Get(i18n.HeaderContentLanguage))
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, "en", locale)
+	assert.Equal(t, "en", w.Header().Get(i18n.HeaderContentLanguage))
+}
+
+func TestErrorsAreTranslated(t *testing.T) {
+	v := validator.New()
+	require.NoError(t, i18n.RegisterValidator(v))
+
+	var input struct {
+		Name string `validate:"required"`
+	}
+	err := exception.Validation(v.Struct(input))
+
+	body := func(locale string) *response.ErrorBody {
+		w := httptest.NewRecorder()
+		w.Header().Set(i18n.HeaderContentLanguage, locale)
+		require.NoError(t, response.Fail(err).JSON(w))
+
+		var res struct {
+			Error *response.ErrorBody `json:"error"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		return res.Error
+	}
+
+	en, id := body("en"), body("id")
+	assert.Equal(t, "Beberapa isian tidak valid.", id.Message)
+	assert.NotEqual(t, en.Message, id.Message)
+	assert.Equal(t, en.Code, id.Code, "the code is never translated")
+	require.Len(t, id.Fields, 1)
+	assert.Equal(t, "required", id.Fields[0].Tag)
+	assert.NotEqual(t, en.Fields[0].Message, id.Fields[0].Message)
+
+	notNull := exception.ErrValidation.WithFields(exception.FieldError{Field: "city", Tag: "notnull"})
+	assert.Equal(t, "city tidak boleh dihapus", notNull.Fields[0].Translate(i18n.Translator("id")).Message)
+	assert.Equal(t, "city cannot be removed", notNull.Fields[0].Translate(i18n.Translator("fr")).Message)
+}
@@ -0,0 +1,287 @@
+package item_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	newJWT "github.com/dgrijalva/jwt-go"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/config/jwt"
+	"github.com/Risuii/helpers/blob"
+	"github.com/Risuii/helpers/constant"
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/media"
+	"github.com/Risuii/internal/item"
+	modelItem "github.com/Risuii/models/item"
+	"github.com/Risuii/models/store"
+	"github.com/Risuii/tests/mock"
+)
+
+func TestGalleryIsLimited(t *testing.T) {
+	db, m := mock.NewMock()
+	defer db.Close()
+	repo := item.NewImageRepositoryImpl(db, constant.TableItemImages)
+
+	m.ExpectBegin()
+	m.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM item_images WHERE itemID = ? FOR UPDATE`)).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(item.MaxImages))
+	m.ExpectRollback()
+
+	_, err := repo.Create(context.Background(), modelItem.Image{ItemID: 3})
+	assert.True(t, errors.Is(err, exception.ErrGalleryFull))
+	assert.NoError(t, m.ExpectationsWereMet())
+}
+
+func TestImagesAreAddedLast(t *testing.T) {
+	db, m := mock.NewMock()
+	defer db.Close()
+	repo := item.NewImageRepositoryImpl(db, constant.TableItemImages)
+
+	m.ExpectBegin()
+	m.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM item_images`)).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(2))
+	m.ExpectExec(regexp.QuoteMeta(`INSERT INTO item_images`)).
+		WithArgs(3, 2, "items/3/a.png", "items/3/a_thumb.png", "image/png", 40, 30, 512, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(9, 1))
+	m.ExpectCommit()
+
+	created, err := repo.Create(context.Background(), modelItem.Image{
+		ItemID:       3,
+		Key:          "items/3/a.png",
+		ThumbnailKey: "items/3/a_thumb.png",
+		ContentType:  "image/png",
+		Width:        40,
+		Height:       30,
+		Size:         512,
+		CreatedAt:    time.Now(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), created.ID)
+	assert.Equal(t, 2, created.Position)
+	assert.NoError(t, m.ExpectationsWereMet())
+}
+
+func TestReorderNeedsEveryImage(t *testing.T) {
+	db, m := mock.NewMock()
+	defer db.Close()
+	repo := item.NewImageRepositoryImpl(db, constant.TableItemImages)
+	find := regexp.QuoteMeta(`SELECT id FROM item_images WHERE itemID = ? FOR UPDATE`)
+
+	for _, ids := range [][]int64{{5, 6}, {5, 6, 8}, {5, 6, 7, 8}} {
+		m.ExpectBegin()
+		m.ExpectQuery(find).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5).AddRow(6).AddRow(7))
+		m.ExpectRollback()
+
+		err := repo.Reorder(context.Background(), 3, ids)
+		assert.True(t, errors.Is(err, exception.ErrImageOrder), "%v", ids)
+	}
+
+	m.ExpectBegin()
+	m.ExpectQuery(find).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5).AddRow(6).AddRow(7))
+	for position, id := range []int64{7, 5, 6} {
+		m.ExpectExec(regexp.QuoteMeta(`UPDATE item_images SET position = ? WHERE id = ?`)).WithArgs(position, id).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	m.ExpectCommit()
+
+	require.NoError(t, repo.Reorder(context.Background(), 3, []int64{7, 5, 6}))
+	assert.NoError(t, m.ExpectationsWereMet())
+}
+
+func TestDeleteImageClosesTheGap(t *testing.T) {
+	db, m := mock.NewMock()
+	defer db.Close()
+	repo := item.NewImageRepositoryImpl(db, constant.TableItemImages)
+	columns := []string{"id", "itemID", "position", "blob_key", "thumbnail_key", "content_type", "width", "height", "size", "created_at"}
+
+	m.ExpectBegin()
+	m.ExpectQuery(regexp.QuoteMeta(`FROM item_images WHERE id = ? AND itemID = ? FOR UPDATE`)).WithArgs(6, 3).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(6, 3, 1, "items/3/b.png", "items/3/b_thumb.png", "image/png", 40, 30, 512, time.Now()))
+	m.ExpectExec(regexp.QuoteMeta(`DELETE FROM item_images WHERE id = ?`)).WithArgs(6).WillReturnResult(sqlmock.NewResult(0, 1))
+	m.ExpectExec(regexp.QuoteMeta(`UPDATE item_images SET position = position - 1 WHERE itemID = ? AND position > ?`)).WithArgs(3, 1).WillReturnResult(sqlmock.NewResult(0, 2))
+	m.ExpectCommit()
+
+	deleted, err := repo.Delete(context.Background(), 3, 6)
+	require.NoError(t, err)
+	assert.Equal(t, "items/3/b.png", deleted.Key)
+
+	m.ExpectBegin()
+	m.ExpectQuery(regexp.QuoteMeta(`FROM item_images WHERE id = ? AND itemID = ? FOR UPDATE`)).WithArgs(6, 4).WillReturnRows(sqlmock.NewRows(columns))
+	m.ExpectRollback()
+
+	_, err = repo.Delete(context.Background(), 4, 6)
+	assert.True(t, errors.Is(err, exception.ErrNotFound), "images of other items are not found")
+	assert.NoError(t, m.ExpectationsWereMet())
+}
+
+// itemRepo holds item 3 of store 7.
+type itemRepo struct {
+	item.ItemRepository
+}
+
+func (r *itemRepo) FindByIDWithStoreID(ctx context.Context, id int64, storeID int64) (modelItem.Item, error) {
+	if id != 3 || storeID != 7 {
+		return modelItem.Item{}, exception.ErrNotFound
+	}
+	return modelItem.Item{ID: 3, StoreID: 7, Name: "Kopi", Version: 1}, nil
+}
+
+type imageRepo struct {
+	images []modelItem.Image
+	nextID int64
+}
+
+func (r *imageRepo) Create(ctx context.Context, params modelItem.Image) (modelItem.Image, error) {
+	if len(r.images) >= item.MaxImages {
+		return params, exception.ErrGalleryFull
+	}
+	r.nextID++
+	params.ID = r.nextID
+	params.Position = len(r.images)
+	r.images = append(r.images, params)
+	return params, nil
+}
+
+func (r *imageRepo) FindByItemID(ctx context.Context, itemID int64) ([]modelItem.Image, error) {
+	images := []modelItem.Image{}
+	for _, i := range r.images {
+		if i.ItemID == itemID {
+			images = append(images, i)
+		}
+	}
+	sort.Slice(images, func(a, b int) bool { return images[a].Position < images[b].Position })
+	return images, nil
+}
+
+func (r *imageRepo) Reorder(ctx context.Context, itemID int64, ids []int64) error {
+	if len(ids) != len(r.images) {
+		return exception.ErrImageOrder
+	}
+	for position, id := range ids {
+		for n := range r.images {
+			if r.images[n].ID == id {
+				r.images[n].Position = position
+			}
+		}
+	}
+	return nil
+}
+
+func (r *imageRepo) Delete(ctx context.Context, itemID int64, id int64) (modelItem.Image, error) {
+	for n, i := range r.images {
+		if i.ID == id && i.ItemID == itemID {
+			r.images = append(r.images[:n], r.images[n+1:]...)
+			for m := range r.images {
+				if r.images[m].Position > i.Position {
+					r.images[m].Position--
+				}
+			}
+			return i, nil
+		}
+	}
+	return modelItem.Image{}, exception.ErrNotFound
+}
+
+type allowAll struct{}
+
+func (allowAll) Authorize(ctx context.Context, storeID int64, userID int64, p store.Permission) (store.Role, error) {
+	return store.RoleOwner, nil
+}
+
+func (allowAll) Permits(ctx context.Context, storeID int64, p store.Permission) error {
+	return nil
+}
+
+func TestGalleryRoutes(t *testing.T) {
+	jwt.JWT_KEY = []byte("secret-secret-secret-secret-secret")
+	limits := media.Limits{MaxSize: 64 << 10, MaxPixels: 1000 * 1000, ThumbnailSize: 32}
+	blobs := blob.NewLocal(t.TempDir(), "http://localhost/media")
+	images := &imageRepo{}
+	usecase := item.NewItemUseCaseImpl(&itemRepo{}, images, media.NewStorage(blobs, limits))
+
+	router := mux.NewRouter()
+	item.NewItemHandler(router, validator.New(), usecase, allowAll{}, limits)
+
+	signed, err := newJWT.NewWithClaims(newJWT.SigningMethodHS256, &jwt.JWTclaim{UserID: 1, StoreID: 7}).SignedString(jwt.JWT_KEY)
+	require.NoError(t, err)
+	do := func(req *http.Request) (int, json.RawMessage) {
+		req.AddCookie(&http.Cookie{Name: "Store-token", Value: signed})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		var body struct {
+			Data json.RawMessage `json:"data"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		return rec.Code, body.Data
+	}
+	upload := func(path string, data []byte) (int, json.RawMessage) {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("image", "photo.png")
+		part.Write(data)
+		form.Close()
+
+		req := httptest.NewRequest(http.MethodPost, path, &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		return do(req)
+	}
+
+	var photo bytes.Buffer
+	require.NoError(t, png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 64, 48))))
+
+	var added []modelItem.Image
+	for i := 0; i < 3; i++ {
+		code, data := upload("/store/items/3/images", photo.Bytes())
+		require.Equal(t, http.StatusCreated, code)
+		var img modelItem.Image
+		require.NoError(t, json.Unmarshal(data, &img))
+		added = append(added, img)
+	}
+	assert.Equal(t, 2, added[2].Position)
+	assert.True(t, strings.HasPrefix(added[0].URL, "http://localhost/media/items/3/"))
+	assert.True(t, strings.HasSuffix(added[0].ThumbnailURL, "_thumb.png"))
+	assert.Equal(t, 64, added[0].Width)
+
+	code, _ := upload("/store/items/3/images", []byte("#!/bin/sh\necho not an image\n"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, code)
+	code, _ = upload("/store/items/3/images", make([]byte, limits.MaxSize+1))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+	code, _ = upload("/store/items/4/images", photo.Bytes())
+	assert.Equal(t, http.StatusNotFound, code, "items of other stores are not found")
+	assert.Len(t, images.images, 3, "rejected uploads are not added")
+
+	order, _ := json.Marshal(modelItem.ImageOrder{IDs: []int64{added[2].ID, added[0].ID, added[1].ID}})
+	code, data := do(httptest.NewRequest(http.MethodPut, "/store/items/3/images/order", bytes.NewReader(order)))
+	require.Equal(t, http.StatusOK, code)
+	var gallery []modelItem.Image
+	require.NoError(t, json.Unmarshal(data, &gallery))
+	assert.Equal(t, []int64{added[2].ID, added[0].ID, added[1].ID}, []int64{gallery[0].ID, gallery[1].ID, gallery[2].ID})
+
+	code, _ = do(httptest.NewRequest(http.MethodPut, "/store/items/3/images/order", strings.NewReader(`{"ids":[1,1]}`)))
+	assert.Equal(t, http.StatusBadRequest, code, "IDs are listed once")
+
+	key := strings.TrimPrefix(added[0].URL, "http://localhost/media/")
+	code, data = do(httptest.NewRequest(http.MethodDelete, "/store/items/3/images/"+strconv.FormatInt(added[0].ID, 10), nil))
+	require.Equal(t, http.StatusOK, code)
+	require.NoError(t, json.Unmarshal(data, &gallery))
+	assert.Equal(t, []int64{added[2].ID, added[1].ID}, []int64{gallery[0].ID, gallery[1].ID})
+	assert.Equal(t, 1, gallery[1].Position)
+	_, err = blobs.Get(context.Background(), key)
+	assert.True(t, errors.Is(err, blob.ErrNotFound), "the files go with the image")
+}
@@ -1 +1,33 @@
 package item_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Risuii/helpers/constant"
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/internal/item"
+	modelItem "github.com/Risuii/models/item"
+	"github.com/Risuii/tests/mock"
+)
+
+func TestRestockAddsInTheDatabase(t *testing.T) {
+	db, m := mock.NewMock()
+	defer db.Close()
+	repo := item.NewItemRepositoryImpl(db, constant.TableItems)
+
+	restock := regexp.QuoteMeta(`UPDATE items SET quantity = quantity + ?, update_at = ?, version = version + 1 WHERE id = ?`)
+	m.ExpectPrepare(restock)
+	m.ExpectExec(restock).WithArgs(5, sqlmock.AnyArg(), 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	m.ExpectExec(restock).WithArgs(5, sqlmock.AnyArg(), 4).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, repo.UpdateKuantitas(context.Background(), 3, modelItem.Item{Quantity: 5, Version: 1}), "the version read is not checked")
+	err := repo.UpdateKuantitas(context.Background(), 4, modelItem.Item{Quantity: 5})
+	assert.True(t, errors.Is(err, exception.ErrNotFound))
+	assert.NoError(t, m.ExpectationsWereMet())
+}
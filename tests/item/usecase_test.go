@@ -1 +1,69 @@
 package item_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/internal/item"
+	modelItem "github.com/Risuii/models/item"
+)
+
+// shelf holds the items of every store by ID.
+type shelf struct {
+	item.ItemRepository
+	items map[int64]modelItem.Item
+}
+
+func (s *shelf) FindByName(ctx context.Context, storeID int64, name string) (modelItem.Item, error) {
+	for _, i := range s.items {
+		if i.StoreID == storeID && i.Name == name {
+			return i, nil
+		}
+	}
+	return modelItem.Item{}, exception.ErrNotFound
+}
+
+func (s *shelf) FindByIDWithStoreID(ctx context.Context, id int64, storeID int64) (modelItem.Item, error) {
+	i, ok := s.items[id]
+	if !ok || i.StoreID != storeID {
+		return modelItem.Item{}, exception.ErrNotFound
+	}
+	return i, nil
+}
+
+func (s *shelf) UpdateKuantitas(ctx context.Context, id int64, params modelItem.Item) error {
+	i := s.items[id]
+	i.Quantity += params.Quantity
+	i.Version++
+	s.items[id] = i
+	return nil
+}
+
+func (s *shelf) AddItem(ctx context.Context, params modelItem.Item) (int64, error) {
+	params.ID = int64(len(s.items) + 1)
+	s.items[params.ID] = params
+	return params.ID, nil
+}
+
+func TestRestockStaysInTheStore(t *testing.T) {
+	ctx := context.Background()
+	items := &shelf{items: map[int64]modelItem.Item{
+		1: {ID: 1, StoreID: 8, Name: "Kopi", Quantity: 10, Version: 1},
+	}}
+	usecase := item.NewItemUseCaseImpl(items, nil, nil)
+
+	res := usecase.AddItem(ctx, 7, modelItem.Item{Name: "Kopi", Quantity: 5})
+	require.NoError(t, res.Err())
+	assert.Equal(t, int64(10), items.items[1].Quantity, "the item of store 8 is left alone")
+	require.Len(t, items.items, 2)
+	assert.Equal(t, int64(7), items.items[2].StoreID)
+
+	res = usecase.AddItem(ctx, 7, modelItem.Item{Name: "Kopi", Quantity: 3})
+	require.NoError(t, res.Err())
+	assert.Equal(t, int64(8), items.items[2].Quantity)
+	assert.Equal(t, int64(10), items.items[1].Quantity)
+}
@@ -0,0 +1,67 @@
+package mailer_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/helpers/logger"
+	"github.com/Risuii/helpers/mailer"
+)
+
+func TestLogRedactsLinks(t *testing.T) {
+	var out bytes.Buffer
+	ctx := logger.NewContext(context.Background(), logger.New(&out, slog.LevelInfo))
+
+	err := mailer.NewLog().Send(ctx, mailer.Message{
+		To:      "sari@example.com",
+		Subject: "Verify your email",
+		Body:    "Open https://shop.example.com/verify-email?token=secret-token to verify.",
+	})
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "https://shop.example.com/verify-email?REDACTED to verify.")
+	assert.NotContains(t, out.String(), "secret-token")
+
+	out.Reset()
+	ctx = logger.NewContext(context.Background(), logger.New(&out, slog.LevelDebug))
+	require.NoError(t, mailer.NewLog().Send(ctx, mailer.Message{Body: "?token=secret-token"}))
+	assert.Contains(t, out.String(), "secret-token", "the body is logged as it is at DEBUG")
+}
+
+func TestSMTPTimesOut(t *testing.T) {
+	// The server accepts the connection but never greets.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	smtp := mailer.NewSMTP(host, port, "", "", "no-reply@example.com", 50*time.Millisecond)
+
+	start := time.Now()
+	err = smtp.Send(context.Background(), mailer.Message{To: "sari@example.com"})
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	smtp = mailer.NewSMTP(host, port, "", "", "no-reply@example.com", time.Minute)
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start = time.Now()
+	err = smtp.Send(ctx, mailer.Message{To: "sari@example.com"})
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second, "a cancelled request stops the send")
+}
@@ -0,0 +1,175 @@
+package media_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/helpers/blob"
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/media"
+)
+
+var limits = media.Limits{MaxSize: 1 << 20, MaxPixels: 1000 * 1000, ThumbnailSize: 100}
+
+func pngOf(t *testing.T, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestDecodeSniffsTheContent(t *testing.T) {
+	img, err := media.Decode(pngOf(t, 40, 30), limits)
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", img.ContentType)
+	assert.Equal(t, ".png", img.Ext)
+	assert.Equal(t, 40, img.Width)
+	assert.Equal(t, 30, img.Height)
+
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8)), nil))
+	img, err = media.Decode(buf.Bytes(), limits)
+	require.NoError(t, err)
+	assert.Equal(t, ".jpg", img.Ext)
+
+	buf.Reset()
+	require.NoError(t, gif.Encode(&buf, image.NewPaletted(image.Rect(0, 0, 8, 8), color.Palette{color.White, color.Black}), nil))
+	img, err = media.Decode(buf.Bytes(), limits)
+	require.NoError(t, err)
+	assert.Equal(t, "image/gif", img.ContentType)
+
+	for name, data := range map[string][]byte{
+		"text":      []byte("<svg xmlns='http://www.w3.org/2000/svg'></svg>"),
+		"truncated": pngOf(t, 40, 30)[:60],
+		"empty":     nil,
+	} {
+		_, err := media.Decode(data, limits)
+		assert.True(t, errors.Is(err, exception.ErrUnsupportedImage), name)
+	}
+}
+
+func TestDecodeChecksThePixelsFirst(t *testing.T) {
+	_, err := media.Decode(pngOf(t, 1001, 1000), limits)
+	assert.True(t, errors.Is(err, exception.ErrUnsupportedImage))
+	assert.Equal(t, http.StatusUnsupportedMediaType, exception.KindOf(err).HTTPStatus())
+}
+
+func TestThumbnail(t *testing.T) {
+	img, err := media.Decode(pngOf(t, 400, 200), limits)
+	require.NoError(t, err)
+
+	thumb, err := img.Thumbnail(100)
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", thumb.ContentType)
+	assert.Equal(t, 100, thumb.Width)
+	assert.Equal(t, 50, thumb.Height)
+
+	decoded, err := png.Decode(bytes.NewReader(thumb.Data))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 100, 50), decoded.Bounds())
+
+	small := image.NewRGBA(image.Rect(0, 0, 10, 20))
+	assert.Same(t, small, media.Fit(small, 100), "small images are not enlarged")
+
+	// Every pixel of the thumbnail is the average of the square it covers.
+	checker := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for i := 0; i < 16; i++ {
+		if (i%4+i/4)%2 == 0 {
+			checker.Set(i%4, i/4, color.White)
+		} else {
+			checker.Set(i%4, i/4, color.Black)
+		}
+	}
+	r, g, b, _ := media.Fit(checker, 2).At(1, 1).RGBA()
+	assert.Equal(t, []uint32{0x8080, 0x8080, 0x8080}, []uint32{r, g, b})
+}
+
+func upload(t *testing.T, field string, data []byte) *http.Request {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	require.NoError(t, form.WriteField("caption", "ignored"))
+	part, err := form.CreateFormFile(field, "photo.png")
+	require.NoError(t, err)
+	part.Write(data)
+	require.NoError(t, form.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return req
+}
+
+func TestRead(t *testing.T) {
+	data := pngOf(t, 10, 10)
+	got, err := media.Read(httptest.NewRecorder(), upload(t, "image", data), "image", limits)
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+
+	_, err = media.Read(httptest.NewRecorder(), upload(t, "other", data), "image", limits)
+	assert.True(t, errors.Is(err, exception.ErrValidation))
+
+	_, err = media.Read(httptest.NewRecorder(), upload(t, "image", make([]byte, limits.MaxSize+1)), "image", limits)
+	assert.True(t, errors.Is(err, exception.ErrFileTooLarge))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, exception.KindOf(err).HTTPStatus())
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	_, err = media.Read(httptest.NewRecorder(), req, "image", limits)
+	assert.True(t, errors.Is(err, exception.ErrBadRequest))
+}
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	blobs := blob.NewLocal(t.TempDir(), "http://localhost/media")
+	storage := media.NewStorage(blobs, limits)
+
+	saved, err := storage.Save(ctx, "items/7", pngOf(t, 300, 150), true)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(saved.Key, "items/7/") && strings.HasSuffix(saved.Key, ".png"))
+	assert.Equal(t, strings.TrimSuffix(saved.Key, ".png")+"_thumb.png", saved.ThumbnailKey)
+	assert.Equal(t, 300, saved.Width)
+
+	for _, key := range []string{saved.Key, saved.ThumbnailKey} {
+		body, err := blobs.Get(ctx, key)
+		require.NoError(t, err)
+		data, _ := io.ReadAll(body)
+		body.Close()
+		assert.Equal(t, "image/png", http.DetectContentType(data))
+	}
+
+	other, err := storage.Save(ctx, "items/7", pngOf(t, 300, 150), false)
+	require.NoError(t, err)
+	assert.NotEqual(t, saved.Key, other.Key, "every upload gets a key of its own")
+	assert.Empty(t, other.ThumbnailKey)
+
+	storage.DeleteURL(ctx, "items/8", storage.URL(other.Key))
+	_, err = blobs.Get(ctx, other.Key)
+	assert.NoError(t, err, "files outside the directory are left alone")
+
+	storage.DeleteURL(ctx, "items/7", storage.URL(other.Key))
+	_, err = blobs.Get(ctx, other.Key)
+	assert.True(t, errors.Is(err, blob.ErrNotFound))
+
+	_, err = storage.Save(ctx, "items/7", []byte("GIF87a but not really"), true)
+	assert.True(t, errors.Is(err, exception.ErrUnsupportedImage))
+}
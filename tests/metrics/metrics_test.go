@@ -0,0 +1,64 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/helpers/metrics"
+	"github.com/Risuii/tests/mock"
+)
+
+func scrape(t *testing.T) string {
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	return rec.Body.String()
+}
+
+func TestMiddlewareLabelsByRouteTemplate(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(metrics.Middleware)
+	router.HandleFunc("/metrics-test/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "404" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}).Methods(http.MethodGet)
+
+	for _, path := range []string{"/metrics-test/items/1", "/metrics-test/items/2", "/metrics-test/items/404"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	body := scrape(t)
+	assert.Contains(t, body, `ecommerce_http_requests_total{method="GET",route="/metrics-test/items/{id}",status="200"} 2`)
+	assert.Contains(t, body, `ecommerce_http_requests_total{method="GET",route="/metrics-test/items/{id}",status="404"} 1`)
+	assert.Contains(t, body, `ecommerce_http_request_duration_seconds_count{method="GET",route="/metrics-test/items/{id}"} 3`)
+	assert.NotContains(t, body, `route="/metrics-test/items/1"`, "IDs never become labels")
+}
+
+func TestBusinessCounters(t *testing.T) {
+	metrics.Orders.Inc()
+	metrics.Logins.WithLabelValues("success").Inc()
+
+	body := scrape(t)
+	assert.Contains(t, body, "ecommerce_orders_total 1")
+	assert.Contains(t, body, `ecommerce_account_logins_total{result="success"} 1`)
+}
+
+func TestRegisterDB(t *testing.T) {
+	db, _ := mock.NewMock()
+	defer db.Close()
+	db.SetMaxOpenConns(7)
+
+	metrics.RegisterDB(db, "metrics_test")
+
+	body := scrape(t)
+	assert.Contains(t, body, `go_sql_max_open_connections{db_name="metrics_test"} 7`)
+	assert.Contains(t, body, `go_sql_open_connections{db_name="metrics_test"}`)
+}
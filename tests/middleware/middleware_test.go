@@ -0,0 +1,61 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/helpers/middleware"
+	"github.com/Risuii/helpers/requestid"
+	"github.com/Risuii/helpers/response"
+)
+
+func serve(h http.HandlerFunc) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(requestid.Header, "req-1")
+	w := httptest.NewRecorder()
+	middleware.Chain(h, requestid.Middleware, middleware.Recover).ServeHTTP(w, r)
+	return w
+}
+
+func TestRecover(t *testing.T) {
+	w := serve(func(w http.ResponseWriter, r *http.Request) {
+		var m map[string]int
+		m["boom"]++
+	})
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "req-1", w.Header().Get(requestid.Header))
+
+	var body struct {
+		Error *response.ErrorBody `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.NotNil(t, body.Error)
+	assert.Equal(t, "INTERNAL_SERVER_ERROR", body.Error.Code)
+	assert.Equal(t, "req-1", body.Error.RequestID)
+	assert.NotContains(t, w.Body.String(), "nil map")
+}
+
+func TestRecoverAfterTheHeaderWasSent(t *testing.T) {
+	w := serve(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("partial"))
+		panic("boom")
+	})
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "partial", w.Body.String(), "nothing is appended to a started response")
+}
+
+func TestRecoverRepanicsAbort(t *testing.T) {
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		serve(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		})
+	})
+}
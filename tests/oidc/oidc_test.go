@@ -0,0 +1,313 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/helpers/oidc"
+	"github.com/Risuii/helpers/response"
+	"github.com/Risuii/internal/account"
+	"github.com/Risuii/models/token"
+)
+
+// mockProvider is a minimal OpenID Connect provider. Its authorize
+// endpoint logs in immediately and sends the browser back with a code.
+type mockProvider struct {
+	*httptest.Server
+	t *testing.T
+
+	mu     sync.Mutex
+	key    *rsa.PrivateKey
+	kid    string
+	grants map[string]grant
+	// claims are added to, and override, the claims of issued ID tokens.
+	claims jwtgo.MapClaims
+}
+
+type grant struct {
+	challenge   string
+	nonce       string
+	redirectURI string
+}
+
+const (
+	clientID     = "shop"
+	clientSecret = "shop-secret"
+)
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	m := &mockProvider{t: t, key: key, kid: "key-1", grants: map[string]grant{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("/jwks", m.jwks)
+	mux.HandleFunc("/authorize", m.authorize)
+	mux.HandleFunc("/token", m.token)
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+
+	return m
+}
+
+func (m *mockProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"issuer":                                m.URL,
+		"authorization_endpoint":                m.URL + "/authorize",
+		"token_endpoint":                        m.URL + "/token",
+		"jwks_uri":                              m.URL + "/jwks",
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (m *mockProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": m.kid,
+			"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (m *mockProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	assert.Equal(m.t, "code", q.Get("response_type"))
+	assert.Equal(m.t, clientID, q.Get("client_id"))
+	assert.Equal(m.t, "S256", q.Get("code_challenge_method"))
+	assert.Contains(m.t, q.Get("scope"), "openid")
+
+	code := "code-" + q.Get("state")
+	m.mu.Lock()
+	m.grants[code] = grant{challenge: q.Get("code_challenge"), nonce: q.Get("nonce"), redirectURI: q.Get("redirect_uri")}
+	m.mu.Unlock()
+
+	target, _ := url.Parse(q.Get("redirect_uri"))
+	target.RawQuery = url.Values{"code": {code}, "state": {q.Get("state")}}.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+func (m *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	fail := func(code string) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": code})
+	}
+
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != clientID || secret != clientSecret {
+		fail("invalid_client")
+		return
+	}
+
+	m.mu.Lock()
+	g, found := m.grants[r.PostFormValue("code")]
+	delete(m.grants, r.PostFormValue("code"))
+	m.mu.Unlock()
+
+	switch {
+	case !found, r.PostFormValue("grant_type") != "authorization_code", r.PostFormValue("redirect_uri") != g.redirectURI:
+		fail("invalid_grant")
+		return
+	case oidc.Challenge(r.PostFormValue("code_verifier")) != g.challenge:
+		fail("invalid_grant")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"id_token":     m.idToken(g.nonce),
+	})
+}
+
+func (m *mockProvider) idToken(nonce string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	claims := jwtgo.MapClaims{
+		"iss":            m.URL,
+		"sub":            "subject-1",
+		"aud":            clientID,
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          nonce,
+		"email":          "sari@example.com",
+		"email_verified": true,
+		"name":           "Sari",
+	}
+	for k, v := range m.claims {
+		claims[k] = v
+	}
+
+	tok := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, claims)
+	tok.Header["kid"] = m.kid
+	signed, err := tok.SignedString(m.key)
+	require.NoError(m.t, err)
+
+	return signed
+}
+
+func (m *mockProvider) provider(redirectURL string) *oidc.Provider {
+	return oidc.New(oidc.Config{
+		Name:         "mock",
+		Issuer:       m.URL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"openid", "email", "profile"},
+	}, m.Client())
+}
+
+func TestVerify(t *testing.T) {
+	m := newMockProvider(t)
+	p := m.provider("http://shop.example.com/auth/mock/callback")
+	ctx := context.Background()
+
+	claims, err := p.Verify(ctx, m.idToken("nonce-1"), "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, oidc.Claims{Subject: "subject-1", Email: "sari@example.com", EmailVerified: true, Name: "Sari"}, claims)
+
+	_, err = p.Verify(ctx, m.idToken("nonce-1"), "nonce-2")
+	assert.Error(t, err, "nonce mismatch")
+
+	bad := []jwtgo.MapClaims{
+		{"iss": "https://evil.example.com"},
+		{"aud": "someone-else"},
+		{"aud": []string{clientID, "someone-else"}},
+		{"exp": time.Now().Add(-time.Hour).Unix()},
+		{"sub": ""},
+	}
+	for _, override := range bad {
+		m.claims = override
+		_, err := p.Verify(ctx, m.idToken("nonce-1"), "nonce-1")
+		assert.Error(t, err, "%v", override)
+	}
+
+	m.claims = jwtgo.MapClaims{"aud": []string{clientID, "someone-else"}, "azp": clientID, "email_verified": "true"}
+	claims, err = p.Verify(ctx, m.idToken("nonce-1"), "nonce-1")
+	require.NoError(t, err, "several audiences are fine when this client is the authorized party")
+	assert.True(t, claims.EmailVerified)
+	m.claims = nil
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	forged := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, jwtgo.MapClaims{
+		"iss": m.URL, "sub": "subject-1", "aud": clientID, "nonce": "nonce-1",
+		"exp": time.Now().Add(time.Minute).Unix(), "iat": time.Now().Unix(),
+	})
+	forged.Header["kid"] = m.kid
+	raw, err := forged.SignedString(other)
+	require.NoError(t, err)
+	_, err = p.Verify(ctx, raw, "nonce-1")
+	assert.Error(t, err, "the signature is checked")
+
+	hs := jwtgo.NewWithClaims(jwtgo.SigningMethodHS256, jwtgo.MapClaims{"iss": m.URL, "sub": "subject-1", "aud": clientID, "nonce": "nonce-1"})
+	raw, err = hs.SignedString([]byte("secret"))
+	require.NoError(t, err)
+	_, err = p.Verify(ctx, raw, "nonce-1")
+	assert.Error(t, err, "only RS256 is accepted")
+}
+
+func TestExchangeRequiresTheVerifier(t *testing.T) {
+	m := newMockProvider(t)
+	p := m.provider("http://shop.example.com/auth/mock/callback")
+	ctx := context.Background()
+
+	verifier, err := oidc.NewVerifier()
+	require.NoError(t, err)
+	authURL, err := p.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+	require.NoError(t, err)
+
+	client := m.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	res, err := client.Get(authURL)
+	require.NoError(t, err)
+	res.Body.Close()
+	location, err := url.Parse(res.Header.Get("Location"))
+	require.NoError(t, err)
+	code := location.Query().Get("code")
+
+	_, err = p.Exchange(ctx, code, "not-the-verifier", "nonce-1")
+	assert.Error(t, err, "PKCE rejects a stolen code without the verifier")
+}
+
+// ssoUseCase records the identities the handler logs in.
+type ssoUseCase struct {
+	account.AccountUseCase
+	provider string
+	claims   oidc.Claims
+}
+
+func (u *ssoUseCase) LoginSSO(ctx context.Context, provider string, claims oidc.Claims) (response.Response, token.Token) {
+	u.provider, u.claims = provider, claims
+	return response.Success(response.StatusOK, "ok"), token.Token{Token: "session"}
+}
+
+func TestLoginFlow(t *testing.T) {
+	m := newMockProvider(t)
+	usecase := &ssoUseCase{}
+
+	router := mux.NewRouter()
+	app := httptest.NewServer(router)
+	t.Cleanup(app.Close)
+	account.NewSSOHandler(router, usecase, []*oidc.Provider{m.provider(app.URL + "/auth/mock/callback")}, []byte("secret-secret-secret-secret-secret"), time.Minute)
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	browser := &http.Client{Jar: jar}
+
+	res, err := browser.Get(app.URL + "/auth/mock/login")
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "/auth/mock/callback", res.Request.URL.Path, "the provider sent the browser back")
+
+	assert.Equal(t, "mock", usecase.provider)
+	assert.Equal(t, "subject-1", usecase.claims.Subject)
+	assert.Equal(t, "sari@example.com", usecase.claims.Email)
+
+	appURL, _ := url.Parse(app.URL)
+	var session string
+	for _, c := range jar.Cookies(appURL) {
+		if c.Name == "token" {
+			session = c.Value
+		}
+	}
+	assert.Equal(t, "session", session)
+
+	// Replaying the callback fails: the state cookie is gone and the code
+	// was used.
+	res, err = browser.Get(res.Request.URL.String())
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	res, err = browser.Get(app.URL + "/auth/unknown/login")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
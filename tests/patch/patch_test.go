@@ -0,0 +1,100 @@
+package patch_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/patch"
+)
+
+type location struct {
+	City   patch.String `json:"city"`
+	Street patch.String `json:"street" patch:"nullable"`
+}
+
+type storePatch struct {
+	Name        patch.String  `json:"name" validate:"omitempty,min=3"`
+	Description patch.String  `json:"description" patch:"nullable"`
+	Rating      patch.Float64 `json:"rating" patch:"nullable"`
+	Location    location      `json:"location"`
+}
+
+func decode(t *testing.T, body string) (storePatch, error) {
+	var p storePatch
+	err := patch.Decode(strings.NewReader(body), &p)
+	return p, err
+}
+
+func TestAbsentNullAndValue(t *testing.T) {
+	p, err := decode(t, `{"name": "Toko Budi", "description": null}`)
+	require.NoError(t, err)
+
+	assert.Equal(t, patch.String{Value: "Toko Budi", Set: true}, p.Name)
+	assert.Equal(t, patch.String{Set: true, Null: true}, p.Description)
+	assert.Equal(t, patch.Float64{}, p.Rating, "absent members are not set")
+
+	name, description, rating := "old", "old", 4.5
+	p.Name.Apply(&name)
+	p.Description.Apply(&description)
+	p.Rating.Apply(&rating)
+	assert.Equal(t, "Toko Budi", name)
+	assert.Equal(t, "", description, "null resets to the zero value")
+	assert.Equal(t, 4.5, rating, "absent members are left alone")
+}
+
+func TestApplyPtr(t *testing.T) {
+	old := 4.5
+
+	rating := &old
+	patch.Float64{}.ApplyPtr(&rating)
+	assert.Same(t, &old, rating)
+
+	patch.Float64{Set: true, Null: true}.ApplyPtr(&rating)
+	assert.Nil(t, rating)
+
+	patch.Float64{Value: 3, Set: true}.ApplyPtr(&rating)
+	require.NotNil(t, rating)
+	assert.Equal(t, 3.0, *rating)
+}
+
+func TestNullIsOnlyAllowedWhenNullable(t *testing.T) {
+	_, err := decode(t, `{"name": null, "location": {"city": null, "street": null}}`)
+	require.True(t, errors.Is(err, exception.ErrValidation))
+
+	fields := err.(*exception.Error).Fields
+	require.Len(t, fields, 2)
+	assert.Equal(t, "name", fields[0].Field)
+	assert.Equal(t, "notnull", fields[0].Tag)
+	assert.Equal(t, "location.city", fields[1].Field)
+	assert.Equal(t, "notnull", fields[1].Tag)
+}
+
+func TestDecodeRejectsWhatIsNotAnObject(t *testing.T) {
+	for _, body := range []string{`null`, `[]`, `"name"`, `{"name": `, `{"name": 7}`} {
+		_, err := decode(t, body)
+		assert.True(t, errors.Is(err, exception.ErrUnprocessableEntity), body)
+	}
+}
+
+func TestValidatorSeesTheValue(t *testing.T) {
+	v := validator.New()
+	patch.RegisterValidator(v)
+
+	for body, valid := range map[string]bool{
+		`{}`:                 true,
+		`{"name": "Toko"}`:   true,
+		`{"name": "To"}`:     false,
+		`{"rating": null}`:   true,
+		`{"name": "Budi's"}`: true,
+	} {
+		p, err := decode(t, body)
+		require.NoError(t, err, body)
+		assert.Equal(t, valid, v.Struct(p) == nil, body)
+	}
+}
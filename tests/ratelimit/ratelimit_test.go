@@ -0,0 +1,139 @@
+package ratelimit_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gorilla/mux"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/ratelimit"
+)
+
+func stores(t *testing.T) map[string]ratelimit.Store {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return map[string]ratelimit.Store{
+		"memory": ratelimit.NewMemoryStore(),
+		"redis":  ratelimit.NewRedisStore(client, "test:"),
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			limit := ratelimit.Limit{Burst: 3, Per: time.Minute}
+
+			for i := 0; i < 3; i++ {
+				ok, _, err := store.Take(ctx, "k", limit)
+				require.NoError(t, err)
+				assert.True(t, ok, "burst request %d", i)
+			}
+
+			ok, retryAfter, err := store.Take(ctx, "k", limit)
+			require.NoError(t, err)
+			assert.False(t, ok)
+			assert.InDelta(t, 20*time.Second, retryAfter, float64(time.Second), "one token refills every 20s")
+
+			ok, _, err = store.Take(ctx, "other", limit)
+			require.NoError(t, err)
+			assert.True(t, ok, "buckets are per key")
+		})
+	}
+}
+
+func TestLockout(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			lockout := ratelimit.NewLockout(store, 3, time.Minute)
+
+			require.NoError(t, lockout.Fail(ctx, "a@example.com"))
+			require.NoError(t, lockout.Fail(ctx, "a@example.com"))
+			require.NoError(t, lockout.Check(ctx, "a@example.com"))
+
+			err := lockout.Fail(ctx, "A@example.com")
+			assert.True(t, errors.Is(err, exception.ErrAccountLocked), "the third failure locks, emails are case-insensitive")
+
+			err = lockout.Check(ctx, "a@example.com")
+			require.True(t, errors.Is(err, exception.ErrAccountLocked))
+			assert.Greater(t, exception.From(err).RetryAfter, 50*time.Second)
+
+			lockout.Reset(ctx, "a@example.com")
+			assert.NoError(t, lockout.Check(ctx, "a@example.com"))
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	router := mux.NewRouter()
+	router.Use(ratelimit.Middleware(map[string][]ratelimit.Rule{
+		"/login": {
+			{Limiter: ratelimit.NewLimiter(store, "login_account", ratelimit.Limit{Burst: 1, Per: time.Minute}), Key: ratelimit.ByJSONField("email")},
+		},
+		"/healthz": nil,
+		ratelimit.DefaultRoute: {
+			{Limiter: ratelimit.NewLimiter(store, "default_ip", ratelimit.Limit{Burst: 2, Per: time.Minute}), Key: ratelimit.ByIP},
+		},
+	}))
+
+	var body string
+	router.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		body = string(raw)
+	}).Methods(http.MethodPost)
+	router.HandleFunc("/items", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+	router.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+
+	login := func(email string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email":"`+email+`","password":"x"}`))
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, login("a@example.com").Code)
+	assert.Contains(t, body, `"email":"a@example.com"`, "the body is left for the handler")
+	assert.Equal(t, http.StatusOK, login("b@example.com").Code, "the login route has its own rules")
+
+	rec := login("A@example.com")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+
+	var envelope struct {
+		Status string `json:"status"`
+		Error  struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&envelope))
+	assert.Equal(t, "TOO_MANY_REQUESTS", envelope.Status)
+	assert.Equal(t, "RATE_LIMITED", envelope.Error.Code)
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items", nil))
+		assert.Equal(t, want, rec.Code, "request %d", i)
+	}
+
+	for i := 0; i < 5; i++ {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		assert.Equal(t, http.StatusOK, rec.Code, "routes without rules are not limited")
+	}
+}
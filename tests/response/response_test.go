@@ -0,0 +1,83 @@
+package response_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/requestid"
+	"github.com/Risuii/helpers/response"
+)
+
+type envelope struct {
+	Status string              `json:"status"`
+	Data   interface{}         `json:"data"`
+	Error  *response.ErrorBody `json:"error"`
+}
+
+func send(t *testing.T, res response.Response) (*httptest.ResponseRecorder, envelope) {
+	w := httptest.NewRecorder()
+	w.Header().Set(requestid.Header, "req-1")
+	require.NoError(t, res.JSON(w))
+
+	var body envelope
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return w, body
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	err := exception.ErrValidation.WithFields(exception.FieldError{
+		Field:   "name",
+		Tag:     "required",
+		Message: "name is required",
+	})
+
+	w, body := send(t, response.Fail(err).WithETag(`"3"`))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Empty(t, w.Header().Get("ETag"), "errors carry no entity tag")
+	assert.Nil(t, body.Data)
+	require.NotNil(t, body.Error)
+	assert.Equal(t, "VALIDATION_FAILED", body.Error.Code)
+	assert.Equal(t, "req-1", body.Error.RequestID)
+	require.Len(t, body.Error.Fields, 1)
+	assert.Equal(t, "name", body.Error.Fields[0].Field)
+	assert.Equal(t, "required", body.Error.Fields[0].Tag)
+}
+
+func TestErrorEnvelopeHidesTheCause(t *testing.T) {
+	err := exception.ErrInternalServer.Wrap(errors.New("dial tcp 10.0.0.1:3306: connection refused"))
+
+	w, body := send(t, response.Fail(err))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "INTERNAL_SERVER_ERROR", body.Error.Code)
+	assert.NotContains(t, w.Body.String(), "10.0.0.1")
+	assert.NotContains(t, w.Body.String(), "fields")
+}
+
+func TestErrorEnvelopeRetryAfter(t *testing.T) {
+	w, body := send(t, response.Fail(exception.ErrTooManyRequests.WithRetryAfter(1500*time.Millisecond)))
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"), "rounded up to whole seconds")
+	assert.Equal(t, "RATE_LIMITED", body.Error.Code)
+}
+
+func TestSuccessEnvelope(t *testing.T) {
+	w, body := send(t, response.Success(response.StatusOK, map[string]int{"id": 7}).WithETag(`"3"`))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+	assert.Nil(t, body.Error)
+	assert.NotContains(t, w.Body.String(), `"error"`)
+	assert.Equal(t, map[string]interface{}{"id": float64(7)}, body.Data)
+}
@@ -0,0 +1,55 @@
+package retry_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Risuii/helpers/retry"
+)
+
+func TestTransient(t *testing.T) {
+	for _, err := range []error{
+		driver.ErrBadConn,
+		fmt.Errorf("query: %w", driver.ErrBadConn),
+		&mysql.MySQLError{Number: 1213, Message: "Deadlock found"},
+		&mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"},
+		&net.OpError{Op: "dial", Err: errors.New("connection refused")},
+	} {
+		assert.True(t, retry.Transient(err), err.Error())
+	}
+
+	for _, err := range []error{
+		mysql.ErrInvalidConn,
+		&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"},
+		&net.OpError{Op: "read", Err: errors.New("connection reset by peer")},
+		context.DeadlineExceeded,
+		errors.New("boom"),
+	} {
+		assert.False(t, retry.Transient(err), err.Error())
+	}
+}
+
+func TestFinal(t *testing.T) {
+	policy := retry.Policy{Attempts: 3, Initial: time.Millisecond, Max: time.Millisecond, Retryable: retry.Transient}
+
+	calls := 0
+	err := policy.Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		if calls == 1 {
+			return driver.ErrBadConn
+		}
+		return retry.Final(driver.ErrBadConn)
+	})
+	assert.Equal(t, 2, calls, "a failed Commit is not run again")
+	assert.Equal(t, driver.ErrBadConn, err, "the error is returned unwrapped")
+
+	assert.NoError(t, retry.Final(nil))
+}
@@ -0,0 +1,64 @@
+package stmtcache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/helpers/stmtcache"
+	"github.com/Risuii/tests/mock"
+)
+
+func TestRelease(t *testing.T) {
+	ctx := context.Background()
+	db, mockDB := mock.NewMock()
+	defer db.Close()
+
+	mockDB.ExpectPrepare("SELECT id FROM items").WillBeClosed()
+	mockDB.ExpectPrepare("SELECT id FROM stores").WillBeClosed()
+
+	items, stores := stmtcache.For(db), stmtcache.For(db)
+	assert.Same(t, items, stores, "repositories of one database share a cache")
+
+	_, err := items.Prepare(ctx, "SELECT id FROM items")
+	require.NoError(t, err)
+	_, err = stores.Prepare(ctx, "SELECT id FROM stores")
+	require.NoError(t, err)
+
+	require.NoError(t, stmtcache.Release(db))
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+	assert.NotSame(t, items, stmtcache.For(db), "a released cache is forgotten")
+	assert.NoError(t, stmtcache.Release(db))
+}
+
+func TestSlowPrepareDoesNotBlockCachedQueries(t *testing.T) {
+	ctx := context.Background()
+	db, mockDB := mock.NewMock()
+	defer db.Close()
+	cache := stmtcache.New(db)
+
+	mockDB.ExpectPrepare("SELECT id FROM items")
+	mockDB.ExpectPrepare("SELECT id FROM stores").WillDelayFor(time.Second)
+
+	_, err := cache.Prepare(ctx, "SELECT id FROM items")
+	require.NoError(t, err)
+
+	slow := make(chan error)
+	go func() {
+		_, err := cache.Prepare(ctx, "SELECT id FROM stores")
+		slow <- err
+	}()
+
+	// Give the slow prepare time to reach the database.
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	_, err = cache.Prepare(ctx, "SELECT id FROM items")
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	require.NoError(t, <-slow)
+	assert.NoError(t, mockDB.ExpectationsWereMet())
+}
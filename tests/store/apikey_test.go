@@ -0,0 +1,220 @@
+package store_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/helpers/apikey"
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/media"
+	"github.com/Risuii/helpers/response"
+	"github.com/Risuii/internal/item"
+	"github.com/Risuii/internal/store"
+	modelItem "github.com/Risuii/models/item"
+	modelStore "github.com/Risuii/models/store"
+)
+
+type storeRepo struct {
+	store.StoreRepository
+	stores map[int64]modelStore.Store
+}
+
+func (r *storeRepo) FindByID(ctx context.Context, id int64) (modelStore.Store, error) {
+	s, ok := r.stores[id]
+	if !ok {
+		return s, exception.ErrNotFound
+	}
+	return s, nil
+}
+
+type keyRepo struct {
+	keys    map[int64]modelStore.APIKey
+	touches int
+}
+
+func newKeyRepo() *keyRepo {
+	return &keyRepo{keys: map[int64]modelStore.APIKey{}}
+}
+
+func (r *keyRepo) Create(ctx context.Context, params modelStore.APIKey) (int64, error) {
+	params.ID = int64(len(r.keys) + 1)
+	r.keys[params.ID] = params
+	return params.ID, nil
+}
+
+func (r *keyRepo) FindByHash(ctx context.Context, keyHash string) (modelStore.APIKey, error) {
+	for _, key := range r.keys {
+		if key.KeyHash == keyHash {
+			return key, nil
+		}
+	}
+	return modelStore.APIKey{}, exception.ErrNotFound
+}
+
+func (r *keyRepo) FindByStoreID(ctx context.Context, storeID int64) ([]modelStore.APIKey, error) {
+	keys := []modelStore.APIKey{}
+	for _, key := range r.keys {
+		if key.StoreID == storeID {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (r *keyRepo) Touch(ctx context.Context, id int64, usedAt time.Time) error {
+	key := r.keys[id]
+	key.LastUsedAt = &usedAt
+	r.keys[id] = key
+	r.touches++
+	return nil
+}
+
+func (r *keyRepo) Delete(ctx context.Context, storeID int64, id int64) error {
+	if key, ok := r.keys[id]; !ok || key.StoreID != storeID {
+		return exception.ErrNotFound
+	}
+	delete(r.keys, id)
+	return nil
+}
+
+func data(res response.Response) interface{} {
+	return res.(*response.ResponseImpl).Data
+}
+
+func TestAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	keys := newKeyRepo()
+	stores := &storeRepo{stores: map[int64]modelStore.Store{7: {ID: 7, UserID: 1}}}
+	usecase := store.NewStoreUseCaseImpl(stores, nil, keys, newMemberRepo(), nil, nil, nil, nil, uploads)
+
+	res := usecase.CreateAPIKey(ctx, 7, 1, modelStore.APIKeyCreate{
+		Name:   "ERP",
+		Scopes: []string{apikey.ScopeItemsWrite, apikey.ScopeItemsRead, apikey.ScopeItemsRead},
+	})
+	require.NoError(t, res.Err())
+	created := data(res).(modelStore.NewAPIKey)
+
+	assert.True(t, strings.HasPrefix(created.Key, apikey.Prefix))
+	assert.True(t, strings.HasPrefix(created.Key, created.Hint))
+	assert.Equal(t, []string{apikey.ScopeItemsRead, apikey.ScopeItemsWrite}, created.Scopes)
+
+	stored := keys.keys[created.ID]
+	assert.Equal(t, apikey.Hash(created.Key), stored.KeyHash, "only the hash is stored")
+	assert.NotContains(t, stored.KeyHash, created.Key)
+
+	body, err := json.Marshal(data(usecase.ListAPIKeys(ctx, 7, 1)))
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), stored.KeyHash)
+	assert.NotContains(t, string(body), created.Key)
+	assert.Contains(t, string(body), created.Hint)
+
+	res = usecase.CreateAPIKey(ctx, 7, 2, modelStore.APIKeyCreate{Name: "ERP", Scopes: []string{apikey.ScopeItemsRead}})
+	assert.True(t, errors.Is(res.Err(), exception.ErrNotFound), "only the owner manages keys")
+	assert.True(t, errors.Is(usecase.RevokeAPIKey(ctx, 7, 2, created.ID).Err(), exception.ErrNotFound))
+
+	past := time.Now().Add(-time.Hour)
+	res = usecase.CreateAPIKey(ctx, 7, 1, modelStore.APIKeyCreate{Name: "ERP", Scopes: []string{apikey.ScopeItemsRead}, ExpiresAt: &past})
+	assert.True(t, errors.Is(res.Err(), exception.ErrValidation))
+
+	require.NoError(t, usecase.RevokeAPIKey(ctx, 7, 1, created.ID).Err())
+	assert.Empty(t, keys.keys)
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	err := validator.New().Struct(modelStore.APIKeyCreate{Name: "ERP", Scopes: []string{"items:delete"}})
+	assert.Error(t, err)
+}
+
+// itemUseCase records the store the handlers act for.
+type itemUseCase struct {
+	item.ItemUseCase
+	storeID int64
+}
+
+func (u *itemUseCase) GetOneItem(ctx context.Context, id int64, storeID int64) response.Response {
+	u.storeID = storeID
+	return response.Success(response.StatusOK, modelItem.Item{ID: id, StoreID: storeID})
+}
+
+func (u *itemUseCase) DeleteItem(ctx context.Context, id int64, storeID int64, version int64) response.Response {
+	u.storeID = storeID
+	return response.Success(response.StatusOK, "deleted")
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	keys := newKeyRepo()
+	items := &itemUseCase{}
+
+	router := mux.NewRouter()
+	router.Use(store.NewAPIKeyAuth(keys))
+	stores := &storeRepo{stores: map[int64]modelStore.Store{7: {ID: 7, UserID: 1, Status: modelStore.StatusActive}}}
+	item.NewItemHandler(router, validator.New(), items, store.NewAccess(stores, newMemberRepo()), media.Limits{})
+
+	newKey := func(expiresAt *time.Time, scopes ...string) string {
+		key, hint, hash, err := apikey.Generate()
+		require.NoError(t, err)
+		keys.Create(context.Background(), modelStore.APIKey{StoreID: 7, Hint: hint, KeyHash: hash, Scopes: scopes, ExpiresAt: expiresAt})
+		return key
+	}
+	do := func(method, authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/store/items/3", nil)
+		req.Header.Set("If-Match", `"1"`)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	code := func(rec *httptest.ResponseRecorder) string {
+		var body response.ResponseImpl
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		if body.Error == nil {
+			return ""
+		}
+		return body.Error.Code
+	}
+
+	reader := newKey(nil, apikey.ScopeItemsRead)
+	rec := do(http.MethodGet, "Bearer "+reader)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, int64(7), items.storeID, "the key acts for its store")
+
+	rec = do(http.MethodDelete, "Bearer "+reader)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, "INSUFFICIENT_SCOPE", code(rec))
+
+	do(http.MethodGet, "Bearer "+reader)
+	assert.Equal(t, 1, keys.touches, "last use is recorded at most once a minute")
+
+	rec = do(http.MethodGet, "Bearer "+apikey.Prefix+"unknown")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "INVALID_API_KEY", code(rec))
+	assert.NotEmpty(t, rec.Header().Get("WWW-Authenticate"))
+
+	past := time.Now().Add(-time.Minute)
+	rec = do(http.MethodGet, "Bearer "+newKey(&past, apikey.ScopeItemsRead))
+	assert.Equal(t, "INVALID_API_KEY", code(rec), "expired keys are rejected")
+
+	rec = do(http.MethodGet, "Bearer admin-token")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "UNAUTHORIZED", code(rec), "other bearer tokens are not keys")
+
+	stores.stores[7] = modelStore.Store{ID: 7, UserID: 1, Status: modelStore.StatusSuspended}
+	writer := newKey(nil, apikey.ScopeItemsRead, apikey.ScopeItemsWrite)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "Bearer "+writer).Code, "suspended stores can still be read")
+	rec = do(http.MethodDelete, "Bearer "+writer)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, "STORE_UNAVAILABLE", code(rec), "suspended stores are frozen")
+}
@@ -0,0 +1,222 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/helpers/constant"
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/internal/admin"
+	"github.com/Risuii/internal/store"
+	modelStore "github.com/Risuii/models/store"
+	"github.com/Risuii/tests/mock"
+)
+
+// statusRepo moves the stores of a storeRepo and keeps the trail.
+type statusRepo struct {
+	stores *storeRepo
+	trail  []modelStore.AuditEntry
+}
+
+func (r *statusRepo) Set(ctx context.Context, update modelStore.StatusUpdate, entry modelStore.AuditEntry) error {
+	s, ok := r.stores.stores[update.StoreID]
+	if !ok {
+		return exception.ErrNotFound
+	}
+	if s.Status != update.From {
+		return exception.ErrConflicted
+	}
+	s.Status, s.StatusReason = update.To, update.Reason
+	s.Version++
+	r.stores.stores[update.StoreID] = s
+	r.trail = append(r.trail, entry)
+	return nil
+}
+
+func (r *statusRepo) FindByStatus(ctx context.Context, status modelStore.Status) ([]modelStore.Store, error) {
+	stores := []modelStore.Store{}
+	for _, s := range r.stores.stores {
+		if s.Status == status {
+			stores = append(stores, s)
+		}
+	}
+	return stores, nil
+}
+
+func (r *storeRepo) FindByUserID(ctx context.Context, userID int64) ([]modelStore.Store, error) {
+	stores := []modelStore.Store{}
+	for _, s := range r.stores {
+		if s.UserID == userID {
+			stores = append(stores, s)
+		}
+	}
+	return stores, nil
+}
+
+// newLifecycle is the team of newTeam with store 7 active and listed at
+// "toko-sari".
+func newLifecycle() (store.StoreUseCase, *forgetful, *statusRepo, *outbox) {
+	stores, members, accounts := newTeam()
+	stores.stores[7] = modelStore.Store{ID: 7, UserID: 1, NameStore: "Toko Sari", Slug: "toko-sari", Status: modelStore.StatusActive, Version: 1}
+	statuses := &statusRepo{stores: stores}
+	mail := &outbox{}
+	repo := &forgetful{storeRepo: stores}
+	usecase := store.NewStoreUseCaseImpl(repo, accounts, nil, members, nil, nil, nil, store.NewLifecycle(statuses, mail), uploads)
+	return usecase, repo, statuses, mail
+}
+
+func TestStatusRules(t *testing.T) {
+	assert.True(t, modelStore.StatusDraft.OwnerCanMove(modelStore.StatusActive))
+	assert.True(t, modelStore.StatusActive.OwnerCanMove(modelStore.StatusVacation))
+	assert.False(t, modelStore.StatusSuspended.OwnerCanMove(modelStore.StatusActive))
+	assert.False(t, modelStore.StatusClosed.OwnerCanMove(modelStore.StatusActive))
+	assert.False(t, modelStore.StatusActive.OwnerCanMove(modelStore.StatusDraft))
+
+	assert.True(t, modelStore.StatusVacation.Listed())
+	assert.False(t, modelStore.StatusVacation.Selling())
+	assert.False(t, modelStore.StatusDraft.Listed())
+	assert.False(t, modelStore.StatusSuspended.Listed())
+	assert.False(t, modelStore.StatusClosed.Listed())
+
+	assert.True(t, modelStore.StatusSuspended.Allows(modelStore.PermissionItemsRead))
+	assert.False(t, modelStore.StatusSuspended.Allows(modelStore.PermissionItemsWrite))
+	assert.False(t, modelStore.StatusSuspended.Allows(modelStore.PermissionStoreDelete), "suspended stores cannot be deleted to dodge moderation")
+	assert.True(t, modelStore.StatusClosed.Allows(modelStore.PermissionStoreDelete))
+
+	suspend := modelStore.Moderation{Action: modelStore.ModerationSuspend}
+	_, ok := suspend.Target(modelStore.StatusClosed)
+	assert.False(t, ok)
+	reinstate := modelStore.Moderation{Action: modelStore.ModerationReinstate}
+	_, ok = reinstate.Target(modelStore.StatusActive)
+	assert.False(t, ok)
+	to, ok := reinstate.Target(modelStore.StatusSuspended)
+	assert.True(t, ok)
+	assert.Equal(t, modelStore.StatusActive, to)
+
+	v := validator.New()
+	assert.Error(t, v.Struct(modelStore.Moderation{Action: "ban", Reason: modelStore.ReasonFraud}))
+	assert.Error(t, v.Struct(modelStore.Moderation{Action: "suspend", Reason: "bad vibes"}))
+	assert.Error(t, v.Struct(modelStore.Moderation{Action: "suspend", Reason: modelStore.ReasonOther}), "other needs a note")
+	assert.Error(t, v.Struct(modelStore.StatusChange{Status: modelStore.StatusSuspended}), "owners cannot suspend")
+}
+
+func TestOwnerStatus(t *testing.T) {
+	ctx := context.Background()
+	usecase, repo, statuses, _ := newLifecycle()
+
+	res := usecase.SetStatus(ctx, 7, 1, 3, modelStore.StatusChange{Status: modelStore.StatusVacation})
+	assert.True(t, errors.Is(res.Err(), exception.ErrForbidden), "staff cannot change the state")
+
+	res = usecase.SetStatus(ctx, 7, 1, 2, modelStore.StatusChange{Status: modelStore.StatusVacation})
+	require.NoError(t, res.Err())
+	assert.Equal(t, modelStore.StatusVacation, data(res).(modelStore.Store).Status)
+	assert.False(t, data(res).(modelStore.Store).OpenNow, "stores on vacation are never open")
+	assert.Equal(t, []int64{1}, repo.forgotten)
+	require.NoError(t, usecase.ReadBySlug(ctx, "toko-sari").Err(), "stores on vacation stay listed")
+
+	res = usecase.SetStatus(ctx, 7, 2, 2, modelStore.StatusChange{Status: modelStore.StatusClosed})
+	assert.True(t, errors.Is(res.Err(), exception.ErrForbidden), "closing is left to the owner")
+
+	res = usecase.SetStatus(ctx, 7, 1, 1, modelStore.StatusChange{Status: modelStore.StatusClosed})
+	assert.True(t, errors.Is(res.Err(), exception.ErrPreconditionFailed))
+
+	require.NoError(t, usecase.SetStatus(ctx, 7, 2, 1, modelStore.StatusChange{Status: modelStore.StatusClosed}).Err())
+	assert.True(t, errors.Is(usecase.ReadBySlug(ctx, "toko-sari").Err(), exception.ErrNotFound), "closed stores leave the catalog")
+	assert.True(t, errors.Is(usecase.Read(ctx, 1).Err(), exception.ErrNotFound))
+
+	res = usecase.SetStatus(ctx, 7, 3, 1, modelStore.StatusChange{Status: modelStore.StatusActive})
+	assert.True(t, errors.Is(res.Err(), exception.ErrStoreUnavailable))
+
+	require.Len(t, statuses.trail, 2)
+	assert.Equal(t, modelStore.AuditStatusChanged, statuses.trail[1].Action)
+	assert.Equal(t, int64(1), statuses.trail[1].ActorID)
+}
+
+func TestModeration(t *testing.T) {
+	ctx := context.Background()
+	usecase, _, statuses, mail := newLifecycle()
+
+	res := usecase.Moderate(ctx, 7, modelStore.Moderation{Action: modelStore.ModerationSuspend, Reason: modelStore.ReasonCounterfeit, Note: "Listing 12 copies a brand."})
+	require.NoError(t, res.Err())
+	assert.Equal(t, modelStore.ReasonCounterfeit, data(res).(modelStore.Store).StatusReason)
+
+	require.Len(t, mail.sent, 1)
+	assert.Equal(t, "sari@example.com", mail.sent[0].To, "the owner is told")
+	assert.Contains(t, mail.sent[0].Body, "counterfeit goods")
+	assert.Contains(t, mail.sent[0].Body, "Listing 12 copies a brand.")
+
+	assert.True(t, errors.Is(usecase.ReadBySlug(ctx, "toko-sari").Err(), exception.ErrNotFound), "suspended stores leave the catalog")
+	res = usecase.SetStatus(ctx, 7, 2, 1, modelStore.StatusChange{Status: modelStore.StatusActive})
+	assert.True(t, errors.Is(res.Err(), exception.ErrStoreUnavailable), "owners cannot lift a suspension")
+
+	res = usecase.Moderate(ctx, 7, modelStore.Moderation{Action: modelStore.ModerationSuspend, Reason: modelStore.ReasonFraud})
+	assert.True(t, errors.Is(res.Err(), exception.ErrStatusTransition))
+
+	require.NoError(t, usecase.ListByStatus(ctx, modelStore.StatusSuspended).Err())
+	assert.Len(t, data(usecase.ListByStatus(ctx, modelStore.StatusSuspended)), 1)
+
+	require.NoError(t, usecase.Moderate(ctx, 7, modelStore.Moderation{Action: modelStore.ModerationReinstate, Reason: modelStore.ReasonAppealAccepted}).Err())
+	require.NoError(t, usecase.ReadBySlug(ctx, "toko-sari").Err())
+	assert.Len(t, mail.sent, 2)
+	assert.Contains(t, mail.sent[1].Subject, "is back")
+
+	require.Len(t, statuses.trail, 2)
+	assert.Equal(t, modelStore.AuditStoreSuspended, statuses.trail[0].Action)
+	assert.Equal(t, int64(0), statuses.trail[0].ActorID, "admins act for the platform")
+	assert.Equal(t, modelStore.ReasonCounterfeit, statuses.trail[0].Details["reason"])
+}
+
+func TestSetStatusRepository(t *testing.T) {
+	db, m := mock.NewMock()
+	defer db.Close()
+	repo := store.NewStatusRepositoryImpl(db, constant.TableStores, constant.TableStoreAudit)
+	update := modelStore.StatusUpdate{StoreID: 7, From: modelStore.StatusActive, To: modelStore.StatusSuspended, Reason: modelStore.ReasonSpam}
+	entry := modelStore.AuditEntry{StoreID: 7, Action: modelStore.AuditStoreSuspended}
+
+	m.ExpectBegin()
+	m.ExpectExec(regexp.QuoteMeta(`UPDATE stores SET status = ?, status_reason = ?`)).WillReturnResult(sqlmock.NewResult(0, 1))
+	m.ExpectExec(regexp.QuoteMeta(`INSERT INTO store_audit_log`)).WillReturnResult(sqlmock.NewResult(1, 1))
+	m.ExpectCommit()
+	require.NoError(t, repo.Set(context.Background(), update, entry))
+
+	m.ExpectBegin()
+	m.ExpectExec(regexp.QuoteMeta(`UPDATE stores SET status = ?`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	m.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM stores WHERE id = ?`)).WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+	m.ExpectRollback()
+	err := repo.Set(context.Background(), update, entry)
+	assert.True(t, errors.Is(err, exception.ErrConflicted), "the store moved meanwhile")
+
+	assert.NoError(t, m.ExpectationsWereMet())
+}
+
+func TestModerationRoutes(t *testing.T) {
+	usecase, _, _, _ := newLifecycle()
+	router := mux.NewRouter()
+	admin.NewAdminHandler(router, "admin-token", validator.New(), nil, usecase)
+
+	do := func(token, method, target, body string) int {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, do("guess", http.MethodPost, "/admin/stores/7/moderation", `{"action":"suspend","reason":"spam"}`))
+	assert.Equal(t, http.StatusBadRequest, do("admin-token", http.MethodPost, "/admin/stores/7/moderation", `{"action":"suspend"}`), "a reason code is needed")
+	assert.Equal(t, http.StatusBadRequest, do("admin-token", http.MethodGet, "/admin/stores?status=banned", ""))
+	assert.Equal(t, http.StatusOK, do("admin-token", http.MethodPost, "/admin/stores/7/moderation", `{"action":"suspend","reason":"spam"}`))
+	assert.Equal(t, http.StatusOK, do("admin-token", http.MethodGet, "/admin/stores", ""))
+	assert.Equal(t, http.StatusConflict, do("admin-token", http.MethodPost, "/admin/stores/7/moderation", `{"action":"suspend","reason":"spam"}`))
+}
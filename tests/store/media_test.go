@@ -0,0 +1,87 @@
+package store_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/helpers/blob"
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/media"
+	"github.com/Risuii/helpers/patch"
+	"github.com/Risuii/internal/store"
+	modelStore "github.com/Risuii/models/store"
+)
+
+var limits = media.Limits{MaxSize: 1 << 20, MaxPixels: 1000 * 1000, ThumbnailSize: 100}
+
+// uploads is the storage of tests that upload nothing.
+var uploads = media.NewStorage(blob.NewLocal(os.TempDir(), "http://localhost/media"), limits)
+
+func pngOf(t *testing.T) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 8))))
+	return buf.Bytes()
+}
+
+func (r *storeRepo) Delete(ctx context.Context, id int64, version int64) error {
+	delete(r.stores, id)
+	return nil
+}
+
+func TestMediaURLsAreNotSetByHand(t *testing.T) {
+	ctx := context.Background()
+	stores, members, accounts := newTeam()
+	usecase := store.NewStoreUseCaseImpl(stores, accounts, nil, members, nil, nil, nil, nil, uploads)
+
+	stolen := "http://localhost/media/stores/8/logo.png"
+	res := usecase.UpdateStore(ctx, 7, 1, 1, modelStore.StoreUpdate{LogoURL: patch.String{Value: stolen, Set: true}})
+	require.True(t, errors.Is(res.Err(), exception.ErrValidation))
+	assert.Equal(t, "uploaded", res.Err().(*exception.Error).Fields[0].Tag)
+
+	res = usecase.CreateStore(ctx, 1, modelStore.Store{NameStore: "Toko Budi", BannerURL: stolen})
+	assert.True(t, errors.Is(res.Err(), exception.ErrValidation))
+
+	elsewhere := "https://cdn.example.com/logo.png"
+	res = usecase.UpdateStore(ctx, 7, 1, 1, modelStore.StoreUpdate{LogoURL: patch.String{Value: elsewhere, Set: true}})
+	require.NoError(t, res.Err())
+	assert.Equal(t, elsewhere, stores.stores[7].LogoURL)
+}
+
+func TestOnlyUploadsOfTheStoreAreDeleted(t *testing.T) {
+	ctx := context.Background()
+	blobs := blob.NewLocal(t.TempDir(), "http://localhost/media")
+	storage := media.NewStorage(blobs, limits)
+	stores, members, accounts := newTeam()
+	usecase := store.NewStoreUseCaseImpl(stores, accounts, nil, members, nil, nil, nil, nil, storage)
+
+	other, err := storage.Save(ctx, "stores/8", pngOf(t), false)
+	require.NoError(t, err)
+	s := stores.stores[7]
+	s.LogoURL = storage.URL(other.Key)
+	stores.stores[7] = s
+
+	res := usecase.SetLogo(ctx, 7, 1, 1, pngOf(t))
+	require.NoError(t, res.Err())
+	_, err = blobs.Get(ctx, other.Key)
+	assert.NoError(t, err, "the file of store 8 is kept")
+
+	own, ok := blob.KeyOf(blobs, stores.stores[7].LogoURL)
+	require.True(t, ok)
+	res = usecase.SetLogo(ctx, 7, 2, 1, pngOf(t))
+	require.NoError(t, res.Err())
+	_, err = blobs.Get(ctx, own)
+	assert.True(t, errors.Is(err, blob.ErrNotFound), "the replaced logo is deleted")
+
+	own, _ = blob.KeyOf(blobs, stores.stores[7].LogoURL)
+	require.NoError(t, usecase.DeleteStore(ctx, 7, 3, 1).Err())
+	_, err = blobs.Get(ctx, own)
+	assert.True(t, errors.Is(err, blob.ErrNotFound))
+}
@@ -0,0 +1,377 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	newJWT "github.com/dgrijalva/jwt-go"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/config/jwt"
+	"github.com/Risuii/helpers/constant"
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/mailer"
+	"github.com/Risuii/helpers/media"
+	"github.com/Risuii/internal/item"
+	"github.com/Risuii/internal/store"
+	modelAccount "github.com/Risuii/models/account"
+	modelStore "github.com/Risuii/models/store"
+	"github.com/Risuii/tests/mock"
+)
+
+type memberRepo struct {
+	members []modelStore.Member
+}
+
+func newMemberRepo(members ...modelStore.Member) *memberRepo {
+	return &memberRepo{members: members}
+}
+
+func (r *memberRepo) Find(ctx context.Context, storeID int64, userID int64) (modelStore.Member, error) {
+	for _, m := range r.members {
+		if m.StoreID == storeID && m.UserID == userID {
+			return m, nil
+		}
+	}
+	return modelStore.Member{}, exception.ErrNotFound
+}
+
+func (r *memberRepo) FindByStoreID(ctx context.Context, storeID int64) ([]modelStore.Member, error) {
+	members := []modelStore.Member{}
+	for _, m := range r.members {
+		if m.StoreID == storeID {
+			members = append(members, m)
+		}
+	}
+	return members, nil
+}
+
+func (r *memberRepo) FindByUserID(ctx context.Context, userID int64) ([]modelStore.Member, error) {
+	members := []modelStore.Member{}
+	for _, m := range r.members {
+		if m.UserID == userID {
+			members = append(members, m)
+		}
+	}
+	return members, nil
+}
+
+func (r *memberRepo) Delete(ctx context.Context, storeID int64, userID int64) error {
+	for i, m := range r.members {
+		if m.StoreID == storeID && m.UserID == userID {
+			r.members = append(r.members[:i], r.members[i+1:]...)
+			return nil
+		}
+	}
+	return exception.ErrNotFound
+}
+
+// invitationRepo keeps invitations in memory and adds accepted ones to
+// members.
+type invitationRepo struct {
+	invitations map[int64]modelStore.Invitation
+	members     *memberRepo
+}
+
+func (r *invitationRepo) Create(ctx context.Context, params modelStore.Invitation) (int64, error) {
+	for _, inv := range r.invitations {
+		if inv.StoreID == params.StoreID && inv.Email == params.Email {
+			return 0, exception.ErrInvitationPending
+		}
+	}
+	params.ID = int64(len(r.invitations) + 1)
+	r.invitations[params.ID] = params
+	return params.ID, nil
+}
+
+func (r *invitationRepo) FindByHash(ctx context.Context, tokenHash string, now time.Time) (modelStore.Invitation, error) {
+	for _, inv := range r.invitations {
+		if inv.TokenHash == tokenHash && inv.ExpiresAt.After(now) {
+			return inv, nil
+		}
+	}
+	return modelStore.Invitation{}, exception.ErrNotFound
+}
+
+func (r *invitationRepo) FindByStoreID(ctx context.Context, storeID int64, now time.Time) ([]modelStore.Invitation, error) {
+	invitations := []modelStore.Invitation{}
+	for _, inv := range r.invitations {
+		if inv.StoreID == storeID {
+			invitations = append(invitations, inv)
+		}
+	}
+	return invitations, nil
+}
+
+func (r *invitationRepo) Delete(ctx context.Context, storeID int64, id int64) error {
+	if inv, ok := r.invitations[id]; !ok || inv.StoreID != storeID {
+		return exception.ErrNotFound
+	}
+	delete(r.invitations, id)
+	return nil
+}
+
+func (r *invitationRepo) Accept(ctx context.Context, invitation modelStore.Invitation, userID int64, now time.Time) error {
+	if _, ok := r.invitations[invitation.ID]; !ok {
+		return exception.ErrInvalidToken
+	}
+	delete(r.invitations, invitation.ID)
+	r.members.members = append(r.members.members, modelStore.Member{StoreID: invitation.StoreID, UserID: userID, Role: invitation.Role})
+	return nil
+}
+
+type accountRepo struct {
+	accounts []modelAccount.Account
+}
+
+func (r *accountRepo) FindByID(ctx context.Context, id int64) (modelAccount.Account, error) {
+	for _, a := range r.accounts {
+		if a.ID == id {
+			return a, nil
+		}
+	}
+	return modelAccount.Account{}, exception.ErrNotFound
+}
+
+func (r *accountRepo) FindByEmail(ctx context.Context, email string) (modelAccount.Account, error) {
+	for _, a := range r.accounts {
+		if strings.EqualFold(a.Email, email) {
+			return a, nil
+		}
+	}
+	return modelAccount.Account{}, exception.ErrNotFound
+}
+
+// outbox keeps the messages sent, or fails with err when it is set.
+type outbox struct {
+	sent []mailer.Message
+	err  error
+}
+
+func (o *outbox) Send(ctx context.Context, msg mailer.Message) error {
+	if o.err != nil {
+		return o.err
+	}
+	o.sent = append(o.sent, msg)
+	return nil
+}
+
+// tokenOf returns the token of the invitation link in msg.
+func tokenOf(t *testing.T, msg mailer.Message) string {
+	link := regexp.MustCompile(`https?://\S+`).FindString(msg.Body)
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+	return u.Query().Get("token")
+}
+
+// The store 7 is owned by 1, managed by 2 and staffed by 3. Account 4 is
+// not a member.
+func newTeam() (*storeRepo, *memberRepo, *accountRepo) {
+	verified := time.Now()
+	stores := &storeRepo{stores: map[int64]modelStore.Store{7: {ID: 7, UserID: 1, NameStore: "Toko Sari", Version: 1}}}
+	members := newMemberRepo(
+		modelStore.Member{StoreID: 7, UserID: 2, Role: modelStore.RoleManager},
+		modelStore.Member{StoreID: 7, UserID: 3, Role: modelStore.RoleStaff},
+	)
+	accounts := &accountRepo{accounts: []modelAccount.Account{
+		{ID: 1, Name: "Sari", Email: "sari@example.com", VerifiedAt: &verified},
+		{ID: 2, Name: "Budi", Email: "budi@example.com", VerifiedAt: &verified},
+		{ID: 3, Name: "Dewi", Email: "dewi@example.com", VerifiedAt: &verified},
+		{ID: 4, Name: "Eka", Email: "eka@example.com", VerifiedAt: &verified},
+		{ID: 5, Name: "Fajar", Email: "fajar@example.com"},
+	}}
+	return stores, members, accounts
+}
+
+func TestRoles(t *testing.T) {
+	assert.True(t, modelStore.RoleOwner.Can(modelStore.PermissionStoreDelete))
+	assert.False(t, modelStore.RoleManager.Can(modelStore.PermissionStoreDelete))
+	assert.True(t, modelStore.RoleManager.Can(modelStore.PermissionStoreEdit))
+	assert.True(t, modelStore.RoleStaff.Can(modelStore.PermissionItemsWrite))
+	assert.False(t, modelStore.RoleStaff.Can(modelStore.PermissionMembersManage))
+	assert.False(t, modelStore.RoleStaff.Can(modelStore.PermissionAPIKeysManage))
+
+	assert.True(t, modelStore.RoleOwner.Manages(modelStore.RoleManager))
+	assert.True(t, modelStore.RoleManager.Manages(modelStore.RoleStaff))
+	assert.False(t, modelStore.RoleManager.Manages(modelStore.RoleManager))
+	assert.False(t, modelStore.RoleStaff.Manages(modelStore.RoleStaff))
+	assert.False(t, modelStore.RoleOwner.Manages(modelStore.RoleOwner))
+
+	v := validator.New()
+	assert.Error(t, v.Struct(modelStore.InvitationCreate{Email: "eka@example.com", Role: modelStore.RoleOwner}), "ownership is transferred, not invited")
+	assert.NoError(t, v.Struct(modelStore.InvitationCreate{Email: "eka@example.com", Role: modelStore.RoleStaff}))
+}
+
+func TestAccess(t *testing.T) {
+	ctx := context.Background()
+	stores, members, _ := newTeam()
+	access := store.NewAccess(stores, members)
+
+	for _, tc := range []struct {
+		userID     int64
+		permission modelStore.Permission
+		err        error
+	}{
+		{1, modelStore.PermissionStoreDelete, nil},
+		{2, modelStore.PermissionStoreEdit, nil},
+		{2, modelStore.PermissionStoreDelete, exception.ErrForbidden},
+		{3, modelStore.PermissionItemsWrite, nil},
+		{3, modelStore.PermissionStoreEdit, exception.ErrForbidden},
+		{4, modelStore.PermissionItemsRead, exception.ErrNotFound},
+	} {
+		_, err := access.Authorize(ctx, 7, tc.userID, tc.permission)
+		assert.Equal(t, tc.err, err, "user %d, %s", tc.userID, tc.permission)
+	}
+}
+
+func TestInvitations(t *testing.T) {
+	ctx := context.Background()
+	stores, members, accounts := newTeam()
+	mail := &outbox{}
+	invitations := store.NewInvitations(&invitationRepo{invitations: map[int64]modelStore.Invitation{}, members: members}, mail, "https://shop.example.com/", 24*time.Hour)
+	usecase := store.NewStoreUseCaseImpl(stores, accounts, nil, members, invitations, nil, nil, nil, uploads)
+
+	res := usecase.Invite(ctx, 7, 2, modelStore.InvitationCreate{Email: "eka@example.com", Role: modelStore.RoleManager})
+	assert.True(t, errors.Is(res.Err(), exception.ErrForbidden), "managers only invite staff")
+
+	res = usecase.Invite(ctx, 7, 3, modelStore.InvitationCreate{Email: "eka@example.com", Role: modelStore.RoleStaff})
+	assert.True(t, errors.Is(res.Err(), exception.ErrForbidden), "staff cannot invite")
+
+	res = usecase.Invite(ctx, 7, 1, modelStore.InvitationCreate{Email: "Dewi@example.com", Role: modelStore.RoleManager})
+	assert.True(t, errors.Is(res.Err(), exception.ErrAlreadyMember))
+
+	res = usecase.Invite(ctx, 7, 2, modelStore.InvitationCreate{Email: " Eka@Example.com", Role: modelStore.RoleStaff})
+	require.NoError(t, res.Err())
+	require.Len(t, mail.sent, 1)
+	assert.Equal(t, "eka@example.com", mail.sent[0].To)
+	assert.Contains(t, mail.sent[0].Body, "Toko Sari")
+	assert.Contains(t, mail.sent[0].Body, "Budi")
+	token := tokenOf(t, mail.sent[0])
+	require.NotEmpty(t, token)
+
+	res = usecase.Invite(ctx, 7, 1, modelStore.InvitationCreate{Email: "eka@example.com", Role: modelStore.RoleStaff})
+	assert.True(t, errors.Is(res.Err(), exception.ErrInvitationPending))
+
+	res = usecase.AcceptInvitation(ctx, 3, modelStore.InvitationAnswer{Token: token})
+	assert.True(t, errors.Is(res.Err(), exception.ErrInvitationEmail), "a forwarded link does not work for others")
+
+	require.NoError(t, usecase.AcceptInvitation(ctx, 4, modelStore.InvitationAnswer{Token: token}).Err())
+	member, err := members.Find(ctx, 7, 4)
+	require.NoError(t, err)
+	assert.Equal(t, modelStore.RoleStaff, member.Role)
+
+	res = usecase.AcceptInvitation(ctx, 4, modelStore.InvitationAnswer{Token: token})
+	assert.True(t, errors.Is(res.Err(), exception.ErrInvalidToken), "invitations work once")
+
+	require.NoError(t, usecase.Invite(ctx, 7, 1, modelStore.InvitationCreate{Email: "fajar@example.com", Role: modelStore.RoleStaff}).Err())
+	token = tokenOf(t, mail.sent[1])
+
+	res = usecase.AcceptInvitation(ctx, 5, modelStore.InvitationAnswer{Token: token})
+	assert.True(t, errors.Is(res.Err(), exception.ErrEmailNotVerified))
+
+	require.NoError(t, usecase.DeclineInvitation(ctx, modelStore.InvitationAnswer{Token: token}).Err())
+	assert.Empty(t, data(usecase.ListInvitations(ctx, 7, 1)))
+
+	mail.err = errors.New("smtp: connection refused")
+	assert.Error(t, usecase.Invite(ctx, 7, 1, modelStore.InvitationCreate{Email: "fajar@example.com", Role: modelStore.RoleStaff}).Err())
+	assert.Empty(t, data(usecase.ListInvitations(ctx, 7, 1)), "an invitation that was not mailed is not kept")
+
+	mail.err = nil
+	assert.NoError(t, usecase.Invite(ctx, 7, 1, modelStore.InvitationCreate{Email: "fajar@example.com", Role: modelStore.RoleStaff}).Err(), "and can be sent again")
+}
+
+func TestRemoveMember(t *testing.T) {
+	ctx := context.Background()
+	stores, members, accounts := newTeam()
+	usecase := store.NewStoreUseCaseImpl(stores, accounts, nil, members, nil, nil, nil, nil, uploads)
+
+	assert.True(t, errors.Is(usecase.RemoveMember(ctx, 7, 3, 2).Err(), exception.ErrForbidden), "staff cannot remove a manager")
+	assert.True(t, errors.Is(usecase.RemoveMember(ctx, 7, 2, 1).Err(), exception.ErrForbidden), "nobody removes the owner")
+	assert.True(t, errors.Is(usecase.RemoveMember(ctx, 7, 1, 1).Err(), exception.ErrForbidden), "the owner cannot leave")
+	assert.True(t, errors.Is(usecase.RemoveMember(ctx, 7, 4, 3).Err(), exception.ErrNotFound))
+
+	listed := data(usecase.ListMembers(ctx, 7, 3)).([]modelStore.Member)
+	require.Len(t, listed, 3)
+	assert.Equal(t, modelStore.RoleOwner, listed[0].Role)
+	assert.Equal(t, "sari@example.com", listed[0].Email)
+
+	require.NoError(t, usecase.RemoveMember(ctx, 7, 2, 3).Err())
+	require.NoError(t, usecase.RemoveMember(ctx, 7, 2, 2).Err(), "members can leave")
+	assert.Empty(t, members.members)
+}
+
+func TestMembersCannotDeleteTheStore(t *testing.T) {
+	ctx := context.Background()
+	stores, members, accounts := newTeam()
+	usecase := store.NewStoreUseCaseImpl(stores, accounts, nil, members, nil, nil, nil, nil, uploads)
+
+	assert.True(t, errors.Is(usecase.DeleteStore(ctx, 7, 1, 2).Err(), exception.ErrForbidden))
+	assert.True(t, errors.Is(usecase.DeleteStore(ctx, 7, 1, 4).Err(), exception.ErrNotFound))
+	assert.True(t, errors.Is(usecase.CreateAPIKey(ctx, 7, 3, modelStore.APIKeyCreate{}).Err(), exception.ErrForbidden))
+}
+
+func TestStoreTokenNeedsMembership(t *testing.T) {
+	jwt.JWT_KEY = []byte("secret-secret-secret-secret-secret")
+	stores, members, _ := newTeam()
+	items := &itemUseCase{}
+
+	router := mux.NewRouter()
+	item.NewItemHandler(router, validator.New(), items, store.NewAccess(stores, members), media.Limits{})
+
+	get := func(userID int64) int {
+		signed, err := newJWT.NewWithClaims(newJWT.SigningMethodHS256, &jwt.JWTclaim{UserID: userID, StoreID: 7}).SignedString(jwt.JWT_KEY)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/store/items/3", nil)
+		req.AddCookie(&http.Cookie{Name: "Store-token", Value: signed})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, get(3))
+	assert.Equal(t, http.StatusForbidden, get(4))
+
+	members.Delete(context.Background(), 7, 3)
+	assert.Equal(t, http.StatusForbidden, get(3), "removal takes effect right away")
+}
+
+func TestAcceptInvitationRepository(t *testing.T) {
+	db, m := mock.NewMock()
+	defer db.Close()
+	repo := store.NewInvitationRepositoryImpl(db, constant.TableInvitations, constant.TableStoreMembers)
+	invitation := modelStore.Invitation{ID: 9, StoreID: 7, Role: modelStore.RoleStaff}
+
+	m.ExpectBegin()
+	m.ExpectExec(regexp.QuoteMeta(`DELETE FROM store_invitations WHERE id = ? AND expires_at > ?`)).WithArgs(9, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	m.ExpectExec(regexp.QuoteMeta(`INSERT INTO store_members (storeID, userID, role, created_at)`)).WithArgs(7, 4, modelStore.RoleStaff, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(3, 1))
+	m.ExpectCommit()
+	require.NoError(t, repo.Accept(context.Background(), invitation, 4, time.Now()))
+
+	m.ExpectBegin()
+	m.ExpectExec(regexp.QuoteMeta(`DELETE FROM store_invitations`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	m.ExpectRollback()
+	err := repo.Accept(context.Background(), invitation, 4, time.Now())
+	assert.True(t, errors.Is(err, exception.ErrInvalidToken))
+
+	m.ExpectBegin()
+	m.ExpectExec(regexp.QuoteMeta(`DELETE FROM store_invitations`)).WillReturnResult(sqlmock.NewResult(0, 1))
+	m.ExpectExec(regexp.QuoteMeta(`INSERT INTO store_members`)).WillReturnError(&mysql.MySQLError{Number: 1062})
+	m.ExpectRollback()
+	err = repo.Accept(context.Background(), invitation, 4, time.Now())
+	assert.True(t, errors.Is(err, exception.ErrAlreadyMember))
+
+	assert.NoError(t, m.ExpectationsWereMet())
+}
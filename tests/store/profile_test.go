@@ -0,0 +1,217 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/helpers/constant"
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/patch"
+	"github.com/Risuii/helpers/slug"
+	"github.com/Risuii/internal/store"
+	modelStore "github.com/Risuii/models/store"
+	"github.com/Risuii/tests/mock"
+)
+
+func (r *storeRepo) FindByName(ctx context.Context, nameStore string) (modelStore.Store, error) {
+	return modelStore.Store{}, exception.ErrNotFound
+}
+
+func (r *storeRepo) FindBySlug(ctx context.Context, slug string) (modelStore.Store, error) {
+	for _, s := range r.stores {
+		if s.Slug == slug {
+			return s, nil
+		}
+	}
+	return modelStore.Store{}, exception.ErrNotFound
+}
+
+// Create enforces the unique slug index of the stores table.
+func (r *storeRepo) Create(ctx context.Context, params modelStore.Store) (int64, error) {
+	if _, err := r.FindBySlug(ctx, params.Slug); err == nil {
+		return 0, exception.ErrSlugTaken
+	}
+	params.ID = int64(len(r.stores) + 100)
+	r.stores[params.ID] = params
+	return params.ID, nil
+}
+
+func (r *storeRepo) Update(ctx context.Context, id int64, params modelStore.Store) error {
+	params.Version++
+	r.stores[id] = params
+	return nil
+}
+
+// week is open 09:00 to 17:00 on weekdays and 20:00 to 02:00 on Fridays.
+func week() modelStore.Hours {
+	var hours modelStore.Hours
+	for _, day := range []string{"monday", "tuesday", "wednesday", "thursday", "friday"} {
+		hours.Weekly = append(hours.Weekly, modelStore.Period{Day: day, Opens: "09:00", Closes: "17:00"})
+	}
+	hours.Weekly = append(hours.Weekly, modelStore.Period{Day: "friday", Opens: "20:00", Closes: "02:00"})
+	return hours
+}
+
+func TestSlug(t *testing.T) {
+	assert.Equal(t, "kopi-cafe", slug.Make("Kopi Café", "store"))
+	assert.Equal(t, "toko-sari-jaya", slug.Make("  Toko  Sari -- Jaya! ", "store"))
+	assert.Equal(t, "store", slug.Make("東京", "store"), "nothing is left of non-Latin names")
+	assert.Len(t, slug.Make(strings.Repeat("ab ", 40), "store"), slug.MaxLength)
+	assert.False(t, strings.HasSuffix(slug.Make(strings.Repeat("a ", 40), "store"), "-"))
+}
+
+func TestOpenAt(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	require.NoError(t, err)
+	at := func(day int, clock string) time.Time {
+		t, _ := time.ParseInLocation("2006-01-02 15:04", fmt.Sprintf("2024-03-%02d %s", day, clock), jakarta)
+		return t
+	}
+	s := modelStore.Store{Timezone: "Asia/Jakarta", Hours: week()}
+
+	// 2024-03-04 is a Monday, 2024-03-08 a Friday.
+	assert.True(t, s.OpenAt(at(4, "09:00")))
+	assert.False(t, s.OpenAt(at(4, "17:00")), "closing time is not open")
+	assert.False(t, s.OpenAt(at(4, "08:59")))
+	assert.True(t, s.OpenAt(at(8, "23:30")))
+	assert.True(t, s.OpenAt(at(9, "01:59")), "friday night runs past midnight")
+	assert.False(t, s.OpenAt(at(9, "02:00")))
+	assert.False(t, s.OpenAt(at(10, "12:00")))
+
+	assert.True(t, s.OpenAt(at(4, "10:00").UTC()), "times are read in the zone of the store")
+	s.Timezone = "UTC"
+	assert.False(t, s.OpenAt(at(4, "10:00")), "10:00 in Jakarta is 03:00 UTC")
+
+	s.Timezone = "Asia/Jakarta"
+	s.Hours.Holidays = []modelStore.Holiday{
+		{Date: "2024-03-11", Name: "Nyepi", Closed: true},
+		{Date: "2024-03-09", Opens: "10:00", Closes: "12:00"},
+		{Date: "2024-03-08", Closed: true},
+	}
+	assert.False(t, s.OpenAt(at(11, "10:00")), "holidays replace the weekly hours")
+	assert.True(t, s.OpenAt(at(9, "11:00")))
+	assert.False(t, s.OpenAt(at(9, "01:00")), "a closed holiday has no night to run past midnight")
+
+	s.Hours = modelStore.Hours{Weekly: []modelStore.Period{{Day: "sunday", Opens: "00:00", Closes: "00:00"}}}
+	assert.True(t, s.OpenAt(at(10, "00:00")))
+	assert.True(t, s.OpenAt(at(10, "23:59")), "00:00 to 00:00 is the whole day")
+	assert.False(t, s.OpenAt(at(11, "00:00")))
+}
+
+func TestHoursValidation(t *testing.T) {
+	v := validator.New()
+	require.NoError(t, v.Struct(week()))
+
+	assert.Error(t, v.Struct(modelStore.Hours{Weekly: []modelStore.Period{{Day: "funday", Opens: "09:00", Closes: "17:00"}}}))
+	assert.Error(t, v.Struct(modelStore.Hours{Weekly: []modelStore.Period{{Day: "monday", Opens: "9am", Closes: "17:00"}}}))
+
+	assert.NoError(t, v.Struct(modelStore.Holiday{Date: "2024-03-11", Closed: true}))
+	assert.Error(t, v.Struct(modelStore.Holiday{Date: "2024-03-11"}), "open holidays need their hours")
+	assert.Error(t, v.Struct(modelStore.Holiday{Date: "11/03/2024", Closed: true}))
+
+	s := modelStore.Store{NameStore: "Toko Sari", Timezone: "Mars/Olympus"}
+	assert.Error(t, v.Struct(s))
+	s.Timezone = "Asia/Jakarta"
+	s.Location.Country = "Indonesia"
+	assert.Error(t, v.Struct(s), "countries are ISO 3166-1 alpha-2 codes")
+}
+
+func TestCreateStoreSlugs(t *testing.T) {
+	ctx := context.Background()
+	stores, members, accounts := newTeam()
+	stores.stores[7] = modelStore.Store{ID: 7, UserID: 1, NameStore: "Toko Sari", Slug: "toko-sari"}
+	usecase := store.NewStoreUseCaseImpl(stores, accounts, nil, members, nil, nil, nil, nil, uploads)
+
+	res := usecase.CreateStore(ctx, 1, modelStore.Store{NameStore: "Toko Sari!", Slug: "picked"})
+	require.NoError(t, res.Err())
+	created := data(res).(modelStore.Store)
+	assert.Equal(t, "toko-sari-2", created.Slug, "slugs are made unique, not picked")
+	assert.Equal(t, "UTC", created.Timezone)
+
+	res = usecase.CreateStore(ctx, 1, modelStore.Store{NameStore: "TOKO sari"})
+	require.NoError(t, res.Err())
+	assert.Equal(t, "toko-sari-3", data(res).(modelStore.Store).Slug)
+
+	res = usecase.ReadBySlug(ctx, "toko-sari-2")
+	require.NoError(t, res.Err())
+	assert.Equal(t, created.ID, data(res).(modelStore.Store).ID)
+	assert.True(t, errors.Is(usecase.ReadBySlug(ctx, "nowhere").Err(), exception.ErrNotFound))
+}
+
+func TestSetHours(t *testing.T) {
+	ctx := context.Background()
+	stores, members, accounts := newTeam()
+	usecase := store.NewStoreUseCaseImpl(stores, accounts, nil, members, nil, nil, nil, nil, uploads)
+
+	res := usecase.SetHours(ctx, 7, 1, 3, week())
+	assert.True(t, errors.Is(res.Err(), exception.ErrForbidden), "staff cannot edit the store")
+
+	res = usecase.SetHours(ctx, 7, 2, 2, week())
+	assert.True(t, errors.Is(res.Err(), exception.ErrPreconditionFailed))
+
+	res = usecase.SetHours(ctx, 7, 1, 2, week())
+	require.NoError(t, res.Err())
+	assert.Len(t, stores.stores[7].Hours.Weekly, 6)
+	assert.Equal(t, int64(2), data(res).(modelStore.Store).Version)
+}
+
+func TestUpdateStoreProfile(t *testing.T) {
+	var update modelStore.StoreUpdate
+	body := `{"logo_url": "https://cdn.example.com/logo.png", "location": {"city": "Bandung", "latitude": -6.9}}`
+	require.NoError(t, patch.Decode(strings.NewReader(body), &update))
+
+	lat := 1.5
+	s := modelStore.Store{NameStore: "Toko Sari", Location: modelStore.Location{Address: "Jl. Merdeka 1", Longitude: &lat}}
+	update.Apply(&s)
+	assert.Equal(t, "https://cdn.example.com/logo.png", s.LogoURL)
+	assert.Equal(t, "Bandung", s.Location.City)
+	assert.Equal(t, "Jl. Merdeka 1", s.Location.Address, "members left out are kept")
+	require.NotNil(t, s.Location.Latitude)
+	assert.Equal(t, -6.9, *s.Location.Latitude)
+
+	update = modelStore.StoreUpdate{}
+	require.NoError(t, patch.Decode(strings.NewReader(`{"location": {"longitude": null}}`), &update))
+	update.Apply(&s)
+	assert.Nil(t, s.Location.Longitude, "null clears the member")
+
+	err := patch.Decode(strings.NewReader(`{"nameStore": null}`), &modelStore.StoreUpdate{})
+	assert.True(t, errors.Is(err, exception.ErrValidation))
+}
+
+func TestFindBySlugRepository(t *testing.T) {
+	db, m := mock.NewMock()
+	defer db.Close()
+	repo := store.NewStoreRepository(db, constant.TableStores)
+
+	m.ExpectPrepare(regexp.QuoteMeta(`FROM stores WHERE slug = ?`)).
+		ExpectQuery().WithArgs("toko-sari").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "userID", "nameStore", "slug", "description", "logo_url", "banner_url", "contact_email", "contact_phone",
+			"address", "city", "country", "latitude", "longitude", "timezone", "hours", "status", "status_reason", "created_at", "update_at", "version",
+		}).AddRow(
+			7, 1, "Toko Sari", "toko-sari", "", "", "", "", "",
+			"", "Bandung", "ID", -6.9, nil, "Asia/Jakarta", `{"weekly":[{"day":"monday","opens":"09:00","closes":"17:00"}]}`, "active", "", time.Now(), time.Now(), 3,
+		))
+
+	s, err := repo.FindBySlug(context.Background(), "toko-sari")
+	require.NoError(t, err)
+	assert.Equal(t, "Asia/Jakarta", s.Timezone)
+	assert.Len(t, s.Hours.Weekly, 1)
+	require.NotNil(t, s.Location.Latitude)
+	assert.Nil(t, s.Location.Longitude)
+
+	m.ExpectQuery(regexp.QuoteMeta(`FROM stores WHERE slug = ?`)).WithArgs("nowhere").WillReturnRows(sqlmock.NewRows(nil))
+	_, err = repo.FindBySlug(context.Background(), "nowhere")
+	assert.True(t, errors.Is(err, exception.ErrNotFound))
+	assert.NoError(t, m.ExpectationsWereMet())
+}
@@ -0,0 +1,175 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/helpers/constant"
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/internal/store"
+	modelStore "github.com/Risuii/models/store"
+	"github.com/Risuii/tests/mock"
+)
+
+// transferRepo keeps transfers in memory and changes the owner in stores
+// when one is accepted.
+type transferRepo struct {
+	transfers map[int64]modelStore.Transfer
+	stores    *storeRepo
+	members   *memberRepo
+	trail     []modelStore.AuditEntry
+}
+
+func (r *transferRepo) Create(ctx context.Context, params modelStore.Transfer, entry modelStore.AuditEntry) error {
+	if _, ok := r.transfers[params.StoreID]; ok {
+		return exception.ErrTransferPending
+	}
+	r.transfers[params.StoreID] = params
+	r.trail = append(r.trail, entry)
+	return nil
+}
+
+func (r *transferRepo) Find(ctx context.Context, storeID int64, now time.Time) (modelStore.Transfer, error) {
+	t, ok := r.transfers[storeID]
+	if !ok {
+		return t, exception.ErrNotFound
+	}
+	return t, nil
+}
+
+func (r *transferRepo) FindByRecipient(ctx context.Context, userID int64, now time.Time) ([]modelStore.Transfer, error) {
+	transfers := []modelStore.Transfer{}
+	for _, t := range r.transfers {
+		if t.ToUserID == userID {
+			transfers = append(transfers, t)
+		}
+	}
+	return transfers, nil
+}
+
+func (r *transferRepo) Cancel(ctx context.Context, storeID int64, entry modelStore.AuditEntry) error {
+	if _, ok := r.transfers[storeID]; !ok {
+		return exception.ErrNotFound
+	}
+	delete(r.transfers, storeID)
+	r.trail = append(r.trail, entry)
+	return nil
+}
+
+func (r *transferRepo) Accept(ctx context.Context, transfer modelStore.Transfer, entry modelStore.AuditEntry) error {
+	delete(r.transfers, transfer.StoreID)
+	s := r.stores.stores[transfer.StoreID]
+	s.UserID = transfer.ToUserID
+	s.Version++
+	r.stores.stores[transfer.StoreID] = s
+	r.members.Delete(ctx, transfer.StoreID, transfer.ToUserID)
+	r.trail = append(r.trail, entry)
+	return nil
+}
+
+// forgetful records what the use case asks caches to drop.
+type forgetful struct {
+	*storeRepo
+	forgotten []int64
+}
+
+func (r *forgetful) Forget(ctx context.Context, id int64, userIDs ...int64) {
+	r.forgotten = append(r.forgotten, userIDs...)
+}
+
+func TestTransfer(t *testing.T) {
+	ctx := context.Background()
+	stores, members, accounts := newTeam()
+	mail := &outbox{}
+	transfers := &transferRepo{transfers: map[int64]modelStore.Transfer{}, stores: stores, members: members}
+	repo := &forgetful{storeRepo: stores}
+	usecase := store.NewStoreUseCaseImpl(repo, accounts, nil, members, nil, store.NewTransfers(transfers, mail, "https://shop.example.com", 72*time.Hour), nil, nil, uploads)
+
+	res := usecase.StartTransfer(ctx, 7, 2, modelStore.TransferCreate{Email: "eka@example.com"})
+	assert.True(t, errors.Is(res.Err(), exception.ErrForbidden), "only the owner gives the store away")
+
+	for _, email := range []string{"nobody@example.com", "fajar@example.com", "sari@example.com"} {
+		res = usecase.StartTransfer(ctx, 7, 1, modelStore.TransferCreate{Email: email})
+		assert.True(t, errors.Is(res.Err(), exception.ErrTransferRecipient), email)
+	}
+
+	require.NoError(t, usecase.StartTransfer(ctx, 7, 1, modelStore.TransferCreate{Email: "budi@example.com"}).Err())
+	require.Len(t, mail.sent, 2, "both parties are told")
+	assert.Equal(t, "budi@example.com", mail.sent[0].To)
+	assert.Equal(t, "sari@example.com", mail.sent[1].To)
+
+	res = usecase.StartTransfer(ctx, 7, 1, modelStore.TransferCreate{Email: "eka@example.com"})
+	assert.True(t, errors.Is(res.Err(), exception.ErrTransferPending))
+
+	assert.True(t, errors.Is(usecase.AcceptTransfer(ctx, 7, 4).Err(), exception.ErrNotFound), "only the recipient accepts")
+	assert.Equal(t, int64(1), stores.stores[7].UserID, "the owner stays until the recipient accepts")
+
+	require.NoError(t, usecase.AcceptTransfer(ctx, 7, 2).Err())
+	assert.Equal(t, int64(2), stores.stores[7].UserID)
+	assert.Equal(t, []int64{1, 2}, repo.forgotten)
+	require.Len(t, mail.sent, 4)
+	assert.Equal(t, "sari@example.com", mail.sent[2].To)
+	assert.Equal(t, "budi@example.com", mail.sent[3].To)
+
+	_, err := members.Find(ctx, 7, 2)
+	assert.True(t, errors.Is(err, exception.ErrNotFound), "the new owner is no longer a manager")
+
+	res = usecase.UpdateStore(ctx, 7, 2, 1, modelStore.StoreUpdate{})
+	assert.True(t, errors.Is(res.Err(), exception.ErrNotFound), "the previous owner lost access")
+
+	require.Len(t, transfers.trail, 2)
+	assert.Equal(t, modelStore.AuditTransferStarted, transfers.trail[0].Action)
+	assert.Equal(t, int64(1), transfers.trail[0].ActorID)
+	assert.Equal(t, modelStore.AuditTransferAccepted, transfers.trail[1].Action)
+	assert.Equal(t, int64(2), transfers.trail[1].ActorID)
+}
+
+func TestDeclineTransfer(t *testing.T) {
+	ctx := context.Background()
+	stores, members, accounts := newTeam()
+	mail := &outbox{}
+	transfers := &transferRepo{transfers: map[int64]modelStore.Transfer{}, stores: stores, members: members}
+	usecase := store.NewStoreUseCaseImpl(stores, accounts, nil, members, nil, store.NewTransfers(transfers, mail, "https://shop.example.com", 72*time.Hour), nil, nil, uploads)
+
+	require.NoError(t, usecase.StartTransfer(ctx, 7, 1, modelStore.TransferCreate{Email: "eka@example.com"}).Err())
+	assert.Len(t, data(usecase.IncomingTransfers(ctx, 4)), 1)
+
+	require.NoError(t, usecase.DeclineTransfer(ctx, 7, 4).Err())
+	assert.Equal(t, "sari@example.com", mail.sent[len(mail.sent)-1].To, "the owner hears about it")
+	assert.Equal(t, int64(1), stores.stores[7].UserID)
+	assert.Equal(t, modelStore.AuditTransferDeclined, transfers.trail[len(transfers.trail)-1].Action)
+
+	assert.True(t, errors.Is(usecase.CancelTransfer(ctx, 7, 1).Err(), exception.ErrNotFound))
+}
+
+func TestAcceptTransferRepository(t *testing.T) {
+	db, m := mock.NewMock()
+	defer db.Close()
+	repo := store.NewTransferRepositoryImpl(db, constant.TableTransfers, constant.TableStores, constant.TableStoreMembers, constant.TableStoreAudit)
+	transfer := modelStore.Transfer{StoreID: 7, FromUserID: 1, ToUserID: 2}
+	entry := modelStore.AuditEntry{StoreID: 7, ActorID: 2, Action: modelStore.AuditTransferAccepted, Details: map[string]interface{}{"from_user_id": 1}, CreatedAt: time.Now()}
+
+	m.ExpectBegin()
+	m.ExpectExec(regexp.QuoteMeta(`DELETE FROM store_transfers WHERE storeID = ? AND to_user = ? AND expires_at > ?`)).WithArgs(7, 2, entry.CreatedAt).WillReturnResult(sqlmock.NewResult(0, 1))
+	m.ExpectExec(regexp.QuoteMeta(`UPDATE stores SET userID = ?, update_at = ?, version = version + 1 WHERE id = ? AND userID = ?`)).WithArgs(2, entry.CreatedAt, 7, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	m.ExpectExec(regexp.QuoteMeta(`DELETE FROM store_members WHERE storeID = ? AND userID = ?`)).WithArgs(7, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	m.ExpectExec(regexp.QuoteMeta(`INSERT INTO store_audit_log (storeID, actorID, action, details, created_at)`)).WithArgs(7, 2, modelStore.AuditTransferAccepted, []byte(`{"from_user_id":1}`), entry.CreatedAt).WillReturnResult(sqlmock.NewResult(1, 1))
+	m.ExpectCommit()
+	require.NoError(t, repo.Accept(context.Background(), transfer, entry))
+
+	m.ExpectBegin()
+	m.ExpectExec(regexp.QuoteMeta(`DELETE FROM store_transfers`)).WillReturnResult(sqlmock.NewResult(0, 1))
+	m.ExpectExec(regexp.QuoteMeta(`UPDATE stores`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	m.ExpectRollback()
+	err := repo.Accept(context.Background(), transfer, entry)
+	assert.True(t, errors.Is(err, exception.ErrConflicted), "the owner must still be the one who offered the store")
+
+	assert.NoError(t, m.ExpectationsWereMet())
+}
@@ -0,0 +1,53 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Risuii/helpers/exception"
+	"github.com/Risuii/helpers/response"
+	"github.com/Risuii/helpers/tracing"
+	"github.com/Risuii/internal/item"
+)
+
+type stubItemUseCase struct {
+	item.ItemUseCase
+}
+
+func (stubItemUseCase) GetOneItem(ctx context.Context, id int64, storeID int64) response.Response {
+	return response.Error(response.StatusNotFound, exception.ErrNotFound)
+}
+
+func TestSpansAcrossLayers(t *testing.T) {
+	exporter := tracing.NewInMemory()
+	usecase := item.NewItemUseCaseTracing(stubItemUseCase{})
+
+	router := mux.NewRouter()
+	router.Use(tracing.RouteMiddleware)
+	router.HandleFunc("/store/items/{itemID}", func(w http.ResponseWriter, r *http.Request) {
+		usecase.GetOneItem(r.Context(), 1, 1).JSON(w)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/store/items/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+
+	tracing.Middleware(router).ServeHTTP(rec, req)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+
+	usecaseSpan, serverSpan := spans[0], spans[1]
+	assert.Equal(t, "ItemUseCase.GetOneItem", usecaseSpan.Name)
+	assert.Equal(t, "GET /store/items/{itemID}", serverSpan.Name)
+	assert.Equal(t, serverSpan.SpanContext.SpanID(), usecaseSpan.Parent.SpanID())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", serverSpan.SpanContext.TraceID().String())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", rec.Header().Get(tracing.HeaderTraceID))
+	assert.Contains(t, rec.Body.String(), `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"`)
+}